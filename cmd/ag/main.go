@@ -2,24 +2,41 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
 	"os/exec"
+	"os/signal"
 	"runtime/debug"
+	"slices"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/arch-err/autogitter/internal/config"
 	"github.com/arch-err/autogitter/internal/connector"
+	"github.com/arch-err/autogitter/internal/errs"
+	"github.com/arch-err/autogitter/internal/git"
+	"github.com/arch-err/autogitter/internal/journal"
+	"github.com/arch-err/autogitter/internal/metrics"
+	"github.com/arch-err/autogitter/internal/notify"
 	"github.com/arch-err/autogitter/internal/sync"
 	"github.com/arch-err/autogitter/internal/ui"
 	"github.com/charmbracelet/huh"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 var (
-	version    = "dev"
-	configPath string
-	debugFlag  bool
+	version     = "dev"
+	configPaths []string
+	debugFlag   bool
+	traceFlag   bool
+	noPager     bool
+	outputMode  string
 )
 
 func getVersion() string {
@@ -38,6 +55,14 @@ func init() {
 	rootCmd.Version = getVersion()
 }
 
+// exitPartialFailure is the exit status of a sync or pull that completed,
+// but with failed sources or repos. Errors that stop a command exit with 1.
+const exitPartialFailure = 2
+
+// exitCredentials is the exit status of a sync or pull where some failures
+// were rejected credentials or host keys, which retrying won't fix
+const exitCredentials = 3
+
 func main() {
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
@@ -45,14 +70,73 @@ func main() {
 }
 
 var rootCmd = &cobra.Command{
-	Use:     "ag",
-	Short:   "Autogitter - Git repository synchronization tool",
-	Long:    `Autogitter (ag) is a tool to synchronize git repositories based on a configuration file.`,
-	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+	Use:   "ag",
+	Short: "Autogitter - Git repository synchronization tool",
+	Long:  `Autogitter (ag) is a tool to synchronize git repositories based on a configuration file.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyEnvFlags(cmd); err != nil {
+			return err
+		}
 		ui.SetDebug(debugFlag)
+		if traceFlag {
+			git.SetTrace(os.Stderr)
+		}
+		sync.Subscribe(renderEvent)
+		journal.Begin(commandLine(cmd))
+		return ui.SetOutput(outputMode)
 	},
 }
 
+// commandLine returns the command and the names of the flags it was given,
+// without their values, which may hold secrets
+func commandLine(cmd *cobra.Command) string {
+	line := cmd.CommandPath()
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		line += " --" + f.Name
+	})
+	return line
+}
+
+// renderEvent shows the engine's events that the sync and pull output
+// doesn't already cover
+func renderEvent(e sync.Event) {
+	switch e := e.(type) {
+	case sync.RepoCloneFailed:
+		ui.Error("failed to clone", "repo", e.Repo, "error", e.Err)
+	case sync.RepoPullFailed:
+		ui.Error("failed to pull", "repo", e.Repo, "error", e.Err)
+	}
+}
+
+// envFlags are the environment variables that set a flag's default, for
+// containers and CI. A flag given on the command line wins.
+var envFlags = map[string]string{
+	"config": "AG_CONFIG",
+	"jobs":   "AG_JOBS",
+	"debug":  "AG_DEBUG",
+	"trace":  "AG_TRACE",
+	"output": "AG_OUTPUT",
+}
+
+// applyEnvFlags sets the flags of cmd that weren't given from their
+// environment variables
+func applyEnvFlags(cmd *cobra.Command) error {
+	for name, envVar := range envFlags {
+		value, ok := os.LookupEnv(envVar)
+		if !ok || value == "" {
+			continue
+		}
+		flag := cmd.Flags().Lookup(name)
+		if flag == nil || flag.Changed {
+			continue
+		}
+		if err := cmd.Flags().Set(name, value); err != nil {
+			return fmt.Errorf("invalid %s: %w", envVar, err)
+		}
+	}
+	return nil
+}
+
 var syncCmd = &cobra.Command{
 	Use:   "sync",
 	Short: "Sync repositories according to config",
@@ -60,6 +144,13 @@ var syncCmd = &cobra.Command{
 	RunE:  runSync,
 }
 
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Move orphaned repos to the trash",
+	Long:  `Prune moves local repos that are no longer in the config, or no longer picked up by an all or regex source, to the trash. It asks for confirmation like sync --prune, but clones nothing, so scheduled syncs can leave pruning to a deliberate manual run. Sources whose repos can't be listed are skipped.`,
+	RunE:  runPrune,
+}
+
 var configCmd = &cobra.Command{
 	Use:   "config",
 	Short: "Edit configuration file",
@@ -88,41 +179,333 @@ var diffCmd = &cobra.Command{
 	RunE:  runDiff,
 }
 
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run sync periodically and expose metrics",
+	Long:  `Serve runs sync (and optionally pull) on a fixed interval without prompting, and exposes Prometheus metrics on /metrics.`,
+	RunE:  runServe,
+}
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Back up local repos as git bundles",
+	Long:  `Backup creates or updates incremental git bundles for every local repo, plus a manifest with commit SHAs.`,
+	RunE:  runBackup,
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "Restore repos from git bundles",
+	Long:  `Restore re-creates clones from a backup directory written by ag backup. Existing paths are left untouched.`,
+	RunE:  runRestore,
+}
+
+var undoCmd = &cobra.Command{
+	Use:   "undo",
+	Short: "Undo the last operation",
+	Long:  `Undo reverses the last operation recorded in the journal: clones are moved to the trash, pruned repos are restored from the trash, and config edits are reverted.`,
+	RunE:  runUndo,
+}
+
+var trashCmd = &cobra.Command{
+	Use:   "trash",
+	Short: "List and restore pruned repos",
+	Long:  `Trash manages the clones that pruning moved to the trash. They are deleted for good by the first sync after trash_days (default 30) have passed.`,
+}
+
+var trashListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the repos in the trash",
+	Long:  `List prints the clones in the trash, newest first, with when they were moved there and the path they came from.`,
+	RunE:  runTrashList,
+}
+
+var trashRestoreCmd = &cobra.Command{
+	Use:   "restore <repo>",
+	Short: "Move a repo out of the trash",
+	Long:  `Restore moves a clone out of the trash back to the path it was pruned from, or to --to. The repo is matched by name or original path; when it was trashed several times, the newest copy is restored.`,
+	Args:  cobra.ExactArgs(1),
+	RunE:  runTrashRestore,
+}
+
+var lockCmd = &cobra.Command{
+	Use:   "lock",
+	Short: "Record the commit of every repo in a lock file",
+	Long:  `Lock writes the HEAD commit of every local repo to autogitter.lock next to the config, so the same checkouts can be restored elsewhere with ag sync --frozen or ag checkout --locked.`,
+	RunE:  runLock,
+}
+
+var checkoutCmd = &cobra.Command{
+	Use:   "checkout --locked",
+	Short: "Check out the commits recorded in the lock file",
+	Long:  `Checkout --locked checks out the commit recorded by ag lock in every local repo, fetching from origin when needed. HEAD is detached at the locked commit; repos with uncommitted changes are skipped.`,
+	RunE:  runCheckout,
+}
+
+var auditCmd = &cobra.Command{
+	Use:   "audit --verify-signatures",
+	Short: "Check that recent commits and tags are signed by allowed keys",
+	Long:  `Audit --verify-signatures checks recent commits on the default branch and recent tags of every repo in sources with a signatures section, and reports ones that are unsigned, fail to verify, or are signed by a key not in allowed_keys. Exits with status 1 when any are found.`,
+	RunE:  runAudit,
+}
+
+var moveCmd = &cobra.Command{
+	Use:   "move <repo>",
+	Short: "Move a local repo to another source",
+	Long:  `Move relocates a local clone, given by name or alias, to another source's local_path, rewrites its origin remote for the new host, and updates the config.`,
+	Args:  cobra.ExactArgs(1),
+	RunE:  runMove,
+}
+
+var fixBranchesCmd = &cobra.Command{
+	Use:   "fix-branches",
+	Short: "Switch clones to a renamed upstream default branch",
+	Long:  `Fix-branches finds clones still on a branch that was removed upstream because the repo's default branch changed (e.g. master to main), and switches them to the new default branch.`,
+	RunE:  runFixBranches,
+}
+
+var syncForksCmd = &cobra.Command{
+	Use:   "sync-forks",
+	Short: "Fast-forward forks from their upstream remote",
+	Long:  `Sync-forks fetches the "upstream" remote of every clone that has one, fast-forwards the default branch to upstream's branch of the same name, and pushes it to origin. Branches with their own commits are left alone.`,
+	RunE:  runSyncForks,
+}
+
+var maintenanceCmd = &cobra.Command{
+	Use:   "maintenance",
+	Short: "Run git maintenance on all local repos",
+	Long:  `Maintenance runs git maintenance (or git gc --auto) across all local repos in parallel and reports the disk space reclaimed.`,
+	RunE:  runMaintenance,
+}
+
+var metaCmd = &cobra.Command{
+	Use:   "meta",
+	Short: "Manage repo metadata files",
+	Long:  `Meta manages the .ag-meta.yaml files that sources with metadata: true keep in each clone.`,
+}
+
+var metaRefreshCmd = &cobra.Command{
+	Use:   "refresh",
+	Short: "Rewrite the metadata file of every clone",
+	Long:  `Refresh looks up every local repo of the sources with metadata: true on its provider and rewrites its .ag-meta.yaml.`,
+	RunE:  runMetaRefresh,
+}
+
+var staleCmd = &cobra.Command{
+	Use:   "stale",
+	Short: "List repos with no recent activity",
+	Long:  `Stale lists repos whose last local commit (or last fetch) is older than a threshold, per source, to help find candidates for pruning or archiving.`,
+	RunE:  runStale,
+}
+
+var newsCmd = &cobra.Command{
+	Use:   "news",
+	Short: "List repos created or removed upstream since the last sync",
+	Long:  `News compares the repos each all or regex source picks up on the provider with those seen by the last sync, and lists the ones created (+) and removed (-) since. Nothing is cloned or pruned.`,
+	RunE:  runNews,
+}
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "List past syncs, pulls, and prunes",
+	Long:  `History lists the syncs, pulls, and prunes recorded in history.jsonl, newest first, with their command, counts, and what they did to each repo: clones, prunes, additions to the config, pulls that brought in commits, and failures.`,
+	RunE:  runHistory,
+}
+
+var addCmd = &cobra.Command{
+	Use:   "add [repo...]",
+	Short: "Add repos to a manual source",
+	Long:  `Add appends repos to a manual-strategy source in the config. With --browse, the source's repos are fetched from the provider API and picked from a searchable list.`,
+	RunE:  runAdd,
+}
+
+var createCmd = &cobra.Command{
+	Use:   "create <source>/<repo>",
+	Short: "Create a repo on the provider and clone it",
+	Long:  `Create makes a new repo through the source's provider API, adds it to the config if the source is manual, and clones it. The source is given by name or by its host/owner, e.g. "github.com/me/new-repo".`,
+	Args:  cobra.ExactArgs(1),
+	RunE:  runCreate,
+}
+
+var forkCmd = &cobra.Command{
+	Use:   "fork <owner/repo>",
+	Short: "Fork a repo into a source and clone it",
+	Long:  `Fork forks a repo through the source's provider API into the source's user or org, adds the fork to the config if the source is manual, clones it, and adds the original repo as the "upstream" remote.`,
+	Args:  cobra.ExactArgs(1),
+	RunE:  runFork,
+}
+
+var findCmd = &cobra.Command{
+	Use:     "find <query>",
+	Aliases: []string{"cd"},
+	Short:   "Print the local path of a repo matching a fuzzy query",
+	Long:    `Find fuzzy-matches the query against all local repos of all sources and prints its path, for use in shell functions like cd "$(ag find foo)". A query that is a repo's alias picks that repo. When several repos match and a terminal is attached, a picker sorted by best match is shown on stderr; otherwise the best match is printed.`,
+	Args:    cobra.MaximumNArgs(1),
+	RunE:    runFind,
+}
+
+var applyCmd = &cobra.Command{
+	Use:   "apply <patch>",
+	Short: "Apply a config patch written by ag diff",
+	Long:  `Apply adds and removes repos in manual sources as listed in a patch file written by ag diff --output-file.`,
+	Args:  cobra.ExactArgs(1),
+	RunE:  runApply,
+}
+
 var (
 	syncPrune      bool
 	syncAdd        bool
 	syncForce      bool
 	syncJobs       int
 	syncDryRun     bool
+	syncFixRemotes bool
+	syncFixConfig  bool
+	syncRenames    bool
+	syncInteract   bool
+	syncFrozen     bool
+	syncLarge      bool
+	syncRetry      bool
+	syncCloneOnly  bool
+	syncPruneOnly  bool
+	syncPull       bool
+	syncPullLog    bool
+	syncTimings    int
+	lockFile       string
+	checkoutLocked bool
+	checkoutDryRun bool
+	auditVerifySig bool
 	pullForce      bool
 	pullJobs       int
+	pullUpstream   bool
+	pullPruneRefs  bool
+	pullRetry      bool
+	pullRepair     bool
+	pullLog        bool
 	configValidate bool
+	configRemote   bool
 	configGenerate bool
 	connectType    string
 	connectHost    string
 	connectToken   string
 	connectList    bool
+	connectNoClip  bool
+	connectTest    bool
+	connectRemove  bool
+	connectRotate  bool
+	connectEncrypt bool
+	serveListen    string
+	serveInterval  time.Duration
+	serveJobs      int
+	servePull      bool
+	serveIncr      bool
+	serveFullList  time.Duration
+	diffDeep       bool
+	diffRemote     bool
+	diffOutput     string
+	diffFormat     string
+	diffExitCode   bool
+	applyDryRun    bool
+	backupDest     string
+	backupMeta     bool
+	restoreFrom    string
+	undoDryRun     bool
+	undoForce      bool
+	trashTo        string
+	moveToSource   string
+	moveDryRun     bool
+	fixForce       bool
+	fixDryRun      bool
+	maintJobs      int
+	maintAuto      bool
+	maintRegister  bool
+	staleThan      string
+	staleBy        string
+	histRepo       string
+	histSince      string
+	histLimit      int
+	histJSON       bool
+	addSource      string
+	addBrowse      bool
+	addDryRun      bool
+	findAll        bool
+	createPrivate  bool
+	createDryRun   bool
+	forkSource     string
+	forkDryRun     bool
+	syncForksDry   bool
+	pruneSource    string
+	pruneDryRun    bool
+	pruneForce     bool
+	pruneInteract  bool
 )
 
 func init() {
-	rootCmd.PersistentFlags().StringVarP(&configPath, "config", "c", "", "path to config file (default: $XDG_CONFIG_HOME/autogitter/config.yaml)")
+	rootCmd.PersistentFlags().StringArrayVarP(&configPaths, "config", "c", nil, "path to config file, repeat to merge files in order (default: $XDG_CONFIG_HOME/autogitter/config.yaml)")
 	rootCmd.PersistentFlags().BoolVar(&debugFlag, "debug", false, "enable debug logging")
+	rootCmd.PersistentFlags().BoolVar(&traceFlag, "trace", false, "log every git command with its environment overrides, directory, exit code, and duration")
+	rootCmd.PersistentFlags().StringVar(&outputMode, "output", ui.OutputText, "output style: text, or gha for GitHub Actions annotations and log groups")
+	rootCmd.PersistentFlags().BoolVar(&noPager, "no-pager", false, "don't page long output of diff, stale, news, and history")
 
 	syncCmd.Flags().BoolVarP(&syncPrune, "prune", "p", false, "prune repos not in config")
 	syncCmd.Flags().BoolVarP(&syncAdd, "add", "a", false, "add orphaned repos to config")
 	syncCmd.Flags().BoolVar(&syncForce, "force", false, "skip confirmation prompts")
 	syncCmd.Flags().IntVarP(&syncJobs, "jobs", "j", 4, "number of parallel clone workers")
 	syncCmd.Flags().BoolVarP(&syncDryRun, "dry-run", "n", false, "show what would happen without making changes")
+	syncCmd.Flags().BoolVar(&syncFixRemotes, "fix-remotes", false, "point origin at the configured URL when it differs")
+	syncCmd.Flags().BoolVar(&syncFixConfig, "fix-config", false, "set git_config values on existing clones where they differ")
+	syncCmd.Flags().BoolVar(&syncRenames, "check-renames", false, "ask the provider whether any cloned repo was renamed upstream")
+	syncCmd.Flags().BoolVarP(&syncInteract, "interactive", "i", false, "confirm each prune separately, showing last commit, dirty state, and size")
+	syncCmd.Flags().BoolVar(&syncLarge, "include-large", false, "clone repos even when they exceed max_repo_size")
+	syncCmd.Flags().BoolVar(&syncFrozen, "frozen", false, "check out the commits recorded in the lock file after syncing")
+	syncCmd.Flags().StringVar(&lockFile, "lock-file", "", "path to the lock file (default: autogitter.lock next to the config)")
+	syncCmd.Flags().BoolVar(&syncRetry, "retry-failed", false, "only retry the clones that failed in the last sync")
+	syncCmd.Flags().BoolVar(&syncCloneOnly, "clone-only", false, "clone new repos and leave orphaned ones alone, without asking")
+	syncCmd.Flags().BoolVar(&syncPruneOnly, "prune-only", false, "prune orphaned repos without cloning new ones")
+	syncCmd.Flags().BoolVar(&syncPull, "pull", false, "also pull existing repos, in the same worker pool as the clones")
+	syncCmd.Flags().BoolVarP(&syncPullLog, "log", "l", false, "with --pull, list the new commits of each updated repo")
+	syncCmd.Flags().IntVar(&syncTimings, "timings", 0, "report how long each phase took and the N slowest clones (default 5 when given without a value)")
+	syncCmd.Flags().Lookup("timings").NoOptDefVal = "5"
+	syncCmd.MarkFlagsMutuallyExclusive("interactive", "force")
+	syncCmd.MarkFlagsMutuallyExclusive("retry-failed", "prune")
+	syncCmd.MarkFlagsMutuallyExclusive("retry-failed", "add")
+	syncCmd.MarkFlagsMutuallyExclusive("clone-only", "prune-only")
+	syncCmd.MarkFlagsMutuallyExclusive("clone-only", "prune")
+	syncCmd.MarkFlagsMutuallyExclusive("clone-only", "add")
+	syncCmd.MarkFlagsMutuallyExclusive("prune-only", "add")
+	syncCmd.MarkFlagsMutuallyExclusive("prune-only", "retry-failed")
+	syncCmd.MarkFlagsMutuallyExclusive("pull", "prune-only")
+	syncCmd.MarkFlagsMutuallyExclusive("pull", "retry-failed")
 
 	rootCmd.AddCommand(syncCmd)
 
+	pruneCmd.Flags().StringVarP(&pruneSource, "source", "s", "", "only prune this source")
+	pruneCmd.Flags().BoolVarP(&pruneDryRun, "dry-run", "n", false, "show what would be pruned without changing anything")
+	pruneCmd.Flags().BoolVar(&pruneForce, "force", false, "skip confirmation prompts")
+	pruneCmd.Flags().BoolVarP(&pruneInteract, "interactive", "i", false, "confirm each prune separately, showing last commit, dirty state, and size")
+	pruneCmd.MarkFlagsMutuallyExclusive("interactive", "force")
+	rootCmd.AddCommand(pruneCmd)
+
 	pullCmd.Flags().BoolVar(&pullForce, "force", false, "skip confirmation prompts")
 	pullCmd.Flags().IntVarP(&pullJobs, "jobs", "j", 4, "number of parallel pull workers")
+	pullCmd.Flags().BoolVar(&pullUpstream, "include-upstream", false, "also fetch the upstream remote of forks")
+	pullCmd.Flags().BoolVar(&pullPruneRefs, "prune-refs", false, "drop remote-tracking branches and tags deleted on the remote")
+	pullCmd.Flags().BoolVarP(&pullLog, "log", "l", false, "list the new commits of each updated repo")
+	pullCmd.Flags().BoolVar(&pullRetry, "retry-failed", false, "only pull the repos that failed in the last pull")
+	pullCmd.Flags().BoolVar(&pullRepair, "repair", false, "re-clone repos whose pull failed because the repository is corrupted")
 	rootCmd.AddCommand(pullCmd)
 
+	diffCmd.Flags().BoolVar(&diffDeep, "deep", false, "show dirty, ahead/behind, and origin state of local repos")
+	diffCmd.Flags().BoolVar(&diffRemote, "remote", false, "compare against the repos that exist on the provider")
+	diffCmd.Flags().StringVarP(&diffOutput, "output-file", "o", "", "write the proposed config changes to a patch file for ag apply")
+	diffCmd.Flags().StringVar(&diffFormat, "format", "text", "output format: text or yaml (the patch)")
+	diffCmd.Flags().BoolVar(&diffExitCode, "exit-code", false, "exit with status 1 when repos would be cloned or are orphaned")
 	rootCmd.AddCommand(diffCmd)
 
+	applyCmd.Flags().BoolVarP(&applyDryRun, "dry-run", "n", false, "show what would change without saving the config")
+	rootCmd.AddCommand(applyCmd)
+
 	configCmd.Flags().BoolVarP(&configValidate, "validate", "v", false, "validate config file without editing")
+	configCmd.Flags().BoolVar(&configRemote, "remote", false, "with --validate, check that the repos of manual sources exist on the provider")
 	configCmd.Flags().BoolVarP(&configGenerate, "generate", "g", false, "generate default config file")
 	rootCmd.AddCommand(configCmd)
 
@@ -130,139 +513,1207 @@ func init() {
 	connectCmd.Flags().StringVarP(&connectHost, "host", "H", "", "git server host (e.g., gitea.company.com)")
 	connectCmd.Flags().StringVarP(&connectToken, "token", "T", "", "API token (skips interactive prompt)")
 	connectCmd.Flags().BoolVarP(&connectList, "list", "l", false, "list configured connections")
+	connectCmd.Flags().BoolVar(&connectNoClip, "no-clipboard", false, "don't copy the token URL to the clipboard")
+	connectCmd.Flags().BoolVar(&connectRemove, "remove", false, "delete the stored credential for --type")
+	connectCmd.Flags().BoolVar(&connectRotate, "rotate", false, "replace the stored credential for --type with a new token")
+	connectCmd.Flags().BoolVar(&connectEncrypt, "encrypt-store", false, "encrypt credentials.env with age and remove the plaintext file")
+	connectCmd.Flags().BoolVar(&connectTest, "test", false, "check that configured credentials work, optionally only for --type")
 	rootCmd.AddCommand(connectCmd)
+
+	serveCmd.Flags().StringVar(&serveListen, "listen", ":9090", "address to serve /metrics on")
+	serveCmd.Flags().DurationVar(&serveInterval, "interval", time.Hour, "time between sync runs")
+	serveCmd.Flags().IntVarP(&serveJobs, "jobs", "j", 4, "number of parallel workers")
+	serveCmd.Flags().BoolVar(&servePull, "pull", false, "pull existing repos after each sync")
+	serveCmd.Flags().BoolVar(&serveIncr, "incremental", false, "reuse the last listing of all/regex sources while their activity feed shows no new repos")
+	serveCmd.Flags().DurationVar(&serveFullList, "full-list-every", sync.DefaultFullListEvery, "list sources in full at least this often with --incremental")
+	rootCmd.AddCommand(serveCmd)
+
+	backupCmd.Flags().StringVarP(&backupDest, "dest", "d", "", "backup directory")
+	_ = backupCmd.MarkFlagRequired("dest")
+	backupCmd.Flags().BoolVar(&backupMeta, "with-metadata", false, "also export issues, pull requests, and releases from the provider")
+	rootCmd.AddCommand(backupCmd)
+
+	restoreCmd.Flags().StringVarP(&restoreFrom, "from", "f", "", "backup directory to restore from")
+	_ = restoreCmd.MarkFlagRequired("from")
+	rootCmd.AddCommand(restoreCmd)
+
+	undoCmd.Flags().BoolVarP(&undoDryRun, "dry-run", "n", false, "show what would be undone without changing anything")
+	undoCmd.Flags().BoolVar(&undoForce, "force", false, "skip the confirmation and revert config files edited since")
+	rootCmd.AddCommand(undoCmd)
+
+	trashRestoreCmd.Flags().StringVar(&trashTo, "to", "", "restore to this path instead of the original one")
+	trashCmd.AddCommand(trashListCmd, trashRestoreCmd)
+	rootCmd.AddCommand(trashCmd)
+
+	moveCmd.Flags().StringVar(&moveToSource, "to-source", "", "name of the source to move the repo to")
+	moveCmd.Flags().BoolVarP(&moveDryRun, "dry-run", "n", false, "show what would happen without making changes")
+	_ = moveCmd.MarkFlagRequired("to-source")
+	rootCmd.AddCommand(moveCmd)
+
+	fixBranchesCmd.Flags().BoolVar(&fixForce, "force", false, "skip confirmation prompts")
+	fixBranchesCmd.Flags().BoolVarP(&fixDryRun, "dry-run", "n", false, "show what would happen without making changes")
+	rootCmd.AddCommand(fixBranchesCmd)
+
+	lockCmd.Flags().StringVar(&lockFile, "lock-file", "", "path to the lock file (default: autogitter.lock next to the config)")
+	rootCmd.AddCommand(lockCmd)
+
+	checkoutCmd.Flags().BoolVar(&checkoutLocked, "locked", false, "check out the commits recorded in the lock file")
+	checkoutCmd.Flags().StringVar(&lockFile, "lock-file", "", "path to the lock file (default: autogitter.lock next to the config)")
+	checkoutCmd.Flags().BoolVarP(&checkoutDryRun, "dry-run", "n", false, "show what would be checked out without changing anything")
+	_ = checkoutCmd.MarkFlagRequired("locked")
+	rootCmd.AddCommand(checkoutCmd)
+
+	auditCmd.Flags().BoolVar(&auditVerifySig, "verify-signatures", false, "verify commit and tag signatures against each source's allowed keys")
+	_ = auditCmd.MarkFlagRequired("verify-signatures")
+	rootCmd.AddCommand(auditCmd)
+
+	syncForksCmd.Flags().BoolVarP(&syncForksDry, "dry-run", "n", false, "show what would be fast-forwarded without changing anything")
+	rootCmd.AddCommand(syncForksCmd)
+
+	maintenanceCmd.Flags().IntVarP(&maintJobs, "jobs", "j", 4, "number of parallel maintenance workers")
+	maintenanceCmd.Flags().BoolVar(&maintAuto, "auto", false, "run git gc --auto, which only compacts repos that need it")
+	maintenanceCmd.Flags().BoolVar(&maintRegister, "register", false, "register repos for scheduled background maintenance")
+	rootCmd.AddCommand(maintenanceCmd)
+
+	metaCmd.AddCommand(metaRefreshCmd)
+	rootCmd.AddCommand(metaCmd)
+
+	staleCmd.Flags().StringVar(&staleThan, "than", "90d", "age threshold, e.g. 90d, 2w, or 36h")
+	staleCmd.Flags().StringVar(&staleBy, "by", sync.StaleByCommit, "measure activity by last \"commit\" or last \"fetch\"")
+	rootCmd.AddCommand(staleCmd)
+
+	rootCmd.AddCommand(newsCmd)
+
+	historyCmd.Flags().StringVar(&histRepo, "repo", "", "only runs that touched repos whose name or path contains this")
+	historyCmd.Flags().StringVar(&histSince, "since", "", "only runs in this period, e.g. 30d, 2w, or 36h")
+	historyCmd.Flags().IntVar(&histLimit, "limit", 20, "show at most this many runs, 0 for all")
+	historyCmd.Flags().BoolVar(&histJSON, "json", false, "print the runs as JSON lines")
+	rootCmd.AddCommand(historyCmd)
+
+	addCmd.Flags().StringVarP(&addSource, "source", "s", "", "name of the manual source (default: the only manual source)")
+	addCmd.Flags().BoolVarP(&addBrowse, "browse", "b", false, "pick repos from the provider's repo list")
+	addCmd.Flags().BoolVarP(&addDryRun, "dry-run", "n", false, "show what would be added without saving the config")
+	rootCmd.AddCommand(addCmd)
+
+	createCmd.Flags().BoolVar(&createPrivate, "private", false, "make the new repo private")
+	createCmd.Flags().BoolVarP(&createDryRun, "dry-run", "n", false, "show what would be created without creating it")
+	rootCmd.AddCommand(createCmd)
+
+	forkCmd.Flags().StringVarP(&forkSource, "source", "s", "", "source to fork into, by name or host/owner (default: the only source)")
+	forkCmd.Flags().BoolVarP(&forkDryRun, "dry-run", "n", false, "show what would be forked without forking it")
+	rootCmd.AddCommand(forkCmd)
+
+	findCmd.Flags().BoolVarP(&findAll, "all", "a", false, "print every matching path, best first")
+	rootCmd.AddCommand(findCmd)
 }
 
-func loadConfig() (*config.Config, string, error) {
-	path := configPath
-	if path == "" {
-		path = config.DefaultConfigPath()
+// configFiles returns the --config files in order, or the default path
+func configFiles() []string {
+	if len(configPaths) == 0 {
+		return []string{config.DefaultConfigPath()}
 	}
+	return configPaths
+}
+
+func loadConfig() (*config.Config, string, error) {
+	// With several files, the first is the main config
+	path := configFiles()[0]
 
-	cfg, err := config.Load(path)
+	cfg, err := config.LoadFiles(configFiles())
 	if err != nil {
 		return nil, path, err
 	}
+	printConfigWarnings(cfg)
 
 	return cfg, path, nil
 }
 
-func runSync(cmd *cobra.Command, args []string) error {
+// printConfigWarnings logs config problems that don't stop a command
+func printConfigWarnings(cfg *config.Config) {
+	for _, warning := range cfg.Warnings() {
+		ui.Warn(warning)
+	}
+}
+
+func runSync(cmd *cobra.Command, args []string) error {
+	loadStart := time.Now()
+	cfg, cfgPath, err := loadConfig()
+	if err != nil {
+		ui.Error("failed to load config", "error", err)
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	loadTime := time.Since(loadStart)
+
+	ui.Info("loaded config", "path", cfgPath, "sources", len(cfg.Sources))
+
+	opts := sync.SyncOptions{
+		Prune:            syncPrune,
+		Add:              syncAdd,
+		Force:            syncForce,
+		ConfigPath:       cfgPath,
+		Jobs:             syncJobs,
+		DryRun:           syncDryRun,
+		FixRemotes:       syncFixRemotes,
+		FixConfig:        syncFixConfig,
+		IncludeLarge:     syncLarge,
+		CheckRenames:     syncRenames,
+		InteractivePrune: syncInteract,
+		RetryFailed:      syncRetry,
+		CloneOnly:        syncCloneOnly,
+		PruneOnly:        syncPruneOnly,
+		Pull:             syncPull,
+	}
+
+	result, err := sync.Run(cmd.Context(), cfg, opts)
+	if err != nil {
+		return err
+	}
+
+	attention := summaryAttention(result.Failures)
+	ui.PrintSummary(syncSummaryTable(result, syncPull && !syncDryRun), summaryFailures(result.Failures))
+	ui.PrintAttention(attention)
+	ui.PrintPullDigest(result.Digests, syncPullLog)
+	if syncTimings > 0 {
+		printSyncTimings(result.Timings, loadTime, result.Duration, syncTimings)
+	}
+
+	if !syncDryRun {
+		sendNotification(cfg, notify.Summary{
+			Command:   "sync",
+			Cloned:    result.Cloned,
+			Pruned:    result.Pruned,
+			Added:     result.Added,
+			Updated:   result.Pulled,
+			Failed:    result.Failed,
+			Attention: notifyAttention(attention),
+		})
+	}
+
+	if syncFrozen {
+		if err := checkoutLockedRepos(cfg, cfgPath, syncDryRun); err != nil {
+			return err
+		}
+	}
+
+	exitIfFailed(result.Failures)
+	return nil
+}
+
+func runPrune(cmd *cobra.Command, args []string) error {
+	cfg, cfgPath, err := loadConfig()
+	if err != nil {
+		ui.Error("failed to load config", "error", err)
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	pruned, err := sync.RunPrune(cfg, sync.PruneOptions{
+		Source:      pruneSource,
+		ConfigPath:  cfgPath,
+		DryRun:      pruneDryRun,
+		Force:       pruneForce,
+		Interactive: pruneInteract,
+	})
+	if pruned > 0 {
+		ui.Info("pruned repos", "count", pruned, "trash", journal.TrashPath())
+	}
+	return err
+}
+
+// syncSummaryTable has a row per synced source, with an Added column
+// only when repos were added to the config, and Pulled and Updated columns
+// when existing repos were pulled
+func syncSummaryTable(result *sync.SyncResult, pulled bool) ui.SummaryTable {
+	t := ui.SummaryTable{Columns: []string{"Cloned", "Pruned", "Skipped", "Failed"}, Total: result.Duration}
+	if result.Added > 0 {
+		t.Columns = append(t.Columns, "Added")
+	}
+	if pulled {
+		t.Columns = append(t.Columns, "Pulled", "Updated")
+	}
+	for _, s := range result.Sources {
+		counts := []int{s.Cloned, s.Pruned, s.Skipped, s.Failed}
+		if result.Added > 0 {
+			counts = append(counts, s.Added)
+		}
+		if pulled {
+			counts = append(counts, s.Pulled, s.Updated)
+		}
+		t.Rows = append(t.Rows, ui.SummaryRow{Name: s.Name, Counts: counts, Duration: s.Duration})
+	}
+	return t
+}
+
+// printSyncTimings prints the time of each sync phase, starting with
+// loading the config, and the slowest clones
+func printSyncTimings(t sync.Timings, loadTime, runTime time.Duration, slowest int) {
+	phases := []ui.Timing{{Name: "config load", Duration: loadTime}}
+	for _, p := range t.Phases {
+		phases = append(phases, ui.Timing{Name: p.Name, Duration: p.Duration})
+	}
+
+	var clones []ui.Timing
+	for _, c := range t.Clones[:min(slowest, len(t.Clones))] {
+		clones = append(clones, ui.Timing{Name: c.Repo, Duration: c.Duration})
+	}
+
+	ui.PrintTimings(phases, loadTime+runTime, clones)
+}
+
+// pullSummaryTable has a row per pulled source, with a Skipped column only
+// when repos were skipped
+func pullSummaryTable(result *sync.PullResult) ui.SummaryTable {
+	t := ui.SummaryTable{Columns: []string{"Pulled", "Updated", "Failed"}, Total: result.Duration}
+	if result.Skipped > 0 {
+		t.Columns = append(t.Columns, "Skipped")
+	}
+	for _, s := range result.Sources {
+		counts := []int{s.Pulled, s.Updated, s.Failed}
+		if result.Skipped > 0 {
+			counts = append(counts, s.Skipped)
+		}
+		t.Rows = append(t.Rows, ui.SummaryRow{
+			Name:     s.Name,
+			Counts:   counts,
+			Duration: s.Duration,
+		})
+	}
+	return t
+}
+
+// summaryFailures lists failed sources and repos for the sync summary,
+// with only the first line of multi-line git errors. Conflicts are listed
+// by summaryAttention instead.
+func summaryFailures(failures []sync.SyncFailure) []ui.SummaryFailure {
+	var summary []ui.SummaryFailure
+	for _, f := range failures {
+		if errs.Kind(f.Err) == errs.KindConflict {
+			continue
+		}
+		name := "source " + f.Source
+		if f.Repo != "" {
+			name = f.Repo
+		}
+		msg, _, _ := strings.Cut(f.Err.Error(), "\n")
+		summary = append(summary, ui.SummaryFailure{Name: name, Error: msg})
+	}
+	return summary
+}
+
+// summaryAttention lists the repos whose pull left conflicts, for the
+// needs attention section below the summary
+func summaryAttention(failures []sync.SyncFailure) []ui.Attention {
+	var attention []ui.Attention
+	for _, f := range failures {
+		var conflict *errs.ConflictError
+		if errors.As(f.Err, &conflict) {
+			attention = append(attention, ui.Attention{Name: f.Repo, Path: f.Path, Files: conflict.Files, Stashed: conflict.Stashed})
+		}
+	}
+	return attention
+}
+
+// notifyAttention converts the needs attention section for notifications
+func notifyAttention(attention []ui.Attention) []notify.Attention {
+	var items []notify.Attention
+	for _, a := range attention {
+		items = append(items, notify.Attention{Repo: a.Name, Path: a.Path, Files: a.Files, Stashed: a.Stashed})
+	}
+	return items
+}
+
+// exitIfFailed exits with exitPartialFailure when some sources or repos
+// failed, so scripts and schedulers can tell a partial failure from success,
+// or with exitCredentials when any failed on credentials
+func exitIfFailed(failures []sync.SyncFailure) {
+	for _, f := range failures {
+		if errs.IsCredentials(f.Err) {
+			os.Exit(exitCredentials)
+		}
+	}
+	if len(failures) > 0 {
+		os.Exit(exitPartialFailure)
+	}
+}
+
+func runPull(cmd *cobra.Command, args []string) error {
+	cfg, cfgPath, err := loadConfig()
+	if err != nil {
+		ui.Error("failed to load config", "error", err)
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	ui.Info("loaded config", "path", cfgPath, "sources", len(cfg.Sources))
+
+	opts := sync.PullOptions{
+		Force:           pullForce,
+		Jobs:            pullJobs,
+		IncludeUpstream: pullUpstream,
+		PruneRefs:       pullPruneRefs,
+		RetryFailed:     pullRetry,
+		Repair:          pullRepair,
+		ConfigPath:      cfgPath,
+	}
+
+	result, err := sync.RunPull(cmd.Context(), cfg, opts)
+	if err != nil {
+		return err
+	}
+
+	attention := summaryAttention(result.Failures)
+	ui.PrintSummary(pullSummaryTable(result), nil)
+	ui.PrintAttention(attention)
+	ui.PrintPullDigest(result.Digests, pullLog)
+
+	sendNotification(cfg, notify.Summary{
+		Command:   "pull",
+		Updated:   result.Updated,
+		Failed:    result.Failed,
+		Attention: notifyAttention(attention),
+	})
+
+	exitIfFailed(result.Failures)
+	return nil
+}
+
+// sendNotification posts a run summary to the configured webhook, if any
+func sendNotification(cfg *config.Config, summary notify.Summary) {
+	if err := notify.Send(cfg.Notifications, summary); err != nil {
+		ui.Warn("failed to send notification", "error", err)
+	}
+}
+
+func runBackup(cmd *cobra.Command, args []string) error {
+	cfg, cfgPath, err := loadConfig()
+	if err != nil {
+		ui.Error("failed to load config", "error", err)
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	ui.Info("loaded config", "path", cfgPath, "sources", len(cfg.Sources))
+
+	result, err := sync.RunBackup(cfg, sync.BackupOptions{Dest: backupDest, WithMetadata: backupMeta})
+	if err != nil {
+		return err
+	}
+
+	if backupMeta {
+		ui.Info("backup complete", "created", result.Created, "unchanged", result.Unchanged, "exported", result.Exported, "failed", result.Failed)
+	} else {
+		ui.Info("backup complete", "created", result.Created, "unchanged", result.Unchanged, "failed", result.Failed)
+	}
+
+	return nil
+}
+
+func runRestore(cmd *cobra.Command, args []string) error {
+	result, err := sync.RunRestore(sync.RestoreOptions{From: restoreFrom})
+	if err != nil {
+		return err
+	}
+
+	ui.Info("restore complete", "restored", result.Restored, "skipped", result.Skipped, "failed", result.Failed)
+
+	return nil
+}
+
+func runUndo(cmd *cobra.Command, args []string) error {
+	op, err := journal.Last()
+	if err != nil {
+		return err
+	}
+	if op == nil {
+		ui.Info("nothing to undo", "journal", journal.Path())
+		return nil
+	}
+
+	what := fmt.Sprintf("%q from %s", op.Command, op.Time.Local().Format("2006-01-02 15:04"))
+	if op.Command == "" {
+		what = "the operation from " + op.Time.Local().Format("2006-01-02 15:04")
+	}
+	steps := make([]string, len(op.Entries))
+	for i, e := range op.Entries {
+		steps[len(op.Entries)-1-i] = e.Describe()
+	}
+
+	if undoDryRun {
+		ui.Info("would undo " + what)
+		for _, step := range steps {
+			ui.Info("would " + step)
+		}
+		return nil
+	}
+
+	if !undoForce {
+		confirm, err := ui.ConfirmUndo(what, steps)
+		if err != nil {
+			return fmt.Errorf("failed to get confirmation: %w", err)
+		}
+		if !confirm {
+			ui.Info("undo cancelled")
+			return nil
+		}
+	}
+
+	return op.Undo(undoForce)
+}
+
+func runFixBranches(cmd *cobra.Command, args []string) error {
+	cfg, cfgPath, err := loadConfig()
+	if err != nil {
+		ui.Error("failed to load config", "error", err)
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	ui.Info("loaded config", "path", cfgPath, "sources", len(cfg.Sources))
+
+	result, err := sync.RunFixBranches(cfg, sync.FixBranchesOptions{
+		Force:  fixForce,
+		DryRun: fixDryRun,
+	})
+	if err != nil {
+		return err
+	}
+
+	ui.Info("fix-branches complete", "switched", result.Switched, "skipped", result.Skipped, "failed", result.Failed)
+
+	return nil
+}
+
+func runLock(cmd *cobra.Command, args []string) error {
+	cfg, cfgPath, err := loadConfig()
+	if err != nil {
+		ui.Error("failed to load config", "error", err)
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	path := lockFile
+	if path == "" {
+		path = sync.DefaultLockPath(cfgPath)
+	}
+
+	result, err := sync.RunLock(cfg, sync.LockOptions{Path: path})
+	if err != nil {
+		return err
+	}
+
+	ui.Info("lock file written", "path", path, "repos", result.Locked, "failed", result.Failed)
+
+	return nil
+}
+
+func runCheckout(cmd *cobra.Command, args []string) error {
+	cfg, cfgPath, err := loadConfig()
+	if err != nil {
+		ui.Error("failed to load config", "error", err)
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	return checkoutLockedRepos(cfg, cfgPath, checkoutDryRun)
+}
+
+// checkoutLockedRepos checks out the lock file's commits, for ag checkout
+// --locked and ag sync --frozen
+func checkoutLockedRepos(cfg *config.Config, cfgPath string, dryRun bool) error {
+	path := lockFile
+	if path == "" {
+		path = sync.DefaultLockPath(cfgPath)
+	}
+
+	result, err := sync.RunCheckoutLocked(cfg, sync.CheckoutLockedOptions{Path: path, DryRun: dryRun})
+	if err != nil {
+		return err
+	}
+
+	ui.Info("checkout complete", "checked_out", result.CheckedOut, "unchanged", result.Unchanged, "missing", result.Missing, "failed", result.Failed)
+	if result.Failed > 0 || result.Missing > 0 {
+		return fmt.Errorf("%d repos could not be checked out at their locked commit", result.Failed+result.Missing)
+	}
+
+	return nil
+}
+
+func runAudit(cmd *cobra.Command, args []string) error {
+	cfg, cfgPath, err := loadConfig()
+	if err != nil {
+		ui.Error("failed to load config", "error", err)
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	ui.Info("loaded config", "path", cfgPath, "sources", len(cfg.Sources))
+
+	result, err := sync.RunAudit(cfg, sync.AuditOptions{VerifySignatures: auditVerifySig})
+	if err != nil {
+		return err
+	}
+
+	ui.Info("audit complete", "repos", result.Repos, "violations", result.Violations)
+	if result.Violations > 0 {
+		return fmt.Errorf("%d signature violations found", result.Violations)
+	}
+
+	return nil
+}
+
+func runSyncForks(cmd *cobra.Command, args []string) error {
+	cfg, cfgPath, err := loadConfig()
+	if err != nil {
+		ui.Error("failed to load config", "error", err)
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	ui.Info("loaded config", "path", cfgPath, "sources", len(cfg.Sources))
+
+	result, err := sync.RunSyncForks(cfg, sync.SyncForksOptions{DryRun: syncForksDry})
+	if err != nil {
+		return err
+	}
+
+	ui.Info("sync-forks complete", "updated", result.Updated, "up_to_date", result.UpToDate, "failed", result.Failed)
+
+	return nil
+}
+
+func runMaintenance(cmd *cobra.Command, args []string) error {
+	cfg, cfgPath, err := loadConfig()
+	if err != nil {
+		ui.Error("failed to load config", "error", err)
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	ui.Info("loaded config", "path", cfgPath, "sources", len(cfg.Sources))
+
+	result, err := sync.RunMaintenance(cfg, sync.MaintenanceOptions{
+		Jobs:     maintJobs,
+		Auto:     maintAuto,
+		Register: maintRegister,
+	})
+	if err != nil {
+		return err
+	}
+
+	ui.Info("maintenance complete", "repos", result.Maintained, "failed", result.Failed, "reclaimed", ui.FormatBytes(result.Reclaimed))
+	if maintRegister {
+		ui.Info("registered for background maintenance", "repos", result.Registered)
+	}
+
+	return nil
+}
+
+func runMetaRefresh(cmd *cobra.Command, args []string) error {
+	cfg, cfgPath, err := loadConfig()
+	if err != nil {
+		ui.Error("failed to load config", "error", err)
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	ui.Debug("loaded config", "path", cfgPath, "sources", len(cfg.Sources))
+
+	result, err := sync.RunMetaRefresh(cfg)
+	if err != nil {
+		return err
+	}
+
+	ui.Info("metadata refreshed", "repos", result.Written, "failed", result.Failed)
+	if result.Failed > 0 {
+		return fmt.Errorf("%d repos failed", result.Failed)
+	}
+	return nil
+}
+
+func runTrashList(cmd *cobra.Command, args []string) error {
+	items, err := journal.ListTrash()
+	if err != nil {
+		return err
+	}
+
+	defer ui.StartPager(!noPager).Stop()
+
+	fmt.Println()
+	entries := make([]ui.TrashEntry, len(items))
+	for i, item := range items {
+		entries[i] = ui.TrashEntry{Name: item.Name(), Path: item.Path, TrashedAt: item.TrashedAt}
+		if item.Source != "" {
+			entries[i].Name = item.Source + "/" + item.Name()
+		}
+	}
+	ui.PrintTrash(entries)
+	return nil
+}
+
+func runTrashRestore(cmd *cobra.Command, args []string) error {
+	items, err := journal.ListTrash()
+	if err != nil {
+		return err
+	}
+
+	matches := journal.MatchTrash(items, args[0])
+	if len(matches) == 0 {
+		return fmt.Errorf("no repo matching %q in the trash", args[0])
+	}
+	// Copies of the same clone are newest first; different clones need a
+	// more precise name
+	for _, m := range matches[1:] {
+		if m.Path != matches[0].Path {
+			var paths []string
+			for _, m := range matches {
+				if !slices.Contains(paths, m.Path) {
+					paths = append(paths, m.Path)
+				}
+			}
+			return fmt.Errorf("%q matches several repos in the trash: %s", args[0], strings.Join(paths, ", "))
+		}
+	}
+
+	item := matches[0]
+	to, err := journal.RestoreTrash(item, trashTo)
+	if err != nil {
+		return err
+	}
+	ui.Info("restored from trash", "repo", item.Name(), "path", to, "trashed", item.TrashedAt.Local().Format("2006-01-02 15:04"))
+	return nil
+}
+
+func runStale(cmd *cobra.Command, args []string) error {
+	than, err := sync.ParseAge(staleThan)
+	if err != nil {
+		return err
+	}
+
+	cfg, _, err := loadConfig()
+	if err != nil {
+		ui.Error("failed to load config", "error", err)
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	results, err := sync.FindStale(cfg, sync.StaleOptions{Than: than, By: staleBy})
+	if err != nil {
+		return err
+	}
+
+	defer ui.StartPager(!noPager).Stop()
+
+	fmt.Println()
+	for _, source := range results {
+		entries := make([]ui.StaleEntry, len(source.Repos))
+		for i, repo := range source.Repos {
+			entries[i] = ui.StaleEntry{Name: repo.Name, LastActivity: repo.LastActivity}
+		}
+		ui.PrintStale(source.Name, entries)
+	}
+
+	return nil
+}
+
+func runHistory(cmd *cobra.Command, args []string) error {
+	opts := sync.HistoryOptions{Repo: histRepo, Limit: histLimit}
+	if histSince != "" {
+		age, err := sync.ParseAge(histSince)
+		if err != nil {
+			return err
+		}
+		opts.Since = time.Now().Add(-age)
+	}
+
+	runs, err := sync.History(opts)
+	if err != nil {
+		return err
+	}
+
+	if histJSON {
+		for _, run := range runs {
+			data, err := json.Marshal(run)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+		}
+		return nil
+	}
+
+	defer ui.StartPager(!noPager).Stop()
+
+	fmt.Println()
+	entries := make([]ui.HistoryRun, len(runs))
+	for i, run := range runs {
+		entries[i] = historyEntry(run)
+	}
+	ui.PrintHistory(entries)
+	return nil
+}
+
+// historyEntry maps a recorded run to what ag history prints of it
+func historyEntry(run sync.RunRecord) ui.HistoryRun {
+	entry := ui.HistoryRun{
+		Time:     run.Time,
+		Command:  run.Command,
+		Duration: time.Duration(run.Seconds * float64(time.Second)),
+	}
+	if entry.Command == "" {
+		entry.Command = run.Run
+	}
+
+	for _, count := range []struct {
+		n    int
+		noun string
+	}{
+		{run.Cloned, "cloned"},
+		{run.Pruned, "pruned"},
+		{run.Added, "added"},
+		{run.Pulled, "pulled"},
+		{run.Updated, "updated"},
+		{run.Skipped, "skipped"},
+		{run.Failed, "failed"},
+	} {
+		if count.n > 0 {
+			entry.Counts = append(entry.Counts, fmt.Sprintf("%d %s", count.n, count.noun))
+		}
+	}
+
+	for _, repo := range run.Repos {
+		r := ui.HistoryRepo{Action: repo.Action, Name: repo.Source, Detail: repo.Path}
+		if repo.Repo != "" {
+			r.Name = repo.Source + "/" + repo.Repo
+		}
+		if repo.Commits > 0 {
+			r.Detail = fmt.Sprintf("%d new commits", repo.Commits)
+		}
+		if repo.Error != "" {
+			r.Failed = true
+			r.Detail = repo.Error
+		}
+		entry.Repos = append(entry.Repos, r)
+	}
+	return entry
+}
+
+func runNews(cmd *cobra.Command, args []string) error {
+	cfg, cfgPath, err := loadConfig()
+	if err != nil {
+		ui.Error("failed to load config", "error", err)
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	results, err := sync.RunNews(cfg, sync.NewsOptions{ConfigPath: cfgPath})
+	if err != nil {
+		return err
+	}
+
+	defer ui.StartPager(!noPager).Stop()
+
+	fmt.Println()
+	for _, source := range results {
+		ui.PrintNews(source.Name, source.Since, source.New, source.Removed)
+	}
+
+	return nil
+}
+
+func runFind(cmd *cobra.Command, args []string) error {
+	cfg, _, err := loadConfig()
+	if err != nil {
+		ui.Error("failed to load config", "error", err)
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	query := ""
+	if len(args) > 0 {
+		query = args[0]
+	}
+
+	matches := sync.FindRepos(cfg, query)
+	if len(matches) == 0 {
+		return fmt.Errorf("no repo matches %q", query)
+	}
+
+	if findAll {
+		for _, match := range matches {
+			fmt.Println(match.Path)
+		}
+		return nil
+	}
+
+	path := matches[0].Path
+	if len(matches) > 1 && ui.CanPrompt() {
+		choices := make([]ui.RepoChoice, len(matches))
+		for i, match := range matches {
+			choices[i] = ui.RepoChoice{Label: match.Source + ": " + match.Name, Path: match.Path}
+			if match.Alias != "" {
+				choices[i].Label += " (" + match.Alias + ")"
+			}
+		}
+		path, err = ui.PickRepo(choices)
+		if err != nil {
+			return fmt.Errorf("failed to get user input: %w", err)
+		}
+	}
+
+	fmt.Println(path)
+	return nil
+}
+
+func runMove(cmd *cobra.Command, args []string) error {
+	cfg, cfgPath, err := loadConfig()
+	if err != nil {
+		ui.Error("failed to load config", "error", err)
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if config.IsRemote(cfgPath) {
+		return fmt.Errorf("cannot move repos with a remote config")
+	}
+
+	return sync.RunMove(cfg, sync.MoveOptions{
+		Repo:       args[0],
+		ToSource:   moveToSource,
+		ConfigPath: cfgPath,
+		DryRun:     moveDryRun,
+	})
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	if serveInterval <= 0 {
+		return fmt.Errorf("interval must be positive")
+	}
+
+	// Fail before the first cycle when the address can't be used
+	listener, err := net.Listen("tcp", serveListen)
+	if err != nil {
+		return fmt.Errorf("metrics server failed: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	server := &http.Server{Addr: serveListen, Handler: mux}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.Serve(listener)
+	}()
+	ui.Info("serving metrics", "addr", serveListen, "interval", serveInterval)
+
+	// SIGINT/SIGTERM stop new clones and pulls; the ones running finish first
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ticker := time.NewTicker(serveInterval)
+	defer ticker.Stop()
+
+	for {
+		serveOnce(ctx)
+
+		select {
+		case err := <-errCh:
+			return fmt.Errorf("metrics server failed: %w", err)
+		case <-ctx.Done():
+			ui.Info("stopping")
+			return server.Close()
+		case <-ticker.C:
+		}
+	}
+}
+
+// serveOnce runs a single unattended sync (and pull) cycle.
+// The config is reloaded every cycle so edits are picked up without a restart.
+func serveOnce(ctx context.Context) {
+	journal.Begin("ag serve")
+
+	cfg, cfgPath, err := loadConfig()
+	if err != nil {
+		ui.Error("failed to load config", "error", err)
+		return
+	}
+
+	result, err := sync.Run(ctx, cfg, sync.SyncOptions{
+		Force:          true,
+		ConfigPath:     cfgPath,
+		Jobs:           serveJobs,
+		NonInteractive: true,
+		Incremental:    serveIncr,
+		FullListEvery:  serveFullList,
+	})
+	if err != nil {
+		ui.Error("sync failed", "error", err)
+		return
+	}
+	ui.Info("sync complete", "cloned", result.Cloned, "failed", result.Failed)
+	sendNotification(cfg, notify.Summary{
+		Command: "sync",
+		Cloned:  result.Cloned,
+		Pruned:  result.Pruned,
+		Added:   result.Added,
+		Failed:  result.Failed,
+	})
+
+	if servePull && ctx.Err() == nil {
+		pullResult, err := sync.RunPull(ctx, cfg, sync.PullOptions{Force: true, Jobs: serveJobs, NonInteractive: true})
+		if err != nil {
+			ui.Error("pull failed", "error", err)
+			return
+		}
+		ui.Info("pull complete", "updated", pullResult.Updated, "failed", pullResult.Failed)
+		sendNotification(cfg, notify.Summary{
+			Command: "pull",
+			Updated: pullResult.Updated,
+			Failed:  pullResult.Failed,
+		})
+	}
+}
+
+func runDiff(cmd *cobra.Command, args []string) error {
+	cfg, cfgPath, err := loadConfig()
+	if err != nil {
+		ui.Error("failed to load config", "error", err)
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	ui.Debug("loaded config", "path", cfgPath, "sources", len(cfg.Sources))
+
+	if diffFormat != "text" && diffFormat != "yaml" {
+		return fmt.Errorf("invalid format %q (must be text or yaml)", diffFormat)
+	}
+
+	var diffs []ui.SourceDiff
+	patch := &config.Patch{}
+
+	for i := range cfg.Sources {
+		source := &cfg.Sources[i]
+
+		statuses, err := sync.ComputeSourceStatus(source, sync.StatusOptions{Deep: diffDeep, Remote: diffRemote})
+		if err != nil {
+			ui.Warn("skipping source", "source", source.Name, "error", err)
+			continue
+		}
+
+		// Convert RepoStatus to DiffEntry
+		entries := make([]ui.DiffEntry, len(statuses))
+		for j, s := range statuses {
+			entries[j] = ui.DiffEntry{
+				Name:           s.Name,
+				Status:         s.Status,
+				Dirty:          s.Dirty,
+				Ahead:          s.Ahead,
+				Behind:         s.Behind,
+				RemoteMismatch: s.RemoteMismatch,
+				GoneUpstream:   s.GoneUpstream,
+			}
+		}
+
+		diffs = append(diffs, ui.SourceDiff{
+			Name:    source.Name,
+			Entries: entries,
+		})
+
+		if sp := sync.PatchFromStatus(source, statuses); sp != nil {
+			patch.Sources = append(patch.Sources, *sp)
+		}
+	}
+
+	if len(diffs) == 0 {
+		ui.Info("no sources to diff")
+		return nil
+	}
+
+	if diffOutput != "" || diffFormat == "yaml" {
+		data, err := patch.Marshal()
+		if err != nil {
+			return err
+		}
+		if diffOutput != "" {
+			if err := os.WriteFile(diffOutput, data, 0644); err != nil {
+				return fmt.Errorf("failed to write patch: %w", err)
+			}
+			ui.Info("wrote patch", "path", diffOutput, "sources", len(patch.Sources))
+		}
+		if diffFormat == "yaml" {
+			fmt.Print(string(data))
+			exitIfDiff(diffs)
+			return nil
+		}
+	}
+
+	pager := ui.StartPager(!noPager)
+	ui.PrintUnifiedDiff(diffs)
+	pager.Stop()
+	exitIfDiff(diffs)
+
+	return nil
+}
+
+// exitIfDiff exits with status 1 when --exit-code is set and any source has
+// repos to clone or orphaned repos, like git diff --exit-code
+func exitIfDiff(diffs []ui.SourceDiff) {
+	if !diffExitCode {
+		return
+	}
+	for _, diff := range diffs {
+		for _, entry := range diff.Entries {
+			if entry.Status == ui.StatusAdded || entry.Status == ui.StatusRemoved {
+				os.Exit(1)
+			}
+		}
+	}
+}
+
+func runAdd(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 && !addBrowse {
+		return fmt.Errorf("pass repo names or --browse")
+	}
+
 	cfg, cfgPath, err := loadConfig()
 	if err != nil {
 		ui.Error("failed to load config", "error", err)
 		return fmt.Errorf("failed to load config: %w", err)
 	}
+	if config.IsRemote(cfgPath) {
+		return fmt.Errorf("cannot add repos to a remote config")
+	}
 
-	ui.Info("loaded config", "path", cfgPath, "sources", len(cfg.Sources))
-
-	opts := sync.SyncOptions{
-		Prune:      syncPrune,
-		Add:        syncAdd,
-		Force:      syncForce,
+	_, err = sync.RunAdd(cfg, sync.AddOptions{
+		Source:     addSource,
+		Repos:      args,
+		Browse:     addBrowse,
 		ConfigPath: cfgPath,
-		Jobs:       syncJobs,
-		DryRun:     syncDryRun,
-	}
+		DryRun:     addDryRun,
+	})
+	return err
+}
 
-	result, err := sync.Run(cfg, opts)
+func runCreate(cmd *cobra.Command, args []string) error {
+	cfg, cfgPath, err := loadConfig()
 	if err != nil {
-		return err
+		ui.Error("failed to load config", "error", err)
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if config.IsRemote(cfgPath) {
+		ui.Warn("config is remote, the new repo won't be added to it")
+		cfgPath = ""
 	}
 
-	ui.PrintSummary(result.Cloned, result.Pruned, result.Skipped)
-
-	return nil
+	return sync.RunCreate(cfg, sync.CreateOptions{
+		Target:     args[0],
+		Private:    createPrivate,
+		ConfigPath: cfgPath,
+		DryRun:     createDryRun,
+	})
 }
 
-func runPull(cmd *cobra.Command, args []string) error {
+func runFork(cmd *cobra.Command, args []string) error {
 	cfg, cfgPath, err := loadConfig()
 	if err != nil {
 		ui.Error("failed to load config", "error", err)
 		return fmt.Errorf("failed to load config: %w", err)
 	}
-
-	ui.Info("loaded config", "path", cfgPath, "sources", len(cfg.Sources))
-
-	opts := sync.PullOptions{
-		Force: pullForce,
-		Jobs:  pullJobs,
+	if config.IsRemote(cfgPath) {
+		ui.Warn("config is remote, the fork won't be added to it")
+		cfgPath = ""
 	}
 
-	result, err := sync.RunPull(cfg, opts)
+	return sync.RunFork(cfg, sync.ForkOptions{
+		Repo:       args[0],
+		Source:     forkSource,
+		ConfigPath: cfgPath,
+		DryRun:     forkDryRun,
+	})
+}
+
+func runApply(cmd *cobra.Command, args []string) error {
+	patch, err := config.LoadPatch(args[0])
 	if err != nil {
 		return err
 	}
+	if patch.IsEmpty() {
+		ui.Info("patch is empty, nothing to apply")
+		return nil
+	}
 
-	ui.Info("pull complete", "updated", result.Updated, "failed", result.Failed)
-
-	return nil
-}
-
-func runDiff(cmd *cobra.Command, args []string) error {
 	cfg, cfgPath, err := loadConfig()
 	if err != nil {
 		ui.Error("failed to load config", "error", err)
 		return fmt.Errorf("failed to load config: %w", err)
 	}
+	if config.IsRemote(cfgPath) {
+		return fmt.Errorf("cannot apply a patch to a remote config")
+	}
 
-	ui.Debug("loaded config", "path", cfgPath, "sources", len(cfg.Sources))
-
-	var diffs []ui.SourceDiff
-
-	for i := range cfg.Sources {
-		source := &cfg.Sources[i]
-
-		statuses, err := sync.ComputeSourceStatus(source)
-		if err != nil {
-			ui.Warn("skipping source", "source", source.Name, "error", err)
-			continue
+	for _, sp := range patch.Sources {
+		for _, name := range sp.Add {
+			ui.Info("add", "source", sp.Source, "repo", name)
 		}
-
-		// Convert RepoStatus to DiffEntry
-		entries := make([]ui.DiffEntry, len(statuses))
-		for j, s := range statuses {
-			entries[j] = ui.DiffEntry{Name: s.Name, Status: s.Status}
+		for _, name := range sp.Remove {
+			ui.Info("remove", "source", sp.Source, "repo", name)
 		}
+	}
 
-		diffs = append(diffs, ui.SourceDiff{
-			Name:    source.Name,
-			Entries: entries,
-		})
+	added, removed, err := cfg.Apply(patch)
+	if err != nil {
+		return err
 	}
 
-	if len(diffs) == 0 {
-		ui.Info("no sources to diff")
+	for _, sp := range patch.Sources {
+		if src := cfg.FindSource(sp.Source); src != nil && len(src.Repos) == 0 {
+			ui.Warn("source has no repos left, add one or remove the source", "source", src.Name)
+		}
+	}
+
+	if applyDryRun {
+		ui.Info("dry run, config not saved", "added", added, "removed", removed)
 		return nil
 	}
 
-	ui.PrintUnifiedDiff(diffs)
+	if err := sync.SaveConfig(cfg, cfgPath); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	ui.Info("config saved", "path", cfgPath, "added", added, "removed", removed)
 
 	return nil
 }
 
-func runConfig(cmd *cobra.Command, args []string) error {
-	path := configPath
-	if path == "" {
-		path = config.DefaultConfigPath()
+// validateRemote reports configured repos that don't match the provider
+func validateRemote(cfg *config.Config, path string) error {
+	problems := sync.ValidateRemote(cfg)
+	for _, p := range problems {
+		ui.Error("repo does not match provider", "source", p.Source, "repo", p.Repo, "problem", p.Problem)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d configured repos don't match the provider", len(problems))
 	}
 
+	ui.Info("config is valid", "path", path)
+	return nil
+}
+
+func runConfig(cmd *cobra.Command, args []string) error {
+	path := configFiles()[0]
+
 	// Generate only mode - output template to stdout
 	if configGenerate {
 		fmt.Print(config.DefaultTemplate)
 		return nil
 	}
 
+	if configRemote && !configValidate {
+		return fmt.Errorf("--remote requires --validate")
+	}
+
 	// Validate only mode
 	if configValidate {
-		if err := config.ValidateFile(path); err != nil {
+		cfg, err := config.LoadFiles(configFiles())
+		if err != nil {
 			ui.Error("config validation failed", "error", err)
 			return err
 		}
+		printConfigWarnings(cfg)
+		if configRemote {
+			return validateRemote(cfg, path)
+		}
 		ui.Info("config is valid", "path", path)
 		return nil
 	}
 
+	if len(configPaths) > 1 {
+		return fmt.Errorf("can only edit one config file at a time")
+	}
+
 	// Cannot edit remote configs
 	if config.IsRemote(path) {
 		return fmt.Errorf("cannot edit remote config, use --validate to check it")
@@ -276,6 +1727,14 @@ func runConfig(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	// Hold the lock while editing so automatic edits wait instead of
+	// clobbering, or being clobbered by, the editor's save
+	unlock, err := config.Lock(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	// Open in editor
 	editor := getEditor()
 	ui.Info("opening config in editor", "editor", editor, "path", path)
@@ -292,7 +1751,7 @@ func runConfig(cmd *cobra.Command, args []string) error {
 		}
 
 		// Validate config after editing
-		if err := config.ValidateFile(path); err != nil {
+		if _, err := config.LoadFiles([]string{path}); err != nil {
 			ui.Error("config validation failed", "error", err)
 
 			var retry bool
@@ -335,8 +1794,24 @@ func getEditor() string {
 }
 
 func runConnect(cmd *cobra.Command, args []string) error {
-	// Load existing credentials (ignore error - credentials may not exist yet)
 	credPath := connector.DefaultCredentialsPath()
+
+	// Encrypt the plaintext store
+	if connectEncrypt {
+		if err := connector.EncryptStore(credPath); err != nil {
+			return err
+		}
+		ui.Info("credentials encrypted", "path", credPath+connector.EncryptedSuffix, "identity", connector.IdentityPath())
+		return nil
+	}
+
+	// Remove mode, before loading the file so the environment check below
+	// only sees variables set outside it
+	if connectRemove {
+		return removeConnection(credPath)
+	}
+
+	// Load existing credentials (ignore error - credentials may not exist yet)
 	_ = connector.LoadCredentialsEnv(credPath)
 
 	// List mode
@@ -344,6 +1819,16 @@ func runConnect(cmd *cobra.Command, args []string) error {
 		return listConnections()
 	}
 
+	// Test mode
+	if connectTest {
+		return testConnections()
+	}
+
+	// Rotate mode
+	if connectRotate {
+		return rotateConnection(credPath)
+	}
+
 	var connType connector.ConnectorType
 	var host string
 	var token string
@@ -440,21 +1925,17 @@ func interactiveConnect() (connector.ConnectorType, string, string, error) {
 
 	var connType connector.ConnectorType
 	var host string
-	var tokenURL string
 
 	switch typeChoice {
 	case "github":
 		connType = connector.ConnectorGitHub
 		host = "github.com"
-		tokenURL = "https://github.com/settings/tokens/new?description=autogitter&scopes=repo"
 	case "gitea":
 		connType = connector.ConnectorGitea
 		host = "gitea.com"
-		tokenURL = "https://gitea.com/user/settings/applications"
 	case "bitbucket":
 		connType = connector.ConnectorBitbucket
 		host = "bitbucket.org"
-		tokenURL = "https://bitbucket.org/account/settings/app-passwords/"
 	case "custom":
 		// Ask for host
 		err := huh.NewInput().
@@ -495,26 +1976,55 @@ func interactiveConnect() (connector.ConnectorType, string, string, error) {
 		switch providerType {
 		case "github":
 			connType = connector.ConnectorGitHub
-			tokenURL = fmt.Sprintf("https://%s/settings/tokens/new", host)
 		case "gitea":
 			connType = connector.ConnectorGitea
-			tokenURL = fmt.Sprintf("https://%s/user/settings/applications", host)
 		case "bitbucket":
 			connType = connector.ConnectorBitbucket
-			tokenURL = fmt.Sprintf("https://%s/account", host)
 		}
 	}
 
-	// Show token generation instructions
+	showTokenInstructions(connType, host)
+
+	token, err := promptToken()
+	if err != nil {
+		return "", "", "", err
+	}
+
+	return connType, host, token, nil
+}
+
+// tokenURL returns the page where a new access token for the host is created
+func tokenURL(connType connector.ConnectorType, host string) string {
+	switch connType {
+	case connector.ConnectorGitHub:
+		if host == "github.com" {
+			return "https://github.com/settings/tokens/new?description=autogitter&scopes=repo"
+		}
+		return fmt.Sprintf("https://%s/settings/tokens/new", host)
+	case connector.ConnectorBitbucket:
+		if host == "bitbucket.org" {
+			return "https://bitbucket.org/account/settings/app-passwords/"
+		}
+		return fmt.Sprintf("https://%s/account", host)
+	default:
+		return fmt.Sprintf("https://%s/user/settings/applications", host)
+	}
+}
+
+// showTokenInstructions prints where to create a token and which permissions it needs
+func showTokenInstructions(connType connector.ConnectorType, host string) {
+	url := tokenURL(connType, host)
+
 	fmt.Println()
 	fmt.Printf("Generate an access token at:\n")
-	fmt.Printf("  %s\n", tokenURL)
+	fmt.Printf("  %s\n", url)
 	if connType == connector.ConnectorBitbucket && host != "bitbucket.org" {
 		fmt.Printf("  (click 'HTTP access tokens' in the menu)\n")
 	}
 	// Copy URL to clipboard
-	ui.CopyToClipboard(tokenURL)
-	fmt.Printf("  \033[90m📋 copied to clipboard\033[0m\n")
+	if clipboardEnabled() && ui.CopyToClipboard(url) {
+		fmt.Printf("  \033[90m📋 copied to clipboard\033[0m\n")
+	}
 	fmt.Println()
 	fmt.Printf("Required permissions:\n")
 	switch connType {
@@ -527,24 +2037,40 @@ func interactiveConnect() (connector.ConnectorType, string, string, error) {
 		fmt.Printf("  - read:repository (to list repositories)\n")
 	}
 	fmt.Println()
+}
 
-	// Prompt for token
+// promptToken asks for an access token without echoing it
+func promptToken() (string, error) {
 	var token string
-	err = huh.NewInput().
+	err := huh.NewInput().
 		Title("Enter your access token").
 		EchoMode(huh.EchoModePassword).
 		Value(&token).
 		Run()
 
 	if err != nil {
-		return "", "", "", err
+		return "", err
 	}
 
 	if token == "" {
-		return "", "", "", fmt.Errorf("token is required")
+		return "", fmt.Errorf("token is required")
 	}
+	return token, nil
+}
 
-	return connType, host, token, nil
+// clipboardEnabled reports whether connect may copy to the clipboard, per
+// --no-clipboard and the ui.clipboard config option
+func clipboardEnabled() bool {
+	if connectNoClip {
+		return false
+	}
+	// connect works without a config, so a missing one allows copying
+	cfg, _, err := loadConfig()
+	if err != nil {
+		ui.Debug("failed to load config", "error", err)
+		return true
+	}
+	return cfg.ClipboardEnabled()
 }
 
 func listConnections() error {
@@ -583,8 +2109,319 @@ func listConnections() error {
 	}
 
 	fmt.Println()
-	fmt.Printf("Credentials file: %s\n", credPath)
+	if connector.IsEncrypted(credPath) {
+		fmt.Printf("Credentials file: %s (encrypted)\n", credPath+connector.EncryptedSuffix)
+	} else {
+		fmt.Printf("Credentials file: %s\n", credPath)
+	}
+
+	return nil
+}
+
+// removeConnection deletes the stored token for --type from the credentials file
+func removeConnection(credPath string) error {
+	if connectType == "" {
+		return fmt.Errorf("--remove needs --type (github|gitea|bitbucket|plugin)")
+	}
+	envVar := connector.GetEnvVarName(connector.ConnectorType(connectType))
+	if envVar == "" {
+		return fmt.Errorf("unknown connector type: %s", connectType)
+	}
+	if connectHost != "" {
+		ui.Warn("credentials are stored per provider type, not per host", "host", connectHost, "removing", envVar)
+	}
+
+	removed, err := connector.RemoveCredential(credPath, envVar)
+	if err != nil {
+		return fmt.Errorf("failed to remove credential: %w", err)
+	}
+	if !removed {
+		ui.Info("no stored credential", "type", connectType, "path", credPath)
+	} else {
+		ui.Info("credential removed", "type", connectType, "path", credPath)
+	}
+
+	if os.Getenv(envVar) != "" {
+		ui.Warn("token is still set in the environment", "variable", envVar)
+	}
+	return nil
+}
+
+// rotateConnection replaces the stored token for --type with a new one. The
+// new token is created through the API where the provider allows it, or
+// entered by hand, and verified before it replaces the old one.
+func rotateConnection(credPath string) error {
+	connType := connector.ConnectorType(connectType)
+	switch connType {
+	case connector.ConnectorGitHub, connector.ConnectorGitea, connector.ConnectorBitbucket:
+	case "":
+		return fmt.Errorf("--rotate needs --type (github|gitea|bitbucket)")
+	default:
+		return fmt.Errorf("cannot rotate %s credentials", connectType)
+	}
+
+	oldToken := connector.GetToken(connType)
+	if oldToken == "" {
+		return fmt.Errorf("no stored credential for %s, run 'ag connect' first", connType)
+	}
+
+	host := rotateHost(connType)
+	ctx := context.Background()
+
+	oldConn, err := connector.New(connType, host, oldToken)
+	if err != nil {
+		return fmt.Errorf("failed to create connector: %w", err)
+	}
+	oldUser := ""
+	if resolver, ok := oldConn.(connector.UserResolver); ok {
+		if oldUser, err = resolver.CurrentUser(ctx); err != nil {
+			ui.Warn("current token does not work", "host", host, "error", err)
+		}
+	}
+
+	// Create the new token through the API when possible
+	var newToken, username, password string
+	rotator, canRotate := oldConn.(connector.TokenRotator)
+	if connectToken != "" {
+		newToken = connectToken
+	} else if canRotate {
+		username, password, err = promptAccount(host, oldUser)
+		if err != nil {
+			return err
+		}
+		name := "autogitter-" + time.Now().Format("20060102-150405")
+		if newToken, err = rotator.CreateToken(ctx, username, password, name); err != nil {
+			return err
+		}
+		ui.Info("created token", "host", host, "name", name)
+	} else {
+		showTokenInstructions(connType, host)
+		if newToken, err = promptToken(); err != nil {
+			return err
+		}
+	}
+
+	// Verify before replacing anything
+	ui.Info("testing new token...")
+	newConn, err := connector.New(connType, host, newToken)
+	if err != nil {
+		return fmt.Errorf("failed to create connector: %w", err)
+	}
+	newUser := ""
+	if resolver, ok := newConn.(connector.UserResolver); ok {
+		newUser, err = resolver.CurrentUser(ctx)
+	} else {
+		err = newConn.TestConnection(ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("new token failed verification, stored credential unchanged: %w", err)
+	}
+	if oldUser != "" && newUser != "" && !strings.EqualFold(oldUser, newUser) {
+		ui.Warn("new token belongs to a different user", "old", oldUser, "new", newUser)
+	}
+
+	envVar := connector.GetEnvVarName(connType)
+	if err := connector.SaveCredential(credPath, envVar, newToken); err != nil {
+		return fmt.Errorf("failed to save credential: %w", err)
+	}
+	ui.Info("credential replaced", "path", credPath, "user", newUser)
+
+	if oldToken == newToken {
+		return nil
+	}
+
+	// Revoke the old token
+	if canRotate && password != "" {
+		var revoke bool
+		err := huh.NewConfirm().
+			Title("Revoke the old token?").
+			Description(fmt.Sprintf("%s on %s will stop working", maskToken(oldToken), host)).
+			Affirmative("Yes, revoke").
+			Negative("No, keep").
+			Value(&revoke).
+			Run()
+		if err != nil {
+			return err
+		}
+		if revoke {
+			if err := rotator.RevokeToken(ctx, username, password, oldToken); err != nil {
+				ui.Error("failed to revoke old token", "error", err)
+				return err
+			}
+			ui.Info("revoked old token", "token", maskToken(oldToken))
+		}
+		return nil
+	}
+
+	fmt.Println()
+	fmt.Printf("Revoke the old token (%s) at:\n", maskToken(oldToken))
+	fmt.Printf("  %s\n", tokenURL(connType, host))
+	fmt.Println()
+	return nil
+}
+
+// rotateHost returns --host, or the host of the first configured source of
+// the given type, or the provider's public host
+func rotateHost(connType connector.ConnectorType) string {
+	if connectHost != "" {
+		host := strings.TrimPrefix(connectHost, "https://")
+		return strings.TrimSuffix(host, "/")
+	}
+	if cfg, _, err := loadConfig(); err == nil {
+		for i := range cfg.Sources {
+			if cfg.Sources[i].Strategy != config.StrategyStatic && cfg.Sources[i].GetConnectorType() == connType {
+				return cfg.Sources[i].GetHost()
+			}
+		}
+	}
+	switch connType {
+	case connector.ConnectorGitHub:
+		return "github.com"
+	case connector.ConnectorBitbucket:
+		return "bitbucket.org"
+	default:
+		return "gitea.com"
+	}
+}
+
+// promptAccount asks for the username and password needed to manage tokens
+func promptAccount(host, username string) (string, string, error) {
+	var password string
+	err := huh.NewForm(huh.NewGroup(
+		huh.NewInput().
+			Title(fmt.Sprintf("Username on %s", host)).
+			Value(&username),
+		huh.NewInput().
+			Title("Password").
+			Description("Only used to create the new token, never stored").
+			EchoMode(huh.EchoModePassword).
+			Value(&password),
+	)).Run()
+	if err != nil {
+		return "", "", err
+	}
+	if username == "" || password == "" {
+		return "", "", fmt.Errorf("username and password are required")
+	}
+	return username, password, nil
+}
+
+// connectionTarget is one provider host whose credentials connect --test checks
+type connectionTarget struct {
+	connType connector.ConnectorType
+	host     string
+	source   *config.Source // nil for a provider's default host
+}
+
+// testConnections checks every stored credential against the hosts the config
+// uses it for, or the provider's default host, and reports the user it
+// authenticates as
+func testConnections() error {
+	if connectType != "" {
+		switch connector.ConnectorType(connectType) {
+		case connector.ConnectorGitHub, connector.ConnectorGitea, connector.ConnectorBitbucket, connector.ConnectorPlugin:
+		default:
+			return fmt.Errorf("unknown connector type: %s", connectType)
+		}
+	}
+
+	var targets []connectionTarget
+	seen := make(map[string]bool)
+	if cfg, _, err := loadConfig(); err == nil {
+		for i := range cfg.Sources {
+			source := &cfg.Sources[i]
+			if source.Strategy == config.StrategyStatic {
+				continue
+			}
+			target := connectionTarget{connType: source.GetConnectorType(), host: source.GetHost(), source: source}
+			key := string(target.connType) + " " + target.host + " " + source.Plugin.Command
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			targets = append(targets, target)
+		}
+	} else {
+		ui.Debug("failed to load config, testing default hosts only", "error", err)
+	}
+
+	// Credentials not used by any source are tested against the public host
+	defaults := []connectionTarget{
+		{connType: connector.ConnectorGitHub, host: "github.com"},
+		{connType: connector.ConnectorGitea, host: "gitea.com"},
+		{connType: connector.ConnectorBitbucket, host: "bitbucket.org"},
+	}
+	for _, target := range defaults {
+		used := false
+		for _, t := range targets {
+			used = used || t.connType == target.connType
+		}
+		if !used && connector.GetToken(target.connType) != "" {
+			targets = append(targets, target)
+		}
+	}
+
+	fmt.Println("Testing connections:")
+	fmt.Println()
+
+	tested, failed := 0, 0
+	for _, target := range targets {
+		if connectType != "" && string(target.connType) != connectType {
+			continue
+		}
+
+		label := fmt.Sprintf("  %-10s %-24s", target.connType, target.host)
+		token := connector.GetTokenForHost(target.connType, target.host)
+		if token == "" && target.connType != connector.ConnectorPlugin {
+			fmt.Printf("%s %s\n", label, ui.UnchangedStyle.Render("no token ("+connector.GetEnvVarName(target.connType)+")"))
+			continue
+		}
+		tested++
+
+		var conn connector.Connector
+		var err error
+		if target.source != nil {
+			conn, err = target.source.NewConnector(token)
+		} else {
+			conn, err = connector.New(target.connType, target.host, token)
+		}
+		if err != nil {
+			fmt.Printf("%s %s\n", label, ui.RemovedStyle.Render("✗ "+err.Error()))
+			failed++
+			continue
+		}
+
+		ctx := context.Background()
+		user := ""
+		if resolver, ok := conn.(connector.UserResolver); ok {
+			user, err = resolver.CurrentUser(ctx)
+		} else {
+			err = conn.TestConnection(ctx)
+		}
+		if err != nil {
+			fmt.Printf("%s %s\n", label, ui.RemovedStyle.Render("✗ "+err.Error()))
+			failed++
+			continue
+		}
+
+		status := "✓ valid"
+		if user != "" {
+			status += " as " + user
+		}
+		fmt.Printf("%s %s\n", label, ui.AddedStyle.Render(status))
+	}
+
+	if tested == 0 {
+		fmt.Println("  No credentials to test.")
+		fmt.Println()
+		fmt.Println("Run 'ag connect' to set up a connection.")
+		return nil
+	}
 
+	fmt.Println()
+	if failed > 0 {
+		return fmt.Errorf("%d of %d connection(s) failed", failed, tested)
+	}
 	return nil
 }
 
@@ -1,17 +1,23 @@
 package config
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"text/template"
 	"time"
 
 	"github.com/arch-err/autogitter/internal/connector"
+	"github.com/arch-err/autogitter/internal/journal"
 	"gopkg.in/yaml.v3"
 )
 
@@ -22,6 +28,33 @@ const (
 	StrategyAll    Strategy = "all"
 	StrategyFile   Strategy = "file"
 	StrategyRegex  Strategy = "regex"
+	StrategyStatic Strategy = "static"
+)
+
+// Layout controls how clones are arranged under a source's local_path
+type Layout string
+
+const (
+	LayoutFlat      Layout = "flat"       // local_path/<repo>
+	LayoutOwner     Layout = "owner"      // local_path/<owner>/<repo>
+	LayoutHostOwner Layout = "host-owner" // local_path/<host>/<owner>/<repo>, as used by ghq
+)
+
+// Protocol selects the URL scheme repos are cloned over
+type Protocol string
+
+const (
+	ProtocolSSH   Protocol = "ssh"
+	ProtocolHTTPS Protocol = "https"
+)
+
+// TagMode controls which tags are fetched on clone and pull
+type TagMode string
+
+const (
+	TagsReachable TagMode = "reachable" // tags pointing into fetched history, git's default
+	TagsAll       TagMode = "all"       // every tag on the remote
+	TagsNone      TagMode = "none"      // no tags
 )
 
 type FileStrategy struct {
@@ -32,52 +65,127 @@ type RegexStrategy struct {
 	Pattern string `yaml:"pattern"`
 }
 
+// StaticStrategy points at a newline-delimited list of clone URLs.
+// The list may be a local file, an HTTP/HTTPS URL, or an SSH path.
+type StaticStrategy struct {
+	List string `yaml:"list"`
+}
+
 // RepoEntry represents a repository in the config.
 // It supports both plain string format ("user/repo") and object format
-// with an optional local_path override.
+// with optional local_path, ssh_options, and upstream overrides.
 type RepoEntry struct {
-	Name      string `yaml:"name"`
-	LocalPath string `yaml:"local_path,omitempty"`
+	Name       string         `yaml:"name"`
+	LocalPath  string         `yaml:"local_path,omitempty"`
+	SSHOptions RepoSSHOptions `yaml:"ssh_options,omitempty"`
+	Upstream   string         `yaml:"upstream,omitempty"` // "owner/repo" on the source's host, or a clone URL
+	Alias      string         `yaml:"alias,omitempty"`    // short name for ag find/cd and ag move, unique across sources
+	Subpath    string         `yaml:"subpath,omitempty"`  // only check out this directory of the repo, with sparse-checkout
+	Link       string         `yaml:"link,omitempty"`     // symlink to the checked-out subpath, e.g. ~/Git/payments
+	Priority   int            `yaml:"priority,omitempty"` // replaces the source's priority when set
+}
+
+// RepoSSHOptions overrides the source's SSH options for a single repo,
+// e.g. a deploy key that only grants access to that repo
+type RepoSSHOptions struct {
+	PrivateKey string `yaml:"private_key,omitempty"`
 }
 
 // UnmarshalYAML allows RepoEntry to be unmarshaled from either a plain string
-// or a mapping with name and local_path fields.
+// or a mapping with name, local_path, ssh_options, upstream, alias, subpath,
+// link, and priority fields. Names may carry the subpath as "owner/repo//dir".
 func (r *RepoEntry) UnmarshalYAML(value *yaml.Node) error {
 	if value.Kind == yaml.ScalarNode {
-		r.Name = value.Value
+		*r = repoEntryFromName(value.Value)
 		return nil
 	}
 	if value.Kind == yaml.MappingNode {
 		// Use an alias type to avoid infinite recursion
 		type repoEntryRaw struct {
-			Name      string `yaml:"name"`
-			LocalPath string `yaml:"local_path,omitempty"`
+			Name       string         `yaml:"name"`
+			LocalPath  string         `yaml:"local_path,omitempty"`
+			SSHOptions RepoSSHOptions `yaml:"ssh_options,omitempty"`
+			Upstream   string         `yaml:"upstream,omitempty"`
+			Alias      string         `yaml:"alias,omitempty"`
+			Subpath    string         `yaml:"subpath,omitempty"`
+			Link       string         `yaml:"link,omitempty"`
+			Priority   int            `yaml:"priority,omitempty"`
 		}
 		var raw repoEntryRaw
 		if err := value.Decode(&raw); err != nil {
 			return err
 		}
-		r.Name = raw.Name
+		*r = repoEntryFromName(raw.Name)
 		r.LocalPath = raw.LocalPath
+		r.SSHOptions = raw.SSHOptions
+		r.Upstream = raw.Upstream
+		r.Alias = raw.Alias
+		if raw.Subpath != "" {
+			r.Subpath = raw.Subpath
+		}
+		r.Link = raw.Link
+		r.Priority = raw.Priority
 		return nil
 	}
 	return fmt.Errorf("expected string or mapping for repo entry, got %v", value.Kind)
 }
 
-// MarshalYAML emits a plain string when no LocalPath is set, or an object otherwise.
+// MarshalYAML emits a plain string when no overrides are set, or an object
+// otherwise. A subpath alone is written into the string as "owner/repo//dir".
 func (r RepoEntry) MarshalYAML() (interface{}, error) {
-	if r.LocalPath == "" {
-		return r.Name, nil
+	if r.LocalPath == "" && r.SSHOptions.PrivateKey == "" && r.Upstream == "" && r.Alias == "" && r.Link == "" && r.Priority == 0 {
+		return entryName(r), nil
 	}
 	return struct {
-		Name      string `yaml:"name"`
-		LocalPath string `yaml:"local_path"`
+		Name       string         `yaml:"name"`
+		LocalPath  string         `yaml:"local_path,omitempty"`
+		SSHOptions RepoSSHOptions `yaml:"ssh_options,omitempty"`
+		Upstream   string         `yaml:"upstream,omitempty"`
+		Alias      string         `yaml:"alias,omitempty"`
+		Subpath    string         `yaml:"subpath,omitempty"`
+		Link       string         `yaml:"link,omitempty"`
+		Priority   int            `yaml:"priority,omitempty"`
 	}{
-		Name:      r.Name,
-		LocalPath: r.LocalPath,
+		Name:       r.Name,
+		LocalPath:  r.LocalPath,
+		SSHOptions: r.SSHOptions,
+		Upstream:   r.Upstream,
+		Alias:      r.Alias,
+		Subpath:    r.Subpath,
+		Link:       r.Link,
+		Priority:   r.Priority,
 	}, nil
 }
 
+// entryKey identifies a repo entry like repoKey, keeping the subpath, since
+// different directories of one monorepo are different entries
+func entryKey(r RepoEntry) string {
+	return repoKey(r.Name) + "//" + r.Subpath
+}
+
+// entryName returns the entry's name with its subpath, as "owner/repo//dir"
+func entryName(r RepoEntry) string {
+	if r.Subpath != "" {
+		return r.Name + "//" + r.Subpath
+	}
+	return r.Name
+}
+
+// repoEntryFromName returns the entry for a repo name, splitting off the
+// subpath of an "owner/repo//dir" name. The "//" of a URL scheme doesn't
+// count, so "https://host/owner/repo.git//dir" works too.
+func repoEntryFromName(name string) RepoEntry {
+	start := 0
+	if idx := strings.Index(name, "://"); idx != -1 {
+		start = idx + len("://")
+	}
+	idx := strings.Index(name[start:], "//")
+	if idx == -1 {
+		return RepoEntry{Name: name}
+	}
+	return RepoEntry{Name: name[:start+idx], Subpath: strings.Trim(name[start+idx+2:], "/")}
+}
+
 // ResolvedLocalPath returns the effective local path for this repo.
 // If LocalPath is set, it returns that; otherwise filepath.Join(sourceLocalPath, baseName).
 func (r RepoEntry) ResolvedLocalPath(sourceLocalPath string) string {
@@ -101,43 +209,246 @@ func RepoEntriesFromNames(names []string) []RepoEntry {
 	return entries
 }
 
-// repoBaseName extracts the repo name from a full "user/repo" string.
+// repoBaseName extracts the repo name from a full "user/repo" string or clone URL.
 func repoBaseName(fullName string) string {
-	parts := strings.Split(fullName, "/")
-	if len(parts) > 0 {
-		return parts[len(parts)-1]
+	name := strings.TrimSuffix(fullName, ".git")
+	if idx := strings.LastIndexAny(name, "/:"); idx != -1 {
+		return name[idx+1:]
+	}
+	return name
+}
+
+// IsCloneURL reports whether a repo entry is a full clone URL rather than a "user/repo" name.
+func IsCloneURL(repo string) bool {
+	if strings.Contains(repo, "://") {
+		return true
+	}
+	// scp-like syntax: git@host:user/repo.git
+	atIdx := strings.Index(repo, "@")
+	colonIdx := strings.Index(repo, ":")
+	return atIdx > 0 && colonIdx > atIdx
+}
+
+// NormalizeRepoName trims whitespace, trailing slashes, and a ".git" suffix
+// from an "owner/repo" entry. Clone URLs are only trimmed of whitespace.
+func NormalizeRepoName(name string) string {
+	name = strings.TrimSpace(name)
+	if IsCloneURL(name) {
+		return name
 	}
-	return fullName
+	return strings.TrimSuffix(strings.TrimRight(name, "/"), ".git")
+}
+
+// repoKey identifies a repo entry regardless of case and ".git" suffix,
+// which providers ignore
+func repoKey(name string) string {
+	return strings.ToLower(strings.TrimSuffix(NormalizeRepoName(name), ".git"))
 }
 
 type SSHOptions struct {
-	Port       int    `yaml:"port,omitempty"`
-	PrivateKey string `yaml:"private_key,omitempty"`
-	Submodules bool   `yaml:"submodules,omitempty"`
+	Port               int    `yaml:"port,omitempty"`
+	PrivateKey         string `yaml:"private_key,omitempty"`
+	Submodules         bool   `yaml:"submodules,omitempty"`
+	HostKeyFingerprint string `yaml:"host_key_fingerprint,omitempty"` // "SHA256:..." the server's host key must match before cloning
+}
+
+// MirrorOptions configures the push to a source's mirror_to host
+type MirrorOptions struct {
+	PrivateKey string `yaml:"private_key,omitempty"` // SSH key for the mirror host; ssh's own keys when empty
+}
+
+// SignatureOptions configures which keys ag audit --verify-signatures
+// accepts for a source's commits and tags
+type SignatureOptions struct {
+	AllowedKeys    []string `yaml:"allowed_keys,omitempty"`    // GPG fingerprints or key IDs, or SSH fingerprints ("SHA256:...")
+	AllowedSigners string   `yaml:"allowed_signers,omitempty"` // git allowed signers file, needed to verify SSH signatures
+	Depth          int      `yaml:"depth,omitempty"`           // recent commits and tags to check per repo
+}
+
+// DefaultSignatureDepth is how many recent commits and tags are audited
+// when signatures.depth is not set
+const DefaultSignatureDepth = 20
+
+// GetDepth returns the number of commits and tags to check
+func (o *SignatureOptions) GetDepth() int {
+	if o.Depth > 0 {
+		return o.Depth
+	}
+	return DefaultSignatureDepth
+}
+
+// AllowsKey reports whether key is one of the allowed keys. GPG key IDs
+// match the end of a full fingerprint. Any key is allowed when none are listed.
+func (o *SignatureOptions) AllowsKey(key string) bool {
+	if len(o.AllowedKeys) == 0 {
+		return true
+	}
+	if key == "" {
+		return false
+	}
+	for _, allowed := range o.AllowedKeys {
+		allowed = strings.ReplaceAll(allowed, " ", "")
+		if strings.EqualFold(allowed, key) || (!strings.HasPrefix(allowed, "SHA256:") && strings.HasSuffix(strings.ToUpper(key), strings.ToUpper(allowed))) {
+			return true
+		}
+	}
+	return false
+}
+
+// PluginOptions configures an external connector plugin (type: plugin).
+// The command receives a JSON request on stdin and replies with JSON on stdout.
+type PluginOptions struct {
+	Command string `yaml:"command"`
 }
 
 type Source struct {
-	Name          string        `yaml:"name"`
-	Source        string        `yaml:"source"`
-	Strategy      Strategy      `yaml:"strategy"`
-	Type          string        `yaml:"type,omitempty"` // "github", "gitea", "bitbucket", or auto-detect from host
-	FileStrategy  FileStrategy  `yaml:"file_strategy,omitempty"`
-	RegexStrategy RegexStrategy `yaml:"regex_strategy,omitempty"`
-	LocalPath     string        `yaml:"local_path"`
-	SSHOptions    SSHOptions    `yaml:"ssh_options,omitempty"`
-	PrivateKey    string        `yaml:"private_key,omitempty"` // deprecated: use ssh_options.private_key
-	Branch        string        `yaml:"branch,omitempty"`
-	Repos         []RepoEntry   `yaml:"repos,omitempty"`
+	Name           string            `yaml:"name"`
+	Source         string            `yaml:"source"`
+	Strategy       Strategy          `yaml:"strategy"`
+	Type           string            `yaml:"type,omitempty"` // "github", "gitea", "bitbucket", "plugin", or auto-detect from host
+	Plugin         PluginOptions     `yaml:"plugin,omitempty"`
+	Project        string            `yaml:"project,omitempty"` // Bitbucket Cloud project key to list the workspace's repos of
+	FileStrategy   FileStrategy      `yaml:"file_strategy,omitempty"`
+	RegexStrategy  RegexStrategy     `yaml:"regex_strategy,omitempty"`
+	StaticStrategy StaticStrategy    `yaml:"static_strategy,omitempty"`
+	LocalPath      string            `yaml:"local_path"`
+	Layout         Layout            `yaml:"layout,omitempty"`        // "flat" (default), "owner", or "host-owner"
+	PathTemplate   string            `yaml:"path_template,omitempty"` // Go template for the clone path, overrides layout
+	ScanDepth      int               `yaml:"scan_depth,omitempty"`    // how many directory levels to search for local repos
+	Ignore         []string          `yaml:"ignore,omitempty"`        // gitignore-style patterns for directories to skip when scanning
+	SSHOptions     SSHOptions        `yaml:"ssh_options,omitempty"`
+	PrivateKey     string            `yaml:"private_key,omitempty"`       // deprecated: use ssh_options.private_key
+	Protocol       Protocol          `yaml:"protocol,omitempty"`          // "ssh" (default) or "https"
+	CredHelper     string            `yaml:"credential_helper,omitempty"` // git credential helper for HTTPS remotes
+	Branch         string            `yaml:"branch,omitempty"`
+	ShallowSince   string            `yaml:"shallow_since,omitempty"`      // only clone history after this date, e.g. "2024-01-01"
+	SingleBranch   bool              `yaml:"single_branch,omitempty"`      // only clone the default or configured branch
+	TrackAll       bool              `yaml:"track_all_branches,omitempty"` // create local branches for every remote branch
+	PruneRefs      bool              `yaml:"prune_refs,omitempty"`         // prune deleted remote branches and tags on pull
+	Tags           TagMode           `yaml:"tags,omitempty"`               // "reachable" (default), "all", or "none"
+	MaxRepoSize    string            `yaml:"max_repo_size,omitempty"`      // skip cloning repos larger than this, e.g. "2GB"
+	Signatures     *SignatureOptions `yaml:"signatures,omitempty"`         // enables ag audit --verify-signatures for the source
+	GitConfig      GitConfig         `yaml:"git_config,omitempty"`         // local git config set on every clone, e.g. user.email
+	MirrorTo       string            `yaml:"mirror_to,omitempty"`          // "host/owner" to replicate each repo to after each pull
+	MirrorOptions  MirrorOptions     `yaml:"mirror_options,omitempty"`     // credentials for the push to mirror_to
+	IncludeWikis   bool              `yaml:"include_wikis,omitempty"`      // clone each repo's wiki into <repo>.wiki next to it
+	Metadata       bool              `yaml:"metadata,omitempty"`           // write the provider's description, topics, etc. into each clone
+	Priority       int               `yaml:"priority,omitempty"`           // clones and pulls of higher priority sources start first
+	Repos          []RepoEntry       `yaml:"repos,omitempty"`
+}
+
+// GitConfig maps git config keys to the values set with `git config --local`
+type GitConfig map[string]string
+
+// Keys returns the config keys in sorted order
+func (g GitConfig) Keys() []string {
+	keys := make([]string, 0, len(g))
+	for key := range g {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Notifications configures messages posted after sync/pull runs
+type Notifications struct {
+	WebhookURL  string `yaml:"webhook_url,omitempty"` // environment variables are expanded when sending
+	Format      string `yaml:"format,omitempty"`      // "json" (default) or "slack"
+	Template    string `yaml:"template,omitempty"`    // Go text/template for the message text
+	OnlyFailure bool   `yaml:"only_failure,omitempty"`
+}
+
+// UIOptions configures interactive output
+type UIOptions struct {
+	Clipboard *bool `yaml:"clipboard,omitempty"` // copy token URLs to the clipboard, default true
+}
+
+// FormatOptions controls how autogitter writes the config file
+type FormatOptions struct {
+	SortRepos bool `yaml:"sort_repos,omitempty"` // keep repo lists sorted and free of duplicates
+}
+
+// PullOptions controls how autogitter pulls existing clones
+type PullOptions struct {
+	OnlyDefaultBranch bool `yaml:"only_default_branch,omitempty"` // skip clones on another branch or a detached HEAD
 }
 
 type Config struct {
-	Sources []Source `yaml:"sources"`
+	Sources       []Source      `yaml:"sources"`
+	Notifications Notifications `yaml:"notifications,omitempty"`
+	SymlinkDir    string        `yaml:"symlink_dir,omitempty"` // flat directory of symlinks to every local repo
+	UI            UIOptions     `yaml:"ui,omitempty"`
+	Format        FormatOptions `yaml:"format,omitempty"`
+	Pull          PullOptions   `yaml:"pull,omitempty"`
+	MaxBandwidth  string        `yaml:"max_bandwidth,omitempty"` // total transfer rate per second across clones and pulls, e.g. "2MB"
+	JobStagger    string        `yaml:"job_stagger,omitempty"`   // minimum delay between clone and pull starts, e.g. "500ms"
+	TrashDays     *int          `yaml:"trash_days,omitempty"`    // days pruned clones stay in the trash, default 30, 0 to keep them
+
+	loaded *loadedFile // the local file the config was loaded from, nil for remote or new configs
+
+	// Files merged by LoadFiles, saved back separately, and the number of
+	// sources each contributed
+	parts       []*Config
+	partSources []int
+}
+
+// ClipboardEnabled reports whether ui.clipboard allows copying to the clipboard
+func (c *Config) ClipboardEnabled() bool {
+	return c.UI.Clipboard == nil || *c.UI.Clipboard
+}
+
+// GetMaxBandwidth returns max_bandwidth in bytes per second, or 0 for no limit
+func (c *Config) GetMaxBandwidth() int64 {
+	rate, err := ParseSize(c.MaxBandwidth)
+	if err != nil {
+		return 0
+	}
+	return rate
+}
+
+// GetJobStagger returns job_stagger as a duration, or 0 to start jobs at once
+func (c *Config) GetJobStagger() time.Duration {
+	d, err := time.ParseDuration(c.JobStagger)
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+// DefaultTrashDays is how long pruned clones stay in the trash by default
+const DefaultTrashDays = 30
+
+// GetTrashDays returns trash_days, the number of days pruned clones stay in
+// the trash, or 0 to keep them until they're restored or deleted by hand
+func (c *Config) GetTrashDays() int {
+	if c.TrashDays == nil {
+		return DefaultTrashDays
+	}
+	return *c.TrashDays
 }
 
 func DefaultConfigPath() string {
 	return filepath.Join(configDir(), "config.yaml")
 }
 
+// SystemConfigDir holds the system-wide config, loaded before the user's
+const SystemConfigDir = "/etc/autogitter"
+
+// SystemConfigPath returns the path to the system-wide config file
+func SystemConfigPath() string {
+	return filepath.Join(SystemConfigDir, "config.yaml")
+}
+
+// hasSystemConfig reports whether there is a system config file or sources.d
+func hasSystemConfig() bool {
+	for _, path := range []string{SystemConfigPath(), filepath.Join(SystemConfigDir, "sources.d")} {
+		if _, err := os.Stat(path); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
 // SourcesDirPath returns the path to the sources.d directory
 func SourcesDirPath() string {
 	return filepath.Join(configDir(), "sources.d")
@@ -157,21 +468,57 @@ func configDir() string {
 }
 
 func Load(path string) (*Config, error) {
-	var cfg Config
+	return loadFile(path, mainFile)
+}
+
+// fileKind is the role of a file in a merged config
+type fileKind int
 
+const (
+	mainFile    fileKind = iota // a config on its own: gets sources.d and is validated
+	layerFile                   // gets sources.d, validated once merged
+	overlayFile                 // a later --config file: just the file, which must exist
+)
+
+// loadFile reads and parses a config file of the given kind
+func loadFile(path string, kind fileKind) (*Config, error) {
 	data, err := readConfig(path)
+	exists := err == nil
 	if err != nil {
 		// For local configs, allow missing config file if sources.d provides sources
-		if !IsRemote(path) && os.IsNotExist(err) {
+		if kind != overlayFile && !IsRemote(path) && os.IsNotExist(err) {
 			// Continue with empty config, sources.d may provide sources
 		} else {
 			return nil, fmt.Errorf("failed to read config file: %w", err)
 		}
-	} else {
+	}
+
+	cfg, err := parseConfig(path, data, exists, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	// Remember what was loaded, so Save can tell if someone else changed it
+	if !IsRemote(path) {
+		cfg.loaded = &loadedFile{path: path, data: data, exists: exists, kind: kind}
+	}
+	return cfg, nil
+}
+
+// parseConfig parses a config file's contents, merging in sources.d and
+// validating the result as its kind requires
+func parseConfig(path string, data []byte, exists bool, kind fileKind) (*Config, error) {
+	var cfg Config
+
+	if exists {
 		if err := yaml.Unmarshal(data, &cfg); err != nil {
 			return nil, fmt.Errorf("failed to parse config file: %w", err)
 		}
 	}
+	if kind == overlayFile {
+		cfg.ExpandPaths()
+		return &cfg, nil
+	}
 
 	// Load additional sources from sources.d directory (only for local configs)
 	if !IsRemote(path) {
@@ -181,8 +528,10 @@ func Load(path string) (*Config, error) {
 		}
 	}
 
-	if err := cfg.Validate(); err != nil {
-		return nil, fmt.Errorf("invalid config: %w", err)
+	if kind == mainFile {
+		if err := cfg.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid config: %w", err)
+		}
 	}
 
 	cfg.ExpandPaths()
@@ -322,11 +671,39 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("no sources defined")
 	}
 
+	switch c.Notifications.Format {
+	case "", "json", "slack":
+	default:
+		return fmt.Errorf("notifications: unknown format %q", c.Notifications.Format)
+	}
+	if c.Notifications.Template != "" {
+		if _, err := template.New("notification").Parse(c.Notifications.Template); err != nil {
+			return fmt.Errorf("notifications: invalid template: %w", err)
+		}
+	}
+
+	if _, err := ParseSize(c.MaxBandwidth); err != nil {
+		return fmt.Errorf("invalid max_bandwidth: %w", err)
+	}
+
+	if c.JobStagger != "" {
+		if d, err := time.ParseDuration(c.JobStagger); err != nil || d < 0 {
+			return fmt.Errorf("invalid job_stagger: %s", c.JobStagger)
+		}
+	}
+
+	if c.TrashDays != nil && *c.TrashDays < 0 {
+		return fmt.Errorf("invalid trash_days: %d", *c.TrashDays)
+	}
+
+	// Aliases name one repo across all sources
+	aliases := make(map[string]string)
+
 	for i, src := range c.Sources {
 		if src.Name == "" {
 			return fmt.Errorf("source %d: name is required", i)
 		}
-		if src.Source == "" {
+		if src.Source == "" && src.Strategy != StrategyStatic {
 			return fmt.Errorf("source %q: source URL is required", src.Name)
 		}
 		if src.LocalPath == "" {
@@ -345,12 +722,105 @@ func (c *Config) Validate() error {
 			}
 		case StrategyAll, StrategyFile, StrategyRegex:
 			// Valid strategies that fetch from API
+		case StrategyStatic:
+			if src.StaticStrategy.List == "" {
+				return fmt.Errorf("source %q: static_strategy.list is required for static strategy", src.Name)
+			}
 		case "":
 			return fmt.Errorf("source %q: strategy is required", src.Name)
 		default:
 			return fmt.Errorf("source %q: unknown strategy %q", src.Name, src.Strategy)
 		}
 
+		if src.Project != "" && (src.GetConnectorType() != connector.ConnectorBitbucket || src.GetHost() != "bitbucket.org") {
+			return fmt.Errorf("source %q: project is only supported for Bitbucket Cloud sources", src.Name)
+		}
+
+		if strings.EqualFold(src.Type, "plugin") && src.Plugin.Command == "" {
+			return fmt.Errorf("source %q: plugin.command is required for plugin type", src.Name)
+		}
+
+		switch src.Layout {
+		case "", LayoutFlat, LayoutOwner, LayoutHostOwner:
+		default:
+			return fmt.Errorf("source %q: unknown layout %q (must be flat, owner, or host-owner)", src.Name, src.Layout)
+		}
+
+		if src.ScanDepth < 0 {
+			return fmt.Errorf("source %q: scan_depth must not be negative", src.Name)
+		}
+
+		switch src.Protocol {
+		case "", ProtocolSSH, ProtocolHTTPS:
+		default:
+			return fmt.Errorf("source %q: unknown protocol %q (must be ssh or https)", src.Name, src.Protocol)
+		}
+
+		if fp := src.SSHOptions.HostKeyFingerprint; fp != "" {
+			if !strings.HasPrefix(fp, "SHA256:") {
+				return fmt.Errorf("source %q: host_key_fingerprint must be a SHA256 fingerprint (ssh-keygen -lf)", src.Name)
+			}
+			if src.Strategy == StrategyStatic {
+				return fmt.Errorf("source %q: host_key_fingerprint is not supported for static sources", src.Name)
+			}
+		}
+
+		if src.SingleBranch && src.TrackAll {
+			return fmt.Errorf("source %q: single_branch and track_all_branches are mutually exclusive", src.Name)
+		}
+
+		if src.MaxRepoSize != "" {
+			if _, err := ParseSize(src.MaxRepoSize); err != nil {
+				return fmt.Errorf("source %q: invalid max_repo_size: %w", src.Name, err)
+			}
+		}
+
+		switch src.Tags {
+		case "", TagsReachable, TagsAll, TagsNone:
+		default:
+			return fmt.Errorf("source %q: unknown tags mode %q (must be all, none, or reachable)", src.Name, src.Tags)
+		}
+
+		if src.PathTemplate != "" {
+			if src.Layout != "" {
+				return fmt.Errorf("source %q: layout and path_template are mutually exclusive", src.Name)
+			}
+			if err := validatePathTemplate(&src); err != nil {
+				return fmt.Errorf("source %q: invalid path_template: %w", src.Name, err)
+			}
+		}
+
+		if strings.Contains(src.MirrorTo, "://") {
+			return fmt.Errorf("source %q: mirror_to must be in host/owner format", src.Name)
+		}
+
+		seen := make(map[string]string)
+		for _, repo := range src.Repos {
+			if err := validateSubpath(repo); err != nil {
+				return fmt.Errorf("source %q: repo %q: %w", src.Name, repo.Name, err)
+			}
+
+			key := entryKey(repo)
+			if first, ok := seen[key]; ok {
+				if first == repo.Name {
+					return fmt.Errorf("source %q: repo %q is listed twice", src.Name, repo.Name)
+				}
+				return fmt.Errorf("source %q: repo %q is listed twice, as %q and %q", src.Name, NormalizeRepoName(repo.Name), first, repo.Name)
+			}
+			seen[key] = repo.Name
+
+			if repo.Alias == "" {
+				continue
+			}
+			if strings.ContainsAny(repo.Alias, "/ \t") {
+				return fmt.Errorf("source %q: alias %q of repo %q can't contain slashes or spaces", src.Name, repo.Alias, repo.Name)
+			}
+			if other, ok := aliases[strings.ToLower(repo.Alias)]; ok {
+				return fmt.Errorf("alias %q is used by both %s and %s/%s", repo.Alias, other, src.Name, repo.Name)
+			}
+			aliases[strings.ToLower(repo.Alias)] = src.Name + "/" + repo.Name
+		}
+
 		if src.Strategy == StrategyFile && src.FileStrategy.Filename == "" {
 			return fmt.Errorf("source %q: file_strategy.filename is required for file strategy", src.Name)
 		}
@@ -365,22 +835,98 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	return c.validatePaths()
+}
+
+// validateSubpath checks that a repo's subpath is a directory inside the
+// repo and that link is only set along with it
+func validateSubpath(repo RepoEntry) error {
+	if repo.Link != "" && repo.Subpath == "" {
+		return fmt.Errorf("link needs a subpath to point at")
+	}
+	if repo.Subpath == "" {
+		return nil
+	}
+	clean := path.Clean(repo.Subpath)
+	if path.IsAbs(repo.Subpath) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return fmt.Errorf("subpath must be a directory inside the repo, got %q", repo.Subpath)
+	}
+	return nil
+}
+
+// validatePaths rejects sources that share a local_path and repos that would
+// be cloned into the same directory. Only repos known from the config are
+// checked; repos listed by a provider API are checked again during sync.
+func (c *Config) validatePaths() error {
+	sourceByPath := make(map[string]string)
+	type owner struct{ source, repo, subpath string }
+	repoByPath := make(map[string]owner)
+
+	for _, src := range c.Sources {
+		resolved := src
+		resolved.LocalPath = filepath.Clean(expandPath(src.LocalPath))
+
+		if other, ok := sourceByPath[resolved.LocalPath]; ok {
+			return fmt.Errorf("sources %q and %q share local_path %s; each would treat the other's repos as orphans", other, src.Name, resolved.LocalPath)
+		}
+		sourceByPath[resolved.LocalPath] = src.Name
+
+		if c.SymlinkDir != "" && filepath.Clean(expandPath(c.SymlinkDir)) == resolved.LocalPath {
+			return fmt.Errorf("symlink_dir must differ from the local_path of source %q", src.Name)
+		}
+
+		if src.Strategy != StrategyManual {
+			continue
+		}
+		for _, repo := range src.Repos {
+			if repo.LocalPath != "" {
+				repo.LocalPath = expandPath(repo.LocalPath)
+			}
+			path := filepath.Clean(resolved.RepoPath(repo))
+			if other, ok := repoByPath[path]; ok {
+				if other.source == src.Name && other.repo == repo.Name {
+					return fmt.Errorf("source %q: subpaths %q and %q of repo %q both check out into %s; give one of them a local_path", src.Name, other.subpath, repo.Subpath, repo.Name, path)
+				}
+				return fmt.Errorf("repos %q (source %q) and %q (source %q) both resolve to %s", other.repo, other.source, repo.Name, src.Name, path)
+			}
+			repoByPath[path] = owner{source: src.Name, repo: repo.Name, subpath: repo.Subpath}
+		}
+	}
+
 	return nil
 }
 
 func (c *Config) ExpandPaths() {
+	if c.SymlinkDir != "" {
+		c.SymlinkDir = expandPath(c.SymlinkDir)
+	}
 	for i := range c.Sources {
 		c.Sources[i].LocalPath = expandPath(c.Sources[i].LocalPath)
+		if c.Sources[i].StaticStrategy.List != "" && !IsRemote(c.Sources[i].StaticStrategy.List) {
+			c.Sources[i].StaticStrategy.List = expandPath(c.Sources[i].StaticStrategy.List)
+		}
 		if c.Sources[i].PrivateKey != "" {
 			c.Sources[i].PrivateKey = expandPath(c.Sources[i].PrivateKey)
 		}
 		if c.Sources[i].SSHOptions.PrivateKey != "" {
 			c.Sources[i].SSHOptions.PrivateKey = expandPath(c.Sources[i].SSHOptions.PrivateKey)
 		}
+		if c.Sources[i].MirrorOptions.PrivateKey != "" {
+			c.Sources[i].MirrorOptions.PrivateKey = expandPath(c.Sources[i].MirrorOptions.PrivateKey)
+		}
+		if sig := c.Sources[i].Signatures; sig != nil && sig.AllowedSigners != "" {
+			sig.AllowedSigners = expandPath(sig.AllowedSigners)
+		}
 		for j := range c.Sources[i].Repos {
 			if c.Sources[i].Repos[j].LocalPath != "" {
 				c.Sources[i].Repos[j].LocalPath = expandPath(c.Sources[i].Repos[j].LocalPath)
 			}
+			if c.Sources[i].Repos[j].SSHOptions.PrivateKey != "" {
+				c.Sources[i].Repos[j].SSHOptions.PrivateKey = expandPath(c.Sources[i].Repos[j].SSHOptions.PrivateKey)
+			}
+			if c.Sources[i].Repos[j].Link != "" {
+				c.Sources[i].Repos[j].Link = expandPath(c.Sources[i].Repos[j].Link)
+			}
 		}
 	}
 }
@@ -398,14 +944,67 @@ func expandPath(path string) string {
 	return path
 }
 
+// GetTags returns the tag mode as passed to git: "all", "none", or "" for
+// git's default of reachable tags
+func (s *Source) GetTags() string {
+	if s.Tags == TagsReachable {
+		return ""
+	}
+	return string(s.Tags)
+}
+
+// GetMaxRepoSize returns max_repo_size in bytes, or 0 for no limit
+func (s *Source) GetMaxRepoSize() int64 {
+	size, err := ParseSize(s.MaxRepoSize)
+	if err != nil {
+		return 0
+	}
+	return size
+}
+
+// ParseSize parses a size in bytes with an optional KB, MB, GB, or TB suffix
+// (powers of 1024), e.g. "500MB" or "2GB"
+func ParseSize(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	n, unit := strings.ToUpper(strings.TrimSpace(s)), int64(1)
+	for _, suffix := range []struct {
+		name string
+		unit int64
+	}{{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if trimmed, ok := strings.CutSuffix(n, suffix.name); ok {
+			n, unit = strings.TrimSpace(trimmed), suffix.unit
+			break
+		}
+	}
+
+	value, err := strconv.ParseFloat(n, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size: %s", s)
+	}
+	return int64(value * float64(unit)), nil
+}
+
 // GetBranch returns the configured branch, or empty string to use remote default
 func (s *Source) GetBranch() string {
 	return s.Branch
 }
 
 func (s *Source) GetRepoURL(repo string) string {
+	// Full clone URLs are used as-is
+	if IsCloneURL(repo) {
+		return repo
+	}
+
 	host := s.GetHost()
 
+	// HTTPS leaves authentication to git's credential helpers
+	if s.Protocol == ProtocolHTTPS {
+		return fmt.Sprintf("https://%s/%s.git", host, repo)
+	}
+
 	// If custom SSH port is specified, use ssh:// URL format
 	if s.SSHOptions.Port > 0 {
 		return fmt.Sprintf("ssh://git@%s:%d/%s.git", host, s.SSHOptions.Port, repo)
@@ -415,6 +1014,157 @@ func (s *Source) GetRepoURL(repo string) string {
 	return fmt.Sprintf("git@%s:%s.git", host, repo)
 }
 
+// GetMirrorURL returns the secondary remote URL for a repo when mirror_to is set.
+// The repo keeps its name and is pushed under the mirror owner, e.g.
+// mirror_to "gitea.backup.lan/mirrors" turns "user/repo" into
+// "git@gitea.backup.lan:mirrors/repo.git".
+func (s *Source) GetMirrorURL(repo string) string {
+	if s.MirrorTo == "" {
+		return ""
+	}
+
+	host, owner := s.MirrorTo, ""
+	if idx := strings.Index(s.MirrorTo, "/"); idx != -1 {
+		host, owner = s.MirrorTo[:idx], s.MirrorTo[idx+1:]
+	}
+
+	name := repoBaseName(repo)
+	if owner != "" {
+		name = owner + "/" + name
+	}
+	return fmt.Sprintf("git@%s:%s.git", host, name)
+}
+
+// PathVars are the fields available to path_template
+type PathVars struct {
+	Host  string
+	Owner string
+	Repo  string
+}
+
+// layoutTemplates are the path templates behind the named layouts
+var layoutTemplates = map[Layout]string{
+	LayoutFlat:      "{{ .Repo }}",
+	LayoutOwner:     "{{ .Owner }}/{{ .Repo }}",
+	LayoutHostOwner: "{{ .Host }}/{{ .Owner }}/{{ .Repo }}",
+}
+
+// pathTemplate returns the source's path_template, or the template of its layout
+func (s *Source) pathTemplate() string {
+	if s.PathTemplate != "" {
+		return s.PathTemplate
+	}
+	if tmpl, ok := layoutTemplates[s.Layout]; ok {
+		return tmpl
+	}
+	// Repos of different orgs go into a directory per org
+	if s.AllOrgs() {
+		return layoutTemplates[LayoutOwner]
+	}
+	return layoutTemplates[LayoutFlat]
+}
+
+// renderPath executes the source's path template
+func (s *Source) renderPath(vars PathVars) (string, error) {
+	tmpl, err := template.New("path").Option("missingkey=error").Parse(s.pathTemplate())
+	if err != nil {
+		return "", err
+	}
+
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return "", err
+	}
+	return filepath.Clean(strings.TrimSpace(buf.String())), nil
+}
+
+// LayoutDepth returns how many directory levels below local_path a clone lives
+func (s *Source) LayoutDepth() int {
+	dir, err := s.renderPath(PathVars{Host: "host", Owner: "owner", Repo: "repo"})
+	if err != nil {
+		return 1
+	}
+	return len(strings.Split(filepath.ToSlash(dir), "/"))
+}
+
+// GetScanDepth returns how deep to search local_path for repos: scan_depth,
+// but never less than the layout needs
+func (s *Source) GetScanDepth() int {
+	return max(s.ScanDepth, s.LayoutDepth())
+}
+
+// RepoDir returns the directory of a repo relative to local_path according
+// to the source's layout or path_template, e.g. "repo" or "owner/repo"
+func (s *Source) RepoDir(repo string) string {
+	vars := PathVars{Host: s.GetHost(), Owner: s.GetUserOrOrg(), Repo: repoBaseName(repo)}
+	if IsCloneURL(repo) {
+		vars.Host, vars.Owner = splitCloneURL(repo)
+	} else if idx := strings.LastIndex(repo, "/"); idx != -1 {
+		vars.Owner = repo[:idx]
+	}
+	if vars.Owner == "" {
+		vars.Owner = "_"
+	}
+
+	dir, err := s.renderPath(vars)
+	if err != nil {
+		// Validate rejects broken templates, so this only happens for unvalidated configs
+		return vars.Repo
+	}
+	return dir
+}
+
+// RepoPath returns where a repo entry is cloned: its local_path override,
+// or its layout directory under the source's local_path
+func (s *Source) RepoPath(repo RepoEntry) string {
+	if repo.HasCustomLocalPath() {
+		return repo.LocalPath
+	}
+	return filepath.Join(s.LocalPath, s.RepoDir(repo.Name))
+}
+
+// validatePathTemplate checks that a path template parses and renders to a
+// relative path that stays inside local_path and depends on the repo name
+func validatePathTemplate(s *Source) error {
+	dir, err := s.renderPath(PathVars{Host: "host", Owner: "owner", Repo: "repo"})
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(s.PathTemplate, ".Repo") {
+		return fmt.Errorf("must contain {{ .Repo }}")
+	}
+	if filepath.IsAbs(dir) || dir == "." || strings.HasPrefix(dir, "..") {
+		return fmt.Errorf("must render to a relative path inside local_path, got %q", dir)
+	}
+	return nil
+}
+
+// splitCloneURL extracts the host and owner from an SSH, scp-like, or HTTPS clone URL
+func splitCloneURL(repo string) (string, string) {
+	var host, path string
+	if idx := strings.Index(repo, "://"); idx != -1 {
+		rest := repo[idx+3:]
+		host, path, _ = strings.Cut(rest, "/")
+		if at := strings.LastIndex(host, "@"); at != -1 {
+			host = host[at+1:]
+		}
+		if colon := strings.Index(host, ":"); colon != -1 {
+			host = host[:colon]
+		}
+	} else {
+		host, path, _ = strings.Cut(repo, ":")
+		if at := strings.LastIndex(host, "@"); at != -1 {
+			host = host[at+1:]
+		}
+	}
+
+	owner := ""
+	if idx := strings.LastIndex(strings.TrimSuffix(path, "/"), "/"); idx != -1 {
+		owner = path[:idx]
+	}
+	return host, owner
+}
+
 // GetPrivateKey returns the SSH private key path, checking both locations
 func (s *Source) GetPrivateKey() string {
 	// Prefer ssh_options.private_key over deprecated top-level private_key
@@ -424,6 +1174,54 @@ func (s *Source) GetPrivateKey() string {
 	return s.PrivateKey
 }
 
+// PrivateKeyFor returns the SSH private key for the repo cloned at path,
+// preferring the repo's own ssh_options over the source's
+func (s *Source) PrivateKeyFor(path string) string {
+	for _, repo := range s.Repos {
+		if repo.SSHOptions.PrivateKey != "" && s.RepoPath(repo) == path {
+			return repo.SSHOptions.PrivateKey
+		}
+	}
+	return s.GetPrivateKey()
+}
+
+// SubpathFor returns the directory the repo cloned at path checks out
+// alone, or "" for the whole repo
+func (s *Source) SubpathFor(path string) string {
+	for _, repo := range s.Repos {
+		if repo.Subpath != "" && s.RepoPath(repo) == path {
+			return repo.Subpath
+		}
+	}
+	return ""
+}
+
+// PriorityFor returns the priority of the repo cloned at path: its own when
+// set, otherwise the source's
+func (s *Source) PriorityFor(path string) int {
+	for _, repo := range s.Repos {
+		if repo.Priority != 0 && s.RepoPath(repo) == path {
+			return repo.Priority
+		}
+	}
+	return s.Priority
+}
+
+// UpstreamFor returns the clone URL of the configured upstream of the repo
+// cloned at path, or "" when it has none
+func (s *Source) UpstreamFor(path string) string {
+	for _, repo := range s.Repos {
+		if repo.Upstream == "" || s.RepoPath(repo) != path {
+			continue
+		}
+		if IsCloneURL(repo.Upstream) {
+			return repo.Upstream
+		}
+		return s.GetRepoURL(repo.Upstream)
+	}
+	return ""
+}
+
 // GetHost extracts the host from the source field
 func (s *Source) GetHost() string {
 	host := s.Source
@@ -433,14 +1231,25 @@ func (s *Source) GetHost() string {
 	return host
 }
 
-// GetUserOrOrg extracts the user/org from the source field
+// GetUserOrOrg extracts the user/org from the source field. It's empty for
+// sources of a whole host and for AllOrgs sources.
 func (s *Source) GetUserOrOrg() string {
+	if s.AllOrgs() {
+		return ""
+	}
 	if idx := strings.Index(s.Source, "/"); idx != -1 {
 		return s.Source[idx+1:]
 	}
 	return ""
 }
 
+// AllOrgs reports whether the source covers every org the token belongs
+// to, written as "github.com/*"
+func (s *Source) AllOrgs() bool {
+	_, owner, ok := strings.Cut(s.Source, "/")
+	return ok && owner == "*"
+}
+
 // GetConnectorType returns the connector type for this source
 func (s *Source) GetConnectorType() connector.ConnectorType {
 	// If explicitly specified, use that
@@ -452,13 +1261,162 @@ func (s *Source) GetConnectorType() connector.ConnectorType {
 			return connector.ConnectorGitea
 		case "bitbucket":
 			return connector.ConnectorBitbucket
+		case "plugin":
+			return connector.ConnectorPlugin
 		}
 	}
 	// Otherwise, auto-detect from host
 	return connector.DetectType(s.GetHost())
 }
 
+// GetToken returns the API token for this source's provider and host
+func (s *Source) GetToken() string {
+	return connector.GetTokenForHost(s.GetConnectorType(), s.GetHost())
+}
+
+// NewConnector creates the API connector for this source
+func (s *Source) NewConnector(token string) (connector.Connector, error) {
+	connType := s.GetConnectorType()
+	if connType == connector.ConnectorPlugin {
+		return connector.NewPluginConnector(s.Plugin.Command, s.GetHost(), token), nil
+	}
+	return connector.New(connType, s.GetHost(), token)
+}
+
+// FindSource returns the source with the given name, or nil if none matches
+func (c *Config) FindSource(name string) *Source {
+	for i := range c.Sources {
+		if c.Sources[i].Name == name {
+			return &c.Sources[i]
+		}
+	}
+	return nil
+}
+
+// FindAlias returns the repo entry with the given alias, ignoring case, and
+// its source, or nil when no repo has it
+func (c *Config) FindAlias(alias string) (*Source, *RepoEntry) {
+	if alias == "" {
+		return nil, nil
+	}
+	for i := range c.Sources {
+		for j := range c.Sources[i].Repos {
+			if strings.EqualFold(c.Sources[i].Repos[j].Alias, alias) {
+				return &c.Sources[i], &c.Sources[i].Repos[j]
+			}
+		}
+	}
+	return nil, nil
+}
+
+// Warnings reports config problems that don't stop a sync, like a repo
+// listed under more than one source on the same host
+func (c *Config) Warnings() []string {
+	var warnings []string
+	sourceOf := make(map[string]string)
+	for _, src := range c.Sources {
+		host := strings.ToLower(src.GetHost())
+		for _, repo := range src.Repos {
+			key := repoKey(repo.Name)
+			if !IsCloneURL(repo.Name) {
+				key = host + "/" + key
+			}
+			if first, ok := sourceOf[key]; ok && first != src.Name {
+				warnings = append(warnings, fmt.Sprintf("repo %q is listed in sources %q and %q", NormalizeRepoName(repo.Name), first, src.Name))
+				continue
+			}
+			sourceOf[key] = src.Name
+		}
+	}
+	return warnings
+}
+
+// normalizeRepos normalizes the names of all repo entries and, with
+// format.sort_repos, sorts each repo list and drops duplicates, keeping the
+// first entry
+func (c *Config) normalizeRepos() {
+	for i := range c.Sources {
+		src := &c.Sources[i]
+		for j := range src.Repos {
+			src.Repos[j].Name = NormalizeRepoName(src.Repos[j].Name)
+		}
+		if !c.Format.SortRepos {
+			continue
+		}
+
+		seen := make(map[string]bool, len(src.Repos))
+		unique := src.Repos[:0]
+		for _, repo := range src.Repos {
+			if key := entryKey(repo); !seen[key] {
+				seen[key] = true
+				unique = append(unique, repo)
+			}
+		}
+		sort.SliceStable(unique, func(a, b int) bool {
+			return entryKey(unique[a]) < entryKey(unique[b])
+		})
+		src.Repos = unique
+	}
+}
+
+// Save writes the config to path under the config lock. Fails with
+// ErrModified if the file changed on disk since the config was loaded. A
+// config merged from several files is written back to those files instead.
 func (c *Config) Save(path string) error {
+	if c.parts != nil {
+		return c.saveParts((*Config).Save)
+	}
+	if IsRemote(path) {
+		return fmt.Errorf("cannot save remote config")
+	}
+
+	unlock, err := Lock(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if c.loaded != nil && c.loaded.path == path {
+		modified, err := c.loaded.modified()
+		if err != nil {
+			return err
+		}
+		if modified {
+			return ErrModified
+		}
+	}
+
+	return c.write(path)
+}
+
+// Clone returns a copy of the config whose sources and repo lists can be
+// edited without changing c
+func (c *Config) Clone() *Config {
+	clone := *c
+	clone.Sources = append([]Source(nil), c.Sources...)
+	for i := range clone.Sources {
+		clone.Sources[i].Repos = append([]RepoEntry(nil), c.Sources[i].Repos...)
+	}
+	return &clone
+}
+
+// Render returns the YAML that Save writes for the config, without
+// changing it
+func (c *Config) Render() ([]byte, error) {
+	clone := c.Clone()
+	clone.normalizeRepos()
+	data, err := yaml.Marshal(clone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	return data, nil
+}
+
+// write marshals the config and replaces the file at path with it. The
+// caller holds the lock.
+func (c *Config) write(path string) error {
+	c.normalizeRepos()
+
 	data, err := yaml.Marshal(c)
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
@@ -469,10 +1427,29 @@ func (c *Config) Save(path string) error {
 		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 
-	if err := os.WriteFile(path, data, 0644); err != nil {
+	// Keep the previous content for ag undo
+	before, err := os.ReadFile(path)
+	if err != nil {
+		before = nil
+	}
+
+	// Write to a temp file and rename, so readers never see half a config
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
 		return fmt.Errorf("failed to write config file: %w", err)
 	}
+	if !bytes.Equal(before, data) {
+		journal.RecordConfig(path, before, data)
+	}
 
+	kind := mainFile
+	if c.loaded != nil {
+		kind = c.loaded.kind
+	}
+	c.loaded = &loadedFile{path: path, data: data, exists: true, kind: kind}
 	return nil
 }
 
@@ -527,6 +1504,26 @@ func CreateDefault(path string) error {
 	return nil
 }
 
+// ReadStaticList reads a newline-delimited list of clone URLs from a local file,
+// HTTP/HTTPS URL, or SSH path. Blank lines and lines starting with # are ignored.
+func ReadStaticList(location string) ([]string, error) {
+	data, err := readConfig(location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read repo list: %w", err)
+	}
+
+	var repos []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		repos = append(repos, line)
+	}
+
+	return repos, nil
+}
+
 // ValidateFile validates a config file without loading it fully
 func ValidateFile(path string) error {
 	_, err := Load(path)
@@ -538,12 +1535,16 @@ func (c *Config) ValidateCredentials() []string {
 	var warnings []string
 
 	for _, src := range c.Sources {
-		if src.Strategy == StrategyManual {
+		if src.Strategy == StrategyManual || src.Strategy == StrategyStatic {
 			continue
 		}
 
 		connType := src.GetConnectorType()
-		token := connector.GetToken(connType)
+		// Plugins handle their own authentication
+		if connType == connector.ConnectorPlugin {
+			continue
+		}
+		token := src.GetToken()
 		if token == "" {
 			envVar := connector.GetEnvVarName(connType)
 			warnings = append(warnings, fmt.Sprintf(
@@ -0,0 +1,193 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrModified is returned by Save when the config file was changed by
+// someone else since it was loaded
+var ErrModified = errors.New("config file changed on disk since it was loaded")
+
+const (
+	lockTimeout = 10 * time.Second
+	lockPoll    = 100 * time.Millisecond
+)
+
+// loadedFile is the local config file as Load read it
+type loadedFile struct {
+	path   string
+	data   []byte
+	exists bool
+	kind   fileKind
+}
+
+// modified reports whether the file no longer has the contents it was loaded with
+func (f *loadedFile) modified() (bool, error) {
+	data, err := os.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return f.exists, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read config file: %w", err)
+	}
+	return !f.exists || !bytes.Equal(data, f.data), nil
+}
+
+// Lock takes the lock on a local config file, held by everything that edits
+// the file: saves, merges, and ag config while the editor is open. Waits up
+// to 10s for another ag process to release it. Returns the unlock function.
+func Lock(path string) (func(), error) {
+	lockPath := path + ".lock"
+	deadline := time.Now().Add(lockTimeout)
+
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to lock config: %w", err)
+		}
+
+		if time.Now().After(deadline) {
+			holder := "another ag process"
+			if data, err := os.ReadFile(lockPath); err == nil {
+				if pid, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil {
+					holder = fmt.Sprintf("ag process %d", pid)
+				}
+			}
+			return nil, fmt.Errorf("config is locked by %s, remove %s if it isn't running", holder, lockPath)
+		}
+		time.Sleep(lockPoll)
+	}
+}
+
+// Merge saves the config's repo list changes on top of the file as it is
+// now on disk, for when Save failed with ErrModified. The repo entries added
+// to, changed in, and removed from each manual source since the config was
+// loaded are applied to a fresh load of the file, keeping everyone else's
+// edits. The config
+// itself is left as it is, so a later Save merges again.
+func (c *Config) Merge(path string) error {
+	if c.parts != nil {
+		return c.saveParts((*Config).Merge)
+	}
+	if c.loaded == nil || c.loaded.path != path {
+		return c.Save(path)
+	}
+
+	unlock, err := Lock(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	base, err := parseConfig(path, c.loaded.data, c.loaded.exists, c.loaded.kind)
+	if err != nil {
+		return fmt.Errorf("failed to parse config as loaded: %w", err)
+	}
+	fresh, err := loadFile(path, c.loaded.kind)
+	if err != nil {
+		return fmt.Errorf("failed to reload config: %w", err)
+	}
+
+	changes := c.repoChanges(base)
+	for _, change := range changes {
+		src := fresh.FindSource(change.source)
+		if src == nil || src.Strategy != StrategyManual {
+			return fmt.Errorf("source %q was changed on disk, can't apply the changes to it", change.source)
+		}
+	}
+	for _, change := range changes {
+		fresh.FindSource(change.source).applyRepoChange(change)
+	}
+
+	return fresh.write(path)
+}
+
+// repoChange is what changed in a manual source's repo list since the
+// config was loaded. Entries are carried whole, so fields like local_path
+// and alias survive the merge.
+type repoChange struct {
+	source string
+	set    []RepoEntry // entries added, or whose fields changed
+	remove []string    // entryKey of each entry removed
+}
+
+// repoChanges returns the repo entries added to, changed in, and removed
+// from the config's manual sources compared to base
+func (c *Config) repoChanges(base *Config) []repoChange {
+	var changes []repoChange
+	for _, src := range c.Sources {
+		if src.Strategy != StrategyManual {
+			continue
+		}
+
+		was := make(map[string]RepoEntry)
+		if old := base.FindSource(src.Name); old != nil {
+			for _, repo := range old.Repos {
+				was[entryKey(repo)] = repo
+			}
+		}
+		now := make(map[string]bool, len(src.Repos))
+		for _, repo := range src.Repos {
+			now[entryKey(repo)] = true
+		}
+
+		change := repoChange{source: src.Name}
+		for _, repo := range src.Repos {
+			if old, ok := was[entryKey(repo)]; !ok || old != repo {
+				change.set = append(change.set, repo)
+			}
+		}
+		for key := range was {
+			if !now[key] {
+				change.remove = append(change.remove, key)
+			}
+		}
+		if len(change.set) > 0 || len(change.remove) > 0 {
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+// applyRepoChange replaces the source's entries that the change sets, in
+// place, removes the ones it removes, and appends the new ones
+func (s *Source) applyRepoChange(change repoChange) {
+	set := make(map[string]RepoEntry, len(change.set))
+	for _, repo := range change.set {
+		set[entryKey(repo)] = repo
+	}
+	drop := make(map[string]bool, len(change.remove))
+	for _, key := range change.remove {
+		drop[key] = true
+	}
+
+	repos := s.Repos[:0]
+	for _, repo := range s.Repos {
+		key := entryKey(repo)
+		if drop[key] {
+			continue
+		}
+		if entry, ok := set[key]; ok {
+			repo = entry
+			delete(set, key)
+		}
+		repos = append(repos, repo)
+	}
+	for _, repo := range change.set {
+		if _, ok := set[entryKey(repo)]; ok {
+			repos = append(repos, repo)
+		}
+	}
+	s.Repos = repos
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// LoadFiles loads one or more config files and merges them in order, on top
+// of the system config when there is one. The first file is the user's main
+// config, with its sources.d. Each later file's sources are appended, and its
+// top-level settings replace those of the earlier files when set, so a
+// machine can add to a shared config without copying it. The merged config
+// is validated as a whole.
+func LoadFiles(paths []string) (*Config, error) {
+	system := hasSystemConfig()
+	if len(paths) == 1 && !system {
+		return Load(paths[0])
+	}
+
+	merged := &Config{}
+	add := func(path string, kind fileKind) error {
+		part, err := loadFile(path, kind)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		merged.overlay(part)
+		merged.parts = append(merged.parts, part)
+		merged.partSources = append(merged.partSources, len(part.Sources))
+		return nil
+	}
+
+	if system {
+		if err := add(SystemConfigPath(), layerFile); err != nil {
+			return nil, err
+		}
+	}
+	for i, path := range paths {
+		kind := overlayFile
+		if i == 0 {
+			kind = layerFile
+		}
+		if err := add(path, kind); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := merged.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	return merged, nil
+}
+
+// overlay appends o's sources to c and copies o's other settings that are set
+func (c *Config) overlay(o *Config) {
+	for _, src := range o.Sources {
+		// Own copy of the repo list, so edits show up as changes to the part
+		src.Repos = append([]RepoEntry(nil), src.Repos...)
+		c.Sources = append(c.Sources, src)
+	}
+
+	cv, ov := reflect.ValueOf(c).Elem(), reflect.ValueOf(o).Elem()
+	for i := 0; i < cv.NumField(); i++ {
+		field := cv.Type().Field(i)
+		if !field.IsExported() || field.Name == "Sources" {
+			continue
+		}
+		if value := ov.Field(i); !value.IsZero() {
+			cv.Field(i).Set(value)
+		}
+	}
+}
+
+// saveParts hands each merged file its sources back and saves it with save
+func (c *Config) saveParts(save func(*Config, string) error) error {
+	total := 0
+	for _, n := range c.partSources {
+		total += n
+	}
+	if total != len(c.Sources) {
+		return fmt.Errorf("sources were added or removed, can't tell which config file they belong to")
+	}
+
+	start := 0
+	for i, part := range c.parts {
+		n := c.partSources[i]
+		sources := c.Sources[start : start+n]
+		start += n
+		if part.loaded == nil || reflect.DeepEqual(part.Sources, sources) {
+			continue // remote or unchanged, nothing to save
+		}
+		part.Sources = append([]Source(nil), sources...)
+		if err := save(part, part.loaded.path); err != nil {
+			return fmt.Errorf("%s: %w", part.loaded.path, err)
+		}
+	}
+	return nil
+}
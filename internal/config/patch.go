@@ -0,0 +1,112 @@
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Patch is a reviewable set of repo list changes for manual sources,
+// written by "ag diff --output-file" and consumed by "ag apply"
+type Patch struct {
+	Sources []SourcePatch `yaml:"sources"`
+}
+
+// SourcePatch lists the repos to add to and remove from one source
+type SourcePatch struct {
+	Source string   `yaml:"source"`
+	Add    []string `yaml:"add,omitempty"`
+	Remove []string `yaml:"remove,omitempty"`
+}
+
+// IsEmpty reports whether the patch changes nothing
+func (p *Patch) IsEmpty() bool {
+	for _, sp := range p.Sources {
+		if len(sp.Add) > 0 || len(sp.Remove) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Marshal encodes the patch as YAML
+func (p *Patch) Marshal() ([]byte, error) {
+	data, err := yaml.Marshal(p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal patch: %w", err)
+	}
+	return append([]byte("# autogitter config patch, apply with: ag apply <file>\n"), data...), nil
+}
+
+// LoadPatch reads a patch file
+func LoadPatch(path string) (*Patch, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read patch: %w", err)
+	}
+
+	var p Patch
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse patch: %w", err)
+	}
+	return &p, nil
+}
+
+// Apply adds and removes repos in the config's manual sources. Repos that are
+// already present (or already absent) are left alone, so applying a patch
+// twice is harmless. Returns the number of repos added and removed.
+func (c *Config) Apply(p *Patch) (int, int, error) {
+	// Check every source first so a bad patch changes nothing
+	for _, sp := range p.Sources {
+		src := c.FindSource(sp.Source)
+		if src == nil {
+			return 0, 0, fmt.Errorf("source not found: %s", sp.Source)
+		}
+		if src.Strategy != StrategyManual {
+			return 0, 0, fmt.Errorf("source %q: only manual sources can be patched", sp.Source)
+		}
+	}
+
+	added, removed := 0, 0
+	for _, sp := range p.Sources {
+		src := c.FindSource(sp.Source)
+
+		drop := make(map[string]bool)
+		for _, name := range sp.Remove {
+			drop[entryKey(repoEntryFromName(name))] = true
+		}
+		kept := src.Repos[:0]
+		for _, repo := range src.Repos {
+			if drop[entryKey(repo)] {
+				removed++
+				continue
+			}
+			kept = append(kept, repo)
+		}
+		src.Repos = kept
+
+		for _, name := range sp.Add {
+			if src.HasRepo(name) {
+				continue
+			}
+			src.Repos = append(src.Repos, repoEntryFromName(NormalizeRepoName(name)))
+			added++
+		}
+	}
+
+	return added, removed, nil
+}
+
+// HasRepo reports whether the source lists the repo, ignoring case and a
+// ".git" suffix. A name with a subpath, "owner/repo//dir", only matches the
+// entry for that directory.
+func (s *Source) HasRepo(name string) bool {
+	key := entryKey(repoEntryFromName(name))
+	for _, repo := range s.Repos {
+		if entryKey(repo) == key {
+			return true
+		}
+	}
+	return false
+}
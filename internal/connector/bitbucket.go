@@ -3,11 +3,15 @@ package connector
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
+
+	"github.com/arch-err/autogitter/internal/errs"
 )
 
 // BitbucketConnector implements the Connector interface for Bitbucket Cloud
@@ -32,10 +36,13 @@ type BitbucketServerRepo struct {
 	} `json:"project"`
 }
 
-// BitbucketRepoResponse represents the paginated response from Cloud
+// BitbucketRepoResponse represents the paginated response from Cloud. Size,
+// the total number of repos, is optional.
 type BitbucketRepoResponse struct {
-	Values []BitbucketRepo `json:"values"`
-	Next   string          `json:"next"`
+	Values  []BitbucketRepo `json:"values"`
+	Next    string          `json:"next"`
+	Size    int             `json:"size"`
+	Pagelen int             `json:"pagelen"`
 }
 
 // BitbucketServerRepoResponse represents the paginated response from Server
@@ -92,7 +99,7 @@ func (b *BitbucketConnector) doRequest(ctx context.Context, method, url string)
 		req.Header.Set("Authorization", "Bearer "+b.token)
 	}
 
-	return b.client.Do(req)
+	return send(b.client, req)
 }
 
 // TestConnection verifies the credentials work
@@ -111,7 +118,7 @@ func (b *BitbucketConnector) TestConnection(ctx context.Context) error {
 	defer resp.Body.Close()
 
 	if resp.StatusCode == 401 {
-		return fmt.Errorf("authentication failed: invalid credentials")
+		return &errs.AuthError{Err: errors.New("invalid credentials")}
 	}
 	if resp.StatusCode != 200 {
 		body, _ := io.ReadAll(resp.Body)
@@ -121,50 +128,146 @@ func (b *BitbucketConnector) TestConnection(ctx context.Context) error {
 	return nil
 }
 
+// CurrentUser returns the username the credentials belong to. Bitbucket
+// Server reports it in the X-AUSERNAME header of any authenticated response.
+func (b *BitbucketConnector) CurrentUser(ctx context.Context) (string, error) {
+	var url string
+	if b.host == "bitbucket.org" {
+		url = fmt.Sprintf("%s/user", b.apiURL())
+	} else {
+		url = fmt.Sprintf("%s/application-properties", b.apiURL())
+	}
+	resp, err := b.doRequest(ctx, "GET", url)
+	if err != nil {
+		return "", fmt.Errorf("failed to connect: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 401 {
+		return "", &errs.AuthError{Err: errors.New("invalid credentials")}
+	}
+	if resp.StatusCode != 200 {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
+	}
+
+	if b.host != "bitbucket.org" {
+		return resp.Header.Get("X-AUSERNAME"), nil
+	}
+
+	var user BitbucketUser
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return "", fmt.Errorf("failed to decode user: %w", err)
+	}
+	if user.Username == "" {
+		return user.DisplayName, nil
+	}
+	return user.Username, nil
+}
+
 // ListRepos returns all repos for the configured workspace/user
 func (b *BitbucketConnector) ListRepos(ctx context.Context, workspace string) ([]string, error) {
 	if b.host == "bitbucket.org" {
-		return b.listReposCloud(ctx, workspace)
+		return b.listReposCloud(ctx, workspace, "")
 	}
 	return b.listReposServer(ctx, workspace)
 }
 
-// listReposCloud fetches repos from Bitbucket Cloud
-func (b *BitbucketConnector) listReposCloud(ctx context.Context, workspace string) ([]string, error) {
-	var repos []string
-	url := fmt.Sprintf("%s/repositories/%s?pagelen=100", b.apiURL(), workspace)
-
-	for url != "" {
-		resp, err := b.doRequest(ctx, "GET", url)
-		if err != nil {
-			return nil, fmt.Errorf("failed to fetch repos: %w", err)
-		}
+// ListProjectRepos returns the repos of one project of a Bitbucket Cloud
+// workspace. Bitbucket Server sources name the project as their owner instead.
+func (b *BitbucketConnector) ListProjectRepos(ctx context.Context, workspace, project string) ([]string, error) {
+	if b.host != "bitbucket.org" {
+		return nil, fmt.Errorf("project is only supported for Bitbucket Cloud, use %s/%s as the source instead", b.host, project)
+	}
+	return b.listReposCloud(ctx, workspace, fmt.Sprintf("project.key=%q", project))
+}
 
-		if resp.StatusCode != 200 {
-			body, _ := io.ReadAll(resp.Body)
-			resp.Body.Close()
-			return nil, fmt.Errorf("failed to fetch repos: %s", string(body))
+// listReposCloud fetches repos from Bitbucket Cloud, filtered by query
+// unless it's empty. When the first page reports the total, the other pages
+// are fetched at once.
+func (b *BitbucketConnector) listReposCloud(ctx context.Context, workspace, query string) ([]string, error) {
+	pageURL := func(page int) string {
+		u := fmt.Sprintf("%s/repositories/%s?pagelen=100&page=%d", b.apiURL(), workspace, page)
+		if query != "" {
+			u += "&q=" + url.QueryEscape(query)
 		}
+		return u
+	}
 
-		var response BitbucketRepoResponse
-		if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-			resp.Body.Close()
-			return nil, fmt.Errorf("failed to decode repos: %w", err)
+	first, err := b.fetchCloudPage(ctx, pageURL(1))
+	if err != nil {
+		return nil, err
+	}
+	repos := cloudRepoNames(first)
+
+	if first.Size > 0 && first.Pagelen > 0 {
+		last := (first.Size + first.Pagelen - 1) / first.Pagelen
+		rest, err := fetchPages(ctx, 2, last, func(ctx context.Context, page int) ([]string, error) {
+			response, err := b.fetchCloudPage(ctx, pageURL(page))
+			if err != nil {
+				return nil, err
+			}
+			return cloudRepoNames(response), nil
+		})
+		if err != nil {
+			return nil, err
 		}
-		resp.Body.Close()
+		return append(repos, rest...), nil
+	}
 
-		for _, repo := range response.Values {
-			repos = append(repos, repo.FullName)
+	for next := first.Next; next != ""; {
+		response, err := b.fetchCloudPage(ctx, next)
+		if err != nil {
+			return nil, err
 		}
-		url = response.Next
+		repos = append(repos, cloudRepoNames(response)...)
+		next = response.Next
 	}
 
 	return repos, nil
 }
 
+// fetchCloudPage fetches a single page of Bitbucket Cloud repos
+func (b *BitbucketConnector) fetchCloudPage(ctx context.Context, url string) (*BitbucketRepoResponse, error) {
+	resp, err := b.doRequest(ctx, "GET", url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch repos: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, apiError(resp, "failed to fetch repos")
+	}
+
+	var response BitbucketRepoResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode repos: %w", err)
+	}
+	return &response, nil
+}
+
+// cloudRepoNames returns the full names of a page of Bitbucket Cloud repos
+func cloudRepoNames(response *BitbucketRepoResponse) []string {
+	var repos []string
+	for _, repo := range response.Values {
+		repos = append(repos, repo.FullName)
+	}
+	return repos
+}
+
+// ListAllRepos returns every repo the token can read on a Bitbucket Server,
+// across all projects, as "PROJECT/slug" or "~user/slug"
+func (b *BitbucketConnector) ListAllRepos(ctx context.Context) ([]string, error) {
+	if b.host == "bitbucket.org" {
+		return nil, fmt.Errorf("bitbucket cloud sources must include a workspace (e.g., bitbucket.org/workspace)")
+	}
+	return b.fetchServerRepos(ctx, fmt.Sprintf("%s/repos", b.apiURL()), func(repo BitbucketServerRepo) string {
+		return repo.Project.Key + "/" + repo.Slug
+	})
+}
+
 // listReposServer fetches repos from Bitbucket Server
 func (b *BitbucketConnector) listReposServer(ctx context.Context, workspace string) ([]string, error) {
-	var repos []string
 	var baseURL string
 
 	// Check if user (~username) or project
@@ -175,6 +278,17 @@ func (b *BitbucketConnector) listReposServer(ctx context.Context, workspace stri
 		baseURL = fmt.Sprintf("%s/projects/%s/repos", b.apiURL(), workspace)
 	}
 
+	// For Server, build full name as project/slug or ~user/slug
+	return b.fetchServerRepos(ctx, baseURL, func(repo BitbucketServerRepo) string {
+		return fmt.Sprintf("%s/%s", workspace, repo.Slug)
+	})
+}
+
+// fetchServerRepos fetches every page of a Bitbucket Server repo listing.
+// Its pages don't report a total, only where the next one starts, so
+// they're fetched in turn.
+func (b *BitbucketConnector) fetchServerRepos(ctx context.Context, baseURL string, fullName func(BitbucketServerRepo) string) ([]string, error) {
+	var repos []string
 	start := 0
 	for {
 		url := fmt.Sprintf("%s?limit=100&start=%d", baseURL, start)
@@ -185,9 +299,9 @@ func (b *BitbucketConnector) listReposServer(ctx context.Context, workspace stri
 		}
 
 		if resp.StatusCode != 200 {
-			body, _ := io.ReadAll(resp.Body)
+			err := apiError(resp, "failed to fetch repos")
 			resp.Body.Close()
-			return nil, fmt.Errorf("failed to fetch repos: %s", string(body))
+			return nil, err
 		}
 
 		var response BitbucketServerRepoResponse
@@ -198,9 +312,7 @@ func (b *BitbucketConnector) listReposServer(ctx context.Context, workspace stri
 		resp.Body.Close()
 
 		for _, repo := range response.Values {
-			// For Server, build full name as project/slug or ~user/slug
-			fullName := fmt.Sprintf("%s/%s", workspace, repo.Slug)
-			repos = append(repos, fullName)
+			repos = append(repos, fullName(repo))
 		}
 
 		if response.IsLastPage {
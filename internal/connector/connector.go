@@ -2,15 +2,108 @@ package connector
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/arch-err/autogitter/internal/errs"
 	"gopkg.in/yaml.v3"
 )
 
+// ErrRepoNotFound is returned by connectors when a repo doesn't exist or the
+// token can't see it; providers answer both with 404
+var ErrRepoNotFound = errors.New("repo not found")
+
+// send performs a request, reporting a failure to reach the provider as an
+// errs.NetworkError
+func send(client *http.Client, req *http.Request) (*http.Response, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, &errs.NetworkError{Err: err}
+	}
+	return resp, nil
+}
+
+// apiError describes an unexpected response with its body. A 401 is an
+// errs.AuthError and a 404 an errs.NotFoundError.
+func apiError(resp *http.Response, msg string) error {
+	body, _ := io.ReadAll(resp.Body)
+	err := fmt.Errorf("%s: %s", msg, strings.TrimSpace(string(body)))
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		return &errs.AuthError{Err: err}
+	case http.StatusNotFound:
+		return &errs.NotFoundError{Err: err}
+	}
+	return err
+}
+
+// maxPageFetches bounds how many pages of a listing are fetched at once
+const maxPageFetches = 8
+
+// fetchPages fetches pages first through last of a listing concurrently, at
+// most maxPageFetches at a time, and returns their repos in page order.
+// The first error cancels the remaining fetches.
+func fetchPages(ctx context.Context, first, last int, fetch func(ctx context.Context, page int) ([]string, error)) ([]string, error) {
+	if last < first {
+		return nil, nil
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	pages := make([][]string, last-first+1)
+	sem := make(chan struct{}, maxPageFetches)
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var firstErr error
+
+	for page := first; page <= last; page++ {
+		wg.Add(1)
+		go func(page int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if ctx.Err() != nil {
+				return
+			}
+
+			repos, err := fetch(ctx, page)
+			if err != nil {
+				errOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
+				return
+			}
+			pages[page-first] = repos
+		}(page)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var repos []string
+	for _, p := range pages {
+		repos = append(repos, p...)
+	}
+	return repos, nil
+}
+
 // Connector interface for Git providers
 type Connector interface {
 	// ListRepos returns all repos for the configured user/org
@@ -21,6 +114,121 @@ type Connector interface {
 	Name() string
 }
 
+// RepoResolver is implemented by connectors that can look up a single repo.
+// Providers redirect renamed or transferred repos, so the returned full name
+// is the repo's current canonical name.
+type RepoResolver interface {
+	ResolveRepo(ctx context.Context, fullName string) (string, error)
+}
+
+// BranchResolver is implemented by connectors that can report a repo's
+// default branch
+type BranchResolver interface {
+	DefaultBranch(ctx context.Context, fullName string) (string, error)
+}
+
+// RepoCreator is implemented by connectors that can create repos. Returns the
+// full name of the new repo.
+type RepoCreator interface {
+	CreateRepo(ctx context.Context, owner, name string, private bool) (string, error)
+}
+
+// RepoForker is implemented by connectors that can fork repos. The fork is
+// created under owner, which may be the token's user or an organization.
+// Returns the full name of the fork.
+type RepoForker interface {
+	ForkRepo(ctx context.Context, fullName, owner string) (string, error)
+}
+
+// ForkResolver is implemented by connectors that can report which repo a
+// fork was made from. Returns "" for repos that aren't forks.
+type ForkResolver interface {
+	ForkParent(ctx context.Context, fullName string) (string, error)
+}
+
+// RepoSizer is implemented by connectors that can report a repo's size on
+// the provider, in bytes
+type RepoSizer interface {
+	RepoSize(ctx context.Context, fullName string) (int64, error)
+}
+
+// RepoMetadata describes a repo as its provider shows it
+type RepoMetadata struct {
+	Description   string
+	Topics        []string
+	DefaultBranch string
+	Visibility    string // "public", "private", or "internal"
+	Archived      bool
+	PushedAt      time.Time // last push, or last update where the provider doesn't track pushes
+}
+
+// MetadataResolver is implemented by connectors that can describe a repo
+type MetadataResolver interface {
+	RepoMetadata(ctx context.Context, fullName string) (*RepoMetadata, error)
+}
+
+// RecordKind is a kind of record a provider keeps about a repo besides its
+// git data
+type RecordKind string
+
+// Kinds of record, as exported by RecordExporter
+const (
+	RecordIssues   RecordKind = "issues"
+	RecordPulls    RecordKind = "pulls"
+	RecordComments RecordKind = "comments" // on issues and pull requests
+	RecordReleases RecordKind = "releases"
+)
+
+// RecordKinds lists every RecordKind, in the order backups export them
+var RecordKinds = []RecordKind{RecordIssues, RecordPulls, RecordComments, RecordReleases}
+
+// RecordExporter is implemented by connectors that can export a repo's
+// issues, pull requests, and releases. Records are returned as the
+// provider's own JSON objects, so nothing the API reports is lost.
+type RecordExporter interface {
+	ExportRecords(ctx context.Context, fullName string, kind RecordKind) ([]json.RawMessage, error)
+}
+
+// UserResolver is implemented by connectors that can report which user the
+// token authenticates as
+type UserResolver interface {
+	CurrentUser(ctx context.Context) (string, error)
+}
+
+// TokenRotator is implemented by connectors that can create and revoke API
+// tokens. Providers only allow this with the account password, not a token.
+type TokenRotator interface {
+	CreateToken(ctx context.Context, username, password, name string) (string, error)
+	RevokeToken(ctx context.Context, username, password, token string) error
+}
+
+// AllReposLister is implemented by connectors that can list every repo the
+// token can read on the server, for sources that don't name a user or org
+type AllReposLister interface {
+	ListAllRepos(ctx context.Context) ([]string, error)
+}
+
+// OrgLister is implemented by connectors that can list the orgs the token
+// user belongs to
+type OrgLister interface {
+	ListOrgs(ctx context.Context) ([]string, error)
+}
+
+// ProjectLister is implemented by connectors whose workspaces group repos
+// into projects, to list the repos of one project
+type ProjectLister interface {
+	ListProjectRepos(ctx context.Context, workspace, project string) ([]string, error)
+}
+
+// ActivityChecker is implemented by connectors that can read a user or org's
+// activity feed, to tell whether its repos changed without listing them all.
+// Returns true when a repo was created, renamed, transferred, forked in, or
+// made public since the given time, and when the feed doesn't reach back that
+// far. Deleted and archived repos don't show up in feeds.
+type ActivityChecker interface {
+	ReposChangedSince(ctx context.Context, userOrOrg string, since time.Time) (bool, error)
+}
+
 // ConnectorType represents the type of Git provider
 type ConnectorType string
 
@@ -28,6 +236,7 @@ const (
 	ConnectorGitHub    ConnectorType = "github"
 	ConnectorGitea     ConnectorType = "gitea"
 	ConnectorBitbucket ConnectorType = "bitbucket"
+	ConnectorPlugin    ConnectorType = "plugin"
 )
 
 // New creates a new connector based on type
@@ -39,6 +248,8 @@ func New(connType ConnectorType, host string, token string) (Connector, error) {
 		return NewGiteaConnector(host, token), nil
 	case ConnectorBitbucket:
 		return NewBitbucketConnector(host, token), nil
+	case ConnectorPlugin:
+		return nil, fmt.Errorf("plugin connectors require a command, use NewPluginConnector")
 	default:
 		return nil, fmt.Errorf("unknown connector type: %s", connType)
 	}
@@ -73,18 +284,15 @@ func DefaultCredentialsPath() string {
 	return filepath.Join(dataHome, "autogitter", "credentials.env")
 }
 
-// LoadCredentialsEnv loads environment variables from a credentials file
+// LoadCredentialsEnv loads environment variables from a credentials file,
+// decrypting it first when only the encrypted store exists
 func LoadCredentialsEnv(path string) error {
-	file, err := os.Open(path)
+	data, _, err := readCredentials(path)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil // Not an error if file doesn't exist
-		}
-		return fmt.Errorf("failed to open credentials file: %w", err)
+		return err
 	}
-	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
 
@@ -123,47 +331,114 @@ func SaveCredential(path, key, value string) error {
 	}
 
 	// Read existing content
-	existingLines := make(map[string]bool)
-	var lines []string
+	data, encrypted, err := readCredentials(path)
+	if err != nil {
+		return err
+	}
 
-	file, err := os.Open(path)
-	if err == nil {
-		scanner := bufio.NewScanner(file)
-		for scanner.Scan() {
-			line := scanner.Text()
-			trimmed := strings.TrimSpace(line)
-
-			// Check if this line sets the key we're updating
-			if !strings.HasPrefix(trimmed, "#") && strings.Contains(trimmed, "=") {
-				parts := strings.SplitN(trimmed, "=", 2)
-				if strings.TrimSpace(parts[0]) == key {
-					// Replace this line
-					lines = append(lines, fmt.Sprintf("%s=%s", key, value))
-					existingLines[key] = true
-					continue
-				}
-			}
-			lines = append(lines, line)
+	found := false
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if credentialKey(line) == key {
+			// Replace this line
+			lines = append(lines, fmt.Sprintf("%s=%s", key, value))
+			found = true
+			continue
 		}
-		file.Close()
+		lines = append(lines, line)
 	}
 
 	// Add new key if it wasn't found
-	if !existingLines[key] {
+	if !found {
 		lines = append(lines, fmt.Sprintf("%s=%s", key, value))
 	}
 
-	// Write back
+	return writeCredentials(path, joinLines(lines), encrypted)
+}
+
+// RemoveCredential deletes a credential from the credentials file.
+// Returns whether the key was found.
+func RemoveCredential(path, key string) (bool, error) {
+	data, encrypted, err := readCredentials(path)
+	if err != nil {
+		return false, err
+	}
+
+	var lines []string
+	found := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if credentialKey(line) == key {
+			found = true
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("failed to read credentials file: %w", err)
+	}
+
+	if !found {
+		return false, nil
+	}
+
+	return true, writeCredentials(path, joinLines(lines), encrypted)
+}
+
+// credentialKey returns the key a KEY=VALUE line sets, or "" for comments
+// and other lines
+func credentialKey(line string) string {
+	trimmed := strings.TrimSpace(line)
+	if strings.HasPrefix(trimmed, "#") || !strings.Contains(trimmed, "=") {
+		return ""
+	}
+	return strings.TrimSpace(strings.SplitN(trimmed, "=", 2)[0])
+}
+
+// joinLines joins lines into file content ending in a newline
+func joinLines(lines []string) []byte {
 	content := strings.Join(lines, "\n")
-	if !strings.HasSuffix(content, "\n") {
+	if content != "" && !strings.HasSuffix(content, "\n") {
 		content += "\n"
 	}
+	return []byte(content)
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it
+// into place, so readers never see a partially written file
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
 
-	return os.WriteFile(path, []byte(content), 0600)
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
 }
 
-// GetToken retrieves the token for a connector type
+// GetToken retrieves the token for a connector type on its public host
 func GetToken(connType ConnectorType) string {
+	return GetTokenForHost(connType, "")
+}
+
+// GetTokenForHost retrieves the token for a connector type. GitHub and Gitea
+// fall back to the gh and tea CLI logins for the given host, e.g. a GitHub
+// Enterprise server or a self-hosted Gitea.
+func GetTokenForHost(connType ConnectorType, host string) string {
 	switch connType {
 	case ConnectorGitHub:
 		// Check GITHUB_TOKEN first
@@ -171,11 +446,20 @@ func GetToken(connType ConnectorType) string {
 			return token
 		}
 		// Fall back to gh CLI token
-		return getGhCliToken("github.com")
+		if host == "" {
+			host = "github.com"
+		}
+		return getGhCliToken(host)
 	case ConnectorGitea:
-		return os.Getenv("GITEA_TOKEN")
+		if token := os.Getenv("GITEA_TOKEN"); token != "" {
+			return token
+		}
+		// Fall back to tea CLI token
+		return getTeaCliToken(host)
 	case ConnectorBitbucket:
 		return os.Getenv("BITBUCKET_TOKEN")
+	case ConnectorPlugin:
+		return os.Getenv("PLUGIN_TOKEN")
 	default:
 		return ""
 	}
@@ -212,6 +496,60 @@ func getGhCliToken(host string) string {
 	if hostConfig, ok := hosts[host]; ok {
 		return hostConfig.OAuthToken
 	}
+	// hosts.yml keys are hostnames as typed at login
+	for name, hostConfig := range hosts {
+		if strings.EqualFold(name, host) {
+			return hostConfig.OAuthToken
+		}
+	}
+
+	return ""
+}
+
+// teaConfig represents the tea CLI config.yml structure
+type teaConfig struct {
+	Logins []struct {
+		Name    string `yaml:"name"`
+		URL     string `yaml:"url"`
+		Token   string `yaml:"token"`
+		Default bool   `yaml:"default"`
+	} `yaml:"logins"`
+}
+
+// getTeaCliToken reads the token of the tea CLI login for host, or of the
+// default login when host is empty
+func getTeaCliToken(host string) string {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		configHome = filepath.Join(home, ".config")
+	}
+
+	data, err := os.ReadFile(filepath.Join(configHome, "tea", "config.yml"))
+	if err != nil {
+		return ""
+	}
+
+	var cfg teaConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return ""
+	}
+
+	for _, login := range cfg.Logins {
+		if host == "" {
+			if login.Default {
+				return login.Token
+			}
+			continue
+		}
+		u, err := url.Parse(login.URL)
+		if err == nil && strings.EqualFold(u.Host, host) {
+			return login.Token
+		}
+	}
 
 	return ""
 }
@@ -225,6 +563,8 @@ func GetEnvVarName(connType ConnectorType) string {
 		return "GITEA_TOKEN"
 	case ConnectorBitbucket:
 		return "BITBUCKET_TOKEN"
+	case ConnectorPlugin:
+		return "PLUGIN_TOKEN"
 	default:
 		return ""
 	}
@@ -0,0 +1,173 @@
+package connector
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// EncryptedSuffix is appended to the credentials path for the encrypted store
+const EncryptedSuffix = ".age"
+
+// decrypted caches the content of each encrypted store read by this process,
+// so age runs (and asks for a passphrase) once instead of on every load
+var (
+	decryptedMu sync.Mutex
+	decrypted   = make(map[string][]byte)
+)
+
+// IdentityPath returns the key used to encrypt and decrypt the credentials
+// store: $XDG_CONFIG_HOME/autogitter/identity if it exists, otherwise the
+// user's ed25519 or RSA SSH key. Returns "" when none exists.
+func IdentityPath() string {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	home, _ := os.UserHomeDir()
+	if configHome == "" && home != "" {
+		configHome = filepath.Join(home, ".config")
+	}
+
+	candidates := []string{filepath.Join(configHome, "autogitter", "identity")}
+	if home != "" {
+		candidates = append(candidates,
+			filepath.Join(home, ".ssh", "id_ed25519"),
+			filepath.Join(home, ".ssh", "id_rsa"))
+	}
+	for _, path := range candidates {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// IsEncrypted reports whether the credentials at path are stored encrypted
+func IsEncrypted(path string) bool {
+	if _, err := os.Stat(path); err == nil {
+		return false
+	}
+	_, err := os.Stat(path + EncryptedSuffix)
+	return err == nil
+}
+
+// EncryptStore encrypts the plaintext credentials file with age, verifies the
+// result decrypts to the same content, and removes the plaintext file
+func EncryptStore(path string) error {
+	plain, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) && IsEncrypted(path) {
+			return fmt.Errorf("credentials are already encrypted: %s", path+EncryptedSuffix)
+		}
+		return fmt.Errorf("failed to read credentials file: %w", err)
+	}
+
+	if err := writeCredentials(path, plain, true); err != nil {
+		return err
+	}
+
+	decrypted, err := decryptFile(path + EncryptedSuffix)
+	if err != nil {
+		os.Remove(path + EncryptedSuffix)
+		return fmt.Errorf("encrypted store failed to decrypt, plaintext kept: %w", err)
+	}
+	if !bytes.Equal(decrypted, plain) {
+		os.Remove(path + EncryptedSuffix)
+		return fmt.Errorf("encrypted store does not match, plaintext kept")
+	}
+
+	return os.Remove(path)
+}
+
+// readCredentials returns the content of the credentials file, decrypting the
+// encrypted store when there is no plaintext file. The encrypted store is only
+// decrypted once per process. A missing store is empty.
+func readCredentials(path string) ([]byte, bool, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return data, false, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, false, fmt.Errorf("failed to open credentials file: %w", err)
+	}
+
+	if !IsEncrypted(path) {
+		return nil, false, nil
+	}
+
+	decryptedMu.Lock()
+	defer decryptedMu.Unlock()
+	if data, ok := decrypted[path]; ok {
+		return data, true, nil
+	}
+	data, err = decryptFile(path + EncryptedSuffix)
+	if err != nil {
+		return nil, true, err
+	}
+	decrypted[path] = data
+	return data, true, nil
+}
+
+// writeCredentials replaces the credentials file, or the encrypted store
+func writeCredentials(path string, data []byte, encrypted bool) error {
+	if !encrypted {
+		return writeFileAtomic(path, data, 0600)
+	}
+
+	ciphertext, err := encryptData(data)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(path+EncryptedSuffix, ciphertext, 0600); err != nil {
+		return err
+	}
+
+	decryptedMu.Lock()
+	decrypted[path] = data
+	decryptedMu.Unlock()
+	return nil
+}
+
+// decryptFile decrypts an age file with the identity from IdentityPath. age
+// asks for an SSH key's passphrase on /dev/tty, not stdin.
+func decryptFile(path string) ([]byte, error) {
+	identity := IdentityPath()
+	if identity == "" {
+		return nil, fmt.Errorf("no identity to decrypt %s, create ~/.config/autogitter/identity with age-keygen", path)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("age", "--decrypt", "--identity", identity, path)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to decrypt credentials: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// encryptData encrypts data to the identity from IdentityPath. SSH keys are
+// encrypted to their public key; age identities to their own recipient.
+func encryptData(data []byte) ([]byte, error) {
+	identity := IdentityPath()
+	if identity == "" {
+		return nil, fmt.Errorf("no identity to encrypt with, create ~/.config/autogitter/identity with age-keygen")
+	}
+
+	args := []string{"--encrypt", "--identity", identity}
+	if _, err := os.Stat(identity + ".pub"); err == nil {
+		args = []string{"--encrypt", "--recipients-file", identity + ".pub"}
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("age", args...)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to encrypt credentials: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
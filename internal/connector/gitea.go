@@ -1,13 +1,17 @@
 package connector
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/arch-err/autogitter/internal/errs"
 )
 
 // GiteaConnector implements the Connector interface for Gitea
@@ -19,9 +23,17 @@ type GiteaConnector struct {
 
 // GiteaRepo represents a repository from the Gitea API
 type GiteaRepo struct {
-	FullName string `json:"full_name"`
-	Archived bool   `json:"archived"`
-	Empty    bool   `json:"empty"`
+	FullName      string     `json:"full_name"`
+	Description   string     `json:"description"`
+	Topics        []string   `json:"topics"`
+	DefaultBranch string     `json:"default_branch"`
+	Private       bool       `json:"private"`
+	Internal      bool       `json:"internal"`
+	Archived      bool       `json:"archived"`
+	Empty         bool       `json:"empty"`
+	Size          int64      `json:"size"` // in KB
+	UpdatedAt     time.Time  `json:"updated_at"`
+	Parent        *GiteaRepo `json:"parent,omitempty"` // set for forks when fetching a single repo
 }
 
 // GiteaUser represents a user from the Gitea API
@@ -29,9 +41,19 @@ type GiteaUser struct {
 	Login string `json:"login"`
 }
 
-// GiteaOrg represents an organization check response
+// GiteaToken represents an access token from the Gitea API. SHA1 holds the
+// token itself and is only returned when the token is created.
+type GiteaToken struct {
+	ID        int64  `json:"id"`
+	Name      string `json:"name"`
+	SHA1      string `json:"sha1"`
+	LastEight string `json:"token_last_eight"`
+}
+
+// GiteaOrg represents an organization from the Gitea API
 type GiteaOrg struct {
-	ID int `json:"id"`
+	ID       int    `json:"id"`
+	Username string `json:"username"`
 }
 
 // NewGiteaConnector creates a new Gitea connector
@@ -67,7 +89,28 @@ func (g *GiteaConnector) doRequest(ctx context.Context, method, url string) (*ht
 		req.Header.Set("Authorization", "token "+g.token)
 	}
 
-	return g.client.Do(req)
+	return send(g.client, req)
+}
+
+// doJSONRequest performs an authenticated HTTP request with a JSON body
+func (g *GiteaConnector) doJSONRequest(ctx context.Context, method, url string, payload interface{}) (*http.Response, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	if g.token != "" {
+		req.Header.Set("Authorization", "token "+g.token)
+	}
+
+	return send(g.client, req)
 }
 
 // TestConnection verifies the token works
@@ -80,7 +123,7 @@ func (g *GiteaConnector) TestConnection(ctx context.Context) error {
 	defer resp.Body.Close()
 
 	if resp.StatusCode == 401 {
-		return fmt.Errorf("authentication failed: invalid token")
+		return &errs.AuthError{Err: errors.New("invalid token")}
 	}
 	if resp.StatusCode != 200 {
 		body, _ := io.ReadAll(resp.Body)
@@ -90,6 +133,30 @@ func (g *GiteaConnector) TestConnection(ctx context.Context) error {
 	return nil
 }
 
+// CurrentUser returns the login of the user the token belongs to
+func (g *GiteaConnector) CurrentUser(ctx context.Context) (string, error) {
+	url := fmt.Sprintf("%s/user", g.apiURL())
+	resp, err := g.doRequest(ctx, "GET", url)
+	if err != nil {
+		return "", fmt.Errorf("failed to connect: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 401 {
+		return "", &errs.AuthError{Err: errors.New("invalid token")}
+	}
+	if resp.StatusCode != 200 {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var user GiteaUser
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return "", fmt.Errorf("failed to decode user: %w", err)
+	}
+	return user.Login, nil
+}
+
 // ListRepos returns all repos for the configured user/org
 func (g *GiteaConnector) ListRepos(ctx context.Context, userOrOrg string) ([]string, error) {
 	// First, check if this is an organization
@@ -98,31 +165,276 @@ func (g *GiteaConnector) ListRepos(ctx context.Context, userOrOrg string) ([]str
 		return nil, err
 	}
 
-	var repos []string
-	page := 1
+	if isOrg {
+		return g.fetchRepoPages(ctx, fmt.Sprintf("%s/orgs/%s/repos", g.apiURL(), userOrOrg))
+	}
+	return g.fetchRepoPages(ctx, fmt.Sprintf("%s/users/%s/repos", g.apiURL(), userOrOrg))
+}
 
-	for {
-		var url string
+// ListAllRepos returns the token user's own repos and the repos of every
+// org they belong to, without duplicates
+func (g *GiteaConnector) ListAllRepos(ctx context.Context) ([]string, error) {
+	repos, err := g.fetchRepoPages(ctx, fmt.Sprintf("%s/user/repos", g.apiURL()))
+	if err != nil {
+		return nil, err
+	}
+
+	orgs, err := g.ListOrgs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, org := range orgs {
+		orgRepos, err := g.fetchRepoPages(ctx, fmt.Sprintf("%s/orgs/%s/repos", g.apiURL(), org))
+		if err != nil {
+			return nil, err
+		}
+		repos = append(repos, orgRepos...)
+	}
+
+	// /user/repos also lists org repos the user can access
+	seen := make(map[string]bool, len(repos))
+	var unique []string
+	for _, repo := range repos {
+		if key := strings.ToLower(repo); !seen[key] {
+			seen[key] = true
+			unique = append(unique, repo)
+		}
+	}
+	return unique, nil
+}
+
+// ListOrgs returns the names of the orgs the token user belongs to
+func (g *GiteaConnector) ListOrgs(ctx context.Context) ([]string, error) {
+	var orgs []string
+	for page := 1; ; page++ {
+		url := fmt.Sprintf("%s/user/orgs?page=%d&limit=50", g.apiURL(), page)
+		resp, err := g.doRequest(ctx, "GET", url)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch orgs: %w", err)
+		}
+
+		if resp.StatusCode != 200 {
+			err := apiError(resp, "failed to fetch orgs")
+			resp.Body.Close()
+			return nil, err
+		}
+
+		var pageOrgs []GiteaOrg
+		if err := json.NewDecoder(resp.Body).Decode(&pageOrgs); err != nil {
+			resp.Body.Close()
+			return nil, fmt.Errorf("failed to decode orgs: %w", err)
+		}
+		resp.Body.Close()
+
+		if len(pageOrgs) == 0 {
+			return orgs, nil
+		}
+		for _, org := range pageOrgs {
+			orgs = append(orgs, org.Username)
+		}
+	}
+}
+
+// ResolveRepo returns the current full name of a repo, following rename redirects
+func (g *GiteaConnector) ResolveRepo(ctx context.Context, fullName string) (string, error) {
+	repo, err := g.getRepo(ctx, fullName)
+	if err != nil {
+		return "", err
+	}
+	return repo.FullName, nil
+}
+
+// DefaultBranch returns the repo's default branch as configured on the provider
+func (g *GiteaConnector) DefaultBranch(ctx context.Context, fullName string) (string, error) {
+	repo, err := g.getRepo(ctx, fullName)
+	if err != nil {
+		return "", err
+	}
+	return repo.DefaultBranch, nil
+}
+
+// CreateRepo creates a repo owned by the token's user, or by an organization
+func (g *GiteaConnector) CreateRepo(ctx context.Context, owner, name string, private bool) (string, error) {
+	url := fmt.Sprintf("%s/user/repos", g.apiURL())
+	if owner != "" {
+		isOrg, err := g.isOrganization(ctx, owner)
+		if err != nil {
+			return "", err
+		}
 		if isOrg {
-			url = fmt.Sprintf("%s/orgs/%s/repos?page=%d&limit=50", g.apiURL(), userOrOrg, page)
-		} else {
-			url = fmt.Sprintf("%s/users/%s/repos?page=%d&limit=50", g.apiURL(), userOrOrg, page)
+			url = fmt.Sprintf("%s/orgs/%s/repos", g.apiURL(), owner)
 		}
+	}
 
-		pageRepos, err := g.fetchRepoPage(ctx, url)
+	resp, err := g.doJSONRequest(ctx, "POST", url, map[string]interface{}{"name": name, "private": private})
+	if err != nil {
+		return "", fmt.Errorf("failed to create repo: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 201 {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("failed to create repo: %s", string(body))
+	}
+
+	var repo GiteaRepo
+	if err := json.NewDecoder(resp.Body).Decode(&repo); err != nil {
+		return "", fmt.Errorf("failed to decode repo: %w", err)
+	}
+	return repo.FullName, nil
+}
+
+// ForkRepo forks a repo to the token's user, or to owner if it is an organization
+func (g *GiteaConnector) ForkRepo(ctx context.Context, fullName, owner string) (string, error) {
+	payload := map[string]interface{}{}
+	if owner != "" {
+		isOrg, err := g.isOrganization(ctx, owner)
 		if err != nil {
+			return "", err
+		}
+		if isOrg {
+			payload["organization"] = owner
+		}
+	}
+
+	url := fmt.Sprintf("%s/repos/%s/forks", g.apiURL(), fullName)
+	resp, err := g.doJSONRequest(ctx, "POST", url, payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to fork repo: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 404 {
+		return "", &errs.NotFoundError{Err: fmt.Errorf("%w: %s", ErrRepoNotFound, fullName)}
+	}
+	if resp.StatusCode != 202 && resp.StatusCode != 201 {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("failed to fork repo: %s", string(body))
+	}
+
+	var repo GiteaRepo
+	if err := json.NewDecoder(resp.Body).Decode(&repo); err != nil {
+		return "", fmt.Errorf("failed to decode repo: %w", err)
+	}
+	return repo.FullName, nil
+}
+
+// ForkParent returns the full name of the repo a fork was made from
+func (g *GiteaConnector) ForkParent(ctx context.Context, fullName string) (string, error) {
+	repo, err := g.getRepo(ctx, fullName)
+	if err != nil {
+		return "", err
+	}
+	if repo.Parent == nil {
+		return "", nil
+	}
+	return repo.Parent.FullName, nil
+}
+
+// RepoSize returns the repo's size as reported by the API, which counts in KB
+func (g *GiteaConnector) RepoSize(ctx context.Context, fullName string) (int64, error) {
+	repo, err := g.getRepo(ctx, fullName)
+	if err != nil {
+		return 0, err
+	}
+	return repo.Size * 1024, nil
+}
+
+// RepoMetadata returns the repo's description, topics, and state on Gitea,
+// which doesn't track pushes separately from other updates
+func (g *GiteaConnector) RepoMetadata(ctx context.Context, fullName string) (*RepoMetadata, error) {
+	repo, err := g.getRepo(ctx, fullName)
+	if err != nil {
+		return nil, err
+	}
+	visibility := "public"
+	switch {
+	case repo.Private:
+		visibility = "private"
+	case repo.Internal:
+		visibility = "internal"
+	}
+	return &RepoMetadata{
+		Description:   repo.Description,
+		Topics:        repo.Topics,
+		DefaultBranch: repo.DefaultBranch,
+		Visibility:    visibility,
+		Archived:      repo.Archived,
+		PushedAt:      repo.UpdatedAt,
+	}, nil
+}
+
+// giteaRecordPaths are the endpoints under /repos/{owner}/{repo} listing
+// each kind of record
+var giteaRecordPaths = map[RecordKind]string{
+	RecordIssues:   "issues?state=all&type=issues",
+	RecordPulls:    "pulls?state=all",
+	RecordComments: "issues/comments?",
+	RecordReleases: "releases?",
+}
+
+// ExportRecords returns every record of a kind of the repo
+func (g *GiteaConnector) ExportRecords(ctx context.Context, fullName string, kind RecordKind) ([]json.RawMessage, error) {
+	path, ok := giteaRecordPaths[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown record kind %q", kind)
+	}
+
+	var records []json.RawMessage
+	for page := 1; ; page++ {
+		url := fmt.Sprintf("%s/repos/%s/%s&limit=50&page=%d", g.apiURL(), fullName, path, page)
+		resp, err := g.doRequest(ctx, "GET", url)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch %s: %w", kind, err)
+		}
+
+		if resp.StatusCode == 404 {
+			resp.Body.Close()
+			return nil, &errs.NotFoundError{Err: fmt.Errorf("%w: %s", ErrRepoNotFound, fullName)}
+		}
+		if resp.StatusCode != 200 {
+			err := apiError(resp, "failed to fetch "+string(kind))
+			resp.Body.Close()
 			return nil, err
 		}
 
-		if len(pageRepos) == 0 {
-			break
+		var pageRecords []json.RawMessage
+		err = json.NewDecoder(resp.Body).Decode(&pageRecords)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", kind, err)
 		}
 
-		repos = append(repos, pageRepos...)
-		page++
+		if len(pageRecords) == 0 {
+			return records, nil
+		}
+		records = append(records, pageRecords...)
 	}
+}
 
-	return repos, nil
+// getRepo fetches a single repo
+func (g *GiteaConnector) getRepo(ctx context.Context, fullName string) (*GiteaRepo, error) {
+	url := fmt.Sprintf("%s/repos/%s", g.apiURL(), fullName)
+	resp, err := g.doRequest(ctx, "GET", url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get repo: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 404 {
+		return nil, &errs.NotFoundError{Err: fmt.Errorf("%w: %s", ErrRepoNotFound, fullName)}
+	}
+	if resp.StatusCode != 200 {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to get repo: %s", string(body))
+	}
+
+	var repo GiteaRepo
+	if err := json.NewDecoder(resp.Body).Decode(&repo); err != nil {
+		return nil, fmt.Errorf("failed to decode repo: %w", err)
+	}
+
+	return &repo, nil
 }
 
 // isOrganization checks if the target is an organization
@@ -149,7 +461,7 @@ func (g *GiteaConnector) isOrganization(ctx context.Context, name string) (bool,
 		defer userResp.Body.Close()
 
 		if userResp.StatusCode == 404 {
-			return false, fmt.Errorf("user or organization not found: %s", name)
+			return false, &errs.NotFoundError{Err: fmt.Errorf("user or organization %s", name)}
 		}
 		return false, nil
 	}
@@ -158,6 +470,30 @@ func (g *GiteaConnector) isOrganization(ctx context.Context, name string) (bool,
 	return false, fmt.Errorf("failed to check organization: %s", string(body))
 }
 
+// fetchRepoPages fetches every page of a repo listing
+func (g *GiteaConnector) fetchRepoPages(ctx context.Context, baseURL string) ([]string, error) {
+	var repos []string
+	page := 1
+
+	for {
+		url := fmt.Sprintf("%s?page=%d&limit=50", baseURL, page)
+
+		pageRepos, err := g.fetchRepoPage(ctx, url)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(pageRepos) == 0 {
+			break
+		}
+
+		repos = append(repos, pageRepos...)
+		page++
+	}
+
+	return repos, nil
+}
+
 // fetchRepoPage fetches a single page of repositories
 func (g *GiteaConnector) fetchRepoPage(ctx context.Context, url string) ([]string, error) {
 	resp, err := g.doRequest(ctx, "GET", url)
@@ -167,8 +503,7 @@ func (g *GiteaConnector) fetchRepoPage(ctx context.Context, url string) ([]strin
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("failed to fetch repos: %s", string(body))
+		return nil, apiError(resp, "failed to fetch repos")
 	}
 
 	var giteaRepos []GiteaRepo
@@ -192,3 +527,179 @@ func (g *GiteaConnector) fetchRepoPage(ctx context.Context, url string) ([]strin
 func (g *GiteaConnector) TokenGenerationURL() string {
 	return fmt.Sprintf("https://%s/user/settings/applications", strings.TrimSuffix(g.host, "/"))
 }
+
+// giteaTokenScopes are the scopes of tokens created by CreateToken
+var giteaTokenScopes = []string{"read:user", "read:repository", "read:organization"}
+
+// doBasicRequest performs a request authenticated with username and password,
+// which Gitea requires for managing tokens
+func (g *GiteaConnector) doBasicRequest(ctx context.Context, method, url, username, password string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.SetBasicAuth(username, password)
+
+	return send(g.client, req)
+}
+
+// CreateToken creates a new access token for the user
+func (g *GiteaConnector) CreateToken(ctx context.Context, username, password, name string) (string, error) {
+	payload, err := json.Marshal(map[string]interface{}{"name": name, "scopes": giteaTokenScopes})
+	if err != nil {
+		return "", err
+	}
+
+	url := fmt.Sprintf("%s/users/%s/tokens", g.apiURL(), username)
+	resp, err := g.doBasicRequest(ctx, "POST", url, username, password, bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("failed to create token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 401 {
+		return "", &errs.AuthError{Err: errors.New("invalid username or password")}
+	}
+	if resp.StatusCode != 201 {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("failed to create token: status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var token GiteaToken
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("failed to decode token: %w", err)
+	}
+	return token.SHA1, nil
+}
+
+// RevokeToken deletes the user's access token that ends in the same eight
+// characters as token
+func (g *GiteaConnector) RevokeToken(ctx context.Context, username, password, token string) error {
+	if len(token) < 8 {
+		return fmt.Errorf("token too short to identify")
+	}
+	lastEight := token[len(token)-8:]
+
+	t, err := g.findToken(ctx, username, password, lastEight)
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/users/%s/tokens/%d", g.apiURL(), username, t.ID)
+	resp, err := g.doBasicRequest(ctx, "DELETE", url, username, password, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete token: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 204 {
+		return fmt.Errorf("failed to delete token %s: status %d", t.Name, resp.StatusCode)
+	}
+	return nil
+}
+
+// findToken pages through the user's access tokens for the one that ends in
+// lastEight
+func (g *GiteaConnector) findToken(ctx context.Context, username, password, lastEight string) (*GiteaToken, error) {
+	for page := 1; ; page++ {
+		url := fmt.Sprintf("%s/users/%s/tokens?page=%d&limit=50", g.apiURL(), username, page)
+		resp, err := g.doBasicRequest(ctx, "GET", url, username, password, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tokens: %w", err)
+		}
+
+		if resp.StatusCode == 401 {
+			resp.Body.Close()
+			return nil, &errs.AuthError{Err: errors.New("invalid username or password")}
+		}
+		if resp.StatusCode != 200 {
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			return nil, fmt.Errorf("failed to list tokens: status %d: %s", resp.StatusCode, string(body))
+		}
+
+		var tokens []GiteaToken
+		if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+			resp.Body.Close()
+			return nil, fmt.Errorf("failed to decode tokens: %w", err)
+		}
+		resp.Body.Close()
+
+		if len(tokens) == 0 {
+			return nil, fmt.Errorf("token not found for user %s", username)
+		}
+		for i := range tokens {
+			if tokens[i].LastEight == lastEight {
+				return &tokens[i], nil
+			}
+		}
+	}
+}
+
+// giteaFeedPages caps how many pages of an activity feed are read before
+// giving up and reporting a possible change
+const giteaFeedPages = 10
+
+// GiteaActivity represents an entry of a Gitea activity feed
+type GiteaActivity struct {
+	OpType  string    `json:"op_type"`
+	Created time.Time `json:"created"`
+}
+
+// ReposChangedSince reads the user's or org's activity feed back to since
+func (g *GiteaConnector) ReposChangedSince(ctx context.Context, userOrOrg string, since time.Time) (bool, error) {
+	isOrg, err := g.isOrganization(ctx, userOrOrg)
+	if err != nil {
+		return false, err
+	}
+
+	feed := fmt.Sprintf("%s/users/%s/activities/feeds", g.apiURL(), userOrOrg)
+	if isOrg {
+		feed = fmt.Sprintf("%s/orgs/%s/activities/feeds", g.apiURL(), userOrOrg)
+	}
+
+	for page := 1; page <= giteaFeedPages; page++ {
+		url := fmt.Sprintf("%s?page=%d&limit=50", feed, page)
+		activities, err := g.fetchActivityPage(ctx, url)
+		if err != nil {
+			return false, err
+		}
+		if len(activities) == 0 {
+			return false, nil
+		}
+
+		for _, activity := range activities {
+			if !activity.Created.After(since) {
+				return false, nil
+			}
+			switch activity.OpType {
+			case "create_repo", "rename_repo", "transfer_repo":
+				return true, nil
+			}
+		}
+	}
+	return true, nil
+}
+
+// fetchActivityPage fetches a single page of an activity feed, newest first
+func (g *GiteaConnector) fetchActivityPage(ctx context.Context, url string) ([]GiteaActivity, error) {
+	resp, err := g.doRequest(ctx, "GET", url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch activity feed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, apiError(resp, "failed to fetch activity feed")
+	}
+
+	var activities []GiteaActivity
+	if err := json.NewDecoder(resp.Body).Decode(&activities); err != nil {
+		return nil, fmt.Errorf("failed to decode activity feed: %w", err)
+	}
+	return activities, nil
+}
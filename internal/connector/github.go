@@ -1,13 +1,19 @@
 package connector
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/arch-err/autogitter/internal/errs"
 )
 
 // GitHubConnector implements the Connector interface for GitHub
@@ -19,9 +25,16 @@ type GitHubConnector struct {
 
 // GitHubRepo represents a repository from the GitHub API
 type GitHubRepo struct {
-	FullName string `json:"full_name"`
-	Archived bool   `json:"archived"`
-	Disabled bool   `json:"disabled"`
+	FullName      string      `json:"full_name"`
+	Description   string      `json:"description"`
+	Topics        []string    `json:"topics"`
+	DefaultBranch string      `json:"default_branch"`
+	Visibility    string      `json:"visibility"` // "public", "private", or "internal"
+	Archived      bool        `json:"archived"`
+	Disabled      bool        `json:"disabled"`
+	Size          int64       `json:"size"` // in KB
+	PushedAt      time.Time   `json:"pushed_at"`
+	Parent        *GitHubRepo `json:"parent,omitempty"` // set for forks when fetching a single repo
 }
 
 // GitHubUser represents a user from the GitHub API
@@ -58,20 +71,45 @@ func (g *GitHubConnector) apiURL() string {
 	return fmt.Sprintf("https://%s/api/v3", g.host)
 }
 
-// doRequest performs an authenticated HTTP request
+// doRequest performs an authenticated HTTP request, waiting out rate limits
 func (g *GitHubConnector) doRequest(ctx context.Context, method, url string) (*http.Response, error) {
-	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	return sendThrottled(ctx, g.client, g.host, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, method, url, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Accept", "application/vnd.github+json")
+		req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+		if g.token != "" {
+			req.Header.Set("Authorization", "Bearer "+g.token)
+		}
+		return req, nil
+	})
+}
+
+// doJSONRequest performs an authenticated HTTP request with a JSON body,
+// waiting out rate limits
+func (g *GitHubConnector) doJSONRequest(ctx context.Context, method, url string, payload interface{}) (*http.Response, error) {
+	body, err := json.Marshal(payload)
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("Accept", "application/vnd.github+json")
-	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
-	if g.token != "" {
-		req.Header.Set("Authorization", "Bearer "+g.token)
-	}
+	return sendThrottled(ctx, g.client, g.host, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
 
-	return g.client.Do(req)
+		req.Header.Set("Accept", "application/vnd.github+json")
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+		if g.token != "" {
+			req.Header.Set("Authorization", "Bearer "+g.token)
+		}
+		return req, nil
+	})
 }
 
 // TestConnection verifies the token works
@@ -84,7 +122,7 @@ func (g *GitHubConnector) TestConnection(ctx context.Context) error {
 	defer resp.Body.Close()
 
 	if resp.StatusCode == 401 {
-		return fmt.Errorf("authentication failed: invalid token")
+		return &errs.AuthError{Err: errors.New("invalid token")}
 	}
 	if resp.StatusCode != 200 {
 		body, _ := io.ReadAll(resp.Body)
@@ -94,6 +132,30 @@ func (g *GitHubConnector) TestConnection(ctx context.Context) error {
 	return nil
 }
 
+// CurrentUser returns the login of the user the token belongs to
+func (g *GitHubConnector) CurrentUser(ctx context.Context) (string, error) {
+	url := fmt.Sprintf("%s/user", g.apiURL())
+	resp, err := g.doRequest(ctx, "GET", url)
+	if err != nil {
+		return "", fmt.Errorf("failed to connect: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 401 {
+		return "", &errs.AuthError{Err: errors.New("invalid token")}
+	}
+	if resp.StatusCode != 200 {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var user GitHubUser
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return "", fmt.Errorf("failed to decode user: %w", err)
+	}
+	return user.Login, nil
+}
+
 // ListRepos returns all repos for the configured user/org
 func (g *GitHubConnector) ListRepos(ctx context.Context, userOrOrg string) ([]string, error) {
 	// First, determine if this is a user or organization
@@ -102,31 +164,288 @@ func (g *GitHubConnector) ListRepos(ctx context.Context, userOrOrg string) ([]st
 		return nil, err
 	}
 
-	var repos []string
-	var url string
-	page := 1
-
-	for {
+	pageURL := func(page int) string {
 		if userType == "Organization" {
-			url = fmt.Sprintf("%s/orgs/%s/repos?per_page=100&page=%d", g.apiURL(), userOrOrg, page)
-		} else {
-			url = fmt.Sprintf("%s/users/%s/repos?per_page=100&page=%d", g.apiURL(), userOrOrg, page)
+			return fmt.Sprintf("%s/orgs/%s/repos?per_page=100&page=%d", g.apiURL(), userOrOrg, page)
 		}
+		return fmt.Sprintf("%s/users/%s/repos?per_page=100&page=%d", g.apiURL(), userOrOrg, page)
+	}
+
+	repos, links, err := g.fetchRepoPage(ctx, pageURL(1))
+	if err != nil {
+		return nil, err
+	}
 
-		pageRepos, hasMore, err := g.fetchRepoPage(ctx, url)
+	// The first page links to the last, so the rest can be fetched at once
+	if links.last > 1 {
+		rest, err := fetchPages(ctx, 2, links.last, func(ctx context.Context, page int) ([]string, error) {
+			pageRepos, _, err := g.fetchRepoPage(ctx, pageURL(page))
+			return pageRepos, err
+		})
 		if err != nil {
 			return nil, err
 		}
+		return append(repos, rest...), nil
+	}
 
+	for page := 2; links.next; page++ {
+		var pageRepos []string
+		pageRepos, links, err = g.fetchRepoPage(ctx, pageURL(page))
+		if err != nil {
+			return nil, err
+		}
 		repos = append(repos, pageRepos...)
+	}
 
-		if !hasMore {
-			break
+	return repos, nil
+}
+
+// ListOrgs returns the logins of the orgs the token user belongs to. Orgs
+// that hide the membership are only listed for tokens with read:org.
+func (g *GitHubConnector) ListOrgs(ctx context.Context) ([]string, error) {
+	var orgs []string
+	for page := 1; ; page++ {
+		url := fmt.Sprintf("%s/user/orgs?per_page=100&page=%d", g.apiURL(), page)
+		resp, err := g.doRequest(ctx, "GET", url)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch orgs: %w", err)
+		}
+
+		if resp.StatusCode != 200 {
+			err := apiError(resp, "failed to fetch orgs")
+			resp.Body.Close()
+			return nil, err
+		}
+
+		var pageOrgs []GitHubUser
+		if err := json.NewDecoder(resp.Body).Decode(&pageOrgs); err != nil {
+			resp.Body.Close()
+			return nil, fmt.Errorf("failed to decode orgs: %w", err)
+		}
+		links := parsePageLinks(resp.Header.Get("Link"))
+		resp.Body.Close()
+
+		for _, org := range pageOrgs {
+			orgs = append(orgs, org.Login)
+		}
+		if !links.next {
+			return orgs, nil
 		}
-		page++
 	}
+}
 
-	return repos, nil
+// ResolveRepo returns the current full name of a repo, following rename redirects
+func (g *GitHubConnector) ResolveRepo(ctx context.Context, fullName string) (string, error) {
+	repo, err := g.getRepo(ctx, fullName)
+	if err != nil {
+		return "", err
+	}
+	return repo.FullName, nil
+}
+
+// DefaultBranch returns the repo's default branch as configured on the provider
+func (g *GitHubConnector) DefaultBranch(ctx context.Context, fullName string) (string, error) {
+	repo, err := g.getRepo(ctx, fullName)
+	if err != nil {
+		return "", err
+	}
+	return repo.DefaultBranch, nil
+}
+
+// CreateRepo creates a repo owned by the token's user, or by an organization
+func (g *GitHubConnector) CreateRepo(ctx context.Context, owner, name string, private bool) (string, error) {
+	url := fmt.Sprintf("%s/user/repos", g.apiURL())
+	if owner != "" {
+		userType, err := g.getUserType(ctx, owner)
+		if err != nil {
+			return "", err
+		}
+		if userType == "Organization" {
+			url = fmt.Sprintf("%s/orgs/%s/repos", g.apiURL(), owner)
+		}
+	}
+
+	resp, err := g.doJSONRequest(ctx, "POST", url, map[string]interface{}{"name": name, "private": private})
+	if err != nil {
+		return "", fmt.Errorf("failed to create repo: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 201 {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("failed to create repo: %s", string(body))
+	}
+
+	var repo GitHubRepo
+	if err := json.NewDecoder(resp.Body).Decode(&repo); err != nil {
+		return "", fmt.Errorf("failed to decode repo: %w", err)
+	}
+	return repo.FullName, nil
+}
+
+// ForkRepo forks a repo to the token's user, or to owner if it is an organization
+func (g *GitHubConnector) ForkRepo(ctx context.Context, fullName, owner string) (string, error) {
+	payload := map[string]interface{}{}
+	if owner != "" {
+		userType, err := g.getUserType(ctx, owner)
+		if err != nil {
+			return "", err
+		}
+		if userType == "Organization" {
+			payload["organization"] = owner
+		}
+	}
+
+	url := fmt.Sprintf("%s/repos/%s/forks", g.apiURL(), fullName)
+	resp, err := g.doJSONRequest(ctx, "POST", url, payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to fork repo: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 404 {
+		return "", &errs.NotFoundError{Err: fmt.Errorf("%w: %s", ErrRepoNotFound, fullName)}
+	}
+	if resp.StatusCode != 202 && resp.StatusCode != 201 {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("failed to fork repo: %s", string(body))
+	}
+
+	var repo GitHubRepo
+	if err := json.NewDecoder(resp.Body).Decode(&repo); err != nil {
+		return "", fmt.Errorf("failed to decode repo: %w", err)
+	}
+	return repo.FullName, nil
+}
+
+// ForkParent returns the full name of the repo a fork was made from
+func (g *GitHubConnector) ForkParent(ctx context.Context, fullName string) (string, error) {
+	repo, err := g.getRepo(ctx, fullName)
+	if err != nil {
+		return "", err
+	}
+	if repo.Parent == nil {
+		return "", nil
+	}
+	return repo.Parent.FullName, nil
+}
+
+// RepoSize returns the repo's size as reported by the API, which counts in KB
+func (g *GitHubConnector) RepoSize(ctx context.Context, fullName string) (int64, error) {
+	repo, err := g.getRepo(ctx, fullName)
+	if err != nil {
+		return 0, err
+	}
+	return repo.Size * 1024, nil
+}
+
+// RepoMetadata returns the repo's description, topics, and state on GitHub
+func (g *GitHubConnector) RepoMetadata(ctx context.Context, fullName string) (*RepoMetadata, error) {
+	repo, err := g.getRepo(ctx, fullName)
+	if err != nil {
+		return nil, err
+	}
+	return &RepoMetadata{
+		Description:   repo.Description,
+		Topics:        repo.Topics,
+		DefaultBranch: repo.DefaultBranch,
+		Visibility:    repo.Visibility,
+		Archived:      repo.Archived,
+		PushedAt:      repo.PushedAt,
+	}, nil
+}
+
+// gitHubRecordPaths are the endpoints under /repos/{owner}/{repo} listing
+// each kind of record
+var gitHubRecordPaths = map[RecordKind]string{
+	RecordIssues:   "issues?state=all",
+	RecordPulls:    "pulls?state=all",
+	RecordComments: "issues/comments?",
+	RecordReleases: "releases?",
+}
+
+// ExportRecords returns every record of a kind of the repo. GitHub lists
+// pull requests among issues too; they're left out of the issues.
+func (g *GitHubConnector) ExportRecords(ctx context.Context, fullName string, kind RecordKind) ([]json.RawMessage, error) {
+	path, ok := gitHubRecordPaths[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown record kind %q", kind)
+	}
+
+	var records []json.RawMessage
+	for page := 1; ; page++ {
+		url := fmt.Sprintf("%s/repos/%s/%s&per_page=100&page=%d", g.apiURL(), fullName, path, page)
+		resp, err := g.doRequest(ctx, "GET", url)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch %s: %w", kind, err)
+		}
+
+		// Repos with issues turned off answer 410
+		if resp.StatusCode == 410 {
+			resp.Body.Close()
+			return records, nil
+		}
+		if resp.StatusCode == 404 {
+			resp.Body.Close()
+			return nil, &errs.NotFoundError{Err: fmt.Errorf("%w: %s", ErrRepoNotFound, fullName)}
+		}
+		if resp.StatusCode != 200 {
+			err := apiError(resp, "failed to fetch "+string(kind))
+			resp.Body.Close()
+			return nil, err
+		}
+
+		var pageRecords []json.RawMessage
+		if err := json.NewDecoder(resp.Body).Decode(&pageRecords); err != nil {
+			resp.Body.Close()
+			return nil, fmt.Errorf("failed to decode %s: %w", kind, err)
+		}
+		links := parsePageLinks(resp.Header.Get("Link"))
+		resp.Body.Close()
+
+		for _, record := range pageRecords {
+			if kind == RecordIssues && isGitHubPull(record) {
+				continue
+			}
+			records = append(records, record)
+		}
+		if !links.next {
+			return records, nil
+		}
+	}
+}
+
+// isGitHubPull reports whether an issue record is a pull request
+func isGitHubPull(issue json.RawMessage) bool {
+	var fields struct {
+		PullRequest json.RawMessage `json:"pull_request"`
+	}
+	return json.Unmarshal(issue, &fields) == nil && fields.PullRequest != nil
+}
+
+// getRepo fetches a single repo
+func (g *GitHubConnector) getRepo(ctx context.Context, fullName string) (*GitHubRepo, error) {
+	url := fmt.Sprintf("%s/repos/%s", g.apiURL(), fullName)
+	resp, err := g.doRequest(ctx, "GET", url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get repo: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 404 {
+		return nil, &errs.NotFoundError{Err: fmt.Errorf("%w: %s", ErrRepoNotFound, fullName)}
+	}
+	if resp.StatusCode != 200 {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to get repo: %s", string(body))
+	}
+
+	var repo GitHubRepo
+	if err := json.NewDecoder(resp.Body).Decode(&repo); err != nil {
+		return nil, fmt.Errorf("failed to decode repo: %w", err)
+	}
+
+	return &repo, nil
 }
 
 // getUserType determines if the target is a user or organization
@@ -139,7 +458,7 @@ func (g *GitHubConnector) getUserType(ctx context.Context, userOrOrg string) (st
 	defer resp.Body.Close()
 
 	if resp.StatusCode == 404 {
-		return "", fmt.Errorf("user or organization not found: %s", userOrOrg)
+		return "", &errs.NotFoundError{Err: fmt.Errorf("user or organization %s", userOrOrg)}
 	}
 	if resp.StatusCode != 200 {
 		body, _ := io.ReadAll(resp.Body)
@@ -154,22 +473,49 @@ func (g *GitHubConnector) getUserType(ctx context.Context, userOrOrg string) (st
 	return user.Type, nil
 }
 
+// pageLinks is the pagination of a GitHub response, from its Link header
+type pageLinks struct {
+	next bool
+	last int // number of the last page, 0 when not linked
+}
+
+// parsePageLinks reads the rel="next" and rel="last" links of a Link header
+func parsePageLinks(header string) pageLinks {
+	var links pageLinks
+	for _, link := range strings.Split(header, ",") {
+		target, rel, ok := strings.Cut(link, ";")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(rel) {
+		case `rel="next"`:
+			links.next = true
+		case `rel="last"`:
+			u, err := url.Parse(strings.Trim(strings.TrimSpace(target), "<>"))
+			if err != nil {
+				continue
+			}
+			links.last, _ = strconv.Atoi(u.Query().Get("page"))
+		}
+	}
+	return links
+}
+
 // fetchRepoPage fetches a single page of repositories
-func (g *GitHubConnector) fetchRepoPage(ctx context.Context, url string) ([]string, bool, error) {
+func (g *GitHubConnector) fetchRepoPage(ctx context.Context, url string) ([]string, pageLinks, error) {
 	resp, err := g.doRequest(ctx, "GET", url)
 	if err != nil {
-		return nil, false, fmt.Errorf("failed to fetch repos: %w", err)
+		return nil, pageLinks{}, fmt.Errorf("failed to fetch repos: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, false, fmt.Errorf("failed to fetch repos: %s", string(body))
+		return nil, pageLinks{}, apiError(resp, "failed to fetch repos")
 	}
 
 	var ghRepos []GitHubRepo
 	if err := json.NewDecoder(resp.Body).Decode(&ghRepos); err != nil {
-		return nil, false, fmt.Errorf("failed to decode repos: %w", err)
+		return nil, pageLinks{}, fmt.Errorf("failed to decode repos: %w", err)
 	}
 
 	var repos []string
@@ -181,13 +527,97 @@ func (g *GitHubConnector) fetchRepoPage(ctx context.Context, url string) ([]stri
 		repos = append(repos, repo.FullName)
 	}
 
-	// Check for next page via Link header
-	hasMore := false
-	linkHeader := resp.Header.Get("Link")
-	if linkHeader != "" {
-		hasMore = strings.Contains(linkHeader, `rel="next"`)
+	return repos, parsePageLinks(resp.Header.Get("Link")), nil
+}
+
+// gitHubFeedLimit is how many events GitHub keeps in a feed; older ones
+// aren't returned
+const gitHubFeedLimit = 300
+
+// GitHubEvent represents an event from the GitHub events API
+type GitHubEvent struct {
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Payload   struct {
+		RefType string      `json:"ref_type"`         // for CreateEvent
+		Forkee  *GitHubRepo `json:"forkee,omitempty"` // for ForkEvent
+	} `json:"payload"`
+}
+
+// ReposChangedSince reads the user's or org's event feed back to since.
+// Org feeds are read through the token's user, so that events of private
+// repos are included.
+func (g *GitHubConnector) ReposChangedSince(ctx context.Context, userOrOrg string, since time.Time) (bool, error) {
+	userType, err := g.getUserType(ctx, userOrOrg)
+	if err != nil {
+		return false, err
 	}
 
-	return repos, hasMore, nil
+	feed := fmt.Sprintf("%s/users/%s/events", g.apiURL(), userOrOrg)
+	if userType == "Organization" {
+		login, err := g.CurrentUser(ctx)
+		if err != nil {
+			return false, err
+		}
+		feed = fmt.Sprintf("%s/users/%s/events/orgs/%s", g.apiURL(), login, userOrOrg)
+	}
+
+	read := 0
+	for page := 1; ; page++ {
+		url := fmt.Sprintf("%s?per_page=100&page=%d", feed, page)
+		events, hasMore, err := g.fetchEventPage(ctx, url)
+		if err != nil {
+			return false, err
+		}
+
+		for _, event := range events {
+			if !event.CreatedAt.After(since) {
+				return false, nil
+			}
+			if gitHubRepoEvent(event, userOrOrg) {
+				return true, nil
+			}
+		}
+
+		read += len(events)
+		if !hasMore {
+			// A full feed may have dropped events older than its oldest
+			return read >= gitHubFeedLimit, nil
+		}
+	}
+}
+
+// gitHubRepoEvent reports whether an event created a repo owned by owner or
+// made one visible
+func gitHubRepoEvent(event GitHubEvent, owner string) bool {
+	switch event.Type {
+	case "CreateEvent":
+		return event.Payload.RefType == "repository"
+	case "PublicEvent":
+		return true
+	case "ForkEvent":
+		forkee := event.Payload.Forkee
+		return forkee != nil && strings.HasPrefix(strings.ToLower(forkee.FullName), strings.ToLower(owner)+"/")
+	}
+	return false
 }
 
+// fetchEventPage fetches a single page of an event feed, newest first
+func (g *GitHubConnector) fetchEventPage(ctx context.Context, url string) ([]GitHubEvent, bool, error) {
+	resp, err := g.doRequest(ctx, "GET", url)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to fetch events: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, false, apiError(resp, "failed to fetch events")
+	}
+
+	var events []GitHubEvent
+	if err := json.NewDecoder(resp.Body).Decode(&events); err != nil {
+		return nil, false, fmt.Errorf("failed to decode events: %w", err)
+	}
+
+	return events, parsePageLinks(resp.Header.Get("Link")).next, nil
+}
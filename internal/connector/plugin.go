@@ -0,0 +1,118 @@
+package connector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// PluginConnector implements the Connector interface by delegating to an
+// external executable. The plugin receives a JSON request on stdin and
+// writes a JSON response to stdout.
+type PluginConnector struct {
+	command string
+	args    []string
+	host    string
+	token   string
+}
+
+// PluginRequest is the JSON payload written to the plugin's stdin
+type PluginRequest struct {
+	Action string `json:"action"` // "list-repos" or "test"
+	Host   string `json:"host"`
+	Owner  string `json:"owner,omitempty"`
+	Token  string `json:"token,omitempty"`
+}
+
+// PluginResponse is the JSON payload read from the plugin's stdout
+type PluginResponse struct {
+	Repos []string `json:"repos,omitempty"`
+	Error string   `json:"error,omitempty"`
+}
+
+const (
+	PluginActionListRepos = "list-repos"
+	PluginActionTest      = "test"
+)
+
+// NewPluginConnector creates a new plugin connector.
+// The command may include arguments separated by whitespace.
+func NewPluginConnector(command, host, token string) *PluginConnector {
+	fields := strings.Fields(command)
+	p := &PluginConnector{
+		host:  host,
+		token: token,
+	}
+	if len(fields) > 0 {
+		p.command = fields[0]
+		p.args = fields[1:]
+	}
+	return p
+}
+
+// Name returns the connector name
+func (p *PluginConnector) Name() string {
+	return "plugin"
+}
+
+// TestConnection asks the plugin to verify it can reach the forge
+func (p *PluginConnector) TestConnection(ctx context.Context) error {
+	_, err := p.call(ctx, PluginRequest{Action: PluginActionTest, Host: p.host, Token: p.token})
+	return err
+}
+
+// ListRepos asks the plugin for all repos of the given user/org
+func (p *PluginConnector) ListRepos(ctx context.Context, userOrOrg string) ([]string, error) {
+	resp, err := p.call(ctx, PluginRequest{
+		Action: PluginActionListRepos,
+		Host:   p.host,
+		Owner:  userOrOrg,
+		Token:  p.token,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Repos, nil
+}
+
+// call runs the plugin binary with the request on stdin and decodes its response
+func (p *PluginConnector) call(ctx context.Context, req PluginRequest) (*PluginResponse, error) {
+	if p.command == "" {
+		return nil, fmt.Errorf("plugin command is not configured")
+	}
+
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode plugin request: %w", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.command, p.args...)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.Env = append(os.Environ(), "AG_PLUGIN_ACTION="+req.Action)
+
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			return nil, fmt.Errorf("plugin %s failed: %w", p.command, err)
+		}
+		return nil, fmt.Errorf("plugin %s failed: %w: %s", p.command, err, msg)
+	}
+
+	var resp PluginResponse
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return nil, fmt.Errorf("failed to decode plugin response: %w", err)
+	}
+
+	if resp.Error != "" {
+		return nil, fmt.Errorf("plugin %s: %s", p.command, resp.Error)
+	}
+
+	return &resp, nil
+}
@@ -0,0 +1,176 @@
+package connector
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arch-err/autogitter/internal/errs"
+)
+
+const (
+	// maxRateLimitWait is the longest a request waits for a rate limit to
+	// reset; longer waits, e.g. an exhausted hourly quota, fail instead
+	maxRateLimitWait = 2 * time.Minute
+	// maxRateLimitRetries is how often a rate limited request is retried
+	maxRateLimitRetries = 3
+	// secondaryRateLimitWait is the wait when GitHub doesn't give one; its
+	// docs ask for at least a minute
+	secondaryRateLimitWait = time.Minute
+	// maxRequestInterval caps the spacing slowDown adds between requests
+	maxRequestInterval = 10 * time.Second
+)
+
+// throttle spaces out the requests to a host once it has asked to slow down
+type throttle struct {
+	mu       sync.Mutex
+	interval time.Duration // 0 until the host rate limits
+	next     time.Time
+}
+
+var (
+	throttlesMu sync.Mutex
+	throttles   = make(map[string]*throttle)
+
+	rateLimitMu      sync.RWMutex
+	rateLimitHandler func(host string, wait time.Duration)
+)
+
+// OnRateLimit sets fn to be called before each wait for a host's rate limit
+// to reset, e.g. to tell the user why a listing stalls. A nil fn removes it.
+func OnRateLimit(fn func(host string, wait time.Duration)) {
+	rateLimitMu.Lock()
+	defer rateLimitMu.Unlock()
+	rateLimitHandler = fn
+}
+
+// notifyRateLimit passes a wait to the handler set with OnRateLimit
+func notifyRateLimit(host string, wait time.Duration) {
+	rateLimitMu.RLock()
+	fn := rateLimitHandler
+	rateLimitMu.RUnlock()
+	if fn != nil {
+		fn(host, wait)
+	}
+}
+
+// throttleFor returns the throttle of a host, shared by all its connectors
+func throttleFor(host string) *throttle {
+	throttlesMu.Lock()
+	defer throttlesMu.Unlock()
+	t := throttles[host]
+	if t == nil {
+		t = &throttle{}
+		throttles[host] = t
+	}
+	return t
+}
+
+// wait blocks until the next request may be sent
+func (t *throttle) wait(ctx context.Context) error {
+	t.mu.Lock()
+	start := t.next
+	if now := time.Now(); start.Before(now) {
+		start = now
+	}
+	t.next = start.Add(t.interval)
+	t.mu.Unlock()
+	return sleep(ctx, time.Until(start))
+}
+
+// slowDown doubles the spacing between requests, starting at a second
+func (t *throttle) slowDown() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.interval = min(max(2*t.interval, time.Second), maxRequestInterval)
+}
+
+// sleep waits for d, or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// sendThrottled sends the request newReq builds, waiting out GitHub's
+// primary and secondary rate limits and abuse detection, and slowing down
+// later requests to the host. newReq is called again for each retry.
+func sendThrottled(ctx context.Context, client *http.Client, host string, newReq func() (*http.Request, error)) (*http.Response, error) {
+	t := throttleFor(host)
+	for attempt := 0; ; attempt++ {
+		if err := t.wait(ctx); err != nil {
+			return nil, err
+		}
+		req, err := newReq()
+		if err != nil {
+			return nil, err
+		}
+		resp, err := send(client, req)
+		if err != nil {
+			return nil, err
+		}
+
+		wait, limited := gitHubRateLimit(resp)
+		if !limited {
+			return resp, nil
+		}
+		resp.Body.Close()
+		t.slowDown()
+
+		if wait > maxRateLimitWait {
+			return nil, &errs.RateLimitError{Err: fmt.Errorf("%s asks to wait %s, until %s; try again later or use a token with a higher limit",
+				host, wait.Round(time.Second), time.Now().Add(wait).Format(time.Kitchen))}
+		}
+		if attempt == maxRateLimitRetries {
+			return nil, &errs.RateLimitError{Err: fmt.Errorf("%s still rate limits after %d retries; try again later", host, attempt)}
+		}
+		notifyRateLimit(host, wait)
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// gitHubRateLimit reports whether a response is GitHub refusing a request
+// for going over a rate limit, and how long it asks to wait. Secondary
+// limits and abuse detection without a Retry-After are only told apart by
+// the message, so the body is read and put back.
+func gitHubRateLimit(resp *http.Response) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+
+	if after := resp.Header.Get("Retry-After"); after != "" {
+		if secs, err := strconv.Atoi(after); err == nil {
+			return time.Duration(secs) * time.Second, true
+		}
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			return max(time.Until(time.Unix(reset, 0)), 0) + time.Second, true
+		}
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	msg := strings.ToLower(string(body))
+	if strings.Contains(msg, "secondary rate limit") || strings.Contains(msg, "abuse detection") {
+		return secondaryRateLimitWait, true
+	}
+	return 0, false
+}
@@ -0,0 +1,134 @@
+// Package errs defines the kinds of failure that git commands and provider
+// APIs report, so callers can tell them apart with errors.As instead of
+// matching on messages.
+package errs
+
+import "errors"
+
+// AuthError is a rejected credential: an invalid API token, or an SSH key or
+// HTTPS login the remote refused
+type AuthError struct {
+	Err error
+}
+
+func (e *AuthError) Error() string { return "authentication failed: " + e.Err.Error() }
+func (e *AuthError) Unwrap() error { return e.Err }
+
+// HostKeyError is an SSH host key that isn't trusted, or doesn't match the
+// pinned one
+type HostKeyError struct {
+	Err error
+}
+
+func (e *HostKeyError) Error() string { return "host key verification failed: " + e.Err.Error() }
+func (e *HostKeyError) Unwrap() error { return e.Err }
+
+// NotFoundError is a repo that doesn't exist, or that the credentials can't
+// see; providers answer both the same way
+type NotFoundError struct {
+	Err error
+}
+
+func (e *NotFoundError) Error() string { return "not found: " + e.Err.Error() }
+func (e *NotFoundError) Unwrap() error { return e.Err }
+
+// NetworkError is a host that couldn't be reached, or a connection that
+// dropped. Unlike the other kinds, trying again later may succeed.
+type NetworkError struct {
+	Err error
+}
+
+func (e *NetworkError) Error() string { return "network error: " + e.Err.Error() }
+func (e *NetworkError) Unwrap() error { return e.Err }
+
+// DirtyWorktreeError is a checkout or pull refused because it would
+// overwrite uncommitted changes
+type DirtyWorktreeError struct {
+	Err error
+}
+
+func (e *DirtyWorktreeError) Error() string { return "uncommitted changes: " + e.Err.Error() }
+func (e *DirtyWorktreeError) Unwrap() error { return e.Err }
+
+// RateLimitError is a provider refusing requests until a rate limit resets.
+// Like a NetworkError, trying again later may succeed.
+type RateLimitError struct {
+	Err error
+}
+
+func (e *RateLimitError) Error() string { return "rate limited: " + e.Err.Error() }
+func (e *RateLimitError) Unwrap() error { return e.Err }
+
+// CorruptRepoError is a local repository with missing or damaged objects,
+// which a fresh clone fixes
+type CorruptRepoError struct {
+	Err error
+}
+
+func (e *CorruptRepoError) Error() string { return "repository corrupted: " + e.Err.Error() }
+func (e *CorruptRepoError) Unwrap() error { return e.Err }
+
+// ConflictError is a pull that left conflicts in the working tree: local
+// commits conflict with the pulled ones, or local changes stashed for the
+// pull didn't apply on top of them
+type ConflictError struct {
+	Err     error
+	Files   []string // conflicting files, relative to the repo
+	Stashed bool     // the local changes are still in the stash
+}
+
+func (e *ConflictError) Error() string { return "merge conflict: " + e.Err.Error() }
+func (e *ConflictError) Unwrap() error { return e.Err }
+
+// Kinds of failure, as reported by Kind
+const (
+	KindAuth      = "auth"
+	KindHostKey   = "host_key"
+	KindNotFound  = "not_found"
+	KindNetwork   = "network"
+	KindDirty     = "dirty"
+	KindRateLimit = "rate_limit"
+	KindCorrupt   = "corrupt"
+	KindConflict  = "conflict"
+)
+
+// Kind classifies err as one of the Kind constants, or "" when it isn't one
+// of the errors in this package
+func Kind(err error) string {
+	var (
+		auth     *AuthError
+		hostKey  *HostKeyError
+		notFound *NotFoundError
+		network  *NetworkError
+		dirty    *DirtyWorktreeError
+		limited  *RateLimitError
+		corrupt  *CorruptRepoError
+		conflict *ConflictError
+	)
+	switch {
+	case errors.As(err, &auth):
+		return KindAuth
+	case errors.As(err, &hostKey):
+		return KindHostKey
+	case errors.As(err, &notFound):
+		return KindNotFound
+	case errors.As(err, &network):
+		return KindNetwork
+	case errors.As(err, &dirty):
+		return KindDirty
+	case errors.As(err, &limited):
+		return KindRateLimit
+	case errors.As(err, &corrupt):
+		return KindCorrupt
+	case errors.As(err, &conflict):
+		return KindConflict
+	}
+	return ""
+}
+
+// IsCredentials reports whether err was caused by credentials or host keys,
+// which need fixing before a retry can succeed
+func IsCredentials(err error) bool {
+	kind := Kind(err)
+	return kind == KindAuth || kind == KindHostKey
+}
@@ -0,0 +1,45 @@
+package git
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	gosync "sync"
+)
+
+var (
+	bandwidthMu    gosync.RWMutex
+	bandwidthLimit int64 // bytes per second per transfer, 0 for unlimited
+)
+
+// LimitBandwidth caps the transfer rate of every later clone, pull, and fetch
+// at bytesPerSec in each direction, by running git under trickle. Zero
+// removes the limit. Fails if trickle isn't installed.
+func LimitBandwidth(bytesPerSec int64) error {
+	if bytesPerSec > 0 {
+		if _, err := exec.LookPath("trickle"); err != nil {
+			return fmt.Errorf("trickle is not installed")
+		}
+	}
+
+	bandwidthMu.Lock()
+	defer bandwidthMu.Unlock()
+	bandwidthLimit = bytesPerSec
+	return nil
+}
+
+// transferCommand returns a git command for a network transfer, wrapped in
+// trickle when a bandwidth limit is set. trickle's preloaded shaper is
+// inherited by ssh and git-remote-https, which do the actual transfer.
+func transferCommand(args ...string) *exec.Cmd {
+	bandwidthMu.RLock()
+	limit := bandwidthLimit
+	bandwidthMu.RUnlock()
+
+	if limit <= 0 {
+		return exec.Command("git", args...)
+	}
+
+	rate := strconv.FormatInt(max(limit/1024, 1), 10) // trickle counts in KB/s
+	return exec.Command("trickle", append([]string{"-s", "-d", rate, "-u", rate, "git"}, args...)...)
+}
@@ -0,0 +1,124 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/log"
+)
+
+// ListRefs returns all refs of a repo mapped to the commit SHA they point at
+func ListRefs(path string) (map[string]string, error) {
+	cmd := exec.Command("git", "-C", path, "for-each-ref", "--format=%(objectname) %(refname)")
+	output, err := execOutput(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list refs: %w", err)
+	}
+
+	refs := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		parts := strings.SplitN(line, " ", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		refs[parts[1]] = parts[0]
+	}
+	return refs, nil
+}
+
+// HasObject reports whether the repo contains the given object
+func HasObject(path, sha string) bool {
+	cmd := exec.Command("git", "-C", path, "cat-file", "-e", sha+"^{commit}")
+	return execRun(cmd) == nil
+}
+
+// CreateBundle writes a bundle of all refs to file. Commits reachable from
+// any of the prerequisites are excluded, producing an incremental bundle.
+// Returns false without error when there is nothing new to bundle.
+func CreateBundle(path, file string, prerequisites []string) (bool, error) {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return false, fmt.Errorf("failed to create bundle directory: %w", err)
+	}
+
+	args := []string{"-C", path, "bundle", "create", file, "--all"}
+	for _, sha := range prerequisites {
+		args = append(args, "^"+sha)
+	}
+
+	cmd := exec.Command("git", args...)
+	output, err := execCombinedOutput(cmd)
+	if err != nil {
+		if strings.Contains(string(output), "empty bundle") {
+			return false, nil
+		}
+		return false, commandError("bundle create", err, output)
+	}
+
+	log.Debug("created bundle", "path", path, "file", file)
+	return true, nil
+}
+
+// RestoreOptions describes how to re-create a clone from bundles
+type RestoreOptions struct {
+	Path      string
+	Bundles   []string // applied in order, oldest first
+	Branch    string   // branch to check out
+	RemoteURL string   // origin URL to configure
+}
+
+// RestoreFromBundles creates a new repo at opts.Path from a full bundle
+// followed by any incremental bundles.
+func RestoreFromBundles(opts RestoreOptions) error {
+	if opts.Path == "" {
+		return fmt.Errorf("path is required")
+	}
+	if len(opts.Bundles) == 0 {
+		return fmt.Errorf("at least one bundle is required")
+	}
+
+	if err := run("init", "--quiet", opts.Path); err != nil {
+		return err
+	}
+
+	for _, bundle := range opts.Bundles {
+		if err := run("-C", opts.Path, "fetch", "--quiet", "--update-head-ok", bundle, "+refs/*:refs/*"); err != nil {
+			return err
+		}
+	}
+
+	if opts.RemoteURL != "" {
+		if err := run("-C", opts.Path, "remote", "add", "origin", opts.RemoteURL); err != nil {
+			return err
+		}
+	}
+
+	if opts.Branch != "" && opts.Branch != "HEAD" {
+		if err := run("-C", opts.Path, "symbolic-ref", "HEAD", "refs/heads/"+opts.Branch); err != nil {
+			return err
+		}
+	}
+
+	if err := run("-C", opts.Path, "reset", "--quiet", "--hard"); err != nil {
+		return err
+	}
+
+	log.Debug("restored repository", "path", opts.Path, "bundles", len(opts.Bundles))
+	return nil
+}
+
+// run executes a git command and includes its output in the error
+func run(args ...string) error {
+	cmd := exec.Command("git", args...)
+	output, err := execCombinedOutput(cmd)
+	if err != nil {
+		subcommand := args[0]
+		if subcommand == "-C" && len(args) > 2 {
+			subcommand = args[2]
+		}
+		return commandError(subcommand, err, output)
+	}
+	return nil
+}
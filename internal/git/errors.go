@@ -0,0 +1,119 @@
+package git
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/arch-err/autogitter/internal/errs"
+)
+
+// Messages git, ssh, and curl print for each kind of failure, lowercased.
+// Checked in this order: a rejected key also ends in "could not read from
+// remote repository", so auth and host keys come before the network, and a
+// fetch that dies on a broken local object can also say "early eof".
+var failurePatterns = []struct {
+	wrap     func(error) error
+	messages []string
+}{
+	{
+		wrap: func(err error) error { return &errs.HostKeyError{Err: err} },
+		messages: []string{
+			"host key verification failed",
+			"remote host identification has changed",
+		},
+	},
+	{
+		wrap: func(err error) error { return &errs.AuthError{Err: err} },
+		messages: []string{
+			"permission denied (publickey",
+			"authentication failed",
+			"could not read username",
+			"could not read password",
+			"terminal prompts disabled",
+			"invalid username or password",
+			"access denied",
+			"requested url returned error: 401",
+			"requested url returned error: 403",
+		},
+	},
+	{
+		wrap: func(err error) error { return &errs.NotFoundError{Err: err} },
+		messages: []string{
+			"repository not found",
+			"repository does not exist",
+			"does not appear to be a git repository",
+			"requested url returned error: 404",
+		},
+	},
+	{
+		wrap: func(err error) error { return &errs.CorruptRepoError{Err: err} },
+		messages: []string{
+			"is corrupt",
+			"index file corrupt",
+			"bad object",
+			"error: object file",
+			"inflate: data stream error",
+			"unable to unpack",
+			"hash mismatch",
+			"sha1 mismatch",
+			"invalid sha1 pointer",
+			"did not receive expected object",
+			"unable to read tree",
+			"cannot be accessed",
+		},
+	},
+	{
+		wrap: func(err error) error { return &errs.NetworkError{Err: err} },
+		messages: []string{
+			"could not resolve host",
+			"could not resolve hostname",
+			"connection refused",
+			"connection timed out",
+			"operation timed out",
+			"network is unreachable",
+			"connection reset",
+			"failed to connect to",
+			"early eof",
+			"the remote end hung up unexpectedly",
+		},
+	},
+	{
+		wrap: func(err error) error { return &errs.ConflictError{Err: err} },
+		messages: []string{
+			"automatic merge failed",
+			"conflict (content)",
+			"could not apply",
+			"unresolved conflict",
+			"you have unmerged files",
+			"you have not concluded your merge",
+		},
+	},
+	{
+		wrap: func(err error) error { return &errs.DirtyWorktreeError{Err: err} },
+		messages: []string{
+			"your local changes to the following files would be overwritten",
+			"untracked working tree files would be overwritten",
+			"please commit your changes or stash them",
+		},
+	},
+}
+
+// classify wraps the error of a git command in the errs type matching what
+// git printed, or returns it as is when the failure isn't recognized
+func classify(err error, output []byte) error {
+	out := strings.ToLower(string(output))
+	for _, kind := range failurePatterns {
+		for _, msg := range kind.messages {
+			if strings.Contains(out, msg) {
+				return kind.wrap(err)
+			}
+		}
+	}
+	return err
+}
+
+// commandError describes a failed git command with its classified error,
+// followed by git's output on the next lines
+func commandError(what string, err error, output []byte) error {
+	return fmt.Errorf("git %s failed: %w\n%s", what, classify(err, output), string(output))
+}
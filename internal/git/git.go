@@ -1,27 +1,44 @@
 package git
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/arch-err/autogitter/internal/errs"
 	"github.com/charmbracelet/log"
 )
 
 type CloneOptions struct {
-	URL        string
-	Path       string
-	Branch     string
-	PrivateKey string
-	Submodules bool
+	URL              string
+	Path             string
+	Branch           string
+	PrivateKey       string
+	CredentialHelper string
+	Submodules       bool
+	Tags             string // "all", "none", or "" for git's default of reachable tags
+	KnownHostsFile   string // only trust host keys from this file, see PinHostKey
+	ShallowSince     string // only fetch history after this date
+	SingleBranch     bool   // only fetch the cloned branch
+	Sparse           string // only check out this directory, fetching no other file contents
+	// Progress, when set, is called as git reports progress, with the phase
+	// (e.g. "receiving objects") and its percentage
+	Progress func(phase string, percent int)
 }
 
 type PullOptions struct {
-	Path       string
-	PrivateKey string
-	Submodules bool
+	Path             string
+	PrivateKey       string
+	CredentialHelper string
+	Submodules       bool
+	PruneRefs        bool   // drop remote-tracking branches and tags deleted on the remote
+	Tags             string // "all", "none", or "" for git's default of reachable tags
+	KnownHostsFile   string // only trust host keys from this file, see PinHostKey
 }
 
 func Clone(opts CloneOptions) error {
@@ -40,6 +57,11 @@ func Clone(opts CloneOptions) error {
 
 	args := []string{"clone"}
 
+	if opts.Progress != nil {
+		// git only reports progress to a terminal unless asked
+		args = append(args, "--progress")
+	}
+
 	if opts.Submodules {
 		args = append(args, "--recurse-submodules")
 	}
@@ -48,22 +70,60 @@ func Clone(opts CloneOptions) error {
 		args = append(args, "--branch", opts.Branch)
 	}
 
-	args = append(args, opts.URL, opts.Path)
+	if opts.ShallowSince != "" {
+		args = append(args, "--shallow-since="+opts.ShallowSince)
+	}
 
-	cmd := exec.Command("git", args...)
+	if opts.SingleBranch {
+		args = append(args, "--single-branch")
+	}
+
+	if opts.Sparse != "" {
+		args = append(args, "--filter=blob:none", "--sparse")
+	}
 
-	// Handle custom SSH key
-	if opts.PrivateKey != "" {
-		sshCmd := fmt.Sprintf("ssh -i %s -o IdentitiesOnly=yes -o StrictHostKeyChecking=accept-new", opts.PrivateKey)
-		cmd.Env = append(os.Environ(), "GIT_SSH_COMMAND="+sshCmd)
+	switch opts.Tags {
+	case "none":
+		args = append(args, "--no-tags")
+	case "all":
+		// Stored in the clone's config, so the initial fetch and later ones get every tag
+		args = append(args, "--config", "remote.origin.tagOpt=--tags")
 	}
 
-	output, err := cmd.CombinedOutput()
+	args = append(args, opts.URL, opts.Path)
+
+	cmd := transferCommand(args...)
+
+	// Handle custom SSH key and pinned host keys
+	setSSHCommand(cmd, opts.PrivateKey, opts.KnownHostsFile)
+	setCredentialHelper(cmd, opts.CredentialHelper)
+
+	var output []byte
+	var err error
+	if opts.Progress != nil {
+		progress := &progressWriter{report: opts.Progress}
+		cmd.Stdout = progress
+		cmd.Stderr = progress
+		err = execRun(cmd)
+		output = progress.Output()
+	} else {
+		output, err = execCombinedOutput(cmd)
+	}
 	if err != nil {
-		return fmt.Errorf("git clone failed: %w\n%s", err, string(output))
+		return commandError("clone", err, output)
+	}
+
+	if opts.Sparse != "" {
+		// The subpath's files are fetched now, so this needs the clone's credentials
+		cmd := exec.Command("git", "-C", opts.Path, "sparse-checkout", "set", opts.Sparse)
+		setSSHCommand(cmd, opts.PrivateKey, opts.KnownHostsFile)
+		setCredentialHelper(cmd, opts.CredentialHelper)
+		if output, err := execCombinedOutput(cmd); err != nil {
+			return commandError("sparse-checkout", err, output)
+		}
 	}
 
-	log.Debug("cloned repository", "url", opts.URL, "path", opts.Path)
+	log.Debug("cloned repository", "url", opts.URL, "path", opts.Path, "sparse", opts.Sparse)
 	return nil
 }
 
@@ -72,30 +132,51 @@ func Pull(opts PullOptions) error {
 		return fmt.Errorf("path is required")
 	}
 
-	cmd := exec.Command("git", "-C", opts.Path, "pull")
-
-	// Handle custom SSH key
-	if opts.PrivateKey != "" {
-		sshCmd := fmt.Sprintf("ssh -i %s -o IdentitiesOnly=yes -o StrictHostKeyChecking=accept-new", opts.PrivateKey)
-		cmd.Env = append(os.Environ(), "GIT_SSH_COMMAND="+sshCmd)
+	args := []string{"-C", opts.Path}
+	if opts.PruneRefs {
+		// pull has no --prune-tags, so set it for the fetch pull runs
+		args = append(args, "-c", "fetch.prune=true", "-c", "fetch.pruneTags=true")
+	}
+	args = append(args, "pull")
+	switch opts.Tags {
+	case "none":
+		args = append(args, "--no-tags")
+	case "all":
+		args = append(args, "--tags")
 	}
 
-	output, err := cmd.CombinedOutput()
+	cmd := transferCommand(args...)
+
+	// Handle custom SSH key and pinned host keys
+	setSSHCommand(cmd, opts.PrivateKey, opts.KnownHostsFile)
+	setCredentialHelper(cmd, opts.CredentialHelper)
+
+	output, err := execCombinedOutput(cmd)
 	if err != nil {
-		return fmt.Errorf("git pull failed: %w\n%s", err, string(output))
+		err = commandError("pull", err, output)
+		var conflict *errs.ConflictError
+		if errors.As(err, &conflict) {
+			conflict.Files, _ = ConflictedFiles(opts.Path)
+		}
+		return err
+	}
+	// With autostash, git keeps the local changes in the stash and still
+	// succeeds when they don't apply on top of the pulled commits
+	if strings.Contains(strings.ToLower(string(output)), "applying autostash resulted in conflicts") {
+		files, _ := ConflictedFiles(opts.Path)
+		conflict := &errs.ConflictError{Err: errors.New("local changes didn't apply after pulling"), Files: files, Stashed: true}
+		return fmt.Errorf("git pull: %w\n%s", conflict, string(output))
 	}
 
 	log.Debug("pulled repository", "path", opts.Path)
 
 	if opts.Submodules {
-		subCmd := exec.Command("git", "-C", opts.Path, "submodule", "update", "--init", "--recursive")
-		if opts.PrivateKey != "" {
-			sshCmd := fmt.Sprintf("ssh -i %s -o IdentitiesOnly=yes -o StrictHostKeyChecking=accept-new", opts.PrivateKey)
-			subCmd.Env = append(os.Environ(), "GIT_SSH_COMMAND="+sshCmd)
-		}
-		subOutput, subErr := subCmd.CombinedOutput()
+		subCmd := transferCommand("-C", opts.Path, "submodule", "update", "--init", "--recursive")
+		setSSHCommand(subCmd, opts.PrivateKey, opts.KnownHostsFile)
+		setCredentialHelper(subCmd, opts.CredentialHelper)
+		subOutput, subErr := execCombinedOutput(subCmd)
 		if subErr != nil {
-			return fmt.Errorf("git submodule update failed: %w\n%s", subErr, string(subOutput))
+			return commandError("submodule update", subErr, subOutput)
 		}
 		log.Debug("updated submodules", "path", opts.Path)
 	}
@@ -103,6 +184,146 @@ func Pull(opts PullOptions) error {
 	return nil
 }
 
+type FetchOptions struct {
+	Path             string
+	Remote           string
+	PrivateKey       string
+	CredentialHelper string
+	KnownHostsFile   string // only trust host keys from this file, see PinHostKey
+}
+
+// Fetch fetches a remote other than origin, e.g. "upstream"
+func Fetch(opts FetchOptions) error {
+	if opts.Path == "" {
+		return fmt.Errorf("path is required")
+	}
+
+	cmd := transferCommand("-C", opts.Path, "fetch", "--prune", opts.Remote)
+	setSSHCommand(cmd, opts.PrivateKey, opts.KnownHostsFile)
+	setCredentialHelper(cmd, opts.CredentialHelper)
+
+	output, err := execCombinedOutput(cmd)
+	if err != nil {
+		return commandError("fetch "+opts.Remote, err, output)
+	}
+
+	log.Debug("fetched remote", "path", opts.Path, "remote", opts.Remote)
+	return nil
+}
+
+type PushBranchOptions struct {
+	Path             string
+	Remote           string
+	Branch           string
+	PrivateKey       string
+	CredentialHelper string
+	KnownHostsFile   string // only trust host keys from this file, see PinHostKey
+}
+
+// PushBranch pushes a local branch to the branch of the same name on a remote
+func PushBranch(opts PushBranchOptions) error {
+	if opts.Path == "" {
+		return fmt.Errorf("path is required")
+	}
+
+	cmd := transferCommand("-C", opts.Path, "push", opts.Remote, "refs/heads/"+opts.Branch+":refs/heads/"+opts.Branch)
+	setSSHCommand(cmd, opts.PrivateKey, opts.KnownHostsFile)
+	setCredentialHelper(cmd, opts.CredentialHelper)
+
+	output, err := execCombinedOutput(cmd)
+	if err != nil {
+		return commandError("push", err, output)
+	}
+
+	log.Debug("pushed branch", "path", opts.Path, "remote", opts.Remote, "branch", opts.Branch)
+	return nil
+}
+
+type PushMirrorOptions struct {
+	Path           string
+	URL            string
+	PrivateKey     string
+	KnownHostsFile string // only trust host keys from this file, see PinHostKey
+}
+
+// mirrorRefspecs push origin's branches as the mirror's branches, plus all
+// tags. A working clone only has the branches that were checked out under
+// refs/heads, so its remote-tracking refs are what the mirror must match.
+var mirrorRefspecs = []string{"+refs/remotes/origin/*:refs/heads/*", "+refs/tags/*:refs/tags/*"}
+
+// PushMirror replicates origin's branches and tags to a secondary remote,
+// deleting branches and tags that are gone from origin
+func PushMirror(opts PushMirrorOptions) error {
+	if opts.Path == "" {
+		return fmt.Errorf("path is required")
+	}
+	if opts.URL == "" {
+		return fmt.Errorf("URL is required")
+	}
+
+	args := append([]string{"-C", opts.Path, "push", "--prune", opts.URL}, mirrorRefspecs...)
+	// origin/HEAD would match the branch pattern and become a branch named HEAD
+	if _, err := LocalDefaultBranch(opts.Path); err == nil {
+		args = append(args, ":refs/heads/HEAD")
+	}
+	cmd := transferCommand(args...)
+	setSSHCommand(cmd, opts.PrivateKey, opts.KnownHostsFile)
+
+	output, err := execCombinedOutput(cmd)
+	if err != nil {
+		return commandError("push --prune", err, output)
+	}
+
+	log.Debug("pushed mirror", "path", opts.Path, "url", opts.URL)
+	return nil
+}
+
+// setSSHKey configures the command to use a specific SSH private key
+func setSSHKey(cmd *exec.Cmd, privateKey string) {
+	setSSHCommand(cmd, privateKey, "")
+}
+
+// setSSHCommand configures the command to use a specific SSH private key
+// and, when knownHosts is set, to only accept host keys listed in that file
+func setSSHCommand(cmd *exec.Cmd, privateKey, knownHosts string) {
+	if privateKey == "" && knownHosts == "" {
+		return
+	}
+
+	sshCmd := "ssh"
+	if privateKey != "" {
+		sshCmd += fmt.Sprintf(" -i %s -o IdentitiesOnly=yes", privateKey)
+	}
+	if knownHosts != "" {
+		sshCmd += fmt.Sprintf(" -o UserKnownHostsFile=%s -o StrictHostKeyChecking=yes", knownHosts)
+	} else {
+		sshCmd += " -o StrictHostKeyChecking=accept-new"
+	}
+	setEnv(cmd, "GIT_SSH_COMMAND="+sshCmd)
+}
+
+// setCredentialHelper makes git use only the given credential helper for
+// HTTPS remotes, e.g. "manager", "osxkeychain", or "libsecret". The empty
+// value first clears helpers from the user's git config.
+func setCredentialHelper(cmd *exec.Cmd, helper string) {
+	if helper == "" {
+		return
+	}
+	setEnv(cmd,
+		"GIT_CONFIG_COUNT=2",
+		"GIT_CONFIG_KEY_0=credential.helper", "GIT_CONFIG_VALUE_0=",
+		"GIT_CONFIG_KEY_1=credential.helper", "GIT_CONFIG_VALUE_1="+helper)
+}
+
+// setEnv adds variables to the command's environment, starting from the
+// current process environment
+func setEnv(cmd *exec.Cmd, vars ...string) {
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, vars...)
+}
+
 func IsGitRepo(path string) bool {
 	gitDir := filepath.Join(path, ".git")
 	info, err := os.Stat(gitDir)
@@ -113,23 +334,394 @@ func IsGitRepo(path string) bool {
 }
 
 func GetRemoteURL(path string) (string, error) {
-	cmd := exec.Command("git", "-C", path, "remote", "get-url", "origin")
-	output, err := cmd.Output()
+	return RemoteURL(path, "origin")
+}
+
+// RemoteURL returns the URL of the named remote
+func RemoteURL(path, name string) (string, error) {
+	cmd := exec.Command("git", "-C", path, "remote", "get-url", name)
+	output, err := execOutput(cmd)
 	if err != nil {
 		return "", fmt.Errorf("failed to get remote URL: %w", err)
 	}
 	return strings.TrimSpace(string(output)), nil
 }
 
+// SetRemoteURL changes the URL of the origin remote
+func SetRemoteURL(path, url string) error {
+	cmd := exec.Command("git", "-C", path, "remote", "set-url", "origin", url)
+	output, err := execCombinedOutput(cmd)
+	if err != nil {
+		return fmt.Errorf("failed to set remote URL: %w\n%s", err, string(output))
+	}
+	return nil
+}
+
+// SetRemote adds a remote, or changes its URL if it already exists
+func SetRemote(path, name, url string) error {
+	cmd := exec.Command("git", "-C", path, "remote", "add", name, url)
+	if _, err := execCombinedOutput(cmd); err == nil {
+		return nil
+	}
+
+	cmd = exec.Command("git", "-C", path, "remote", "set-url", name, url)
+	output, err := execCombinedOutput(cmd)
+	if err != nil {
+		return fmt.Errorf("failed to set remote %s: %w\n%s", name, err, string(output))
+	}
+	return nil
+}
+
+// HasRemote reports whether the repo has a remote with the given name
+func HasRemote(path, name string) bool {
+	return execRun(exec.Command("git", "-C", path, "remote", "get-url", name)) == nil
+}
+
+// CanFastForward reports whether the local branch is strictly behind ref,
+// e.g. "upstream/main", so it can be fast-forwarded to it
+func CanFastForward(path, branch, ref string) (bool, error) {
+	local, err := revParse(path, "refs/heads/"+branch)
+	if err != nil {
+		return false, err
+	}
+	target, err := revParse(path, ref)
+	if err != nil {
+		return false, err
+	}
+	if local == target {
+		return false, nil
+	}
+
+	cmd := exec.Command("git", "-C", path, "merge-base", "--is-ancestor", local, target)
+	if err := execRun(cmd); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			return false, fmt.Errorf("%s has diverged from %s", branch, ref)
+		}
+		return false, fmt.Errorf("failed to compare %s with %s: %w", branch, ref, err)
+	}
+	return true, nil
+}
+
+// FastForward moves the local branch to ref without merging. A checked-out
+// branch is updated with merge --ff-only so the working tree follows.
+func FastForward(path, branch, ref string) error {
+	current, err := GetCurrentBranch(path)
+	if err != nil {
+		return err
+	}
+	if current == branch {
+		return run("-C", path, "merge", "--ff-only", "--quiet", ref)
+	}
+	return run("-C", path, "fetch", "--quiet", ".", ref+":refs/heads/"+branch)
+}
+
+// HeadCommit returns the SHA of the commit HEAD points at
+func HeadCommit(path string) (string, error) {
+	return revParse(path, "HEAD")
+}
+
+// CheckoutCommit checks out a commit with a detached HEAD
+func CheckoutCommit(path, sha string) error {
+	return run("-C", path, "checkout", "--quiet", "--detach", sha)
+}
+
+// ExcludeLocally adds a pattern to the repo's info/exclude, so matching
+// files are ignored without touching .gitignore. Patterns already listed
+// aren't added again.
+func ExcludeLocally(path, pattern string) error {
+	cmd := exec.Command("git", "-C", path, "rev-parse", "--git-path", "info/exclude")
+	output, err := execOutput(cmd)
+	if err != nil {
+		return fmt.Errorf("failed to find info/exclude: %w", err)
+	}
+	exclude := strings.TrimSpace(string(output))
+	if !filepath.IsAbs(exclude) {
+		exclude = filepath.Join(path, exclude)
+	}
+
+	data, err := os.ReadFile(exclude)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == pattern {
+			return nil
+		}
+	}
+
+	if len(data) > 0 && !strings.HasSuffix(string(data), "\n") {
+		data = append(data, '\n')
+	}
+	data = append(data, pattern+"\n"...)
+	if err := os.MkdirAll(filepath.Dir(exclude), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(exclude, data, 0644)
+}
+
+func revParse(path, ref string) (string, error) {
+	cmd := exec.Command("git", "-C", path, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	output, err := execOutput(cmd)
+	if err != nil {
+		return "", fmt.Errorf("unknown ref %s", ref)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// TrackAllBranches creates a local tracking branch for every branch on
+// origin that has none, and fast-forwards existing local branches that are
+// behind their origin branch. Diverged branches are left alone. Returns the
+// number of branches created and updated.
+func TrackAllBranches(path string) (int, int, error) {
+	cmd := exec.Command("git", "-C", path, "for-each-ref", "--format=%(refname:lstrip=3)", "refs/remotes/origin/")
+	output, err := execOutput(cmd)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to list remote branches: %w", err)
+	}
+
+	created, updated := 0, 0
+	for _, branch := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if branch == "" || branch == "HEAD" {
+			continue
+		}
+
+		if _, err := revParse(path, "refs/heads/"+branch); err != nil {
+			if err := run("-C", path, "branch", "--quiet", "--track", branch, "origin/"+branch); err != nil {
+				return created, updated, err
+			}
+			created++
+			continue
+		}
+
+		behind, err := CanFastForward(path, branch, "origin/"+branch)
+		if err != nil || !behind {
+			log.Debug("not fast-forwarding branch", "path", path, "branch", branch, "error", err)
+			continue
+		}
+		if err := FastForward(path, branch, "origin/"+branch); err != nil {
+			return created, updated, err
+		}
+		updated++
+	}
+
+	log.Debug("tracked all branches", "path", path, "created", created, "updated", updated)
+	return created, updated, nil
+}
+
+// GetConfig returns a value from the repo's local git config, or "" when unset
+func GetConfig(path, key string) (string, error) {
+	cmd := exec.Command("git", "-C", path, "config", "--local", "--get", key)
+	output, err := execOutput(cmd)
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get git config %s: %w", key, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// SetConfig sets a value in the repo's local git config
+func SetConfig(path, key, value string) error {
+	cmd := exec.Command("git", "-C", path, "config", "--local", key, value)
+	output, err := execCombinedOutput(cmd)
+	if err != nil {
+		return fmt.Errorf("failed to set git config %s: %w\n%s", key, err, string(output))
+	}
+	return nil
+}
+
 func GetCurrentBranch(path string) (string, error) {
 	cmd := exec.Command("git", "-C", path, "rev-parse", "--abbrev-ref", "HEAD")
-	output, err := cmd.Output()
+	output, err := execOutput(cmd)
 	if err != nil {
 		return "", fmt.Errorf("failed to get current branch: %w", err)
 	}
 	return strings.TrimSpace(string(output)), nil
 }
 
+// LocalDefaultBranch returns the branch origin/HEAD points at, as recorded
+// when the repo was cloned, without asking the remote
+func LocalDefaultBranch(path string) (string, error) {
+	cmd := exec.Command("git", "-C", path, "symbolic-ref", "--short", "refs/remotes/origin/HEAD")
+	output, err := execOutput(cmd)
+	if err != nil {
+		return "", fmt.Errorf("origin/HEAD is not set: %w", err)
+	}
+	return strings.TrimPrefix(strings.TrimSpace(string(output)), "origin/"), nil
+}
+
+// RemoteDefaultBranch asks origin which branch its HEAD points at
+func RemoteDefaultBranch(path, privateKey string) (string, error) {
+	cmd := exec.Command("git", "-C", path, "ls-remote", "--symref", "origin", "HEAD")
+	setSSHKey(cmd, privateKey)
+	output, err := execOutput(cmd)
+	if err != nil {
+		return "", fmt.Errorf("failed to query remote HEAD: %w", err)
+	}
+
+	// Output: "ref: refs/heads/main\tHEAD"
+	for _, line := range strings.Split(string(output), "\n") {
+		if ref, ok := strings.CutPrefix(line, "ref: refs/heads/"); ok {
+			return strings.TrimSuffix(ref, "\tHEAD"), nil
+		}
+	}
+	return "", fmt.Errorf("remote HEAD is not a branch")
+}
+
+// RemoteBranchExists reports whether origin has the given branch
+func RemoteBranchExists(path, branch, privateKey string) (bool, error) {
+	cmd := exec.Command("git", "-C", path, "ls-remote", "--exit-code", "--heads", "origin", branch)
+	setSSHKey(cmd, privateKey)
+	err := execRun(cmd)
+	if err == nil {
+		return true, nil
+	}
+	// --exit-code exits with 2 when no matching refs were found
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 2 {
+		return false, nil
+	}
+	return false, fmt.Errorf("failed to list remote branches: %w", err)
+}
+
+// RemoteExists reports whether a repo exists at url. A repo that isn't
+// there is told apart from a remote that couldn't be reached, which is an
+// error.
+func RemoteExists(url, privateKey, knownHosts, credentialHelper string) (bool, error) {
+	cmd := exec.Command("git", "ls-remote", "--quiet", url, "HEAD")
+	setSSHCommand(cmd, privateKey, knownHosts)
+	setCredentialHelper(cmd, credentialHelper)
+	setEnv(cmd, "GIT_TERMINAL_PROMPT=0")
+	output, err := execCombinedOutput(cmd)
+	if err == nil {
+		return true, nil
+	}
+	err = commandError("ls-remote", err, output)
+	if errs.Kind(err) == errs.KindNotFound {
+		return false, nil
+	}
+	return false, err
+}
+
+type SwitchBranchOptions struct {
+	Path             string
+	From             string // local branch that tracked the old default
+	To               string // new default branch on origin
+	PrivateKey       string
+	CredentialHelper string
+	KnownHostsFile   string // only trust host keys from this file, see PinHostKey
+}
+
+// SwitchBranch moves a clone from an old default branch to a new one. The
+// local branch is renamed when the target doesn't exist locally yet, so
+// unpushed commits are carried over, then set to track origin/<To>.
+func SwitchBranch(opts SwitchBranchOptions) error {
+	if opts.Path == "" {
+		return fmt.Errorf("path is required")
+	}
+
+	fetch := transferCommand("-C", opts.Path, "fetch", "--prune", "origin")
+	setSSHCommand(fetch, opts.PrivateKey, opts.KnownHostsFile)
+	setCredentialHelper(fetch, opts.CredentialHelper)
+	if output, err := execCombinedOutput(fetch); err != nil {
+		return commandError("fetch", err, output)
+	}
+
+	if execRun(exec.Command("git", "-C", opts.Path, "rev-parse", "--verify", "--quiet", "refs/heads/"+opts.To)) == nil {
+		if err := run("-C", opts.Path, "checkout", "--quiet", opts.To); err != nil {
+			return err
+		}
+	} else if err := run("-C", opts.Path, "branch", "-m", opts.From, opts.To); err != nil {
+		return err
+	}
+
+	if err := run("-C", opts.Path, "branch", "--quiet", "--set-upstream-to=origin/"+opts.To, opts.To); err != nil {
+		return err
+	}
+
+	// Best effort: keeps origin/HEAD in line with the new default
+	if err := run("-C", opts.Path, "remote", "set-head", "origin", "--auto"); err != nil {
+		log.Debug("failed to update origin/HEAD", "path", opts.Path, "error", err)
+	}
+
+	log.Debug("switched branch", "path", opts.Path, "from", opts.From, "to", opts.To)
+	return nil
+}
+
+// IsDirty reports whether the working tree has uncommitted or untracked changes
+func IsDirty(path string) (bool, error) {
+	cmd := exec.Command("git", "-C", path, "status", "--porcelain")
+	output, err := execOutput(cmd)
+	if err != nil {
+		return false, fmt.Errorf("failed to get status: %w", err)
+	}
+	return len(strings.TrimSpace(string(output))) > 0, nil
+}
+
+// ConflictedFiles returns the paths, relative to the repo, of files with
+// unresolved merge conflicts
+func ConflictedFiles(path string) ([]string, error) {
+	cmd := exec.Command("git", "-C", path, "diff", "--name-only", "--diff-filter=U", "-z")
+	output, err := execOutput(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list conflicts: %w", err)
+	}
+	var files []string
+	for _, file := range strings.Split(string(output), "\x00") {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// ChangedFiles returns the paths, relative to the repo, of modified, added,
+// and untracked files in the working tree. Ignored files aren't included.
+func ChangedFiles(path string) ([]string, error) {
+	cmd := exec.Command("git", "-C", path, "status", "--porcelain", "-z", "--untracked-files=all")
+	output, err := execOutput(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get status: %w", err)
+	}
+
+	var files []string
+	fields := strings.Split(string(output), "\x00")
+	for i := 0; i < len(fields); i++ {
+		entry := fields[i]
+		if len(entry) < 4 {
+			continue
+		}
+		status, file := entry[:2], entry[3:]
+		if status[0] == 'R' || status[0] == 'C' {
+			i++ // the original path of a rename or copy follows
+		}
+		if status[0] == 'D' || status[1] == 'D' {
+			continue // nothing left to keep
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// AheadBehind returns how many commits HEAD is ahead of and behind its upstream.
+// It uses the locally known remote-tracking state and does not fetch.
+func AheadBehind(path string) (int, int, error) {
+	cmd := exec.Command("git", "-C", path, "rev-list", "--left-right", "--count", "HEAD...@{upstream}")
+	output, err := execOutput(cmd)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to compare with upstream: %w", err)
+	}
+
+	var ahead, behind int
+	if _, err := fmt.Sscanf(strings.TrimSpace(string(output)), "%d\t%d", &ahead, &behind); err != nil {
+		return 0, 0, fmt.Errorf("failed to parse rev-list output: %w", err)
+	}
+	return ahead, behind, nil
+}
+
 func RepoNameFromPath(path string) string {
 	return filepath.Base(path)
 }
@@ -149,3 +741,57 @@ func RepoNameFromURL(url string) string {
 	url = strings.TrimSuffix(url, ".git")
 	return filepath.Base(url)
 }
+
+// LastCommitTime returns the committer date of HEAD
+func LastCommitTime(path string) (time.Time, error) {
+	cmd := exec.Command("git", "-C", path, "log", "-1", "--format=%ct")
+	output, err := execOutput(cmd)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get last commit: %w", err)
+	}
+
+	secs, err := strconv.ParseInt(strings.TrimSpace(string(output)), 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse commit time: %w", err)
+	}
+	return time.Unix(secs, 0), nil
+}
+
+// Commit is a single commit listed by CommitsBetween
+type Commit struct {
+	SHA     string // abbreviated
+	Author  string
+	Subject string
+}
+
+// CommitsBetween lists the commits reachable from to but not from, newest
+// first, like git log from..to
+func CommitsBetween(path, from, to string) ([]Commit, error) {
+	cmd := exec.Command("git", "-C", path, "log", "--format=%h%x00%an%x00%s", from+".."+to)
+	output, err := execOutput(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list commits: %w", err)
+	}
+
+	var commits []Commit
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.SplitN(line, "\x00", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		commits = append(commits, Commit{SHA: fields[0], Author: fields[1], Subject: fields[2]})
+	}
+	return commits, nil
+}
+
+// LastFetchTime returns when the repo last fetched from a remote, based on
+// FETCH_HEAD. Repos never fetched since cloning fall back to when HEAD last changed.
+func LastFetchTime(path string) (time.Time, error) {
+	for _, name := range []string{"FETCH_HEAD", "HEAD"} {
+		info, err := os.Stat(filepath.Join(path, ".git", name))
+		if err == nil {
+			return info.ModTime(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("failed to get last fetch: not a git repository")
+}
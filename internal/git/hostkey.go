@@ -0,0 +1,93 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// PinHostKey fetches the host keys of an SSH server with ssh-keyscan and
+// checks that one of them has the expected fingerprint ("SHA256:..."). The
+// matching key replaces any earlier entry for the host in knownHostsFile, so
+// clones using that file trust nothing else. Port 0 means 22.
+func PinHostKey(knownHostsFile, host string, port int, fingerprint string) error {
+	if port == 0 {
+		port = 22
+	}
+
+	scan := exec.Command("ssh-keyscan", "-T", "10", "-p", strconv.Itoa(port), host)
+	output, err := scan.Output()
+	if err != nil {
+		return fmt.Errorf("ssh-keyscan %s failed: %w", host, err)
+	}
+
+	var seen []string
+	var pinned string
+	for _, line := range strings.Split(string(output), "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fp, err := keyFingerprint(line)
+		if err != nil {
+			return err
+		}
+		if fp == fingerprint {
+			pinned = line
+			break
+		}
+		seen = append(seen, fp)
+	}
+	if pinned == "" {
+		if len(seen) == 0 {
+			return fmt.Errorf("no host keys received from %s", host)
+		}
+		return fmt.Errorf("host key mismatch for %s: expected %s, server offered %s", host, fingerprint, strings.Join(seen, ", "))
+	}
+
+	return replaceKnownHost(knownHostsFile, strings.Fields(pinned)[0], pinned)
+}
+
+// keyFingerprint returns the SHA256 fingerprint of a known_hosts line
+func keyFingerprint(line string) (string, error) {
+	cmd := exec.Command("ssh-keygen", "-l", "-E", "sha256", "-f", "-")
+	cmd.Stdin = strings.NewReader(line + "\n")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to fingerprint host key: %w", err)
+	}
+
+	// Output: "256 SHA256:... host (ED25519)"
+	fields := strings.Fields(string(output))
+	if len(fields) < 2 {
+		return "", fmt.Errorf("unexpected ssh-keygen output: %s", output)
+	}
+	return fields[1], nil
+}
+
+// replaceKnownHost writes line to the known hosts file in place of any
+// existing lines for the same host pattern
+func replaceKnownHost(path, hostPattern, line string) error {
+	var kept [][]byte
+	if data, err := os.ReadFile(path); err == nil {
+		for _, existing := range bytes.Split(data, []byte("\n")) {
+			fields := bytes.Fields(existing)
+			if len(fields) == 0 || string(fields[0]) == hostPattern {
+				continue
+			}
+			kept = append(kept, existing)
+		}
+	}
+	kept = append(kept, []byte(line))
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create known hosts directory: %w", err)
+	}
+	if err := os.WriteFile(path, append(bytes.Join(kept, []byte("\n")), '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write known hosts file: %w", err)
+	}
+	return nil
+}
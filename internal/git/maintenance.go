@@ -0,0 +1,32 @@
+package git
+
+import (
+	"os/exec"
+
+	"github.com/charmbracelet/log"
+)
+
+// Maintain compacts a repo. With auto, it runs "git gc --auto", which only does
+// work when git's own thresholds are exceeded; otherwise it runs the full
+// "git maintenance run" task set.
+func Maintain(path string, auto bool) error {
+	args := []string{"-C", path, "maintenance", "run"}
+	if auto {
+		args = []string{"-C", path, "gc", "--auto", "--quiet"}
+	}
+
+	cmd := exec.Command("git", args...)
+	output, err := execCombinedOutput(cmd)
+	if err != nil {
+		return commandError(args[2], err, output)
+	}
+
+	log.Debug("ran maintenance", "path", path, "auto", auto)
+	return nil
+}
+
+// StartMaintenance registers the repo for background maintenance and makes
+// sure the scheduler (cron, systemd, or launchd) is set up
+func StartMaintenance(path string) error {
+	return run("-C", path, "maintenance", "start")
+}
@@ -0,0 +1,52 @@
+package git
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// progressLine matches git's progress lines, e.g. "Receiving objects:  45% (9/20)"
+// or "remote: Counting objects: 100% (12/12), done."
+var progressLine = regexp.MustCompile(`^(?:remote: )?([A-Za-z ]+):\s+(\d+)%`)
+
+// progressWriter reports the progress lines git rewrites in place with \r,
+// and keeps the rest of the output, one line per \n, for error messages
+type progressWriter struct {
+	report func(phase string, percent int)
+	output bytes.Buffer
+	line   []byte
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	for _, b := range p {
+		switch b {
+		case '\r':
+			w.parse()
+			w.line = w.line[:0]
+		case '\n':
+			w.parse()
+			w.output.Write(w.line)
+			w.output.WriteByte('\n')
+			w.line = w.line[:0]
+		default:
+			w.line = append(w.line, b)
+		}
+	}
+	return len(p), nil
+}
+
+func (w *progressWriter) parse() {
+	m := progressLine.FindSubmatch(w.line)
+	if m == nil {
+		return
+	}
+	percent, _ := strconv.Atoi(string(m[2]))
+	w.report(strings.ToLower(string(m[1])), percent)
+}
+
+// Output returns what git printed, without the intermediate progress updates
+func (w *progressWriter) Output() []byte {
+	return append(w.output.Bytes(), w.line...)
+}
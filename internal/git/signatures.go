@@ -0,0 +1,104 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// Signature is the verification result of a commit or tag
+type Signature struct {
+	Ref    string // commit SHA or tag name
+	Status string // git's %G? code: G good, U good but unknown validity, N none, B bad, E can't check, X/Y expired, R revoked
+	Key    string // fingerprint of the signing key, if known
+}
+
+// Good reports whether the signature verified
+func (s Signature) Good() bool {
+	return s.Status == "G" || s.Status == "U"
+}
+
+// signingKeyPattern finds the key in verify-tag --raw output: VALIDSIG for
+// GPG, or the "with ... key SHA256:..." part for SSH
+var signingKeyPattern = regexp.MustCompile(`VALIDSIG ([0-9A-F]+)|key (SHA256:\S+)`)
+
+// CommitSignatures verifies the signatures of the last count commits of ref.
+// allowedSigners is passed as gpg.ssh.allowedSignersFile for SSH signatures.
+func CommitSignatures(path, ref string, count int, allowedSigners string) ([]Signature, error) {
+	args := append(signerArgs(path, allowedSigners), "log", fmt.Sprintf("-n%d", count), "--format=%H%x00%G?%x00%GF%x00%GK", ref, "--")
+	output, err := execOutput(exec.Command("git", args...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commit signatures: %w", err)
+	}
+
+	var sigs []Signature
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.Split(line, "\x00")
+		if len(fields) != 4 {
+			continue
+		}
+		key := fields[2]
+		if key == "" {
+			key = fields[3]
+		}
+		sigs = append(sigs, Signature{Ref: fields[0], Status: fields[1], Key: key})
+	}
+	return sigs, nil
+}
+
+// TagSignatures verifies the signatures of the count most recent tags.
+// Lightweight tags can't be signed and are reported with status N.
+func TagSignatures(path string, count int, allowedSigners string) ([]Signature, error) {
+	cmd := exec.Command("git", "-C", path, "for-each-ref", "--sort=-creatordate", fmt.Sprintf("--count=%d", count),
+		"--format=%(refname:short)%00%(objecttype)", "refs/tags")
+	output, err := execOutput(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+
+	var sigs []Signature
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		name, objType, ok := strings.Cut(line, "\x00")
+		if !ok {
+			continue
+		}
+		if objType != "tag" {
+			sigs = append(sigs, Signature{Ref: name, Status: "N"})
+			continue
+		}
+		sigs = append(sigs, verifyTag(path, name, allowedSigners))
+	}
+	return sigs, nil
+}
+
+func verifyTag(path, name, allowedSigners string) Signature {
+	args := append(signerArgs(path, allowedSigners), "verify-tag", "--raw", name)
+	output, err := execCombinedOutput(exec.Command("git", args...))
+
+	sig := Signature{Ref: name, Status: "G"}
+	if m := signingKeyPattern.FindStringSubmatch(string(output)); m != nil {
+		sig.Key = m[1] + m[2]
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr) && strings.Contains(string(output), "no signature found"):
+		sig.Status = "N"
+	case errors.As(err, &exitErr):
+		sig.Status = "B"
+	default:
+		sig.Status = "E"
+	}
+	return sig
+}
+
+func signerArgs(path, allowedSigners string) []string {
+	args := []string{"-C", path}
+	if allowedSigners != "" {
+		args = append(args, "-c", "gpg.ssh.allowedSignersFile="+allowedSigners)
+	}
+	return args
+}
@@ -0,0 +1,166 @@
+package git
+
+import (
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	gosync "sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+var (
+	traceMu gosync.RWMutex
+	tracer  *log.Logger // nil when tracing is off
+)
+
+// SetTrace logs every git command run afterwards to w, with its argv,
+// environment overrides, working directory, exit code, and duration.
+// Credentials in URLs and auth headers are redacted. A nil w turns tracing off.
+func SetTrace(w io.Writer) {
+	traceMu.Lock()
+	defer traceMu.Unlock()
+	if w == nil {
+		tracer = nil
+		return
+	}
+	tracer = log.NewWithOptions(w, log.Options{
+		Prefix:          "trace",
+		ReportTimestamp: true,
+		TimeFormat:      "15:04:05.000",
+	})
+}
+
+// execOutput runs cmd like cmd.Output, tracing it when enabled
+func execOutput(cmd *exec.Cmd) ([]byte, error) {
+	var output []byte
+	err := traced(cmd, func() (err error) {
+		output, err = cmd.Output()
+		return err
+	})
+	return output, err
+}
+
+// execCombinedOutput runs cmd like cmd.CombinedOutput, tracing it when enabled
+func execCombinedOutput(cmd *exec.Cmd) ([]byte, error) {
+	var output []byte
+	err := traced(cmd, func() (err error) {
+		output, err = cmd.CombinedOutput()
+		return err
+	})
+	return output, err
+}
+
+// execRun runs cmd like cmd.Run, tracing it when enabled
+func execRun(cmd *exec.Cmd) error {
+	return traced(cmd, cmd.Run)
+}
+
+func traced(cmd *exec.Cmd, run func() error) error {
+	traceMu.RLock()
+	logger := tracer
+	traceMu.RUnlock()
+	if logger == nil {
+		return run()
+	}
+
+	start := time.Now()
+	err := run()
+	took := time.Since(start)
+
+	dir := cmd.Dir
+	if dir == "" {
+		dir, _ = os.Getwd()
+	}
+
+	exitCode := 0
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		exitCode = exitErr.ExitCode()
+	} else if err != nil {
+		exitCode = -1
+	}
+
+	keyvals := []any{"argv", redactArgs(cmd.Args)}
+	if env := envOverrides(cmd.Env); len(env) > 0 {
+		keyvals = append(keyvals, "env", env)
+	}
+	keyvals = append(keyvals, "dir", dir, "exit", exitCode, "took", took.Round(time.Millisecond))
+	if exitCode == -1 {
+		keyvals = append(keyvals, "error", err)
+	}
+	logger.Print(cmd.Args[0], keyvals...)
+	return err
+}
+
+// redactArgs joins argv for display, hiding secrets
+func redactArgs(args []string) string {
+	redacted := make([]string, len(args))
+	for i, arg := range args {
+		redacted[i] = redactValue(arg)
+	}
+	return strings.Join(redacted, " ")
+}
+
+// envOverrides returns the variables cmd sets on top of the process
+// environment, with secrets hidden
+func envOverrides(env []string) string {
+	if env == nil {
+		return ""
+	}
+	inherited := os.Environ()
+
+	// GIT_CONFIG_VALUE_<n> is secret when its GIT_CONFIG_KEY_<n> is an auth header
+	secretValues := map[string]bool{}
+	for _, kv := range env {
+		name, value, _ := strings.Cut(kv, "=")
+		if n, ok := strings.CutPrefix(name, "GIT_CONFIG_KEY_"); ok && isSecretKey(value) {
+			secretValues["GIT_CONFIG_VALUE_"+n] = true
+		}
+	}
+
+	var overrides []string
+	for _, kv := range env {
+		if slices.Contains(inherited, kv) {
+			continue
+		}
+		name, value, _ := strings.Cut(kv, "=")
+		if secretValues[name] || isSecretKey(name) {
+			value = "xxxxx"
+		} else {
+			value = redactValue(value)
+		}
+		overrides = append(overrides, name+"="+value)
+	}
+	return strings.Join(overrides, " ")
+}
+
+// redactValue hides the password of a URL and the value of an auth header
+// passed as -c key=value
+func redactValue(s string) string {
+	if key, _, ok := strings.Cut(s, "="); ok && isSecretKey(key) {
+		return key + "=xxxxx"
+	}
+	if u, err := url.Parse(s); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			return u.Redacted()
+		}
+	}
+	return s
+}
+
+// isSecretKey reports whether a config key or variable name holds credentials
+func isSecretKey(name string) bool {
+	name = strings.ToLower(name)
+	for _, word := range []string{"token", "password", "secret", "extraheader"} {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,336 @@
+// Package journal records the changes autogitter makes on disk, clones,
+// prunes, and config writes, in an append-only file with enough detail to
+// reverse them, so the last operation can be undone.
+package journal
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	gosync "sync"
+	"time"
+
+	"github.com/arch-err/autogitter/internal/connector"
+	"github.com/arch-err/autogitter/internal/ui"
+)
+
+// File is the journal's name in the data directory, next to the credentials
+const File = "journal.jsonl"
+
+// Actions recorded in the journal
+const (
+	ActionClone   = "clone"   // a repo was cloned to Path
+	ActionPrune   = "prune"   // the clone at Path was moved to Trash
+	ActionConfig  = "config"  // the config file at Path was written
+	ActionRestore = "restore" // a clone was moved out of the trash to Path
+	ActionUndo    = "undo"    // the operation Undoes was undone
+)
+
+// Entry is one line of the journal
+type Entry struct {
+	Op      string    `json:"op"` // the operation the entry belongs to
+	Command string    `json:"command,omitempty"`
+	Time    time.Time `json:"time"`
+	Action  string    `json:"action"`
+	Source  string    `json:"source,omitempty"`
+	Repo    string    `json:"repo,omitempty"`
+	Path    string    `json:"path,omitempty"`
+	URL     string    `json:"url,omitempty"`
+	Trash   string    `json:"trash,omitempty"` // where a pruned clone was moved
+
+	// Config writes keep the file's previous content, and a hash of the
+	// written one to tell whether it was edited since
+	Before    *string `json:"before,omitempty"` // nil when the file didn't exist
+	AfterHash string  `json:"after_sha256,omitempty"`
+
+	Undoes string `json:"undoes,omitempty"`
+}
+
+var (
+	mu      gosync.Mutex
+	current string
+	command string
+)
+
+// Path returns the location of the journal
+func Path() string {
+	return filepath.Join(filepath.Dir(connector.DefaultCredentialsPath()), File)
+}
+
+// Begin starts a new operation: the entries recorded from now on belong to
+// it and are undone together. Without Begin, a process records everything
+// as one operation.
+func Begin(cmd string) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = newID()
+	command = cmd
+}
+
+// Command returns the command line given to the current operation's Begin
+func Command() string {
+	mu.Lock()
+	defer mu.Unlock()
+	return command
+}
+
+func newID() string {
+	return time.Now().UTC().Format("20060102T150405.000000000Z")
+}
+
+// record appends an entry to the current operation. A journal that can't
+// be written only warns: it must never stop the change it describes.
+func record(e Entry) {
+	mu.Lock()
+	defer mu.Unlock()
+	if current == "" {
+		current = newID()
+	}
+	e.Op = current
+	e.Command = command
+	e.Time = time.Now().UTC()
+
+	if err := appendEntry(e); err != nil {
+		ui.Warn("failed to write journal", "path", Path(), "error", err)
+	}
+}
+
+func appendEntry(e Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	path := Path()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// RecordClone records a new clone
+func RecordClone(source, repo, path, url string) {
+	record(Entry{Action: ActionClone, Source: source, Repo: repo, Path: path, URL: url})
+}
+
+// RecordConfig records a write of the config file at path. before is the
+// file's previous content, nil when it didn't exist.
+func RecordConfig(path string, before, after []byte) {
+	e := Entry{Action: ActionConfig, Path: path, AfterHash: hash(after)}
+	if before != nil {
+		s := string(before)
+		e.Before = &s
+	}
+	record(e)
+}
+
+func hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Prune moves the clone at path to the trash and records it, so it can be
+// restored with undo
+func Prune(source, repo, path string) error {
+	url := ""
+	if data, err := os.ReadFile(filepath.Join(path, ".git", "config")); err == nil {
+		url = originURL(string(data))
+	}
+	item, err := moveToTrash(source, repo, path, url)
+	if err != nil {
+		return err
+	}
+	record(Entry{Action: ActionPrune, Source: source, Repo: repo, Path: path, URL: url, Trash: item})
+	return nil
+}
+
+// originURL reads the url of the origin remote from a git config file
+func originURL(gitConfig string) string {
+	inOrigin := false
+	for _, line := range strings.Split(gitConfig, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			inOrigin = line == `[remote "origin"]`
+			continue
+		}
+		if key, value, ok := strings.Cut(line, "="); ok && inOrigin && strings.TrimSpace(key) == "url" {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+// Operation is the entries of one operation, in the order they happened
+type Operation struct {
+	ID      string
+	Command string
+	Time    time.Time
+	Entries []Entry
+}
+
+// Last returns the most recent operation that changed something and wasn't
+// undone, or nil when there is none
+func Last() (*Operation, error) {
+	entries, err := readEntries()
+	if err != nil {
+		return nil, err
+	}
+
+	undone := make(map[string]bool)
+	for _, e := range entries {
+		if e.Action == ActionUndo {
+			undone[e.Undoes] = true
+		}
+	}
+
+	var op *Operation
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if e.Action == ActionUndo || undone[e.Op] {
+			continue
+		}
+		if op == nil {
+			op = &Operation{ID: e.Op, Command: e.Command}
+		}
+		if e.Op != op.ID {
+			continue
+		}
+		op.Time = e.Time
+		op.Entries = append([]Entry{e}, op.Entries...)
+	}
+	return op, nil
+}
+
+func readEntries() ([]Entry, error) {
+	f, err := os.Open(Path())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// A line cut short by a crash shouldn't hide the rest
+			continue
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+	return entries, nil
+}
+
+// Describe returns a line saying what undoing the entry does
+func (e Entry) Describe() string {
+	switch e.Action {
+	case ActionClone:
+		return fmt.Sprintf("move clone %s to the trash", e.Path)
+	case ActionPrune:
+		return fmt.Sprintf("restore pruned %s from the trash", e.Path)
+	case ActionRestore:
+		return fmt.Sprintf("move restored %s back to the trash", e.Path)
+	case ActionConfig:
+		if e.Before == nil {
+			return fmt.Sprintf("remove config %s, which didn't exist before", e.Path)
+		}
+		return fmt.Sprintf("restore the previous content of config %s", e.Path)
+	}
+	return e.Action + " " + e.Path
+}
+
+// Undo reverses the operation's entries, newest first. Entries that were
+// already reversed are skipped, so an undo that failed halfway can be run
+// again. A config edited since it was written is only restored with force.
+// The operation is marked undone once every entry was reversed.
+func (op *Operation) Undo(force bool) error {
+	var failed []string
+	for i := len(op.Entries) - 1; i >= 0; i-- {
+		e := op.Entries[i]
+		if err := undoEntry(e, force); err != nil {
+			ui.Error("failed to undo", "action", e.Action, "path", e.Path, "error", err)
+			failed = append(failed, e.Path)
+			continue
+		}
+		ui.Info("undone", "action", e.Action, "path", e.Path)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d change(s) couldn't be undone", len(failed))
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if err := appendEntry(Entry{Op: newID(), Command: command, Time: time.Now().UTC(), Action: ActionUndo, Undoes: op.ID}); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	return nil
+}
+
+func undoEntry(e Entry, force bool) error {
+	switch e.Action {
+	case ActionClone, ActionRestore:
+		if _, err := os.Stat(e.Path); os.IsNotExist(err) {
+			return nil
+		}
+		_, err := moveToTrash(e.Source, e.Repo, e.Path, e.URL)
+		return err
+
+	case ActionPrune:
+		_, pathErr := os.Stat(e.Path)
+		_, trashErr := os.Stat(e.Trash)
+		if pathErr == nil {
+			if os.IsNotExist(trashErr) {
+				return nil // restored already
+			}
+			return fmt.Errorf("path already exists, restore it by hand from %s", e.Trash)
+		}
+		if trashErr != nil {
+			return fmt.Errorf("no longer in the trash: %w", trashErr)
+		}
+		return restoreFromTrash(e.Trash, e.Path)
+
+	case ActionConfig:
+		current, err := os.ReadFile(e.Path)
+		exists := err == nil
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if e.Before == nil && !exists {
+			return nil
+		}
+		if e.Before != nil && exists && string(current) == *e.Before {
+			return nil
+		}
+		if !force && (!exists || hash(current) != e.AfterHash) {
+			return fmt.Errorf("config changed since, use --force to restore it anyway")
+		}
+		if e.Before == nil {
+			return os.Remove(e.Path)
+		}
+		tmp := e.Path + ".tmp"
+		if err := os.WriteFile(tmp, []byte(*e.Before), 0644); err != nil {
+			return err
+		}
+		return os.Rename(tmp, e.Path)
+	}
+	return fmt.Errorf("unknown action %q", e.Action)
+}
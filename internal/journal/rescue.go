@@ -0,0 +1,68 @@
+package journal
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/arch-err/autogitter/internal/connector"
+)
+
+// RescueDir is the name of the directory in the data directory that keeps
+// the uncommitted files of clones that were replaced by fresh ones
+const RescueDir = "rescue"
+
+// RescuePath returns the location of the rescue directory
+func RescuePath() string {
+	return filepath.Join(filepath.Dir(connector.DefaultCredentialsPath()), RescueDir)
+}
+
+// Rescue copies files, relative to the clone at path, into a new directory
+// under the rescue directory and returns it. nil files copies every file
+// outside .git, for clones too broken to tell which files changed.
+func Rescue(path string, files []string) (string, error) {
+	dir := filepath.Join(RescuePath(), time.Now().UTC().Format("20060102T150405.000000000Z")+"-"+filepath.Base(path))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create rescue directory: %w", err)
+	}
+
+	if files == nil {
+		err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() && d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			if d.Type().IsRegular() {
+				rel, err := filepath.Rel(path, p)
+				if err != nil {
+					return err
+				}
+				files = append(files, rel)
+			}
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	for _, file := range files {
+		src := filepath.Join(path, file)
+		info, err := os.Lstat(src)
+		if err != nil || !info.Mode().IsRegular() {
+			continue // a directory of untracked files, or gone since
+		}
+		dst := filepath.Join(dir, file)
+		if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+			return "", err
+		}
+		if err := copyFile(src, dst, info.Mode().Perm()); err != nil {
+			return "", fmt.Errorf("failed to rescue %s: %w", file, err)
+		}
+	}
+	return dir, nil
+}
@@ -0,0 +1,273 @@
+package journal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/arch-err/autogitter/internal/connector"
+)
+
+// TrashDir is the trash's name in the data directory. Pruned clones are
+// moved there rather than deleted.
+const TrashDir = "trash"
+
+// TrashItem describes a clone in the trash. It's kept next to the clone's
+// directory, as <item>.json.
+type TrashItem struct {
+	Source    string    `json:"source,omitempty"`
+	Repo      string    `json:"repo,omitempty"`
+	Path      string    `json:"path"` // where the clone was
+	URL       string    `json:"url,omitempty"`
+	TrashedAt time.Time `json:"trashed_at"`
+}
+
+// TrashPath returns the location of the trash
+func TrashPath() string {
+	return filepath.Join(filepath.Dir(connector.DefaultCredentialsPath()), TrashDir)
+}
+
+// TrashEntry is a clone in the trash
+type TrashEntry struct {
+	TrashItem
+	Dir string // where the clone is in the trash
+}
+
+// Name returns the entry's repo name, or its original directory name when
+// the repo isn't known
+func (e TrashEntry) Name() string {
+	if e.Repo != "" {
+		return e.Repo
+	}
+	return filepath.Base(e.Path)
+}
+
+// ListTrash returns the clones in the trash, newest first
+func ListTrash() ([]TrashEntry, error) {
+	dirs, err := os.ReadDir(TrashPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trash: %w", err)
+	}
+
+	var entries []TrashEntry
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		entry := TrashEntry{Dir: filepath.Join(TrashPath(), d.Name())}
+		data, err := os.ReadFile(entry.Dir + ".json")
+		if err == nil {
+			err = json.Unmarshal(data, &entry.TrashItem)
+		}
+		if err != nil {
+			// Without its description, the item's age is its mtime and
+			// where it came from is unknown
+			if info, err := d.Info(); err == nil {
+				entry.TrashedAt = info.ModTime()
+			}
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].TrashedAt.After(entries[j].TrashedAt)
+	})
+	return entries, nil
+}
+
+// MatchTrash returns the entries whose repo, original path, or name in the
+// trash is query, ignoring case, or contains it when none is. Entries keep
+// their order.
+func MatchTrash(entries []TrashEntry, query string) []TrashEntry {
+	query = strings.ToLower(strings.TrimSuffix(query, "/"))
+	var exact, partial []TrashEntry
+	for _, e := range entries {
+		candidates := []string{e.Repo, filepath.Base(e.Path), e.Path, filepath.Base(e.Dir)}
+		matched := false
+		for _, c := range candidates {
+			c = strings.ToLower(c)
+			if c != "" && c == query {
+				exact = append(exact, e)
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+		for _, c := range candidates {
+			if c != "" && strings.Contains(strings.ToLower(c), query) {
+				partial = append(partial, e)
+				break
+			}
+		}
+	}
+	if len(exact) > 0 {
+		return exact
+	}
+	return partial
+}
+
+// RestoreTrash moves a clone out of the trash to path, its original path
+// when empty, records it so undo puts it back, and returns where it went
+func RestoreTrash(entry TrashEntry, path string) (string, error) {
+	if path == "" {
+		path = entry.Path
+	}
+	if path == "" {
+		return "", fmt.Errorf("original path of %s is unknown, give one to restore it to", filepath.Base(entry.Dir))
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return "", fmt.Errorf("%s already exists", path)
+	}
+	if err := restoreFromTrash(entry.Dir, path); err != nil {
+		return "", fmt.Errorf("failed to restore from trash: %w", err)
+	}
+	record(Entry{Action: ActionRestore, Source: entry.Source, Repo: entry.Repo, Path: path, URL: entry.URL})
+	return path, nil
+}
+
+// ExpireTrash deletes the clones that were moved to the trash more than
+// maxAge ago and returns them
+func ExpireTrash(maxAge time.Duration) ([]TrashEntry, error) {
+	entries, err := ListTrash()
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().Add(-maxAge)
+	var expired []TrashEntry
+	var errs []error
+	for _, e := range entries {
+		if !e.TrashedAt.Before(cutoff) {
+			continue
+		}
+		if err := os.RemoveAll(e.Dir); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		os.Remove(e.Dir + ".json")
+		expired = append(expired, e)
+	}
+	return expired, errors.Join(errs...)
+}
+
+// moveToTrash moves the directory at path into the trash and returns where
+// it went
+func moveToTrash(source, repo, path, url string) (string, error) {
+	trash := TrashPath()
+	if err := os.MkdirAll(trash, 0700); err != nil {
+		return "", fmt.Errorf("failed to create trash: %w", err)
+	}
+
+	item := filepath.Join(trash, time.Now().UTC().Format("20060102T150405.000000000Z")+"-"+filepath.Base(path))
+	data, err := json.MarshalIndent(TrashItem{
+		Source:    source,
+		Repo:      repo,
+		Path:      path,
+		URL:       url,
+		TrashedAt: time.Now().UTC(),
+	}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(item+".json", data, 0600); err != nil {
+		return "", fmt.Errorf("failed to write trash entry: %w", err)
+	}
+
+	if err := moveDir(path, item); err != nil {
+		os.Remove(item + ".json")
+		return "", fmt.Errorf("failed to move to trash: %w", err)
+	}
+	return item, nil
+}
+
+// restoreFromTrash moves a trashed clone back to path
+func restoreFromTrash(item, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := moveDir(item, path); err != nil {
+		return err
+	}
+	os.Remove(item + ".json")
+	return nil
+}
+
+// moveDir renames src to dst, copying and deleting it when they're on
+// different filesystems
+func moveDir(src, dst string) error {
+	err := os.Rename(src, dst)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+	if err := copyTree(src, dst); err != nil {
+		os.RemoveAll(dst)
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+// copyTree copies the directory src to dst with its modes and symlinks
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case info.Mode()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		default:
+			return copyFile(path, target, info.Mode().Perm())
+		}
+	})
+}
+
+func copyFile(src, dst string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
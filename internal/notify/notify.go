@@ -0,0 +1,121 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/arch-err/autogitter/internal/config"
+)
+
+// Summary describes the outcome of a sync or pull run
+type Summary struct {
+	Command string `json:"command"` // "sync" or "pull"
+	Cloned  int    `json:"cloned"`
+	Pruned  int    `json:"pruned"`
+	Added   int    `json:"added"`
+	Updated int    `json:"updated"`
+	Failed  int    `json:"failed"`
+	Host    string `json:"host"`
+	// Attention lists the repos a pull left with conflicts, which are
+	// also counted in Failed
+	Attention []Attention `json:"attention,omitempty"`
+}
+
+// Attention is a repo a pull left with conflicts to resolve by hand
+type Attention struct {
+	Repo    string   `json:"repo"`
+	Path    string   `json:"path"`
+	Files   []string `json:"files,omitempty"`   // conflicting files, relative to the repo
+	Stashed bool     `json:"stashed,omitempty"` // the local changes are still in the stash
+}
+
+// Success reports whether the run had no failures
+func (s Summary) Success() bool {
+	return s.Failed == 0
+}
+
+// DefaultTemplate is used when notifications.template is not set
+const DefaultTemplate = `{{if .Success}}:white_check_mark:{{else}}:x:{{end}} autogitter {{.Command}} on {{.Host}}: ` +
+	`{{if eq .Command "sync"}}{{.Cloned}} cloned, {{.Pruned}} pruned, {{.Added}} added{{else}}{{.Updated}} updated{{end}}` +
+	`{{if .Failed}}, {{.Failed}} failed{{end}}{{if .Attention}} ({{len .Attention}} need attention){{end}}`
+
+var client = &http.Client{
+	Timeout: 10 * time.Second,
+}
+
+// Send posts the summary to the configured webhook.
+// It is a no-op when no webhook is configured, or when only failures
+// should be reported and the run succeeded.
+func Send(n config.Notifications, summary Summary) error {
+	url := os.ExpandEnv(n.WebhookURL)
+	if url == "" {
+		return nil
+	}
+	if n.OnlyFailure && summary.Success() {
+		return nil
+	}
+
+	if summary.Host == "" {
+		summary.Host, _ = os.Hostname()
+	}
+
+	text, err := render(n.Template, summary)
+	if err != nil {
+		return err
+	}
+
+	var payload interface{}
+	if n.Format == "slack" {
+		payload = map[string]string{"text": text}
+	} else {
+		payload = struct {
+			Summary
+			Success bool   `json:"success"`
+			Text    string `json:"text"`
+		}{summary, summary.Success(), text}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %w", err)
+	}
+
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to send notification: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to send notification: HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	return nil
+}
+
+// render executes the message template against the summary
+func render(tmpl string, summary Summary) (string, error) {
+	if tmpl == "" {
+		tmpl = DefaultTemplate
+	}
+
+	t, err := template.New("notification").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("invalid notification template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, summary); err != nil {
+		return "", fmt.Errorf("failed to render notification: %w", err)
+	}
+
+	return strings.TrimSpace(buf.String()), nil
+}
@@ -0,0 +1,153 @@
+package sync
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/arch-err/autogitter/internal/config"
+	"github.com/arch-err/autogitter/internal/connector"
+	"github.com/arch-err/autogitter/internal/ui"
+)
+
+// AddOptions contains options for the add command
+type AddOptions struct {
+	Source     string   // name of the manual source, may be empty if there is only one
+	Repos      []string // "user/repo" names or bare repo names
+	Browse     bool     // pick repos from the provider's list
+	ConfigPath string
+	DryRun     bool
+}
+
+// RunAdd adds repos to a manual-strategy source, either by name or picked
+// from the provider's repo list. Returns the number of repos added.
+func RunAdd(cfg *config.Config, opts AddOptions) (int, error) {
+	source, err := findManualSource(cfg, opts.Source)
+	if err != nil {
+		return 0, err
+	}
+
+	names := make([]string, 0, len(opts.Repos))
+	for _, name := range opts.Repos {
+		if !strings.Contains(name, "/") && !config.IsCloneURL(name) {
+			if owner := source.GetUserOrOrg(); owner != "" {
+				name = owner + "/" + name
+			}
+		}
+		names = append(names, name)
+	}
+
+	if opts.Browse {
+		picked, err := browseRepos(source)
+		if err != nil {
+			return 0, err
+		}
+		names = append(names, picked...)
+	}
+
+	if len(names) == 0 {
+		ui.Info("nothing to add")
+		return 0, nil
+	}
+
+	for _, name := range names {
+		if source.HasRepo(name) {
+			ui.Info("already in config", "source", source.Name, "repo", name)
+		} else {
+			ui.Info("add", "source", source.Name, "repo", name)
+		}
+	}
+
+	patch := &config.Patch{Sources: []config.SourcePatch{{Source: source.Name, Add: names}}}
+	added, _, err := cfg.Apply(patch)
+	if err != nil {
+		return 0, err
+	}
+
+	if opts.DryRun {
+		ui.Info("dry run, config not saved", "added", added)
+		return added, nil
+	}
+
+	if added > 0 && opts.ConfigPath != "" {
+		if err := SaveConfig(cfg, opts.ConfigPath); err != nil {
+			return 0, fmt.Errorf("failed to save config: %w", err)
+		}
+		ui.Info("config saved", "path", opts.ConfigPath, "added", added)
+	}
+
+	return added, nil
+}
+
+// findManualSource returns the named source, or the only manual source when
+// no name is given
+func findManualSource(cfg *config.Config, name string) (*config.Source, error) {
+	if name != "" {
+		source := cfg.FindSource(name)
+		if source == nil {
+			return nil, fmt.Errorf("source not found: %s", name)
+		}
+		if source.Strategy != config.StrategyManual {
+			return nil, fmt.Errorf("source %s uses strategy %q, repos can only be added to manual sources", name, source.Strategy)
+		}
+		return source, nil
+	}
+
+	var manual []string
+	var found *config.Source
+	for i := range cfg.Sources {
+		if cfg.Sources[i].Strategy == config.StrategyManual {
+			manual = append(manual, cfg.Sources[i].Name)
+			found = &cfg.Sources[i]
+		}
+	}
+	switch len(manual) {
+	case 0:
+		return nil, fmt.Errorf("no manual sources in config")
+	case 1:
+		return found, nil
+	default:
+		return nil, fmt.Errorf("multiple manual sources, pick one with --source: %s", strings.Join(manual, ", "))
+	}
+}
+
+// browseRepos lists the source's repos from the provider API, leaving out the
+// ones already configured, and lets the user pick from them
+func browseRepos(source *config.Source) ([]string, error) {
+	if !ui.IsTTY() {
+		return nil, fmt.Errorf("--browse needs an interactive terminal")
+	}
+
+	credPath := connector.DefaultCredentialsPath()
+	if err := connector.LoadCredentialsEnv(credPath); err != nil {
+		ui.Debug("failed to load credentials file", "error", err)
+	}
+
+	remote, err := fetchReposFromAPI(source)
+	if err != nil {
+		return nil, err
+	}
+
+	configured := make(map[string]bool, len(source.Repos))
+	for _, repo := range source.Repos {
+		configured[strings.ToLower(repo.Name)] = true
+	}
+
+	var available []string
+	for _, name := range remote {
+		if !configured[strings.ToLower(name)] {
+			available = append(available, name)
+		}
+	}
+	if len(available) == 0 {
+		ui.Info("all repos are already in config", "source", source.Name)
+		return nil, nil
+	}
+	sort.Strings(available)
+
+	picked, err := ui.SelectRepos(source.Name, available)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user input: %w", err)
+	}
+	return picked, nil
+}
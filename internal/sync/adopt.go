@@ -0,0 +1,183 @@
+package sync
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/arch-err/autogitter/internal/config"
+	"github.com/arch-err/autogitter/internal/git"
+	"github.com/arch-err/autogitter/internal/ui"
+)
+
+// adoption is where sync --add puts an orphaned clone
+type adoption struct {
+	repo   RepoStatus
+	target *config.Source // source the entry is added to
+	entry  config.RepoEntry
+	path   string // where the clone moves to, empty when it stays put
+	remote string // origin URL
+	host   string // origin host, empty without a usable origin
+	owner  string // origin owner
+	// unmatched is set when no source covers the origin's host and owner,
+	// so the entry is the full clone URL in the orphan's own source
+	unmatched bool
+}
+
+// planAdoption reads an orphan's origin URL to decide where it belongs: a
+// manual source for the origin's host and owner, preferring the orphan's own
+// source, or the orphan's source when it's on the same host. Clones without a
+// usable origin get a name guessed from their source.
+func planAdoption(cfg *config.Config, source *config.Source, repo RepoStatus) adoption {
+	a := adoption{repo: repo, target: source}
+	a.remote, _ = git.GetRemoteURL(repo.LocalPath)
+	a.host = remoteHost(a.remote)
+	fullName := fullNameFromURL(a.remote)
+	if a.host == "" || fullName == "" {
+		a.host = ""
+		a.entry = config.RepoEntry{Name: orphanFullName(source, repo)}
+		return a
+	}
+	a.owner = fullName[:strings.LastIndex(fullName, "/")]
+	a.entry = config.RepoEntry{Name: fullName}
+
+	if coversOwner(source, a.host, a.owner) {
+		return a
+	}
+	covered := false
+	for i := range cfg.Sources {
+		other := &cfg.Sources[i]
+		if other == source || other.Strategy != config.StrategyManual || !coversOwner(other, a.host, a.owner) {
+			continue
+		}
+		covered = true
+		path := other.RepoPath(a.entry)
+		if _, err := os.Stat(path); err == nil {
+			ui.Warn("repo belongs to another source, but its path there is taken", "repo", fullName, "source", other.Name, "path", path)
+			continue
+		}
+		a.target, a.path = other, path
+		return a
+	}
+	if strings.EqualFold(source.GetHost(), a.host) {
+		return a
+	}
+
+	// Only the full URL clones it from this source
+	a.entry = config.RepoEntry{Name: a.remote}
+	a.unmatched = !covered
+	return a
+}
+
+// coversOwner reports whether a source clones owner's repos from host
+func coversOwner(source *config.Source, host, owner string) bool {
+	if !strings.EqualFold(source.GetHost(), host) {
+		return false
+	}
+	sourceOwner := source.GetUserOrOrg()
+	return sourceOwner == "" || strings.EqualFold(sourceOwner, owner)
+}
+
+// offerNewSources asks, once per host and owner, whether to create a manual
+// source for orphans no source covers, and points their adoptions at it.
+// Returns the sources that were created; they're not in cfg yet.
+func offerNewSources(cfg *config.Config, from *config.Source, adoptions []adoption) ([]*config.Source, error) {
+	var created []*config.Source
+	offered := make(map[string]*config.Source)
+	for i := range adoptions {
+		a := &adoptions[i]
+		if !a.unmatched {
+			continue
+		}
+
+		key := strings.ToLower(a.host + "/" + a.owner)
+		newSource, ok := offered[key]
+		if !ok {
+			candidate := newSourceFor(cfg, from, *a, created)
+			if candidate != nil {
+				confirm, err := ui.ConfirmNewSource(a.repo.Name, candidate.Source, candidate.LocalPath)
+				if err != nil {
+					return nil, err
+				}
+				if confirm {
+					newSource = candidate
+					created = append(created, newSource)
+				}
+			}
+			offered[key] = newSource
+		}
+		if newSource == nil {
+			continue
+		}
+
+		entry := config.RepoEntry{Name: fullNameFromURL(a.remote)}
+		a.target, a.entry, a.path, a.unmatched = newSource, entry, newSource.RepoPath(entry), false
+	}
+	return created, nil
+}
+
+// newSourceFor returns a manual source for an adoption's origin host and
+// owner, in a directory named after the owner next to from's local_path, or
+// nil when that directory is another source's
+func newSourceFor(cfg *config.Config, from *config.Source, a adoption, created []*config.Source) *config.Source {
+	localPath := filepath.Join(filepath.Dir(from.LocalPath), path.Base(a.owner))
+	taken := func(s *config.Source) bool {
+		return filepath.Clean(s.LocalPath) == localPath
+	}
+	for i := range cfg.Sources {
+		if taken(&cfg.Sources[i]) {
+			return nil
+		}
+	}
+	for _, s := range created {
+		if taken(s) {
+			return nil
+		}
+	}
+
+	name := path.Base(a.owner)
+	if cfg.FindSource(name) != nil {
+		name = a.host + "/" + a.owner
+	}
+	source := &config.Source{
+		Name:      name,
+		Source:    a.host + "/" + a.owner,
+		Strategy:  config.StrategyManual,
+		LocalPath: localPath,
+	}
+
+	// Clone over the same protocol and port as the existing clone
+	if u, err := url.Parse(a.remote); err == nil && strings.Contains(a.remote, "://") {
+		switch u.Scheme {
+		case "https":
+			source.Protocol = config.ProtocolHTTPS
+		case "ssh":
+			if port, err := strconv.Atoi(u.Port()); err == nil && port != 22 {
+				source.SSHOptions.Port = port
+			}
+		}
+	}
+	return source
+}
+
+// moveAdopted moves an adopted clone into its new source's directory
+func moveAdopted(a adoption) error {
+	if a.path == "" {
+		return nil
+	}
+	if _, err := os.Stat(a.path); err == nil {
+		return fmt.Errorf("target path already exists: %s", a.path)
+	}
+	if err := os.MkdirAll(filepath.Dir(a.path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := os.Rename(a.repo.LocalPath, a.path); err != nil {
+		return fmt.Errorf("failed to move repo: %w", err)
+	}
+	ui.Info("moved", "repo", a.entry.Name, "from", a.repo.LocalPath, "to", a.path)
+	return nil
+}
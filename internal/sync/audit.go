@@ -0,0 +1,111 @@
+package sync
+
+import (
+	"github.com/arch-err/autogitter/internal/config"
+	"github.com/arch-err/autogitter/internal/git"
+	"github.com/arch-err/autogitter/internal/ui"
+)
+
+// AuditOptions contains options for the audit command
+type AuditOptions struct {
+	VerifySignatures bool
+}
+
+// AuditResult contains the results of an audit
+type AuditResult struct {
+	Repos      int
+	Violations int
+}
+
+// signatureProblems describes the %G? codes of signatures that didn't verify
+var signatureProblems = map[string]string{
+	"N": "not signed",
+	"B": "bad signature",
+	"E": "signature can't be checked",
+	"X": "signature has expired",
+	"Y": "signing key has expired",
+	"R": "signing key was revoked",
+}
+
+// RunAudit checks the repos of every source with a signatures section:
+// recent commits on the default branch and recent tags must carry a good
+// signature from one of the source's allowed keys
+func RunAudit(cfg *config.Config, opts AuditOptions) (*AuditResult, error) {
+	result := &AuditResult{}
+	if !opts.VerifySignatures {
+		return result, nil
+	}
+
+	audited := false
+	for i := range cfg.Sources {
+		source := &cfg.Sources[i]
+		if source.Signatures == nil {
+			continue
+		}
+		audited = true
+
+		for _, repo := range listLocalRepos(source) {
+			result.Repos++
+			result.Violations += auditSignatures(source.Signatures, repo)
+		}
+	}
+
+	if !audited {
+		ui.Warn("no source has a signatures section, nothing to audit")
+	}
+	return result, nil
+}
+
+// auditSignatures reports unsigned or untrusted commits and tags of a repo
+// and returns how many were found
+func auditSignatures(opts *config.SignatureOptions, repo localRepo) int {
+	// origin/HEAD is the upstream default branch; fall back to what's checked out
+	ref := "refs/remotes/origin/HEAD"
+	if !git.HasObject(repo.path, ref) {
+		ref = "HEAD"
+	}
+
+	violations := 0
+	commits, err := git.CommitSignatures(repo.path, ref, opts.GetDepth(), opts.AllowedSigners)
+	if err != nil {
+		ui.Error("failed to audit commits", "repo", repo.name, "error", err)
+		violations++
+	}
+	for _, sig := range commits {
+		if problem := signatureProblem(opts, sig); problem != "" {
+			ui.Warn("commit "+problem, "repo", repo.name, "commit", shortSHA(sig.Ref), "key", sig.Key)
+			violations++
+		}
+	}
+
+	tags, err := git.TagSignatures(repo.path, opts.GetDepth(), opts.AllowedSigners)
+	if err != nil {
+		ui.Error("failed to audit tags", "repo", repo.name, "error", err)
+		violations++
+	}
+	for _, sig := range tags {
+		if problem := signatureProblem(opts, sig); problem != "" {
+			ui.Warn("tag "+problem, "repo", repo.name, "tag", sig.Ref, "key", sig.Key)
+			violations++
+		}
+	}
+
+	if violations == 0 {
+		ui.Debug("signatures ok", "repo", repo.name, "commits", len(commits), "tags", len(tags))
+	}
+	return violations
+}
+
+// signatureProblem returns why a signature is not acceptable, or ""
+func signatureProblem(opts *config.SignatureOptions, sig git.Signature) string {
+	if !sig.Good() {
+		if problem, ok := signatureProblems[sig.Status]; ok {
+			return problem
+		}
+		return "signature did not verify"
+	}
+	if !opts.AllowsKey(sig.Key) {
+		return "signed by a key that is not allowed"
+	}
+	return ""
+}
@@ -0,0 +1,347 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/arch-err/autogitter/internal/config"
+	"github.com/arch-err/autogitter/internal/connector"
+	"github.com/arch-err/autogitter/internal/git"
+	"github.com/arch-err/autogitter/internal/ui"
+)
+
+// ManifestFile is the name of the backup manifest inside the backup directory
+const ManifestFile = "manifest.json"
+
+// BackupOptions contains options for the backup command
+type BackupOptions struct {
+	Dest string
+	// WithMetadata also exports each repo's issues, pull requests, comments,
+	// and releases from the provider into JSON files next to its bundles
+	WithMetadata bool
+}
+
+// BackupResult contains the results of a backup operation
+type BackupResult struct {
+	Created   int
+	Unchanged int
+	Failed    int
+	// Exported counts the repos whose provider records were exported, with
+	// WithMetadata
+	Exported int
+}
+
+// RestoreOptions contains options for the restore command
+type RestoreOptions struct {
+	From string
+}
+
+// RestoreResult contains the results of a restore operation
+type RestoreResult struct {
+	Restored int
+	Skipped  int
+	Failed   int
+}
+
+// BackupManifest records every backed-up repo and its bundle chain
+type BackupManifest struct {
+	Repos map[string]*BackupEntry `json:"repos"`
+}
+
+// BackupEntry describes the backup state of a single repo
+type BackupEntry struct {
+	Source    string            `json:"source"`
+	Name      string            `json:"name"`
+	Path      string            `json:"path"`
+	RemoteURL string            `json:"remote_url,omitempty"`
+	Branch    string            `json:"branch,omitempty"`
+	Refs      map[string]string `json:"refs"`
+	Bundles   []string          `json:"bundles"` // relative to the backup directory, oldest first
+	UpdatedAt time.Time         `json:"updated_at"`
+	// Records are the files of provider records, relative to the backup
+	// directory, as of ExportedAt
+	Records    []string  `json:"records,omitempty"`
+	ExportedAt time.Time `json:"exported_at,omitempty"`
+}
+
+var unsafePathChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// RunBackup creates or updates git bundles for every local repo of every source.
+// The first backup of a repo is a full bundle; later runs only bundle commits
+// that are not reachable from the refs recorded in the manifest.
+func RunBackup(cfg *config.Config, opts BackupOptions) (*BackupResult, error) {
+	result := &BackupResult{}
+
+	if opts.Dest == "" {
+		return nil, fmt.Errorf("backup destination is required")
+	}
+	if err := os.MkdirAll(opts.Dest, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	manifestPath := filepath.Join(opts.Dest, ManifestFile)
+	manifest, err := loadManifest(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+
+	if opts.WithMetadata {
+		if err := connector.LoadCredentialsEnv(connector.DefaultCredentialsPath()); err != nil {
+			ui.Debug("failed to load credentials file", "error", err)
+		}
+	}
+
+	for i := range cfg.Sources {
+		source := &cfg.Sources[i]
+
+		var exporter connector.RecordExporter
+		if opts.WithMetadata {
+			exporter, _ = newSourceConnector(source).(connector.RecordExporter)
+			if exporter == nil {
+				ui.Warn("provider can't export records or there's no token, backing up git data only", "source", source.Name)
+			}
+		}
+
+		for _, repo := range listLocalRepos(source) {
+			key := source.Name + "/" + repo.name
+			entry := manifest.Repos[key]
+			if entry == nil {
+				entry = &BackupEntry{Source: source.Name, Name: repo.name}
+				manifest.Repos[key] = entry
+			}
+
+			relDir := filepath.Join(unsafePathChars.ReplaceAllString(source.Name, "-"), repo.name)
+			created, err := backupRepo(repo, entry, opts.Dest, relDir, stamp)
+			if err != nil {
+				ui.Error("failed to back up repo", "repo", key, "error", err)
+				result.Failed++
+				continue
+			}
+
+			if exporter != nil && !(source.IncludeWikis && strings.HasSuffix(repo.path, wikiSuffix)) {
+				if err := exportRecords(exporter, repo, entry, opts.Dest, relDir); err != nil {
+					ui.Error("failed to export provider records", "repo", key, "error", err)
+					result.Failed++
+					continue
+				}
+				result.Exported++
+			}
+			if created {
+				ui.Info("backed up", "repo", key)
+				result.Created++
+			} else {
+				ui.Debug("backup up to date", "repo", key)
+				result.Unchanged++
+			}
+		}
+	}
+
+	if err := saveManifest(manifestPath, manifest); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// backupRepo writes a new bundle for repo if its refs changed since the last backup
+func backupRepo(repo localRepo, entry *BackupEntry, dest, relDir, stamp string) (bool, error) {
+	refs, err := git.ListRefs(repo.path)
+	if err != nil {
+		return false, err
+	}
+
+	entry.Path = repo.path
+	if url, err := git.GetRemoteURL(repo.path); err == nil {
+		entry.RemoteURL = url
+	}
+	if branch, err := git.GetCurrentBranch(repo.path); err == nil {
+		entry.Branch = branch
+	}
+
+	if len(entry.Bundles) > 0 && refsEqual(entry.Refs, refs) {
+		return false, nil
+	}
+
+	// Exclude everything the previous bundles already contain
+	var prerequisites []string
+	if len(entry.Bundles) > 0 {
+		seen := make(map[string]bool)
+		for _, sha := range entry.Refs {
+			if !seen[sha] && git.HasObject(repo.path, sha) {
+				prerequisites = append(prerequisites, sha)
+			}
+			seen[sha] = true
+		}
+	}
+
+	// Sequence prefix keeps bundle names unique and ordered within a chain
+	relFile := filepath.Join(relDir, fmt.Sprintf("%04d-%s.bundle", len(entry.Bundles), stamp))
+	created, err := git.CreateBundle(repo.path, filepath.Join(dest, relFile), prerequisites)
+	if err != nil {
+		return false, err
+	}
+
+	entry.Refs = refs
+	entry.UpdatedAt = time.Now().UTC()
+	if created {
+		entry.Bundles = append(entry.Bundles, relFile)
+	}
+	return created, nil
+}
+
+// exportRecords writes every kind of provider record of repo into
+// <kind>.json in relDir, replacing the previous export. The records change
+// in place, unlike git history, so they're snapshots rather than increments.
+func exportRecords(exporter connector.RecordExporter, repo localRepo, entry *BackupEntry, dest, relDir string) error {
+	fullName := repo.fullName
+	if name := fullNameFromURL(entry.RemoteURL); name != "" {
+		fullName = name
+	}
+
+	var files []string
+	for _, kind := range connector.RecordKinds {
+		records, err := exporter.ExportRecords(context.Background(), fullName, kind)
+		if err != nil {
+			return err
+		}
+		if records == nil {
+			records = []json.RawMessage{}
+		}
+
+		data, err := json.MarshalIndent(records, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %w", kind, err)
+		}
+
+		relFile := filepath.Join(relDir, string(kind)+".json")
+		if err := writeFileAtomic(filepath.Join(dest, relFile), data); err != nil {
+			return fmt.Errorf("failed to write %s: %w", kind, err)
+		}
+		files = append(files, relFile)
+		ui.Debug("exported provider records", "repo", fullName, "kind", kind, "count", len(records))
+	}
+
+	entry.Records = files
+	entry.ExportedAt = time.Now().UTC()
+	return nil
+}
+
+// RunRestore re-creates clones from the bundles in a backup directory.
+// Repos whose path already exists are skipped.
+func RunRestore(opts RestoreOptions) (*RestoreResult, error) {
+	result := &RestoreResult{}
+
+	manifest, err := loadManifest(filepath.Join(opts.From, ManifestFile))
+	if err != nil {
+		return nil, err
+	}
+	if len(manifest.Repos) == 0 {
+		return nil, fmt.Errorf("no backups found in %s", opts.From)
+	}
+
+	keys := make([]string, 0, len(manifest.Repos))
+	for key := range manifest.Repos {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		entry := manifest.Repos[key]
+
+		if _, err := os.Stat(entry.Path); err == nil {
+			ui.Info("path already exists, skipping", "repo", key, "path", entry.Path)
+			result.Skipped++
+			continue
+		}
+
+		bundles := make([]string, len(entry.Bundles))
+		for i, b := range entry.Bundles {
+			bundles[i] = filepath.Join(opts.From, b)
+		}
+
+		err := git.RestoreFromBundles(git.RestoreOptions{
+			Path:      entry.Path,
+			Bundles:   bundles,
+			Branch:    entry.Branch,
+			RemoteURL: entry.RemoteURL,
+		})
+		if err != nil {
+			ui.Error("failed to restore repo", "repo", key, "error", err)
+			result.Failed++
+			continue
+		}
+
+		ui.Info("restored", "repo", key, "path", entry.Path)
+		result.Restored++
+	}
+
+	return result, nil
+}
+
+func loadManifest(path string) (*BackupManifest, error) {
+	manifest := &BackupManifest{Repos: make(map[string]*BackupEntry)}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return manifest, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	if manifest.Repos == nil {
+		manifest.Repos = make(map[string]*BackupEntry)
+	}
+	return manifest, nil
+}
+
+// saveManifest writes the manifest atomically so an interrupted backup never
+// leaves a truncated file behind
+func saveManifest(path string, manifest *BackupManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+
+	if err := writeFileAtomic(path, data); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic writes data to path through a temporary file, creating
+// the parent directory
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func refsEqual(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for ref, sha := range a {
+		if b[ref] != sha {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,30 @@
+package sync
+
+import (
+	"github.com/arch-err/autogitter/internal/config"
+	"github.com/arch-err/autogitter/internal/git"
+	"github.com/arch-err/autogitter/internal/ui"
+)
+
+// limitBandwidth splits max_bandwidth evenly between the transfers that run
+// at once and returns the number of workers to use. Without trickle the rate
+// can't be capped, so transfers at least run one at a time.
+func limitBandwidth(cfg *config.Config, workers, jobs int) int {
+	total := cfg.GetMaxBandwidth()
+	if total == 0 {
+		return workers
+	}
+
+	if workers <= 0 {
+		workers = 4
+	}
+	workers = max(min(workers, jobs), 1)
+
+	perTransfer := total / int64(workers)
+	if err := git.LimitBandwidth(perTransfer); err != nil {
+		ui.Warn("can't limit bandwidth, transferring one repo at a time", "error", err)
+		return 1
+	}
+	ui.Debug("limiting bandwidth", "total", ui.FormatBytes(total)+"/s", "per_transfer", ui.FormatBytes(perTransfer)+"/s", "workers", workers)
+	return workers
+}
@@ -0,0 +1,204 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/arch-err/autogitter/internal/config"
+	"github.com/arch-err/autogitter/internal/connector"
+	"github.com/arch-err/autogitter/internal/git"
+	"github.com/arch-err/autogitter/internal/ui"
+)
+
+// FixBranchesOptions contains options for the fix-branches command
+type FixBranchesOptions struct {
+	Force  bool
+	DryRun bool
+}
+
+// FixBranchesResult contains the results of a fix-branches operation
+type FixBranchesResult struct {
+	Switched int
+	Skipped  int
+	Failed   int
+}
+
+// branchChange describes a clone whose checked-out branch was the upstream
+// default before it was renamed, e.g. master -> main
+type branchChange struct {
+	repo localRepo
+	from string
+	to   string
+}
+
+// RunFixBranches finds clones still on a branch that no longer exists upstream
+// because the repo's default branch changed, and switches them to the new one
+func RunFixBranches(cfg *config.Config, opts FixBranchesOptions) (*FixBranchesResult, error) {
+	result := &FixBranchesResult{}
+
+	credPath := connector.DefaultCredentialsPath()
+	if err := connector.LoadCredentialsEnv(credPath); err != nil {
+		ui.Debug("failed to load credentials file", "error", err)
+	}
+
+	for i := range cfg.Sources {
+		source := &cfg.Sources[i]
+		resolver, _ := newSourceConnector(source).(connector.BranchResolver)
+
+		for _, repo := range listLocalRepos(source) {
+			change, err := detectBranchChange(source, resolver, repo)
+			if err != nil {
+				ui.Warn("failed to check default branch", "repo", repo.name, "error", err)
+				result.Failed++
+				continue
+			}
+			if change == nil {
+				continue
+			}
+
+			switched, err := applyBranchChange(source, *change, opts.Force, opts.DryRun)
+			switch {
+			case err != nil:
+				ui.Error("failed to switch branch", "repo", repo.name, "error", err)
+				result.Failed++
+			case switched:
+				result.Switched++
+			default:
+				result.Skipped++
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// detectBranchChange returns a change when the clone's current branch is gone
+// from origin and the upstream default branch is a different one. Clones on
+// branches that still exist upstream are left alone.
+func detectBranchChange(source *config.Source, resolver connector.BranchResolver, repo localRepo) (*branchChange, error) {
+	current, err := git.GetCurrentBranch(repo.path)
+	if err != nil || current == "HEAD" {
+		// Detached HEAD, nothing to track
+		return nil, nil
+	}
+
+	defaultBranch, err := defaultBranchFor(source, resolver, repo)
+	if err != nil {
+		return nil, err
+	}
+	if defaultBranch == "" || defaultBranch == current {
+		return nil, nil
+	}
+
+	exists, err := git.RemoteBranchExists(repo.path, current, source.PrivateKeyFor(repo.path))
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, nil
+	}
+
+	return &branchChange{repo: repo, from: current, to: defaultBranch}, nil
+}
+
+// defaultBranchFor asks the provider API for the repo's default branch,
+// falling back to origin's HEAD when the API can't answer
+func defaultBranchFor(source *config.Source, resolver connector.BranchResolver, repo localRepo) (string, error) {
+	if resolver != nil {
+		fullName := guessFullName(source.Source, repo.name)
+		if remote, err := git.GetRemoteURL(repo.path); err == nil {
+			if name := fullNameFromURL(remote); name != "" {
+				fullName = name
+			}
+		}
+
+		branch, err := resolver.DefaultBranch(context.Background(), fullName)
+		if err == nil && branch != "" {
+			return branch, nil
+		}
+		ui.Debug("failed to get default branch from API", "repo", fullName, "error", err)
+	}
+
+	return git.RemoteDefaultBranch(repo.path, source.PrivateKeyFor(repo.path))
+}
+
+// applyBranchChange switches the clone after confirmation.
+// Returns whether the branch was switched.
+func applyBranchChange(source *config.Source, change branchChange, force, dryRun bool) (bool, error) {
+	if dryRun {
+		ui.Info("would switch branch", "repo", change.repo.name, "from", change.from, "to", change.to)
+		return false, nil
+	}
+
+	if !force {
+		confirm, err := ui.ConfirmSwitchBranch(change.repo.name, change.from, change.to)
+		if err != nil {
+			return false, fmt.Errorf("failed to get user input: %w", err)
+		}
+		if !confirm {
+			return false, nil
+		}
+	}
+
+	err := git.SwitchBranch(git.SwitchBranchOptions{
+		Path:             change.repo.path,
+		From:             change.from,
+		To:               change.to,
+		PrivateKey:       source.PrivateKeyFor(change.repo.path),
+		CredentialHelper: source.CredHelper,
+		KnownHostsFile:   remoteKnownHosts(source, change.repo.path, "origin"),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	ui.Info("switched branch", "repo", change.repo.name, "from", change.from, "to", change.to)
+	return true, nil
+}
+
+// skipOffDefaultBranch drops the pull jobs of clones checked out on a branch
+// other than their default one, or on a detached HEAD, so pulls never merge
+// into work in progress. Returns the jobs to pull and the skipped ones.
+func skipOffDefaultBranch(jobs []pullJob) ([]pullJob, []pullJob) {
+	var kept, skipped []pullJob
+	for _, job := range jobs {
+		current, err := git.GetCurrentBranch(job.path)
+		if err != nil {
+			// e.g. no commits yet, the pull reports what's wrong
+			kept = append(kept, job)
+			continue
+		}
+		if current == "HEAD" {
+			ui.Info("not pulling, HEAD is detached", "repo", job.name)
+			skipped = append(skipped, job)
+			continue
+		}
+
+		defaultBranch, err := pullDefaultBranch(job)
+		if err != nil {
+			ui.Warn("not pulling, can't tell the default branch", "repo", job.name, "error", err)
+			skipped = append(skipped, job)
+			continue
+		}
+		if current != defaultBranch {
+			ui.Info("not pulling, not on the default branch", "repo", job.name, "branch", current, "default", defaultBranch)
+			skipped = append(skipped, job)
+			continue
+		}
+		kept = append(kept, job)
+	}
+	return kept, skipped
+}
+
+// pullDefaultBranch returns the branch a clone is pulled on: the source's
+// branch when set, otherwise origin/HEAD, asking origin only when the clone
+// doesn't record it
+func pullDefaultBranch(job pullJob) (string, error) {
+	if branch := job.source.GetBranch(); branch != "" {
+		return branch, nil
+	}
+	if branch, err := git.LocalDefaultBranch(job.path); err == nil {
+		return branch, nil
+	}
+	return git.RemoteDefaultBranch(job.path, job.privateKey)
+}
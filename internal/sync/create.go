@@ -0,0 +1,119 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/arch-err/autogitter/internal/config"
+	"github.com/arch-err/autogitter/internal/connector"
+	"github.com/arch-err/autogitter/internal/ui"
+)
+
+// CreateOptions contains options for the create command
+type CreateOptions struct {
+	Target     string // "<source>/<repo>", where source is a source name or its host/owner
+	Private    bool
+	ConfigPath string
+	DryRun     bool
+}
+
+// RunCreate creates a repo on the source's provider, adds it to the config
+// when the source is manual, and clones it
+func RunCreate(cfg *config.Config, opts CreateOptions) error {
+	source, name, err := parseRepoTarget(cfg, opts.Target)
+	if err != nil {
+		return err
+	}
+
+	owner := source.GetUserOrOrg()
+	if opts.DryRun {
+		ui.Info("would create repo", "source", source.Name, "owner", owner, "repo", name, "private", opts.Private)
+		return nil
+	}
+
+	credPath := connector.DefaultCredentialsPath()
+	if err := connector.LoadCredentialsEnv(credPath); err != nil {
+		ui.Debug("failed to load credentials file", "error", err)
+	}
+
+	creator, ok := newSourceConnector(source).(connector.RepoCreator)
+	if !ok {
+		return fmt.Errorf("source %s can't create repos (needs a github or gitea provider and a token)", source.Name)
+	}
+
+	fullName, err := creator.CreateRepo(context.Background(), owner, name, opts.Private)
+	if err != nil {
+		return err
+	}
+	ui.Info("created repo", "source", source.Name, "repo", fullName, "private", opts.Private)
+
+	_, err = addAndClone(cfg, source, fullName, opts.ConfigPath)
+	return err
+}
+
+// addAndClone adds a repo that now exists on the provider to a manual
+// source's config and clones it into the source's local_path. Returns the
+// path of the clone.
+func addAndClone(cfg *config.Config, source *config.Source, fullName, configPath string) (string, error) {
+	if source.Strategy == config.StrategyManual {
+		patch := &config.Patch{Sources: []config.SourcePatch{{Source: source.Name, Add: []string{fullName}}}}
+		added, _, err := cfg.Apply(patch)
+		if err != nil {
+			return "", err
+		}
+		if added > 0 && configPath != "" {
+			if err := SaveConfig(cfg, configPath); err != nil {
+				return "", fmt.Errorf("failed to save config: %w", err)
+			}
+			ui.Info("config saved", "path", configPath, "added", added)
+		}
+	}
+
+	path := filepath.Join(source.LocalPath, source.RepoDir(fullName))
+	if _, err := os.Stat(path); err == nil {
+		return "", fmt.Errorf("path already exists, not cloning: %s", path)
+	}
+
+	if err := pinHostKey(source); err != nil {
+		return "", fmt.Errorf("host key verification failed, not cloning: %w", err)
+	}
+
+	// A new repo may have no commits yet, so don't ask for the source's branch
+	if err := cloneRepo(source, fullName, path, "", nil); err != nil {
+		return "", fmt.Errorf("failed to clone %s: %w", fullName, err)
+	}
+	ui.Info("cloned", "repo", fullName, "path", path)
+	return path, nil
+}
+
+// parseRepoTarget splits "<source>/<repo>" and finds the source, matching
+// the name first and then the host/owner, e.g. "github.com/me/new-repo"
+func parseRepoTarget(cfg *config.Config, target string) (*config.Source, string, error) {
+	idx := strings.LastIndex(target, "/")
+	if idx <= 0 || idx == len(target)-1 {
+		return nil, "", fmt.Errorf("expected <source>/<repo>, got %q", target)
+	}
+	ref, name := target[:idx], target[idx+1:]
+
+	source, err := findSourceRef(cfg, ref)
+	if err != nil {
+		return nil, "", err
+	}
+	return source, name, nil
+}
+
+// findSourceRef finds a source by name or by its host/owner
+func findSourceRef(cfg *config.Config, ref string) (*config.Source, error) {
+	if source := cfg.FindSource(ref); source != nil {
+		return source, nil
+	}
+	for i := range cfg.Sources {
+		if strings.EqualFold(cfg.Sources[i].Source, ref) {
+			return &cfg.Sources[i], nil
+		}
+	}
+	return nil, fmt.Errorf("source not found: %s", ref)
+}
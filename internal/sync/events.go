@@ -0,0 +1,119 @@
+package sync
+
+import (
+	"slices"
+	gosync "sync"
+	"time"
+)
+
+// Event is something the engine did during a sync or pull. Subscribers get
+// one of the types below.
+type Event interface {
+	event()
+}
+
+// RepoCloned is a repo that was cloned
+type RepoCloned struct {
+	Source   string
+	Repo     string
+	Path     string
+	Duration time.Duration
+}
+
+// RepoCloneFailed is a repo that failed to clone
+type RepoCloneFailed struct {
+	Source string
+	Repo   string
+	Path   string
+	Err    error
+}
+
+// RepoPruned is an orphaned clone that was moved to the trash
+type RepoPruned struct {
+	Source string
+	Repo   string
+	Path   string
+}
+
+// RepoAdded is an orphaned clone that was added to the config
+type RepoAdded struct {
+	Source string
+	Repo   string
+}
+
+// RepoPulled is a repo that was pulled. Commits counts the new commits,
+// zero when it was already up to date.
+type RepoPulled struct {
+	Source   string
+	Repo     string
+	Path     string
+	Commits  int
+	Duration time.Duration
+}
+
+// RepoPullFailed is a repo that failed to pull
+type RepoPullFailed struct {
+	Source string
+	Repo   string
+	Path   string
+	Err    error
+}
+
+// SourceSynced is a source whose sync finished, with what was done to it
+type SourceSynced struct {
+	Summary SourceSummary
+}
+
+// SourceFailed is a source that couldn't be synced at all, e.g. because
+// its repos couldn't be listed
+type SourceFailed struct {
+	Source string
+	Err    error
+}
+
+func (RepoCloned) event()      {}
+func (RepoCloneFailed) event() {}
+func (RepoPruned) event()      {}
+func (RepoAdded) event()       {}
+func (RepoPulled) event()      {}
+func (RepoPullFailed) event()  {}
+func (SourceSynced) event()    {}
+func (SourceFailed) event()    {}
+
+type subscriber struct {
+	id int
+	fn func(Event)
+}
+
+var (
+	subscribersMu gosync.Mutex
+	subscribers   []subscriber
+	nextID        int
+)
+
+// Subscribe calls fn with every event the engine emits from now on, until
+// the returned function is called. Events are delivered one at a time, in
+// the order they happened, from the goroutine running the sync or pull.
+func Subscribe(fn func(Event)) (unsubscribe func()) {
+	subscribersMu.Lock()
+	defer subscribersMu.Unlock()
+	id := nextID
+	nextID++
+	subscribers = append(subscribers, subscriber{id: id, fn: fn})
+	return func() {
+		subscribersMu.Lock()
+		defer subscribersMu.Unlock()
+		subscribers = slices.DeleteFunc(subscribers, func(s subscriber) bool { return s.id == id })
+	}
+}
+
+// emit delivers an event to the subscribers, in the order they subscribed
+func emit(e Event) {
+	subscribersMu.Lock()
+	subs := slices.Clone(subscribers)
+	subscribersMu.Unlock()
+
+	for _, s := range subs {
+		s.fn(e)
+	}
+}
@@ -0,0 +1,123 @@
+package sync
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/arch-err/autogitter/internal/config"
+)
+
+// FoundRepo is a local repo matching a find query
+type FoundRepo struct {
+	Source string
+	Name   string
+	Alias  string // the repo's alias in the config, if any
+	Path   string
+	Score  int
+}
+
+// FindRepos fuzzy-matches query against every local repo of every source and
+// returns the matches, best first. An empty query matches everything. A
+// query that is a repo's alias matches only that repo.
+func FindRepos(cfg *config.Config, query string) []FoundRepo {
+	query = strings.ToLower(query)
+	seen := make(map[string]bool)
+
+	var found []FoundRepo
+	for i := range cfg.Sources {
+		source := &cfg.Sources[i]
+		aliases := make(map[string]string)
+		for _, entry := range source.Repos {
+			if entry.Alias != "" {
+				aliases[filepath.Clean(source.RepoPath(entry))] = entry.Alias
+			}
+		}
+
+		for _, repo := range listLocalRepos(source) {
+			if seen[repo.path] {
+				continue
+			}
+			seen[repo.path] = true
+
+			name := filepath.ToSlash(repo.name)
+			alias := aliases[filepath.Clean(repo.path)]
+			if alias != "" && strings.ToLower(alias) == query {
+				return []FoundRepo{{Source: source.Name, Name: name, Alias: alias, Path: repo.path}}
+			}
+
+			score, ok := fuzzyScore(query, strings.ToLower(name))
+			for _, candidate := range []string{repo.fullName, alias} {
+				if candidate == "" {
+					continue
+				}
+				if altScore, altOK := fuzzyScore(query, strings.ToLower(candidate)); altOK && (!ok || altScore > score) {
+					score, ok = altScore, true
+				}
+			}
+			if !ok {
+				continue
+			}
+			found = append(found, FoundRepo{Source: source.Name, Name: name, Alias: alias, Path: repo.path, Score: score})
+		}
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		if found[i].Score != found[j].Score {
+			return found[i].Score > found[j].Score
+		}
+		return found[i].Path < found[j].Path
+	})
+	return found
+}
+
+// fuzzyScore reports whether every character of query appears in candidate in
+// order, and scores the match: consecutive characters, characters at the
+// start of a path segment or word, and an exact repo name rank higher
+func fuzzyScore(query, candidate string) (int, bool) {
+	if query == "" {
+		return 0, true
+	}
+
+	score := 0
+	qi := 0
+	prevMatch := -2
+	for ci, r := range candidate {
+		if qi >= len(query) {
+			break
+		}
+		qr, size := utf8.DecodeRuneInString(query[qi:])
+		if r != qr {
+			continue
+		}
+
+		score++
+		if ci == prevMatch+1 {
+			score += 3
+		}
+		if ci == 0 || strings.ContainsRune("/-_. ", rune(candidate[ci-1])) {
+			score += 5
+		}
+		prevMatch = ci + utf8.RuneLen(r) - 1
+		qi += size
+	}
+	if qi < len(query) {
+		return 0, false
+	}
+
+	base := candidate
+	if idx := strings.LastIndex(candidate, "/"); idx != -1 {
+		base = candidate[idx+1:]
+	}
+	switch {
+	case base == query:
+		score += 20
+	case strings.Contains(base, query):
+		score += 10
+	}
+
+	// Prefer shorter names among otherwise equal matches
+	score -= len(candidate) / 10
+	return score, true
+}
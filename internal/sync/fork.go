@@ -0,0 +1,231 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/arch-err/autogitter/internal/config"
+	"github.com/arch-err/autogitter/internal/connector"
+	"github.com/arch-err/autogitter/internal/git"
+	"github.com/arch-err/autogitter/internal/ui"
+)
+
+// forkReadyTimeout is how long to wait for the provider to finish creating a
+// fork before cloning it, since forking happens in the background
+const forkReadyTimeout = 30 * time.Second
+
+// ForkOptions contains options for the fork command
+type ForkOptions struct {
+	Repo       string // "owner/repo" to fork, on the source's host
+	Source     string // source to fork into, by name or host/owner
+	ConfigPath string
+	DryRun     bool
+}
+
+// SyncForksOptions contains options for the sync-forks command
+type SyncForksOptions struct {
+	DryRun bool
+}
+
+// SyncForksResult contains the results of a sync-forks operation
+type SyncForksResult struct {
+	Updated  int
+	UpToDate int
+	Failed   int
+}
+
+// RunFork forks a repo into the source's user or org, adds the fork to the
+// config when the source is manual, clones it, and adds the original repo
+// as the "upstream" remote
+func RunFork(cfg *config.Config, opts ForkOptions) error {
+	if strings.Count(opts.Repo, "/") != 1 {
+		return fmt.Errorf("expected owner/repo, got %q", opts.Repo)
+	}
+
+	source, err := findForkSource(cfg, opts.Source)
+	if err != nil {
+		return err
+	}
+
+	owner := source.GetUserOrOrg()
+	if opts.DryRun {
+		ui.Info("would fork repo", "repo", opts.Repo, "source", source.Name, "owner", owner)
+		return nil
+	}
+
+	credPath := connector.DefaultCredentialsPath()
+	if err := connector.LoadCredentialsEnv(credPath); err != nil {
+		ui.Debug("failed to load credentials file", "error", err)
+	}
+
+	conn := newSourceConnector(source)
+	forker, ok := conn.(connector.RepoForker)
+	if !ok {
+		return fmt.Errorf("source %s can't fork repos (needs a github or gitea provider and a token)", source.Name)
+	}
+
+	ctx := context.Background()
+	fullName, err := forker.ForkRepo(ctx, opts.Repo, owner)
+	if err != nil {
+		return err
+	}
+	ui.Info("forked repo", "repo", opts.Repo, "fork", fullName)
+
+	if resolver, ok := conn.(connector.RepoResolver); ok {
+		waitForRepo(ctx, resolver, fullName)
+	}
+
+	path, err := addAndClone(cfg, source, fullName, opts.ConfigPath)
+	if err != nil {
+		return err
+	}
+
+	upstream := source.GetRepoURL(opts.Repo)
+	if err := git.SetRemote(path, "upstream", upstream); err != nil {
+		return err
+	}
+	ui.Info("added upstream remote", "repo", fullName, "upstream", upstream)
+	return nil
+}
+
+// findForkSource returns the named source, or the only source when no name
+// is given
+func findForkSource(cfg *config.Config, ref string) (*config.Source, error) {
+	if ref != "" {
+		return findSourceRef(cfg, ref)
+	}
+	if len(cfg.Sources) != 1 {
+		return nil, fmt.Errorf("multiple sources in config, pick one with --source")
+	}
+	return &cfg.Sources[0], nil
+}
+
+// waitForRepo polls the provider until the repo can be looked up or the
+// timeout passes
+func waitForRepo(ctx context.Context, resolver connector.RepoResolver, fullName string) {
+	deadline := time.Now().Add(forkReadyTimeout)
+	for {
+		if _, err := resolver.ResolveRepo(ctx, fullName); err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			ui.Warn("fork is not ready yet, trying to clone anyway", "repo", fullName)
+			return
+		}
+		ui.Debug("waiting for fork", "repo", fullName)
+		time.Sleep(2 * time.Second)
+	}
+}
+
+// configureUpstream adds the "upstream" remote to a fresh clone, using the
+// repo's upstream from the config or, failing that, the fork parent
+// reported by the provider
+func configureUpstream(source *config.Source, fullName, path string) {
+	upstream := source.UpstreamFor(path)
+	if upstream == "" {
+		resolver, ok := newSourceConnector(source).(connector.ForkResolver)
+		if !ok || config.IsCloneURL(fullName) {
+			return
+		}
+		parent, err := resolver.ForkParent(context.Background(), fullName)
+		if err != nil {
+			ui.Debug("failed to look up fork parent", "repo", fullName, "error", err)
+			return
+		}
+		if parent == "" {
+			return
+		}
+		upstream = source.GetRepoURL(parent)
+	}
+
+	if err := git.SetRemote(path, "upstream", upstream); err != nil {
+		ui.Warn("failed to add upstream remote", "repo", fullName, "error", err)
+	}
+}
+
+// RunSyncForks fast-forwards the default branch of every clone with an
+// "upstream" remote to upstream's branch of the same name and pushes it to
+// origin. Branches that have diverged from upstream are left alone.
+func RunSyncForks(cfg *config.Config, opts SyncForksOptions) (*SyncForksResult, error) {
+	result := &SyncForksResult{}
+
+	credPath := connector.DefaultCredentialsPath()
+	if err := connector.LoadCredentialsEnv(credPath); err != nil {
+		ui.Debug("failed to load credentials file", "error", err)
+	}
+
+	for i := range cfg.Sources {
+		source := &cfg.Sources[i]
+		for _, repo := range listLocalRepos(source) {
+			if !git.HasRemote(repo.path, "upstream") {
+				continue
+			}
+
+			updated, err := syncFork(source, repo, opts.DryRun)
+			switch {
+			case err != nil:
+				ui.Error("failed to sync fork", "repo", repo.name, "error", err)
+				result.Failed++
+			case updated:
+				result.Updated++
+			default:
+				result.UpToDate++
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// syncFork fetches upstream and fast-forwards the clone's default branch.
+// Returns whether the branch was (or, in a dry run, would be) updated.
+func syncFork(source *config.Source, repo localRepo, dryRun bool) (bool, error) {
+	privateKey := source.PrivateKeyFor(repo.path)
+
+	branch, err := git.RemoteDefaultBranch(repo.path, privateKey)
+	if err != nil {
+		return false, err
+	}
+
+	err = git.Fetch(git.FetchOptions{
+		Path:             repo.path,
+		Remote:           "upstream",
+		PrivateKey:       privateKey,
+		CredentialHelper: source.CredHelper,
+		KnownHostsFile:   remoteKnownHosts(source, repo.path, "upstream"),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	ref := "upstream/" + branch
+	behind, err := git.CanFastForward(repo.path, branch, ref)
+	if err != nil || !behind {
+		return false, err
+	}
+
+	if dryRun {
+		ui.Info("would fast-forward", "repo", repo.name, "branch", branch, "to", ref)
+		return true, nil
+	}
+
+	if err := git.FastForward(repo.path, branch, ref); err != nil {
+		return false, err
+	}
+	err = git.PushBranch(git.PushBranchOptions{
+		Path:             repo.path,
+		Remote:           "origin",
+		Branch:           branch,
+		PrivateKey:       privateKey,
+		CredentialHelper: source.CredHelper,
+		KnownHostsFile:   remoteKnownHosts(source, repo.path, "origin"),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	ui.Info("synced fork", "repo", repo.name, "branch", branch)
+	return true, nil
+}
@@ -0,0 +1,58 @@
+package sync
+
+import (
+	"fmt"
+
+	"github.com/arch-err/autogitter/internal/config"
+	"github.com/arch-err/autogitter/internal/git"
+	"github.com/arch-err/autogitter/internal/ui"
+)
+
+// applyGitConfig sets the source's git_config values in a fresh clone
+func applyGitConfig(source *config.Source, path string) error {
+	for _, key := range source.GitConfig.Keys() {
+		if err := git.SetConfig(path, key, source.GitConfig[key]); err != nil {
+			return fmt.Errorf("cloned, but %w", err)
+		}
+	}
+	return nil
+}
+
+// checkGitConfig reports clones whose local git config differs from the
+// source's git_config and, when FixConfig is set, sets the configured values
+func checkGitConfig(source *config.Source, statuses []RepoStatus, opts SyncOptions) {
+	if len(source.GitConfig) == 0 {
+		return
+	}
+
+	for _, status := range statuses {
+		if !status.ExistsLocal || !git.IsGitRepo(status.LocalPath) {
+			continue
+		}
+
+		for _, key := range source.GitConfig.Keys() {
+			expected := source.GitConfig[key]
+			actual, err := git.GetConfig(status.LocalPath, key)
+			if err != nil {
+				ui.Debug("failed to read git config", "repo", status.Name, "key", key, "error", err)
+				continue
+			}
+			if actual == expected {
+				continue
+			}
+
+			switch {
+			case !opts.FixConfig:
+				ui.Warn("git config does not match (use --fix-config)", "repo", status.Name, "key", key, "value", actual, "expected", expected)
+			case opts.DryRun:
+				ui.Info("would set git config", "repo", status.Name, "key", key, "from", actual, "to", expected)
+			default:
+				if err := git.SetConfig(status.LocalPath, key, expected); err != nil {
+					ui.Error("failed to set git config", "repo", status.Name, "error", err)
+					continue
+				}
+				ui.Info("set git config", "repo", status.Name, "key", key, "from", actual, "to", expected)
+			}
+		}
+	}
+}
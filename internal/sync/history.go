@@ -0,0 +1,246 @@
+package sync
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/arch-err/autogitter/internal/connector"
+	"github.com/arch-err/autogitter/internal/errs"
+	"github.com/arch-err/autogitter/internal/journal"
+	"github.com/arch-err/autogitter/internal/ui"
+)
+
+// HistoryFile is the name of the run history, next to the credentials file.
+// Every sync, pull, and prune appends a line to it.
+const HistoryFile = "history.jsonl"
+
+// Actions of a RepoRecord
+const (
+	HistoryCloned       = "cloned"
+	HistoryCloneFailed  = "clone_failed"
+	HistoryPruned       = "pruned"
+	HistoryAdded        = "added"
+	HistoryUpdated      = "updated"
+	HistoryPullFailed   = "pull_failed"
+	HistorySourceFailed = "source_failed"
+)
+
+// RunRecord is a sync or pull in the history
+type RunRecord struct {
+	Time    time.Time `json:"time"` // when the run started
+	Run     string    `json:"run"`  // "sync" or "pull"
+	Command string    `json:"command,omitempty"`
+	Config  string    `json:"config,omitempty"`
+	Seconds float64   `json:"duration_seconds"`
+
+	Cloned  int `json:"cloned,omitempty"`
+	Pruned  int `json:"pruned,omitempty"`
+	Added   int `json:"added,omitempty"`
+	Skipped int `json:"skipped,omitempty"`
+	Pulled  int `json:"pulled,omitempty"`  // pulls that succeeded
+	Updated int `json:"updated,omitempty"` // pulls that brought in commits
+	Failed  int `json:"failed,omitempty"`
+
+	// Repos lists what the run did to each repo it changed or failed on.
+	// Pulls that brought nothing in aren't listed.
+	Repos []RepoRecord `json:"repos,omitempty"`
+}
+
+// RepoRecord is something a run did to one repo, or a source that failed
+type RepoRecord struct {
+	Action  string `json:"action"`
+	Source  string `json:"source"`
+	Repo    string `json:"repo,omitempty"`
+	Path    string `json:"path,omitempty"`
+	Commits int    `json:"commits,omitempty"`
+	Error   string `json:"error,omitempty"` // first line of the error
+	Kind    string `json:"kind,omitempty"`  // kind of error, as in failed.json
+}
+
+// historyRecorder collects the events of one run into its RunRecord
+type historyRecorder struct {
+	record      RunRecord
+	unsubscribe func()
+}
+
+func historyPath() string {
+	return filepath.Join(filepath.Dir(connector.DefaultCredentialsPath()), HistoryFile)
+}
+
+// startHistory starts recording a run. The command is the one the journal
+// knows, e.g. "ag sync --prune", so runs of ag serve or ag sync tell apart.
+func startHistory(run, configPath string) *historyRecorder {
+	h := &historyRecorder{record: RunRecord{
+		Time:    time.Now().UTC(),
+		Run:     run,
+		Command: journal.Command(),
+	}}
+	if configPath != "" {
+		h.record.Config = configKey(configPath)
+	}
+	h.unsubscribe = Subscribe(h.add)
+	return h
+}
+
+// add records an event of the run
+func (h *historyRecorder) add(e Event) {
+	var r RepoRecord
+	switch e := e.(type) {
+	case RepoCloned:
+		r = RepoRecord{Action: HistoryCloned, Source: e.Source, Repo: e.Repo, Path: e.Path}
+	case RepoCloneFailed:
+		r = RepoRecord{Action: HistoryCloneFailed, Source: e.Source, Repo: e.Repo, Path: e.Path}
+		r.setError(e.Err)
+	case RepoPruned:
+		r = RepoRecord{Action: HistoryPruned, Source: e.Source, Repo: e.Repo, Path: e.Path}
+	case RepoAdded:
+		r = RepoRecord{Action: HistoryAdded, Source: e.Source, Repo: e.Repo}
+	case RepoPulled:
+		if e.Commits == 0 {
+			return
+		}
+		r = RepoRecord{Action: HistoryUpdated, Source: e.Source, Repo: e.Repo, Path: e.Path, Commits: e.Commits}
+	case RepoPullFailed:
+		r = RepoRecord{Action: HistoryPullFailed, Source: e.Source, Repo: e.Repo, Path: e.Path}
+		r.setError(e.Err)
+	case SourceFailed:
+		r = RepoRecord{Action: HistorySourceFailed, Source: e.Source}
+		r.setError(e.Err)
+	default:
+		return
+	}
+	h.record.Repos = append(h.record.Repos, r)
+}
+
+func (r *RepoRecord) setError(err error) {
+	r.Error, _, _ = strings.Cut(err.Error(), "\n")
+	r.Kind = errs.Kind(err)
+}
+
+// finishSync records the counts of a sync and appends it to the history
+func (h *historyRecorder) finishSync(result *SyncResult) {
+	h.record.Cloned = result.Cloned
+	h.record.Pruned = result.Pruned
+	h.record.Added = result.Added
+	h.record.Skipped = result.Skipped
+	h.record.Failed = result.Failed
+	h.record.Pulled = result.Pulled
+	h.record.Updated = len(result.Digests)
+	h.finish()
+}
+
+// finishPrune records the count of a prune and appends it to the history
+func (h *historyRecorder) finishPrune(pruned int) {
+	h.record.Pruned = pruned
+	h.finish()
+}
+
+// finishPull records the counts of a pull and appends it to the history
+func (h *historyRecorder) finishPull(result *PullResult) {
+	h.record.Pulled = result.Updated
+	h.record.Updated = len(result.Digests)
+	h.record.Failed = result.Failed
+	h.record.Skipped = result.Skipped
+	h.finish()
+}
+
+func (h *historyRecorder) finish() {
+	h.unsubscribe()
+	h.record.Seconds = time.Since(h.record.Time).Seconds()
+
+	if err := appendHistory(h.record); err != nil {
+		ui.Warn("failed to write run history", "path", historyPath(), "error", err)
+	}
+}
+
+func appendHistory(record RunRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	path := historyPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// HistoryOptions filter the runs returned by History
+type HistoryOptions struct {
+	// Repo keeps the runs that did something to a repo whose name or path
+	// contains it, ignoring case, and only their records of those repos
+	Repo string
+	// Since drops runs that started before it
+	Since time.Time
+	// Limit keeps the newest runs only, 0 for all
+	Limit int
+}
+
+// History returns the recorded runs, newest first
+func History(opts HistoryOptions) ([]RunRecord, error) {
+	f, err := os.Open(historyPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	defer f.Close()
+
+	var runs []RunRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var run RunRecord
+		if err := json.Unmarshal(scanner.Bytes(), &run); err != nil {
+			continue // cut short by a crash
+		}
+		if run.Time.Before(opts.Since) {
+			continue
+		}
+		if opts.Repo != "" {
+			run.Repos = matchingRepos(run.Repos, opts.Repo)
+			if len(run.Repos) == 0 {
+				continue
+			}
+		}
+		runs = append(runs, run)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+
+	// Newest first
+	for i, j := 0, len(runs)-1; i < j; i, j = i+1, j-1 {
+		runs[i], runs[j] = runs[j], runs[i]
+	}
+	if opts.Limit > 0 && len(runs) > opts.Limit {
+		runs = runs[:opts.Limit]
+	}
+	return runs, nil
+}
+
+// matchingRepos returns the records whose repo name or path contains query
+func matchingRepos(repos []RepoRecord, query string) []RepoRecord {
+	query = strings.ToLower(query)
+	var matching []RepoRecord
+	for _, r := range repos {
+		if strings.Contains(strings.ToLower(r.Repo), query) || strings.Contains(strings.ToLower(r.Path), query) {
+			matching = append(matching, r)
+		}
+	}
+	return matching
+}
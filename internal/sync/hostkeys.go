@@ -0,0 +1,121 @@
+package sync
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/arch-err/autogitter/internal/config"
+	"github.com/arch-err/autogitter/internal/connector"
+	"github.com/arch-err/autogitter/internal/errs"
+	"github.com/arch-err/autogitter/internal/git"
+	"github.com/arch-err/autogitter/internal/ui"
+)
+
+// knownHostsPath is the known_hosts file holding pinned host keys, next to
+// the credentials file
+func knownHostsPath() string {
+	return filepath.Join(filepath.Dir(connector.DefaultCredentialsPath()), "known_hosts")
+}
+
+// pinsHostKey reports whether clones of the source use a pinned host key
+func pinsHostKey(source *config.Source) bool {
+	return source.SSHOptions.HostKeyFingerprint != "" && source.Protocol != config.ProtocolHTTPS
+}
+
+// knownHostsFor returns the pinned known_hosts file for cloning url from the
+// source, or "" to use the user's own. The pinned key is the source host's,
+// so clone URL entries on other hosts use the user's.
+func knownHostsFor(source *config.Source, url string) string {
+	if !pinsHostKey(source) || !strings.EqualFold(remoteHost(url), source.GetHost()) {
+		return ""
+	}
+	return knownHostsPath()
+}
+
+// remoteKnownHosts returns the pinned known_hosts file for a remote of the
+// clone at path, or "" when the remote isn't on the source's pinned host
+func remoteKnownHosts(source *config.Source, path, remote string) string {
+	if !pinsHostKey(source) {
+		return ""
+	}
+	url, err := git.RemoteURL(path, remote)
+	if err != nil {
+		return ""
+	}
+	return knownHostsFor(source, url)
+}
+
+// pinHostKey checks the source's SSH server against its host_key_fingerprint
+// and records the key for clones, pulls, and pushes to trust
+func pinHostKey(source *config.Source) error {
+	if !pinsHostKey(source) {
+		return nil
+	}
+	return git.PinHostKey(knownHostsPath(), source.GetHost(), source.SSHOptions.Port, source.SSHOptions.HostKeyFingerprint)
+}
+
+// verifyHostKeys pins the host key of every source with clone jobs and drops
+// the jobs of sources whose server doesn't match. Returns the remaining jobs
+// and the dropped ones as failed clones.
+func verifyHostKeys(jobs []cloneJob) ([]cloneJob, []cloneResult) {
+	failed := make(map[*config.Source]error)
+	checked := make(map[*config.Source]bool)
+	for _, job := range jobs {
+		if checked[job.source] {
+			continue
+		}
+		checked[job.source] = true
+		if err := pinHostKey(job.source); err != nil {
+			ui.Error("host key verification failed, not cloning", "source", job.source.Name, "error", err)
+			failed[job.source] = fmt.Errorf("host key verification failed: %w", err)
+		}
+	}
+	if len(failed) == 0 {
+		return jobs, nil
+	}
+
+	var kept []cloneJob
+	var dropped []cloneResult
+	for _, job := range jobs {
+		if err, ok := failed[job.source]; ok {
+			dropped = append(dropped, cloneResult{job: job, err: err})
+			continue
+		}
+		kept = append(kept, job)
+	}
+	return kept, dropped
+}
+
+// verifyPullHostKeys pins the host key of every source with pull jobs on its
+// pinned host, as verifyHostKeys does for clones, and drops the jobs of
+// sources whose server doesn't match. Returns the remaining jobs and the
+// dropped ones as failed pulls.
+func verifyPullHostKeys(jobs []pullJob) ([]pullJob, []pullResult) {
+	failed := make(map[*config.Source]error)
+	checked := make(map[*config.Source]bool)
+	for _, job := range jobs {
+		if job.knownHosts == "" || checked[job.source] {
+			continue
+		}
+		checked[job.source] = true
+		if err := pinHostKey(job.source); err != nil {
+			ui.Error("host key verification failed, not pulling", "source", job.source.Name, "error", err)
+			failed[job.source] = &errs.HostKeyError{Err: err}
+		}
+	}
+	if len(failed) == 0 {
+		return jobs, nil
+	}
+
+	var kept []pullJob
+	var dropped []pullResult
+	for _, job := range jobs {
+		if err, ok := failed[job.source]; ok && job.knownHosts != "" {
+			dropped = append(dropped, pullResult{job: job, err: err})
+			continue
+		}
+		kept = append(kept, job)
+	}
+	return kept, dropped
+}
@@ -0,0 +1,118 @@
+package sync
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/arch-err/autogitter/internal/config"
+	"github.com/arch-err/autogitter/internal/ui"
+)
+
+// IgnoreFile is the name of the per-source ignore file in local_path
+const IgnoreFile = ".agignore"
+
+// ignorePattern is one line of an ignore list in gitignore syntax
+type ignorePattern struct {
+	pattern  string
+	negate   bool
+	anchored bool // contains a "/" other than a trailing one, so it matches from the root
+}
+
+// ignoreList decides which directories under local_path are skipped when
+// scanning for repos. Like gitignore, the last matching pattern wins.
+type ignoreList struct {
+	patterns []ignorePattern
+}
+
+// loadIgnore combines a source's ignore: config list with its .agignore file
+func loadIgnore(source *config.Source) *ignoreList {
+	lines := append([]string{}, source.Ignore...)
+
+	file, err := os.Open(filepath.Join(source.LocalPath, IgnoreFile))
+	if err == nil {
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		if err := scanner.Err(); err != nil {
+			ui.Warn("failed to read ignore file", "source", source.Name, "error", err)
+		}
+	}
+
+	return parseIgnore(lines)
+}
+
+func parseIgnore(lines []string) *ignoreList {
+	list := &ignoreList{}
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		p := ignorePattern{}
+		if strings.HasPrefix(line, "!") {
+			p.negate = true
+			line = line[1:]
+		}
+		// Only directories are scanned, so a trailing slash changes nothing
+		line = strings.TrimSuffix(line, "/")
+		p.anchored = strings.Contains(line, "/")
+		p.pattern = strings.TrimPrefix(line, "/")
+		if p.pattern != "" {
+			list.patterns = append(list.patterns, p)
+		}
+	}
+	return list
+}
+
+// Match reports whether the directory at rel (relative to local_path,
+// slash-separated) is ignored
+func (l *ignoreList) Match(rel string) bool {
+	if l == nil {
+		return false
+	}
+
+	ignored := false
+	for _, p := range l.patterns {
+		var matched bool
+		if p.anchored {
+			matched = matchSegments(strings.Split(p.pattern, "/"), strings.Split(rel, "/"))
+		} else {
+			matched, _ = path.Match(p.pattern, path.Base(rel))
+		}
+		if matched {
+			ignored = !p.negate
+		}
+	}
+	return ignored
+}
+
+// matchSegments matches path segments against pattern segments, where "**"
+// matches any number of segments
+func matchSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(segments) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], segments[1:])
+}
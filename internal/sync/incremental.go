@@ -0,0 +1,91 @@
+package sync
+
+import (
+	"context"
+	"time"
+
+	"github.com/arch-err/autogitter/internal/config"
+	"github.com/arch-err/autogitter/internal/connector"
+	"github.com/arch-err/autogitter/internal/ui"
+)
+
+// DefaultFullListEvery is how often an incremental sync lists a source's
+// repos in full when FullListEvery isn't set. Activity feeds don't report
+// deleted or archived repos, so those are only noticed then.
+const DefaultFullListEvery = 24 * time.Hour
+
+// listing is what a sync learned about a source's repos from the provider
+type listing struct {
+	repos    []string // every repo listed, before the regex
+	from     string   // host/owner listed
+	polledAt time.Time
+	listedAt time.Time // when repos were listed in full
+}
+
+// listingFrom identifies what a source lists, so a saved listing isn't
+// reused after the source is pointed elsewhere
+func listingFrom(source *config.Source) string {
+	from := source.GetHost() + "/" + source.GetUserOrOrg()
+	if source.Project != "" {
+		from += " project " + source.Project
+	}
+	return from
+}
+
+// listReposIncremental lists the source's repos, reusing the last sync's
+// listing when the provider's activity feed shows no repo created, renamed,
+// or made public since. Lists in full when there's no saved listing, it's
+// older than fullListEvery, or the feed can't be read.
+func listReposIncremental(ctx context.Context, source *config.Source, last *sourceState, fullListEvery time.Duration) (listing, error) {
+	now := time.Now().UTC()
+	from := listingFrom(source)
+
+	// Feeds belong to a user or org, so sources without one always list
+	hasFeed := source.GetUserOrOrg() != ""
+	if hasFeed && last != nil && last.ListedFrom == from && !last.ListedAt.IsZero() && now.Sub(last.ListedAt) < fullListEvery {
+		changed, err := reposChangedSince(ctx, source, last.PolledAt)
+		switch {
+		case err != nil:
+			ui.Debug("failed to read activity feed, listing all repos", "source", source.Name, "error", err)
+		case changed:
+			ui.Debug("repos changed upstream, listing all repos", "source", source.Name)
+		default:
+			ui.Debug("no repo changes in activity feed, reusing last listing", "source", source.Name, "listed_at", last.ListedAt)
+			return listing{repos: last.Listed, from: from, polledAt: now, listedAt: last.ListedAt}, nil
+		}
+	}
+
+	repos, err := ListRepos(ctx, source)
+	if err != nil {
+		return listing{}, err
+	}
+	return listing{repos: repos, from: from, polledAt: now, listedAt: now}, nil
+}
+
+// reposChangedSince asks the source's provider whether its repos changed
+// since the given time. Providers without an activity feed always may have.
+func reposChangedSince(ctx context.Context, source *config.Source, since time.Time) (bool, error) {
+	conn, err := source.NewConnector(source.GetToken())
+	if err != nil {
+		return false, err
+	}
+	checker, ok := conn.(connector.ActivityChecker)
+	if !ok {
+		return true, nil
+	}
+	return checker.ReposChangedSince(ctx, source.GetUserOrOrg(), since)
+}
+
+// lastSyncState returns the state the last sync with the config recorded,
+// or nil when there is none
+func lastSyncState(configPath string) *syncState {
+	if configPath == "" {
+		return nil
+	}
+	all, err := loadState()
+	if err != nil {
+		ui.Warn("failed to read sync state, listing all repos", "error", err)
+		return nil
+	}
+	return all[configKey(configPath)]
+}
@@ -0,0 +1,186 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/arch-err/autogitter/internal/config"
+	"github.com/arch-err/autogitter/internal/ui"
+)
+
+// UpdateSymlinks makes cfg.SymlinkDir contain one symlink per local repo across
+// all sources, pointing at the clone. Links into a source's local_path whose
+// repo no longer exists are removed; links elsewhere, regular files, and
+// directories in SymlinkDir are never touched.
+func UpdateSymlinks(cfg *config.Config) error {
+	dir := cfg.SymlinkDir
+	if dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create symlink directory: %w", err)
+	}
+
+	want := symlinkTargets(cfg)
+	roots := sourceRoots(cfg)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read symlink directory: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.Type()&os.ModeSymlink == 0 {
+			continue
+		}
+		link := filepath.Join(dir, entry.Name())
+		target, err := os.Readlink(link)
+		if err == nil && want[entry.Name()] == target {
+			delete(want, entry.Name())
+			continue
+		}
+		if err != nil || !insideAny(target, roots) {
+			if _, ok := want[entry.Name()]; ok {
+				ui.Warn("symlink not created by autogitter, skipping", "path", link)
+				delete(want, entry.Name())
+			}
+			continue
+		}
+		if err := os.Remove(link); err != nil {
+			ui.Warn("failed to remove stale symlink", "path", link, "error", err)
+			continue
+		}
+		ui.Debug("removed symlink", "path", link)
+	}
+
+	for name, target := range want {
+		link := filepath.Join(dir, name)
+		if _, err := os.Lstat(link); err == nil {
+			ui.Warn("path in symlink directory is not a symlink, skipping", "path", link)
+			continue
+		}
+		if err := os.Symlink(target, link); err != nil {
+			ui.Warn("failed to create symlink", "path", link, "error", err)
+			continue
+		}
+		ui.Debug("created symlink", "path", link, "target", target)
+	}
+
+	return nil
+}
+
+// sourceRoots returns the absolute local_path of every source
+func sourceRoots(cfg *config.Config) []string {
+	roots := make([]string, 0, len(cfg.Sources))
+	for i := range cfg.Sources {
+		root, err := filepath.Abs(cfg.Sources[i].LocalPath)
+		if err != nil {
+			continue
+		}
+		roots = append(roots, root)
+	}
+	return roots
+}
+
+// insideAny reports whether path is within one of the roots
+func insideAny(path string, roots []string) bool {
+	for _, root := range roots {
+		rel, err := filepath.Rel(root, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// symlinkTargets maps link names to clone paths. Repos are linked by name;
+// when two repos share a name, both are linked as "<owner>-<repo>" instead.
+func symlinkTargets(cfg *config.Config) map[string]string {
+	type candidate struct {
+		name     string
+		fullName string
+		path     string
+	}
+
+	var all []candidate
+	count := make(map[string]int)
+	for i := range cfg.Sources {
+		for _, repo := range listLocalRepos(&cfg.Sources[i]) {
+			name := repoNameFromFullName(filepath.ToSlash(repo.name))
+			fullName := repo.fullName
+			if !strings.Contains(fullName, "/") {
+				fullName = guessFullName(cfg.Sources[i].Source, name)
+			}
+			all = append(all, candidate{name: name, fullName: fullName, path: repo.path})
+			count[name]++
+		}
+	}
+
+	sort.Slice(all, func(a, b int) bool { return all[a].path < all[b].path })
+
+	targets := make(map[string]string)
+	for _, c := range all {
+		name := c.name
+		if count[name] > 1 {
+			name = strings.ReplaceAll(c.fullName, "/", "-")
+		}
+		if existing, ok := targets[name]; ok {
+			ui.Warn("duplicate repo name, skipping symlink", "name", name, "path", c.path, "linked", existing)
+			continue
+		}
+
+		target, err := filepath.Abs(c.path)
+		if err != nil {
+			target = c.path
+		}
+		targets[name] = target
+	}
+	return targets
+}
+
+// updateRepoLinks points the link of every repo entry that has one at the
+// subpath it checks out. Links wait for the clone to exist, and paths that
+// aren't symlinks are left alone.
+func updateRepoLinks(cfg *config.Config) {
+	for i := range cfg.Sources {
+		source := &cfg.Sources[i]
+		for _, repo := range source.Repos {
+			if repo.Link == "" {
+				continue
+			}
+			target, err := filepath.Abs(filepath.Join(source.RepoPath(repo), repo.Subpath))
+			if err != nil {
+				continue
+			}
+			if _, err := os.Stat(target); err != nil {
+				continue // not cloned yet
+			}
+
+			current, err := os.Readlink(repo.Link)
+			if err == nil && current == target {
+				continue
+			}
+			if err == nil {
+				if err := os.Remove(repo.Link); err != nil {
+					ui.Warn("failed to replace link", "repo", repo.Name, "path", repo.Link, "error", err)
+					continue
+				}
+			} else if _, err := os.Lstat(repo.Link); err == nil {
+				ui.Warn("link path exists and is not a symlink, skipping", "repo", repo.Name, "path", repo.Link)
+				continue
+			}
+
+			if err := os.MkdirAll(filepath.Dir(repo.Link), 0755); err != nil {
+				ui.Warn("failed to create link directory", "repo", repo.Name, "error", err)
+				continue
+			}
+			if err := os.Symlink(target, repo.Link); err != nil {
+				ui.Warn("failed to create link", "repo", repo.Name, "path", repo.Link, "error", err)
+				continue
+			}
+			ui.Info("linked", "repo", repo.Name, "path", repo.Link, "target", target)
+		}
+	}
+}
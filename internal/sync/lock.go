@@ -0,0 +1,241 @@
+package sync
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/arch-err/autogitter/internal/config"
+	"github.com/arch-err/autogitter/internal/connector"
+	"github.com/arch-err/autogitter/internal/errs"
+	"github.com/arch-err/autogitter/internal/git"
+	"github.com/arch-err/autogitter/internal/ui"
+)
+
+// LockFile is the default name of the lock file, kept next to the config
+const LockFile = "autogitter.lock"
+
+// LockOptions contains options for the lock command
+type LockOptions struct {
+	Path string
+}
+
+// LockResult contains the results of a lock operation
+type LockResult struct {
+	Locked int
+	Failed int
+}
+
+// CheckoutLockedOptions contains options for checking out locked commits
+type CheckoutLockedOptions struct {
+	Path   string
+	DryRun bool
+}
+
+// CheckoutLockedResult contains the results of checking out locked commits
+type CheckoutLockedResult struct {
+	CheckedOut int
+	Unchanged  int
+	Missing    int
+	Failed     int
+}
+
+// Lock records the commit every managed repo was at
+type Lock struct {
+	Repos map[string]*LockEntry `json:"repos"` // keyed by "<source>/<repo dir>"
+}
+
+// LockEntry is the locked state of a single repo
+type LockEntry struct {
+	Source string `json:"source"`
+	Name   string `json:"name"`             // directory under the source's local_path
+	Repo   string `json:"repo,omitempty"`   // "owner/repo" or clone URL
+	Branch string `json:"branch,omitempty"` // branch checked out when locked, for reference
+	Commit string `json:"commit"`
+}
+
+// DefaultLockPath returns the lock file next to the config, or in the default
+// config directory when the config is remote
+func DefaultLockPath(configPath string) string {
+	if configPath == "" || config.IsRemote(configPath) {
+		configPath = config.DefaultConfigPath()
+	}
+	return filepath.Join(filepath.Dir(configPath), LockFile)
+}
+
+// RunLock writes the HEAD commit of every local repo of every source to the
+// lock file, replacing its previous content
+func RunLock(cfg *config.Config, opts LockOptions) (*LockResult, error) {
+	result := &LockResult{}
+	lock := &Lock{Repos: make(map[string]*LockEntry)}
+
+	for i := range cfg.Sources {
+		source := &cfg.Sources[i]
+		for _, repo := range listLocalRepos(source) {
+			key := source.Name + "/" + repo.name
+			sha, err := git.HeadCommit(repo.path)
+			if err != nil {
+				ui.Error("failed to lock repo", "repo", key, "error", err)
+				result.Failed++
+				continue
+			}
+
+			entry := &LockEntry{Source: source.Name, Name: repo.name, Repo: repo.fullName, Commit: sha}
+			if branch, err := git.GetCurrentBranch(repo.path); err == nil && branch != "HEAD" {
+				entry.Branch = branch
+			}
+			lock.Repos[key] = entry
+			ui.Debug("locked", "repo", key, "commit", sha)
+			result.Locked++
+		}
+	}
+
+	if err := saveLock(opts.Path, lock); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// RunCheckoutLocked checks out the locked commit in every local repo listed
+// in the lock file, fetching when the commit isn't there yet. Repos with
+// uncommitted changes are skipped.
+func RunCheckoutLocked(cfg *config.Config, opts CheckoutLockedOptions) (*CheckoutLockedResult, error) {
+	result := &CheckoutLockedResult{}
+
+	lock, err := loadLock(opts.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	credPath := connector.DefaultCredentialsPath()
+	if err := connector.LoadCredentialsEnv(credPath); err != nil {
+		ui.Debug("failed to load credentials file", "error", err)
+	}
+
+	locked := make(map[string]bool, len(lock.Repos))
+	for i := range cfg.Sources {
+		source := &cfg.Sources[i]
+		for _, repo := range listLocalRepos(source) {
+			key := source.Name + "/" + repo.name
+			entry := lock.Repos[key]
+			if entry == nil {
+				ui.Warn("repo is not in the lock file", "repo", key)
+				continue
+			}
+			locked[key] = true
+
+			changed, err := checkoutLocked(source, repo, entry.Commit, opts.DryRun)
+			switch {
+			case err != nil:
+				ui.Error("failed to check out locked commit", "repo", key, "error", err)
+				result.Failed++
+			case changed:
+				result.CheckedOut++
+			default:
+				result.Unchanged++
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(lock.Repos))
+	for key := range lock.Repos {
+		if !locked[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		ui.Warn("locked repo is not cloned (run ag sync)", "repo", key)
+		result.Missing++
+	}
+
+	return result, nil
+}
+
+// checkoutLocked moves a clone to the locked commit. Returns whether HEAD
+// was (or, in a dry run, would be) moved.
+func checkoutLocked(source *config.Source, repo localRepo, sha string, dryRun bool) (bool, error) {
+	head, err := git.HeadCommit(repo.path)
+	if err == nil && head == sha {
+		return false, nil
+	}
+
+	if dirty, err := git.IsDirty(repo.path); err != nil {
+		return false, err
+	} else if dirty {
+		return false, &errs.DirtyWorktreeError{Err: errors.New("not checking out over them")}
+	}
+
+	if dryRun {
+		ui.Info("would check out", "repo", repo.name, "commit", shortSHA(sha))
+		return true, nil
+	}
+
+	if !git.HasObject(repo.path, sha) {
+		err := git.Fetch(git.FetchOptions{
+			Path:             repo.path,
+			Remote:           "origin",
+			PrivateKey:       source.PrivateKeyFor(repo.path),
+			CredentialHelper: source.CredHelper,
+			KnownHostsFile:   remoteKnownHosts(source, repo.path, "origin"),
+		})
+		if err != nil {
+			return false, err
+		}
+		if !git.HasObject(repo.path, sha) {
+			return false, fmt.Errorf("commit %s not found on origin", shortSHA(sha))
+		}
+	}
+
+	if err := git.CheckoutCommit(repo.path, sha); err != nil {
+		return false, err
+	}
+	ui.Info("checked out", "repo", repo.name, "commit", shortSHA(sha))
+	return true, nil
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 12 {
+		return sha[:12]
+	}
+	return sha
+}
+
+func loadLock(path string) (*Lock, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("lock file not found: %s (create it with ag lock)", path)
+		}
+		return nil, fmt.Errorf("failed to read lock file: %w", err)
+	}
+
+	lock := &Lock{}
+	if err := json.Unmarshal(data, lock); err != nil {
+		return nil, fmt.Errorf("failed to parse lock file: %w", err)
+	}
+	if lock.Repos == nil {
+		lock.Repos = make(map[string]*LockEntry)
+	}
+	return lock, nil
+}
+
+// saveLock writes the lock file atomically, like saveManifest
+func saveLock(path string, lock *Lock) error {
+	data, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode lock file: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write lock file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write lock file: %w", err)
+	}
+	return nil
+}
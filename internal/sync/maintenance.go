@@ -0,0 +1,142 @@
+package sync
+
+import (
+	"io/fs"
+	"path/filepath"
+	gosync "sync"
+	"time"
+
+	"github.com/arch-err/autogitter/internal/config"
+	"github.com/arch-err/autogitter/internal/git"
+	"github.com/arch-err/autogitter/internal/ui"
+)
+
+// MaintenanceOptions contains options for the maintenance command
+type MaintenanceOptions struct {
+	Jobs     int
+	Auto     bool // run "git gc --auto" instead of "git maintenance run"
+	Register bool // register repos with "git maintenance start"
+}
+
+// MaintenanceResult contains the results of a maintenance operation
+type MaintenanceResult struct {
+	Maintained int
+	Failed     int
+	Registered int
+	Reclaimed  int64 // bytes freed across all .git directories
+}
+
+type maintenanceResult struct {
+	name      string
+	reclaimed int64
+	err       error
+	duration  time.Duration
+}
+
+// RunMaintenance compacts every local repo of every source in parallel
+func RunMaintenance(cfg *config.Config, opts MaintenanceOptions) (*MaintenanceResult, error) {
+	result := &MaintenanceResult{}
+
+	var repos []localRepo
+	for i := range cfg.Sources {
+		repos = append(repos, listLocalRepos(&cfg.Sources[i])...)
+	}
+
+	if len(repos) == 0 {
+		ui.Info("no repos to maintain")
+		return result, nil
+	}
+
+	numWorkers := opts.Jobs
+	if numWorkers <= 0 {
+		numWorkers = 4
+	}
+	if numWorkers > len(repos) {
+		numWorkers = len(repos)
+	}
+
+	jobsChan := make(chan localRepo, len(repos))
+	results := make(chan maintenanceResult, len(repos))
+
+	progress := ui.NewProgress(len(repos), "Maintaining repos")
+
+	var wg gosync.WaitGroup
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for repo := range jobsChan {
+				start := time.Now()
+				gitDir := filepath.Join(repo.path, ".git")
+				before := dirSize(gitDir)
+				err := git.Maintain(repo.path, opts.Auto)
+				// Packing tiny repos can grow them slightly; don't report that as negative savings
+				reclaimed := max(before-dirSize(gitDir), 0)
+				results <- maintenanceResult{
+					name:      repo.name,
+					reclaimed: reclaimed,
+					err:       err,
+					duration:  time.Since(start),
+				}
+			}
+		}()
+	}
+
+	for _, repo := range repos {
+		jobsChan <- repo
+	}
+	close(jobsChan)
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var failures []maintenanceResult
+	for res := range results {
+		progress.Increment(res.name, res.err, res.duration)
+		if res.err != nil {
+			failures = append(failures, res)
+			continue
+		}
+		result.Maintained++
+		result.Reclaimed += res.reclaimed
+	}
+
+	progress.Finish()
+
+	for _, res := range failures {
+		ui.Error("failed to maintain", "repo", res.name, "error", res.err)
+	}
+	result.Failed = len(failures)
+
+	// Registration edits the global git config, so it must not run concurrently
+	if opts.Register {
+		for _, repo := range repos {
+			if err := git.StartMaintenance(repo.path); err != nil {
+				ui.Error("failed to register for maintenance", "repo", repo.name, "error", err)
+				continue
+			}
+			result.Registered++
+		}
+	}
+
+	return result, nil
+}
+
+// dirSize returns the total size of all regular files under path
+func dirSize(path string) int64 {
+	var size int64
+	_ = filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
@@ -0,0 +1,142 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/arch-err/autogitter/internal/config"
+	"github.com/arch-err/autogitter/internal/connector"
+	"github.com/arch-err/autogitter/internal/git"
+	"github.com/arch-err/autogitter/internal/ui"
+	"gopkg.in/yaml.v3"
+)
+
+// MetaFile is written inside each clone of a source with metadata set. It's
+// excluded through the clone's info/exclude, so it doesn't make it dirty.
+const MetaFile = ".ag-meta.yaml"
+
+// repoMeta is the content of a MetaFile
+type repoMeta struct {
+	Repo          string    `yaml:"repo"`
+	Description   string    `yaml:"description"`
+	Topics        []string  `yaml:"topics"`
+	DefaultBranch string    `yaml:"default_branch"`
+	Visibility    string    `yaml:"visibility"`
+	Archived      bool      `yaml:"archived"`
+	PushedAt      time.Time `yaml:"pushed_at"`
+	FetchedAt     time.Time `yaml:"fetched_at"`
+}
+
+// MetaResult contains the results of a metadata refresh
+type MetaResult struct {
+	Written int
+	Failed  int
+}
+
+// newMetadataResolver returns the source's connector when it can describe
+// repos, or nil
+func newMetadataResolver(source *config.Source) connector.MetadataResolver {
+	resolver, _ := newSourceConnector(source).(connector.MetadataResolver)
+	return resolver
+}
+
+// writeRepoMeta looks up a repo on the provider and writes its MetaFile
+// into the clone at path
+func writeRepoMeta(resolver connector.MetadataResolver, fullName, path string) error {
+	meta, err := resolver.RepoMetadata(context.Background(), fullName)
+	if err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(repoMeta{
+		Repo:          fullName,
+		Description:   meta.Description,
+		Topics:        meta.Topics,
+		DefaultBranch: meta.DefaultBranch,
+		Visibility:    meta.Visibility,
+		Archived:      meta.Archived,
+		PushedAt:      meta.PushedAt.UTC(),
+		FetchedAt:     time.Now().UTC().Truncate(time.Second),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := git.ExcludeLocally(path, "/"+MetaFile); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(path, MetaFile), data, 0644)
+}
+
+// writeCloneMeta writes the MetaFile of a new clone, warning when the
+// provider can't be asked
+func writeCloneMeta(source *config.Source, fullName, path string) {
+	if config.IsCloneURL(fullName) {
+		fullName = fullNameFromURL(fullName)
+	}
+	resolver := newMetadataResolver(source)
+	if resolver == nil {
+		ui.Debug("provider can't describe repos, no metadata written", "source", source.Name)
+		return
+	}
+	if err := writeRepoMeta(resolver, fullName, path); err != nil {
+		ui.Warn("failed to write repo metadata", "repo", fullName, "error", err)
+	}
+}
+
+// RunMetaRefresh rewrites the MetaFile of every local repo of the sources
+// with metadata set
+func RunMetaRefresh(cfg *config.Config) (*MetaResult, error) {
+	result := &MetaResult{}
+
+	credPath := connector.DefaultCredentialsPath()
+	if err := connector.LoadCredentialsEnv(credPath); err != nil {
+		ui.Debug("failed to load credentials file", "error", err)
+	}
+
+	for i := range cfg.Sources {
+		source := &cfg.Sources[i]
+		if !source.Metadata {
+			continue
+		}
+		resolver := newMetadataResolver(source)
+		if resolver == nil {
+			ui.Warn("skipping source, its provider can't describe repos or there's no token", "source", source.Name)
+			continue
+		}
+
+		var repos []localRepo
+		for _, repo := range listLocalRepos(source) {
+			if source.IncludeWikis && strings.HasSuffix(repo.path, wikiSuffix) {
+				continue
+			}
+			repos = append(repos, repo)
+		}
+
+		progress := ui.NewProgress(len(repos), fmt.Sprintf("Refreshing metadata of %s", source.Name))
+		for _, repo := range repos {
+			start := time.Now()
+			fullName := repo.fullName
+			if remote, err := git.GetRemoteURL(repo.path); err == nil {
+				if name := fullNameFromURL(remote); name != "" {
+					fullName = name
+				}
+			}
+
+			err := writeRepoMeta(resolver, fullName, repo.path)
+			if err != nil {
+				result.Failed++
+			} else {
+				result.Written++
+			}
+			progress.Increment(repo.name, err, time.Since(start))
+		}
+		progress.Finish()
+	}
+
+	return result, nil
+}
@@ -0,0 +1,142 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/arch-err/autogitter/internal/config"
+	"github.com/arch-err/autogitter/internal/git"
+	"github.com/arch-err/autogitter/internal/ui"
+)
+
+// MoveOptions contains options for the move command
+type MoveOptions struct {
+	Repo       string // repo name or "user/repo"
+	ToSource   string // name of the target source
+	ConfigPath string
+	DryRun     bool
+}
+
+// RunMove relocates a local clone into another source: the directory is moved
+// to the target's local_path, origin is pointed at the target host/owner, and
+// the config is updated for manual-strategy sources.
+func RunMove(cfg *config.Config, opts MoveOptions) error {
+	target := cfg.FindSource(opts.ToSource)
+	if target == nil {
+		return fmt.Errorf("target source not found: %s", opts.ToSource)
+	}
+
+	from, repo, err := findLocalRepo(cfg, opts.Repo)
+	if err != nil {
+		return err
+	}
+	if from == target {
+		return fmt.Errorf("repo %s already belongs to source %s", opts.Repo, target.Name)
+	}
+
+	baseName := repoNameFromFullName(repo.name)
+	newFullName := baseName
+	if owner := target.GetUserOrOrg(); owner != "" {
+		newFullName = owner + "/" + baseName
+	}
+
+	newPath := filepath.Join(target.LocalPath, target.RepoDir(newFullName))
+	if _, err := os.Stat(newPath); err == nil {
+		return fmt.Errorf("target path already exists: %s", newPath)
+	}
+	newURL := target.GetRepoURL(newFullName)
+	oldURL, _ := git.GetRemoteURL(repo.path)
+
+	if opts.DryRun {
+		ui.Info("would move", "repo", repo.name, "from", repo.path, "to", newPath)
+		if oldURL != newURL {
+			ui.Info("would set origin", "url", newURL)
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := os.Rename(repo.path, newPath); err != nil {
+		return fmt.Errorf("failed to move repo: %w", err)
+	}
+	ui.Info("moved", "repo", repo.name, "from", repo.path, "to", newPath)
+
+	if oldURL != newURL {
+		if err := git.SetRemoteURL(newPath, newURL); err != nil {
+			return err
+		}
+		ui.Info("updated origin", "from", oldURL, "to", newURL)
+	}
+
+	// Only manual sources keep an explicit repo list in the config
+	changed := false
+	alias := ""
+	if from.Strategy == config.StrategyManual {
+		for i, entry := range from.Repos {
+			if entry.Name == repo.fullName {
+				alias = entry.Alias
+				from.Repos = append(from.Repos[:i], from.Repos[i+1:]...)
+				changed = true
+				break
+			}
+		}
+		if len(from.Repos) == 0 {
+			ui.Warn("source has no repos left, add one or remove the source", "source", from.Name)
+		}
+	}
+	if target.Strategy == config.StrategyManual {
+		target.Repos = append(target.Repos, config.RepoEntry{Name: newFullName, Alias: alias})
+		changed = true
+	}
+
+	if changed && opts.ConfigPath != "" {
+		if err := SaveConfig(cfg, opts.ConfigPath); err != nil {
+			return fmt.Errorf("failed to save config: %w", err)
+		}
+		ui.Info("config saved", "path", opts.ConfigPath)
+	}
+
+	return nil
+}
+
+// findLocalRepo locates a repo on disk by alias, "user/repo", or bare repo
+// name
+func findLocalRepo(cfg *config.Config, name string) (*config.Source, localRepo, error) {
+	if source, entry := cfg.FindAlias(name); entry != nil {
+		path := filepath.Clean(source.RepoPath(*entry))
+		for _, repo := range listLocalRepos(source) {
+			if filepath.Clean(repo.path) == path {
+				repo.fullName = entry.Name
+				return source, repo, nil
+			}
+		}
+		return nil, localRepo{}, fmt.Errorf("repo not found locally: %s (alias %s)", entry.Name, name)
+	}
+
+	base := repoNameFromFullName(name)
+
+	for i := range cfg.Sources {
+		source := &cfg.Sources[i]
+		for _, repo := range listLocalRepos(source) {
+			if repoNameFromFullName(repo.name) != base {
+				continue
+			}
+			// Prefer the configured full name so the entry can be removed later
+			for _, entry := range source.Repos {
+				if repoNameFromFullName(entry.Name) == base {
+					repo.fullName = entry.Name
+					break
+				}
+			}
+			if name != base && repo.fullName != name && repo.fullName != base {
+				continue
+			}
+			return source, repo, nil
+		}
+	}
+
+	return nil, localRepo{}, fmt.Errorf("repo not found locally: %s", name)
+}
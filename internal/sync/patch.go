@@ -0,0 +1,33 @@
+package sync
+
+import (
+	"github.com/arch-err/autogitter/internal/config"
+	"github.com/arch-err/autogitter/internal/ui"
+)
+
+// PatchFromStatus turns a source's status into the config changes that would
+// bring the config in line with reality: orphaned clones and repos new
+// upstream are added, and entries gone upstream are removed.
+// Returns nil for sources without an editable repo list.
+func PatchFromStatus(source *config.Source, statuses []RepoStatus) *config.SourcePatch {
+	if source.Strategy != config.StrategyManual {
+		return nil
+	}
+
+	patch := &config.SourcePatch{Source: source.Name}
+	for _, s := range statuses {
+		switch {
+		case s.Status == ui.StatusRemoved && !s.GoneUpstream:
+			patch.Add = append(patch.Add, orphanFullName(source, s))
+		case s.Status == ui.StatusUpstream:
+			patch.Add = append(patch.Add, s.FullName)
+		case s.InConfig && s.GoneUpstream:
+			patch.Remove = append(patch.Remove, s.FullName)
+		}
+	}
+
+	if len(patch.Add) == 0 && len(patch.Remove) == 0 {
+		return nil
+	}
+	return patch
+}
@@ -0,0 +1,40 @@
+package sync
+
+import "slices"
+
+// prioritized is a clone or pull job
+type prioritized interface {
+	priority() int
+}
+
+func (j cloneJob) priority() int {
+	if j.pull != nil {
+		return j.pull.priority()
+	}
+	return j.source.PriorityFor(j.status.LocalPath)
+}
+
+func (j pullJob) priority() int {
+	return j.source.PriorityFor(j.path)
+}
+
+// rankedJob is a job with its priority, looked up once for sorting
+type rankedJob[T prioritized] struct {
+	job      T
+	priority int
+}
+
+// sortByPriority orders a job queue so higher priority jobs start first,
+// keeping the order of jobs with the same priority
+func sortByPriority[T prioritized](jobs []T) {
+	ranked := make([]rankedJob[T], len(jobs))
+	for i, job := range jobs {
+		ranked[i] = rankedJob[T]{job: job, priority: job.priority()}
+	}
+	slices.SortStableFunc(ranked, func(a, b rankedJob[T]) int {
+		return b.priority - a.priority
+	})
+	for i, r := range ranked {
+		jobs[i] = r.job
+	}
+}
@@ -0,0 +1,123 @@
+package sync
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/arch-err/autogitter/internal/config"
+	"github.com/arch-err/autogitter/internal/ui"
+)
+
+// PruneOptions contains options for the prune command
+type PruneOptions struct {
+	Source      string // only prune this source, every source when empty
+	ConfigPath  string
+	DryRun      bool
+	Force       bool // skip confirmation prompts
+	Interactive bool // confirm each prune separately
+}
+
+// RunPrune moves the local repos that no source's repo list includes to the
+// trash, with the same confirmations as sync --prune but without cloning
+// anything. Clones of repos that were renamed upstream are renamed in place
+// first, as sync does. Sources whose repo list can't be read are skipped rather than
+// having every clone treated as orphaned. Returns the number of repos pruned.
+func RunPrune(cfg *config.Config, opts PruneOptions) (int, error) {
+	sources := make([]*config.Source, 0, len(cfg.Sources))
+	if opts.Source != "" {
+		source := cfg.FindSource(opts.Source)
+		if source == nil {
+			return 0, fmt.Errorf("source not found: %s", opts.Source)
+		}
+		sources = append(sources, source)
+	} else {
+		for i := range cfg.Sources {
+			sources = append(sources, &cfg.Sources[i])
+		}
+	}
+
+	if !opts.DryRun && !opts.Force && !ui.CanPrompt() {
+		return 0, fmt.Errorf("prune asks for confirmation, run it in a terminal or pass --force")
+	}
+
+	pruned := 0
+	if !opts.DryRun {
+		history := startHistory("prune", opts.ConfigPath)
+		defer func() { history.finishPrune(pruned) }()
+	}
+
+	for _, source := range sources {
+		statuses, err := ComputeSourceStatus(source, StatusOptions{})
+		if err != nil {
+			ui.Warn("skipping source", "source", source.Name, "error", err)
+			continue
+		}
+
+		// Clones of repos renamed upstream are renamed, not trashed, as in sync --prune
+		statuses, renamed := detectRenames(source, statuses, SyncOptions{DryRun: opts.DryRun, Force: opts.Force})
+		if renamed && opts.ConfigPath != "" {
+			if err := SaveConfig(cfg, opts.ConfigPath); err != nil {
+				ui.Error("failed to save config", "error", err)
+			} else {
+				ui.Info("config saved", "path", opts.ConfigPath)
+			}
+		}
+
+		orphaned := getOrphanedRepos(statuses)
+		if len(orphaned) == 0 {
+			ui.Info("no orphaned repos", "source", source.Name)
+			continue
+		}
+		sort.Slice(orphaned, func(i, j int) bool { return orphaned[i].Name < orphaned[j].Name })
+
+		if opts.DryRun {
+			for _, repo := range orphaned {
+				d := describePrune(repo)
+				ui.Info("would prune", "source", source.Name, "repo", repo.Name, "size", ui.FormatBytes(d.Size), "last_commit", d.LastCommitText(), "dirty", d.Dirty)
+			}
+			continue
+		}
+
+		if opts.Force {
+			for _, repo := range orphaned {
+				if pruneRepo(source, repo) {
+					pruned++
+				}
+			}
+			continue
+		}
+
+		details := describePrunes(orphaned)
+		if opts.Interactive {
+			pruned += pruneInteractive(source, orphaned, details)
+			continue
+		}
+
+		_, orphaned, err = selectPending(nil, orphaned, details)
+		if err != nil {
+			return pruned, fmt.Errorf("failed to get user input: %w", err)
+		}
+		if len(orphaned) == 0 {
+			continue
+		}
+		repos := make([]ui.PruneDetails, len(orphaned))
+		for i, r := range orphaned {
+			repos[i] = details[r.LocalPath]
+		}
+		confirm, err := ui.ConfirmPrune(repos)
+		if err != nil {
+			return pruned, fmt.Errorf("failed to get confirmation: %w", err)
+		}
+		if !confirm {
+			ui.Info("prune cancelled", "source", source.Name)
+			continue
+		}
+		for _, repo := range orphaned {
+			if pruneRepo(source, repo) {
+				pruned++
+			}
+		}
+	}
+
+	return pruned, nil
+}
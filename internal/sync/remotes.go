@@ -0,0 +1,125 @@
+package sync
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/arch-err/autogitter/internal/config"
+	"github.com/arch-err/autogitter/internal/git"
+	"github.com/arch-err/autogitter/internal/ui"
+)
+
+// checkRemote compares a local repo's origin URL with the URL the config
+// would clone it from. Returns the expected and actual URLs and whether they differ.
+func checkRemote(source *config.Source, status RepoStatus) (string, string, bool) {
+	if !status.InConfig || !status.ExistsLocal || !git.IsGitRepo(status.LocalPath) {
+		return "", "", false
+	}
+
+	expected := source.GetRepoURL(status.FullName)
+	actual, err := git.GetRemoteURL(status.LocalPath)
+	if err != nil {
+		ui.Debug("failed to get remote URL", "repo", status.Name, "error", err)
+		return expected, "", false
+	}
+
+	return expected, actual, !sameRemote(expected, actual)
+}
+
+// checkRemotes reports repos whose origin doesn't match the config and,
+// when FixRemotes is set, points origin at the configured URL
+func checkRemotes(source *config.Source, statuses []RepoStatus, opts SyncOptions) {
+	for _, status := range statuses {
+		expected, actual, mismatch := checkRemote(source, status)
+		if !mismatch {
+			continue
+		}
+
+		switch {
+		case !opts.FixRemotes:
+			ui.Warn("origin does not match config (use --fix-remotes)", "repo", status.FullName, "origin", actual, "expected", expected)
+		case opts.DryRun:
+			ui.Info("would fix remote", "repo", status.FullName, "from", actual, "to", expected)
+		default:
+			if err := git.SetRemoteURL(status.LocalPath, expected); err != nil {
+				ui.Error("failed to fix remote", "repo", status.FullName, "error", err)
+				continue
+			}
+			ui.Info("fixed remote", "repo", status.FullName, "from", actual, "to", expected)
+		}
+	}
+}
+
+// checkUpstreams adds the "upstream" remote to clones whose config entry sets
+// upstream, and reports or, with FixRemotes, fixes ones pointing elsewhere
+func checkUpstreams(source *config.Source, statuses []RepoStatus, opts SyncOptions) {
+	for _, status := range statuses {
+		if !status.InConfig || !status.ExistsLocal || !git.IsGitRepo(status.LocalPath) {
+			continue
+		}
+		expected := source.UpstreamFor(status.LocalPath)
+		if expected == "" {
+			continue
+		}
+
+		actual, err := git.RemoteURL(status.LocalPath, "upstream")
+		if err == nil && sameRemote(expected, actual) {
+			continue
+		}
+
+		switch {
+		case err == nil && !opts.FixRemotes:
+			ui.Warn("upstream does not match config (use --fix-remotes)", "repo", status.FullName, "upstream", actual, "expected", expected)
+		case opts.DryRun:
+			ui.Info("would set upstream remote", "repo", status.FullName, "upstream", expected)
+		default:
+			if err := git.SetRemote(status.LocalPath, "upstream", expected); err != nil {
+				ui.Error("failed to set upstream remote", "repo", status.FullName, "error", err)
+				continue
+			}
+			ui.Info("set upstream remote", "repo", status.FullName, "upstream", expected)
+		}
+	}
+}
+
+// sameRemote compares two remote URLs, ignoring case, a trailing slash, and a .git suffix
+func sameRemote(a, b string) bool {
+	normalize := func(url string) string {
+		url = strings.TrimSuffix(url, "/")
+		url = strings.TrimSuffix(url, ".git")
+		return strings.ToLower(url)
+	}
+	return normalize(a) == normalize(b)
+}
+
+// remoteKey reduces a clone URL to "host/owner/repo" so SSH, scp-like, and
+// HTTPS URLs of the same repo compare equal
+func remoteKey(remote string) string {
+	host := remoteHost(remote)
+	path := fullNameFromURL(remote)
+	if host == "" || path == "" {
+		return ""
+	}
+	return strings.ToLower(host + "/" + path)
+}
+
+// remoteHost returns the host of an SSH, scp-like, or HTTPS clone URL,
+// without user or port
+func remoteHost(remote string) string {
+	if strings.Contains(remote, "://") {
+		u, err := url.Parse(remote)
+		if err != nil {
+			return ""
+		}
+		return u.Hostname()
+	}
+	idx := strings.Index(remote, ":")
+	if idx == -1 {
+		return ""
+	}
+	host := remote[:idx]
+	if at := strings.LastIndex(host, "@"); at != -1 {
+		host = host[at+1:]
+	}
+	return host
+}
@@ -0,0 +1,221 @@
+package sync
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/arch-err/autogitter/internal/config"
+	"github.com/arch-err/autogitter/internal/connector"
+	"github.com/arch-err/autogitter/internal/git"
+	"github.com/arch-err/autogitter/internal/ui"
+)
+
+// detectRenames finds local repos that were renamed or transferred upstream and
+// offers to rename them in place, instead of cloning a duplicate under the new
+// name and flagging the old clone as orphaned.
+//
+// Orphaned repos are always checked when the source also has repos to clone.
+// With CheckRenames, every configured repo that exists locally is checked too.
+// Returns the updated statuses and whether the config was changed.
+func detectRenames(source *config.Source, statuses []RepoStatus, opts SyncOptions) ([]RepoStatus, bool) {
+	var candidates []int
+	added := make(map[string]int)
+	for i, s := range statuses {
+		switch {
+		case s.Status == ui.StatusAdded:
+			added[strings.ToLower(s.FullName)] = i
+		case s.Status == ui.StatusRemoved:
+			candidates = append(candidates, i)
+		case opts.CheckRenames && s.InConfig && s.ExistsLocal:
+			candidates = append(candidates, i)
+		}
+	}
+
+	checkOrphans := len(added) > 0
+	if len(candidates) == 0 || (!checkOrphans && !opts.CheckRenames) {
+		return statuses, false
+	}
+
+	resolver := newRepoResolver(source)
+	if resolver == nil {
+		return statuses, false
+	}
+
+	ctx := context.Background()
+	configChanged := false
+	drop := make(map[int]bool)
+
+	for _, i := range candidates {
+		status := &statuses[i]
+		if status.Status == ui.StatusRemoved && !checkOrphans {
+			continue
+		}
+
+		oldName := status.FullName
+		if oldName == "" {
+			oldName = localFullName(source, *status)
+		}
+		if config.IsCloneURL(oldName) {
+			continue // outliers from other hosts aren't the provider's to resolve
+		}
+
+		newName, err := resolver.ResolveRepo(ctx, oldName)
+		if err != nil {
+			ui.Debug("failed to resolve repo", "repo", oldName, "error", err)
+			continue
+		}
+		if strings.EqualFold(newName, oldName) {
+			continue
+		}
+
+		// An orphan only counts as renamed if its new name is about to be cloned
+		addedIdx, isPending := added[strings.ToLower(newName)]
+		if status.Status == ui.StatusRemoved && !isPending {
+			continue
+		}
+
+		newPath := status.LocalPath
+		if !status.InConfig || !hasCustomPath(source, oldName) {
+			newPath = filepath.Join(source.LocalPath, source.RepoDir(newName))
+		}
+
+		if opts.DryRun {
+			ui.Info("would rename", "from", oldName, "to", newName, "path", newPath)
+			continue
+		}
+		if opts.NonInteractive {
+			ui.Warn("repo was renamed upstream", "from", oldName, "to", newName)
+			continue
+		}
+		if !opts.Force {
+			confirm, err := ui.ConfirmRename(oldName, newName)
+			if err != nil || !confirm {
+				continue
+			}
+		}
+
+		if newPath != status.LocalPath {
+			if _, err := os.Stat(newPath); err == nil {
+				ui.Warn("cannot rename, path already exists", "repo", oldName, "path", newPath)
+				continue
+			}
+			if err := os.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+				ui.Error("failed to create directory", "path", filepath.Dir(newPath), "error", err)
+				continue
+			}
+			if err := os.Rename(status.LocalPath, newPath); err != nil {
+				ui.Error("failed to rename repo", "repo", oldName, "error", err)
+				continue
+			}
+		}
+		if err := git.SetRemoteURL(newPath, source.GetRepoURL(newName)); err != nil {
+			ui.Warn("failed to update origin", "repo", newName, "error", err)
+		}
+		ui.Info("renamed", "from", oldName, "to", newName, "path", newPath)
+
+		if source.Strategy == config.StrategyManual && status.InConfig {
+			for j := range source.Repos {
+				if source.Repos[j].Name == oldName {
+					source.Repos[j].Name = newName
+					configChanged = true
+				}
+			}
+		}
+
+		// The renamed clone now satisfies the pending entry, if any
+		if isPending {
+			statuses[addedIdx].Status = ui.StatusUnchanged
+			statuses[addedIdx].ExistsLocal = true
+			statuses[addedIdx].LocalPath = newPath
+			drop[i] = true
+		} else {
+			status.Name = repoNameFromFullName(newName)
+			status.FullName = newName
+			status.LocalPath = newPath
+		}
+	}
+
+	if len(drop) > 0 {
+		kept := statuses[:0]
+		for i, s := range statuses {
+			if !drop[i] {
+				kept = append(kept, s)
+			}
+		}
+		statuses = kept
+	}
+
+	return statuses, configChanged
+}
+
+// newRepoResolver returns a resolver for the source's provider, or nil when
+// the provider can't look up single repos or no token is available
+func newRepoResolver(source *config.Source) connector.RepoResolver {
+	resolver, _ := newSourceConnector(source).(connector.RepoResolver)
+	return resolver
+}
+
+// newSourceConnector returns an API connector for the source, or nil when the
+// source has no provider API or no token is available
+func newSourceConnector(source *config.Source) connector.Connector {
+	if source.Strategy == config.StrategyStatic {
+		return nil
+	}
+
+	token := source.GetToken()
+	if token == "" {
+		ui.Debug("no token, skipping API lookups", "source", source.Name)
+		return nil
+	}
+
+	conn, err := source.NewConnector(token)
+	if err != nil {
+		return nil
+	}
+	return conn
+}
+
+// localFullName derives "owner/repo" for a local clone from its origin URL,
+// falling back to the source's user/org
+func localFullName(source *config.Source, status RepoStatus) string {
+	if remote, err := git.GetRemoteURL(status.LocalPath); err == nil {
+		if name := fullNameFromURL(remote); name != "" {
+			return name
+		}
+	}
+	return guessFullName(source.Source, status.Name)
+}
+
+// fullNameFromURL extracts "owner/repo" from an SSH, scp-like, or HTTPS clone URL
+func fullNameFromURL(remote string) string {
+	var path string
+	if strings.Contains(remote, "://") {
+		u, err := url.Parse(remote)
+		if err != nil {
+			return ""
+		}
+		path = u.Path
+	} else if idx := strings.Index(remote, ":"); idx != -1 {
+		path = remote[idx+1:]
+	} else {
+		return ""
+	}
+
+	path = strings.Trim(strings.TrimSuffix(path, ".git"), "/")
+	if !strings.Contains(path, "/") {
+		return ""
+	}
+	return path
+}
+
+func hasCustomPath(source *config.Source, fullName string) bool {
+	for _, repo := range source.Repos {
+		if repo.Name == fullName {
+			return repo.HasCustomLocalPath()
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package sync
+
+import (
+	"fmt"
+
+	"github.com/arch-err/autogitter/internal/git"
+	"github.com/arch-err/autogitter/internal/journal"
+	"github.com/arch-err/autogitter/internal/ui"
+)
+
+// repairCorrupt replaces a clone whose pull failed on a corrupted repository
+// with a fresh clone, after confirmation unless opts.Repair is set. Returns
+// whether the clone was replaced.
+func repairCorrupt(job pullJob, opts PullOptions) bool {
+	if !opts.Repair {
+		if opts.NonInteractive || opts.Force || !ui.CanPrompt() {
+			ui.Warn("repository is corrupted (run ag pull --repair to re-clone it)", "repo", job.name, "path", job.path)
+			return false
+		}
+		confirm, err := ui.ConfirmRepair(job.name, job.path)
+		if err != nil {
+			ui.Error("failed to get confirmation", "error", err)
+			return false
+		}
+		if !confirm {
+			return false
+		}
+	}
+
+	if err := repairClone(job); err != nil {
+		ui.Error("failed to repair", "repo", job.name, "error", err)
+		return false
+	}
+	return true
+}
+
+// repairClone copies the uncommitted files of a clone to the rescue
+// directory, moves the clone to the trash, and clones it again from its
+// origin. Both moves are journaled, so undo puts the broken clone back.
+func repairClone(job pullJob) error {
+	url, err := git.GetRemoteURL(job.path)
+	if err != nil || url == "" {
+		url = job.source.GetRepoURL(job.fullName)
+	}
+
+	// Status reads the index and HEAD, which may be what's broken; then
+	// there's no telling what changed, so every file is kept
+	files, err := git.ChangedFiles(job.path)
+	if err != nil {
+		files = nil
+	}
+	if err != nil || len(files) > 0 {
+		dir, err := journal.Rescue(job.path, files)
+		if err != nil {
+			return err
+		}
+		ui.Warn("saved uncommitted files of corrupted repo", "repo", job.name, "to", dir)
+	}
+
+	if err := journal.Prune(job.source.Name, job.name, job.path); err != nil {
+		return err
+	}
+	if err := cloneRepo(job.source, url, job.path, job.source.GetBranch(), nil); err != nil {
+		return fmt.Errorf("broken clone is in the trash, but cloning it again failed: %w", err)
+	}
+	journal.RecordClone(job.source.Name, job.name, job.path, url)
+	ui.Info("re-cloned corrupted repo", "repo", job.name, "path", job.path)
+	return nil
+}
@@ -0,0 +1,294 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/arch-err/autogitter/internal/config"
+	"github.com/arch-err/autogitter/internal/connector"
+	"github.com/arch-err/autogitter/internal/errs"
+	"github.com/arch-err/autogitter/internal/git"
+	"github.com/arch-err/autogitter/internal/ui"
+)
+
+// FailedFile records the clones and pulls that failed in the last run, with
+// the full error, for --retry-failed and unattended runs. It's kept next to
+// the credentials.
+const FailedFile = "failed.json"
+
+// stderrTailLines is how much of git's output is kept for each failure
+const stderrTailLines = 20
+
+// failedOps are the failures of the last sync and pull with one config
+type failedOps struct {
+	Clones []failedRepo `json:"clones,omitempty"`
+	Pulls  []failedRepo `json:"pulls,omitempty"`
+}
+
+// failedRepo is a single failed clone or pull
+type failedRepo struct {
+	Source    string    `json:"source"`
+	Repo      string    `json:"repo"` // "owner/repo" or clone URL for clones, display name for pulls
+	Path      string    `json:"path"`
+	Operation string    `json:"operation"` // "clone" or "pull"
+	Error     string    `json:"error"`
+	Kind      string    `json:"kind,omitempty"`      // auth, host_key, not_found, network, dirty, and so on, when known
+	ExitCode  int       `json:"exit_code,omitempty"` // exit code of the failed git command
+	Stderr    string    `json:"stderr,omitempty"`    // last lines of git's output
+	Files     []string  `json:"files,omitempty"`     // conflicting files, for conflicts
+	Stashed   bool      `json:"stashed,omitempty"`   // local changes left in the stash, for conflicts
+	Time      time.Time `json:"time"`
+}
+
+// newFailedRepo splits a git error into its message and the output git
+// printed, which the git package appends after the first line
+func newFailedRepo(source *config.Source, repo, path, operation string, err error) failedRepo {
+	failed := failedRepo{
+		Source:    source.Name,
+		Repo:      repo,
+		Path:      path,
+		Operation: operation,
+		Time:      time.Now().UTC(),
+	}
+	if err == nil {
+		return failed
+	}
+
+	msg, output, _ := strings.Cut(err.Error(), "\n")
+	failed.Error = msg
+	if lines := strings.Split(strings.TrimSpace(output), "\n"); len(lines) > stderrTailLines {
+		output = strings.Join(lines[len(lines)-stderrTailLines:], "\n")
+	}
+	failed.Stderr = strings.TrimSpace(output)
+	failed.Kind = errs.Kind(err)
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		failed.ExitCode = exitErr.ExitCode()
+	}
+	var conflict *errs.ConflictError
+	if errors.As(err, &conflict) {
+		failed.Files, failed.Stashed = conflict.Files, conflict.Stashed
+	}
+	return failed
+}
+
+func failedPath() string {
+	return filepath.Join(filepath.Dir(connector.DefaultCredentialsPath()), FailedFile)
+}
+
+// configKey identifies a config in the failed and state files. Local configs
+// are keyed by absolute path so runs from different directories agree.
+func configKey(configPath string) string {
+	if strings.Contains(configPath, "://") {
+		return configPath
+	}
+	if abs, err := filepath.Abs(configPath); err == nil {
+		return abs
+	}
+	return configPath
+}
+
+// loadFailed reads the failed file, keyed by config. A missing file has no failures.
+func loadFailed() (map[string]*failedOps, error) {
+	all := make(map[string]*failedOps)
+	data, err := os.ReadFile(failedPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return all, nil
+		}
+		return nil, fmt.Errorf("failed to read failed file: %w", err)
+	}
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, fmt.Errorf("failed to parse failed file: %w", err)
+	}
+	return all, nil
+}
+
+// failedFor returns the failures recorded for a config
+func failedFor(configPath string) (*failedOps, error) {
+	all, err := loadFailed()
+	if err != nil {
+		return nil, err
+	}
+	if ops := all[configKey(configPath)]; ops != nil {
+		return ops, nil
+	}
+	return &failedOps{}, nil
+}
+
+// updateFailed applies update to the failures of a config and writes the
+// file. Configs left without failures are dropped.
+func updateFailed(configPath string, update func(*failedOps)) error {
+	all, err := loadFailed()
+	if err != nil {
+		return err
+	}
+
+	key := configKey(configPath)
+	ops := all[key]
+	if ops == nil {
+		ops = &failedOps{}
+	}
+	update(ops)
+	if len(ops.Clones) == 0 && len(ops.Pulls) == 0 {
+		delete(all, key)
+	} else {
+		all[key] = ops
+	}
+
+	if err := writeDataFile(failedPath(), all); err != nil {
+		return fmt.Errorf("failed to write failed file: %w", err)
+	}
+	return nil
+}
+
+// writeDataFile writes v as JSON to a file in the data directory
+// atomically, like saveLock
+func writeDataFile(path string, v any) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// recordFailedClones replaces the recorded clone failures with those of this run
+func recordFailedClones(configPath string, failures []cloneResult) {
+	if configPath == "" {
+		return
+	}
+	err := updateFailed(configPath, func(ops *failedOps) {
+		ops.Clones = nil
+		for _, res := range failures {
+			ops.Clones = append(ops.Clones, newFailedRepo(res.job.source, res.job.status.FullName, res.job.status.LocalPath, "clone", res.err))
+		}
+	})
+	if err != nil {
+		ui.Warn("failed to record failed clones", "error", err)
+	} else if len(failures) > 0 {
+		ui.Info("failure details written", "path", failedPath())
+	}
+}
+
+// recordFailedPulls replaces the recorded pull failures with those of this run
+func recordFailedPulls(configPath string, failures []pullResult) {
+	if configPath == "" {
+		return
+	}
+	err := updateFailed(configPath, func(ops *failedOps) {
+		ops.Pulls = nil
+		for _, res := range failures {
+			ops.Pulls = append(ops.Pulls, newFailedRepo(res.job.source, res.job.name, res.job.path, "pull", res.err))
+		}
+	})
+	if err != nil {
+		ui.Warn("failed to record failed pulls", "error", err)
+	} else if len(failures) > 0 {
+		ui.Info("failure details written", "path", failedPath())
+	}
+}
+
+// retryFailedClones clones only the repos whose clone failed in the last
+// sync, without listing or scanning any source
+func retryFailedClones(ctx context.Context, cfg *config.Config, opts SyncOptions) (*SyncResult, error) {
+	result := &SyncResult{}
+	start := time.Now()
+	defer func() { result.Duration = time.Since(start) }()
+
+	ops, err := failedFor(opts.ConfigPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(ops.Clones) == 0 {
+		ui.Info("no failed clones to retry")
+		return result, nil
+	}
+
+	var jobs []cloneJob
+	for _, failed := range ops.Clones {
+		source := cfg.FindSource(failed.Source)
+		if source == nil {
+			ui.Warn("source no longer in config, not retrying", "source", failed.Source, "repo", failed.Repo)
+			result.Skipped++
+			summaryFor(&result.Sources, failed.Source).Skipped++
+			continue
+		}
+		if git.IsGitRepo(failed.Path) {
+			ui.Debug("already cloned", "repo", failed.Repo, "path", failed.Path)
+			continue
+		}
+		if opts.DryRun {
+			ui.Info("would clone", "repo", failed.Repo, "path", failed.Path)
+			continue
+		}
+		jobs = append(jobs, cloneJob{
+			status: RepoStatus{
+				Name:      filepath.Base(failed.Path),
+				FullName:  failed.Repo,
+				LocalPath: failed.Path,
+				Status:    ui.StatusAdded,
+			},
+			source: source,
+		})
+	}
+
+	if opts.DryRun {
+		return result, nil
+	}
+
+	jobs, failures := verifyHostKeys(jobs)
+
+	if len(jobs) > 0 {
+		cloned, cloneFailures, _ := cloneReposParallel(ctx, jobs, limitBandwidth(cfg, opts.Jobs, len(jobs)), cfg.GetJobStagger())
+		result.Cloned = len(cloned)
+		result.tallyClones(cloned)
+		result.tallyClones(cloneFailures)
+		failures = append(failures, cloneFailures...)
+	}
+	result.failClones(failures)
+	recordFailedClones(opts.ConfigPath, failures)
+
+	if err := UpdateSymlinks(cfg); err != nil {
+		ui.Warn("failed to update symlinks", "error", err)
+	}
+
+	return result, nil
+}
+
+// onlyFailedPulls keeps the jobs whose pull failed in the last run
+func onlyFailedPulls(configPath string, jobs []pullJob) []pullJob {
+	ops, err := failedFor(configPath)
+	if err != nil {
+		ui.Warn("can't read failed pulls", "error", err)
+		return nil
+	}
+
+	failed := make(map[string]bool)
+	for _, f := range ops.Pulls {
+		failed[f.Path] = true
+	}
+
+	var kept []pullJob
+	for _, job := range jobs {
+		if failed[job.path] {
+			kept = append(kept, job)
+		}
+	}
+	ui.Info("retrying failed pulls", "count", len(kept))
+	return kept
+}
@@ -0,0 +1,31 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/arch-err/autogitter/internal/config"
+	"github.com/arch-err/autogitter/internal/ui"
+)
+
+// SaveConfig saves an automatic edit of the config. If the file was changed
+// on disk since it was loaded, asks whether to reload it and apply the edit
+// on top; without a terminal the save fails instead of clobbering the change.
+func SaveConfig(cfg *config.Config, path string) error {
+	err := cfg.Save(path)
+	if !errors.Is(err, config.ErrModified) {
+		return err
+	}
+
+	if !ui.IsTTY() {
+		return fmt.Errorf("%w, run again to pick up the changes", err)
+	}
+	reload, promptErr := ui.ConfirmReloadConfig(path)
+	if promptErr != nil {
+		return fmt.Errorf("prompt failed: %w", promptErr)
+	}
+	if !reload {
+		return err
+	}
+	return cfg.Merge(path)
+}
@@ -0,0 +1,56 @@
+package sync
+
+import (
+	"context"
+
+	"github.com/arch-err/autogitter/internal/config"
+	"github.com/arch-err/autogitter/internal/connector"
+	"github.com/arch-err/autogitter/internal/ui"
+)
+
+// sizeChecker looks up repo sizes for a source with max_repo_size set
+type sizeChecker struct {
+	sizer connector.RepoSizer
+	limit int64
+}
+
+// newSizeChecker returns nil when the source has no size limit, the limit is
+// overridden, or the provider can't report sizes
+func newSizeChecker(source *config.Source, includeLarge bool) *sizeChecker {
+	limit := source.GetMaxRepoSize()
+	if limit == 0 || includeLarge {
+		return nil
+	}
+
+	conn := newSourceConnector(source)
+	if conn == nil {
+		ui.Warn("can't check repo sizes without API access, max_repo_size ignored", "source", source.Name)
+		return nil
+	}
+	sizer, ok := conn.(connector.RepoSizer)
+	if !ok {
+		ui.Warn("provider doesn't report repo sizes, max_repo_size ignored", "source", source.Name, "connector", conn.Name())
+		return nil
+	}
+	return &sizeChecker{sizer: sizer, limit: limit}
+}
+
+// tooLarge reports whether the repo exceeds the limit, warning if so. Repos
+// whose size can't be looked up, like clone URL entries, are let through.
+func (c *sizeChecker) tooLarge(fullName string) bool {
+	if c == nil || config.IsCloneURL(fullName) {
+		return false
+	}
+
+	size, err := c.sizer.RepoSize(context.Background(), fullName)
+	if err != nil {
+		ui.Debug("failed to get repo size", "repo", fullName, "error", err)
+		return false
+	}
+	if size <= c.limit {
+		return false
+	}
+
+	ui.Warn("skipping large repo, use --include-large to clone it", "repo", fullName, "size", ui.FormatBytes(size), "max", ui.FormatBytes(c.limit))
+	return true
+}
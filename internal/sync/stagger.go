@@ -0,0 +1,19 @@
+package sync
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// staggerJobs feeds jobs to a worker pool, waiting at least interval between
+// jobs plus up to half that again of random jitter, so a server doesn't see
+// every worker connect in the same instant. Closes the channel when done.
+func staggerJobs[T any](jobs []T, ch chan<- T, interval time.Duration) {
+	defer close(ch)
+	for i, job := range jobs {
+		if i > 0 && interval > 0 {
+			time.Sleep(interval + rand.N(interval/2+1))
+		}
+		ch <- job
+	}
+}
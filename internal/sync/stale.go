@@ -0,0 +1,106 @@
+package sync
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arch-err/autogitter/internal/config"
+	"github.com/arch-err/autogitter/internal/git"
+	"github.com/arch-err/autogitter/internal/ui"
+)
+
+// Staleness is measured either by the last local commit or the last fetch
+const (
+	StaleByCommit = "commit"
+	StaleByFetch  = "fetch"
+)
+
+// StaleOptions contains options for the stale command
+type StaleOptions struct {
+	Than time.Duration
+	By   string // StaleByCommit or StaleByFetch
+}
+
+// StaleRepo is a repo whose last activity is older than the threshold
+type StaleRepo struct {
+	Name         string
+	Path         string
+	LastActivity time.Time
+}
+
+// SourceStale holds the stale repos of one source, oldest first
+type SourceStale struct {
+	Name  string
+	Repos []StaleRepo
+}
+
+// FindStale lists repos per source whose last activity is older than opts.Than
+func FindStale(cfg *config.Config, opts StaleOptions) ([]SourceStale, error) {
+	if opts.By == "" {
+		opts.By = StaleByCommit
+	}
+	if opts.By != StaleByCommit && opts.By != StaleByFetch {
+		return nil, fmt.Errorf("invalid --by value %q (must be commit or fetch)", opts.By)
+	}
+
+	cutoff := time.Now().Add(-opts.Than)
+	var results []SourceStale
+
+	for i := range cfg.Sources {
+		source := &cfg.Sources[i]
+		stale := SourceStale{Name: source.Name}
+
+		for _, repo := range listLocalRepos(source) {
+			var last time.Time
+			var err error
+			if opts.By == StaleByFetch {
+				last, err = git.LastFetchTime(repo.path)
+			} else {
+				last, err = git.LastCommitTime(repo.path)
+			}
+			if err != nil {
+				// Empty repos have no commits to date
+				ui.Debug("failed to get last activity", "repo", repo.name, "error", err)
+				continue
+			}
+
+			if last.Before(cutoff) {
+				stale.Repos = append(stale.Repos, StaleRepo{
+					Name:         repo.name,
+					Path:         repo.path,
+					LastActivity: last,
+				})
+			}
+		}
+
+		sort.Slice(stale.Repos, func(a, b int) bool {
+			return stale.Repos[a].LastActivity.Before(stale.Repos[b].LastActivity)
+		})
+		results = append(results, stale)
+	}
+
+	return results, nil
+}
+
+// ParseAge parses a duration that, unlike time.ParseDuration, also accepts
+// days and weeks, e.g. "90d", "2w", or "36h"
+func ParseAge(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			value, err := strconv.Atoi(n)
+			if err != nil || value < 0 {
+				return 0, fmt.Errorf("invalid duration: %s", s)
+			}
+			return time.Duration(value) * unit, nil
+		}
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration: %s", s)
+	}
+	return d, nil
+}
@@ -0,0 +1,194 @@
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/arch-err/autogitter/internal/config"
+	"github.com/arch-err/autogitter/internal/connector"
+	"github.com/arch-err/autogitter/internal/ui"
+)
+
+// StateFile records what each sync saw upstream, for ag news. It's kept
+// next to the credentials.
+const StateFile = "state.json"
+
+// syncState is the state of the last sync with one config
+type syncState struct {
+	Sources map[string]*sourceState `json:"sources"` // keyed by source name
+}
+
+// sourceState is what the last sync of a source saw upstream
+type sourceState struct {
+	SyncedAt time.Time `json:"synced_at"`
+	Upstream []string  `json:"upstream"` // repos the source's strategy picked up
+
+	// Kept by incremental syncs, to reuse the listing while the provider's
+	// activity feed shows no changes
+	Listed     []string  `json:"listed,omitempty"`      // every repo listed, before the regex
+	ListedFrom string    `json:"listed_from,omitempty"` // host/owner listed
+	ListedAt   time.Time `json:"listed_at,omitempty"`   // when Listed was listed in full
+	PolledAt   time.Time `json:"polled_at,omitempty"`   // when the provider was last asked
+}
+
+// NewsOptions contains options for the news command
+type NewsOptions struct {
+	ConfigPath string
+}
+
+// SourceNews lists the upstream changes of one source since its last sync
+type SourceNews struct {
+	Name    string
+	Since   time.Time // time of the last sync
+	New     []string  // repos created upstream that the strategy picks up
+	Removed []string  // repos gone upstream, or no longer matched
+}
+
+func statePath() string {
+	return filepath.Join(filepath.Dir(connector.DefaultCredentialsPath()), StateFile)
+}
+
+// loadState reads the state file, keyed by config. A missing file is empty.
+func loadState() (map[string]*syncState, error) {
+	all := make(map[string]*syncState)
+	data, err := os.ReadFile(statePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return all, nil
+		}
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, fmt.Errorf("failed to parse state file: %w", err)
+	}
+	return all, nil
+}
+
+// recordUpstream stores the upstream repo lists of the synced sources, and
+// the listings of incremental syncs, keeping the state of sources that
+// weren't listed this time
+func recordUpstream(configPath string, upstream map[string][]string, listings map[string]listing) {
+	if configPath == "" || len(upstream) == 0 {
+		return
+	}
+
+	all, err := loadState()
+	if err != nil {
+		ui.Warn("failed to record sync state", "error", err)
+		return
+	}
+
+	key := configKey(configPath)
+	state := all[key]
+	if state == nil {
+		state = &syncState{}
+		all[key] = state
+	}
+	if state.Sources == nil {
+		state.Sources = make(map[string]*sourceState)
+	}
+
+	now := time.Now().UTC()
+	for name, repos := range upstream {
+		sorted := append([]string(nil), repos...)
+		sort.Strings(sorted)
+		state.Sources[name] = &sourceState{SyncedAt: now, Upstream: sorted}
+	}
+	for name, l := range listings {
+		source := state.Sources[name]
+		if source == nil {
+			continue
+		}
+		source.Listed = append([]string(nil), l.repos...)
+		sort.Strings(source.Listed)
+		source.ListedFrom = l.from
+		source.ListedAt = l.listedAt
+		source.PolledAt = l.polledAt
+	}
+
+	if err := writeDataFile(statePath(), all); err != nil {
+		ui.Warn("failed to record sync state", "error", fmt.Errorf("failed to write state file: %w", err))
+	}
+}
+
+// listUpstream returns the repos a source's strategy picks up on the
+// provider. Only all and regex sources list their repos.
+func listUpstream(source *config.Source) ([]string, error) {
+	repos, err := fetchReposFromAPI(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch repos: %w", err)
+	}
+	if source.Strategy == config.StrategyRegex {
+		return filterReposByRegex(repos, source.RegexStrategy.Pattern)
+	}
+	return repos, nil
+}
+
+// RunNews lists, for every all and regex source, the repos created and
+// removed upstream since the last sync. Nothing is cloned, pruned, or recorded.
+func RunNews(cfg *config.Config, opts NewsOptions) ([]SourceNews, error) {
+	credPath := connector.DefaultCredentialsPath()
+	if err := connector.LoadCredentialsEnv(credPath); err != nil {
+		ui.Debug("failed to load credentials file", "error", err)
+	}
+
+	all, err := loadState()
+	if err != nil {
+		return nil, err
+	}
+	state := all[configKey(opts.ConfigPath)]
+
+	var results []SourceNews
+	for i := range cfg.Sources {
+		source := &cfg.Sources[i]
+		if source.Strategy != config.StrategyAll && source.Strategy != config.StrategyRegex {
+			ui.Debug("skipping source, news needs an all or regex strategy", "source", source.Name)
+			continue
+		}
+
+		var last *sourceState
+		if state != nil {
+			last = state.Sources[source.Name]
+		}
+		if last == nil {
+			ui.Warn("source was never synced, run ag sync first", "source", source.Name)
+			continue
+		}
+
+		current, err := listUpstream(source)
+		if err != nil {
+			ui.Warn("skipping source", "source", source.Name, "error", err)
+			continue
+		}
+
+		news := SourceNews{Name: source.Name, Since: last.SyncedAt}
+		news.New = missingFrom(current, last.Upstream)
+		news.Removed = missingFrom(last.Upstream, current)
+		results = append(results, news)
+	}
+
+	return results, nil
+}
+
+// missingFrom returns the repos of a that aren't in b, sorted. Names are
+// compared case-insensitively, like providers do.
+func missingFrom(a, b []string) []string {
+	in := make(map[string]bool, len(b))
+	for _, name := range b {
+		in[strings.ToLower(name)] = true
+	}
+
+	var missing []string
+	for _, name := range a {
+		if !in[strings.ToLower(name)] {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
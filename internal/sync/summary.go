@@ -0,0 +1,73 @@
+package sync
+
+import "time"
+
+// SourceSummary is what a sync or pull did with one source, for the
+// summary table
+type SourceSummary struct {
+	Name     string
+	Cloned   int
+	Pruned   int
+	Added    int
+	Skipped  int
+	Pulled   int
+	Updated  int // pulled repos that got new commits
+	Failed   int
+	Duration time.Duration // listing and scanning the source, plus the time its clones or pulls ran
+}
+
+// summaryFor returns the summary of the named source, adding it on first use
+// so sources are listed in the order they were worked on. The pointer is only
+// valid until the next call.
+func summaryFor(summaries *[]SourceSummary, name string) *SourceSummary {
+	for i := range *summaries {
+		if (*summaries)[i].Name == name {
+			return &(*summaries)[i]
+		}
+	}
+	*summaries = append(*summaries, SourceSummary{Name: name})
+	return &(*summaries)[len(*summaries)-1]
+}
+
+// tallyClones counts the clones of each source and the time they took
+func (r *SyncResult) tallyClones(results []cloneResult) {
+	for _, res := range results {
+		s := summaryFor(&r.Sources, res.job.source.Name)
+		if res.success {
+			s.Cloned++
+		}
+		s.Duration += res.duration
+	}
+}
+
+// tallyPulls counts the pulls of a sync with Pull for each source and the
+// time they took; fail counts the failures
+func (r *SyncResult) tallyPulls(results []pullResult) {
+	for _, res := range results {
+		s := summaryFor(&r.Sources, res.job.source.Name)
+		if res.success {
+			s.Pulled++
+			if res.digest != nil {
+				s.Updated++
+			}
+		}
+		s.Duration += res.duration
+	}
+}
+
+// tallyPulls counts the pulls of each source and the time they took
+func (r *PullResult) tallyPulls(results []pullResult) {
+	for _, res := range results {
+		s := summaryFor(&r.Sources, res.job.source.Name)
+		switch {
+		case !res.success:
+			s.Failed++
+		case res.digest != nil:
+			s.Updated++
+			s.Pulled++
+		default:
+			s.Pulled++
+		}
+		s.Duration += res.duration
+	}
+}
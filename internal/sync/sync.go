@@ -4,17 +4,30 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"sort"
 	"strings"
 	gosync "sync"
+	"time"
 
 	"github.com/arch-err/autogitter/internal/config"
 	"github.com/arch-err/autogitter/internal/connector"
+	"github.com/arch-err/autogitter/internal/errs"
 	"github.com/arch-err/autogitter/internal/git"
+	"github.com/arch-err/autogitter/internal/journal"
+	"github.com/arch-err/autogitter/internal/metrics"
 	"github.com/arch-err/autogitter/internal/ui"
 )
 
+func init() {
+	connector.OnRateLimit(func(host string, wait time.Duration) {
+		ui.Warn("rate limited, waiting", "host", host, "wait", wait.Round(time.Second))
+	})
+}
+
 type SyncOptions struct {
 	Prune      bool
 	Add        bool
@@ -22,17 +35,51 @@ type SyncOptions struct {
 	ConfigPath string
 	Jobs       int
 	DryRun     bool
+	// FixRemotes points origin at the configured URL when they differ
+	FixRemotes bool
+	// FixConfig sets git_config values on existing clones where they differ
+	FixConfig bool
+	// IncludeLarge clones repos even when they exceed max_repo_size
+	IncludeLarge bool
+	// CheckRenames asks the provider whether any existing clone was renamed
+	// upstream, not just orphaned ones
+	CheckRenames bool
+	// NonInteractive never prompts; orphaned repos are left alone
+	// unless Prune or Add is set
+	NonInteractive bool
+	// InteractivePrune confirms each prune separately, showing the repo's
+	// last commit, dirty state, and size
+	InteractivePrune bool
+	// RetryFailed only re-attempts the clones that failed in the last sync
+	RetryFailed bool
+	// CloneOnly clones new repos and leaves orphaned ones alone, without
+	// asking what to do with them
+	CloneOnly bool
+	// PruneOnly prunes orphaned repos, as Prune, and clones nothing
+	PruneOnly bool
+	// Pull also pulls the existing clones of each source, in the same
+	// worker pool as the new clones
+	Pull bool
+	// Incremental reuses the last sync's listing of all and regex sources
+	// while the provider's activity feed shows no new or renamed repos
+	Incremental bool
+	// FullListEvery lists sources in full once the last full listing is
+	// older, with Incremental (default DefaultFullListEvery)
+	FullListEvery time.Duration
 }
 
 type cloneJob struct {
 	status RepoStatus
 	source *config.Source
+	pull   *pullJob // set to pull an existing clone instead
 }
 
 type cloneResult struct {
-	name    string
-	success bool
-	err     error
+	job      cloneJob
+	success  bool
+	err      error
+	duration time.Duration
+	pull     *pullResult // set for pull jobs
 }
 
 type SyncResult struct {
@@ -40,19 +87,92 @@ type SyncResult struct {
 	Pruned  int
 	Skipped int
 	Added   int
+	Failed  int
+	// Pulled counts the existing clones pulled with Pull
+	Pulled int
+	// Digests lists the new commits of each repo pulled with Pull, by name
+	Digests []ui.PullDigest
+	// wouldAdd lists the repos a dry run with Add would add to the config
+	wouldAdd []adoption
+	// Failures lists the sources that couldn't be synced and the repos that
+	// failed to clone
+	Failures []SyncFailure
+	// Sources summarizes each source, in the order they were synced
+	Sources  []SourceSummary
+	Duration time.Duration
+	// Timings breaks the run down by phase, with the time of each clone
+	Timings Timings
+}
+
+// SyncFailure is a source that couldn't be synced, or a repo of it that
+// failed to clone
+type SyncFailure struct {
+	Source string
+	Repo   string // empty when the whole source failed
+	Path   string // the repo's local path, empty when the whole source failed
+	Err    error
+}
+
+// fail records a failure and counts it
+func (r *SyncResult) fail(source, repo string, err error) {
+	r.Failures = append(r.Failures, SyncFailure{Source: source, Repo: repo, Err: err})
+	r.Failed++
+	summaryFor(&r.Sources, source).Failed++
+	if repo == "" {
+		emit(SourceFailed{Source: source, Err: err})
+	}
+}
+
+// failRepo records a repo that failed to clone or pull and counts it
+func (r *SyncResult) failRepo(source, repo, path string, err error) {
+	r.fail(source, repo, err)
+	r.Failures[len(r.Failures)-1].Path = path
+}
+
+// failClones records each failed clone
+func (r *SyncResult) failClones(failures []cloneResult) {
+	for _, res := range failures {
+		r.failRepo(res.job.source.Name, res.job.status.FullName, res.job.status.LocalPath, res.err)
+		emit(RepoCloneFailed{
+			Source: res.job.source.Name,
+			Repo:   res.job.status.FullName,
+			Path:   res.job.status.LocalPath,
+			Err:    res.err,
+		})
+	}
 }
 
 type RepoStatus struct {
-	Name       string
-	FullName   string
-	LocalPath  string
-	Status     ui.DiffStatus
-	InConfig   bool
+	Name        string
+	FullName    string
+	LocalPath   string
+	Status      ui.DiffStatus
+	InConfig    bool
 	ExistsLocal bool
+	// Populated only when StatusOptions.Deep is set
+	Dirty          bool
+	Ahead          int
+	Behind         int
+	RemoteMismatch bool
+	// Populated only when StatusOptions.Remote is set
+	GoneUpstream bool
+}
+
+// StatusOptions contains options for ComputeSourceStatus
+type StatusOptions struct {
+	// Deep inspects each existing repo for uncommitted changes, commits
+	// ahead of/behind its upstream, and an origin that differs from the config
+	Deep bool
+	// Remote compares against the repos that currently exist on the provider
+	Remote bool
 }
 
-func Run(cfg *config.Config, opts SyncOptions) (*SyncResult, error) {
+// Run syncs every source of the config. Canceling ctx stops listing sources
+// and starting clones; clones already running finish.
+func Run(ctx context.Context, cfg *config.Config, opts SyncOptions) (*SyncResult, error) {
 	result := &SyncResult{}
+	start := time.Now()
+	defer func() { result.Duration = time.Since(start) }()
 
 	// Load credentials from credentials.env if it exists
 	credPath := connector.DefaultCredentialsPath()
@@ -60,8 +180,48 @@ func Run(cfg *config.Config, opts SyncOptions) (*SyncResult, error) {
 		ui.Debug("failed to load credentials file", "error", err)
 	}
 
+	if !opts.DryRun {
+		history := startHistory("sync", opts.ConfigPath)
+		defer func() { history.finishSync(result) }()
+	}
+	if opts.PruneOnly {
+		opts.Prune = true
+	}
+
+	if opts.RetryFailed {
+		retried, err := retryFailedClones(ctx, cfg, opts)
+		if retried != nil {
+			result = retried
+		}
+		return retried, err
+	}
+
+	// Clone jobs from all sources share a single worker pool
+	var allJobs []cloneJob
+	// Pulls of existing clones, with Pull, run in the same pool
+	var pullJobs []cloneJob
+	// Pulls dropped because their source's host key didn't match
+	var hostKeyFailures []pullResult
+	pullOpts := PullOptions{Force: opts.Force, NonInteractive: opts.NonInteractive, ConfigPath: opts.ConfigPath}
+	var synced []*config.Source
+	// Upstream repo lists of all/regex sources, recorded for ag news
+	upstream := make(map[string][]string)
+	// Provider listings, recorded for the next incremental sync
+	listings := make(map[string]listing)
+	// Repos a dry run with Add would add, shown as a config diff
+	wouldAdd := make(map[*config.Source][]string)
+	var last *syncState
+	if opts.Incremental {
+		last = lastSyncState(opts.ConfigPath)
+	}
+
 	for i := range cfg.Sources {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
 		source := &cfg.Sources[i]
+		sourceStart := time.Now()
+		ui.Group(source.Name)
 
 		// Handle strategy-specific logic
 		switch source.Strategy {
@@ -69,27 +229,42 @@ func Run(cfg *config.Config, opts SyncOptions) (*SyncResult, error) {
 			// Manual strategy uses the repos from config
 		case config.StrategyAll:
 			// Fetch repos from API
-			repos, err := fetchReposFromAPI(source)
+			repos, err := listSourceRepos(ctx, source, opts, last, listings)
 			if err != nil {
 				ui.Warn("skipping source - failed to fetch repos", "source", source.Name, "error", err)
+				result.fail(source.Name, "", fmt.Errorf("failed to fetch repos: %w", err))
 				continue
 			}
 			source.Repos = config.RepoEntriesFromNames(repos)
+			upstream[source.Name] = repos
 			ui.Debug("fetched repos from API", "source", source.Name, "count", len(repos))
 		case config.StrategyRegex:
 			// Fetch repos from API, then filter by regex pattern
-			repos, err := fetchReposFromAPI(source)
+			repos, err := listSourceRepos(ctx, source, opts, last, listings)
 			if err != nil {
 				ui.Warn("skipping source - failed to fetch repos", "source", source.Name, "error", err)
+				result.fail(source.Name, "", fmt.Errorf("failed to fetch repos: %w", err))
 				continue
 			}
 			filtered, err := filterReposByRegex(repos, source.RegexStrategy.Pattern)
 			if err != nil {
 				ui.Warn("skipping source - invalid regex pattern", "source", source.Name, "error", err)
+				result.fail(source.Name, "", fmt.Errorf("invalid regex pattern: %w", err))
 				continue
 			}
 			source.Repos = config.RepoEntriesFromNames(filtered)
+			upstream[source.Name] = filtered
 			ui.Debug("fetched and filtered repos from API", "source", source.Name, "total", len(repos), "matched", len(filtered))
+		case config.StrategyStatic:
+			// Read clone URLs from the static list
+			repos, err := config.ReadStaticList(source.StaticStrategy.List)
+			if err != nil {
+				ui.Warn("skipping source - failed to read repo list", "source", source.Name, "error", err)
+				result.fail(source.Name, "", err)
+				continue
+			}
+			source.Repos = config.RepoEntriesFromNames(repos)
+			ui.Debug("read repos from static list", "source", source.Name, "count", len(repos))
 		case config.StrategyFile:
 			ui.Warn("skipping source with unsupported strategy", "source", source.Name, "strategy", source.Strategy)
 			continue
@@ -98,49 +273,276 @@ func Run(cfg *config.Config, opts SyncOptions) (*SyncResult, error) {
 			continue
 		}
 
-		sourceResult, err := syncSource(source, cfg, opts)
+		if source.Strategy != config.StrategyManual {
+			result.Timings.phase("list "+source.Name, sourceStart)
+		}
+
+		metrics.ReposManaged.Set(float64(len(source.Repos)), source.Name)
+
+		sourceResult, jobs, err := syncSource(source, cfg, opts)
 		if err != nil {
 			ui.Error("failed to sync source", "source", source.Name, "error", err)
+			result.fail(source.Name, "", err)
 			continue
 		}
 
-		result.Cloned += sourceResult.Cloned
+		allJobs = append(allJobs, jobs...)
+		synced = append(synced, source)
+		if opts.Pull {
+			pulls := pullJobsFor(source, pullOpts)
+			if cfg.Pull.OnlyDefaultBranch {
+				var skipped []pullJob
+				pulls, skipped = skipOffDefaultBranch(pulls)
+				result.Skipped += len(skipped)
+				summaryFor(&result.Sources, source.Name).Skipped += len(skipped)
+			}
+			if opts.DryRun && len(pulls) > 0 {
+				ui.Info("would pull", "source", source.Name, "count", len(pulls))
+			} else if !opts.DryRun {
+				var dropped []pullResult
+				pulls, dropped = verifyPullHostKeys(pulls)
+				hostKeyFailures = append(hostKeyFailures, dropped...)
+				for i := range pulls {
+					pullJobs = append(pullJobs, cloneJob{source: source, pull: &pulls[i]})
+				}
+			}
+		}
+		result.Timings.Phases = append(result.Timings.Phases, sourceResult.Timings.Phases...)
+
 		result.Pruned += sourceResult.Pruned
 		result.Skipped += sourceResult.Skipped
 		result.Added += sourceResult.Added
+		for _, a := range sourceResult.wouldAdd {
+			wouldAdd[a.target] = append(wouldAdd[a.target], a.entry.Name)
+		}
+
+		summary := summaryFor(&result.Sources, source.Name)
+		summary.Pruned += sourceResult.Pruned
+		summary.Skipped += sourceResult.Skipped
+		summary.Added += sourceResult.Added
+		summary.Duration += time.Since(sourceStart)
+	}
+	ui.EndGroup()
+
+	if len(wouldAdd) > 0 {
+		printConfigDiff(cfg, opts.ConfigPath, wouldAdd)
+	}
+
+	// Repos listed by the API at run time can still collide, e.g. two orgs
+	// with a repo of the same name synced into one flat directory
+	allJobs, conflicts := dropConflictingJobs(allJobs)
+	for _, job := range conflicts {
+		result.Skipped++
+		summaryFor(&result.Sources, job.source.Name).Skipped++
+	}
+
+	allJobs, failures := verifyHostKeys(allJobs)
+	// Pulls go after the clones, so new repos start first
+	allJobs = append(allJobs, pullJobs...)
+
+	// Results of the pulls run in the pool
+	var pulls []pullResult
+	if len(allJobs) > 0 {
+		cloneStart := time.Now()
+		var cloned, cloneFailures []cloneResult
+		cloned, cloneFailures, pulls = cloneReposParallel(ctx, allJobs, limitBandwidth(cfg, opts.Jobs, len(allJobs)), cfg.GetJobStagger())
+		if len(pullJobs) > 0 {
+			result.Timings.phase("clone and pull", cloneStart)
+		} else {
+			result.Timings.phase("clone", cloneStart)
+		}
+		result.Timings.addClones(cloned)
+		result.Timings.addClones(cloneFailures)
+		result.Cloned = len(cloned)
+		result.tallyClones(cloned)
+		result.tallyClones(cloneFailures)
+		failures = append(failures, cloneFailures...)
+	}
+
+	pulls = append(hostKeyFailures, pulls...)
+	if len(pulls) > 0 {
+		var pulled, pullFailures []pullResult
+		for _, res := range pulls {
+			if res.success {
+				pulled = append(pulled, res)
+			} else {
+				pullFailures = append(pullFailures, res)
+			}
+		}
+		pulled, pullFailures = settlePulls(pulled, pullFailures, pullOpts)
+		result.Pulled = len(pulled)
+		result.Digests = pullDigests(pulled)
+		result.tallyPulls(pulled)
+		result.tallyPulls(pullFailures)
+		for _, res := range pullFailures {
+			result.failRepo(res.job.source.Name, res.job.name, res.job.path, res.err)
+		}
+		recordFailedPulls(opts.ConfigPath, pullFailures)
+	}
+	result.failClones(failures)
+	for _, source := range synced {
+		emit(SourceSynced{Summary: *summaryFor(&result.Sources, source.Name)})
+	}
+
+	if !opts.DryRun {
+		// A run that cloned nothing can't tell whether the failed clones
+		// would work now
+		if !opts.PruneOnly {
+			recordFailedClones(opts.ConfigPath, failures)
+		}
+		recordUpstream(opts.ConfigPath, upstream, listings)
+
+		failedBySource := make(map[*config.Source]int)
+		for _, res := range failures {
+			failedBySource[res.job.source]++
+		}
+		for _, source := range synced {
+			if failedBySource[source] == 0 {
+				metrics.LastSuccessfulSync.SetToCurrentTime(source.Name)
+			}
+		}
+
+		if err := UpdateSymlinks(cfg); err != nil {
+			ui.Warn("failed to update symlinks", "error", err)
+		}
+		updateRepoLinks(cfg)
+		expireTrash(cfg)
 	}
 
 	return result, nil
 }
 
+// expireTrash deletes the clones that were in the trash for longer than
+// trash_days
+func expireTrash(cfg *config.Config) {
+	days := cfg.GetTrashDays()
+	if days == 0 {
+		return
+	}
+	expired, err := journal.ExpireTrash(time.Duration(days) * 24 * time.Hour)
+	for _, e := range expired {
+		ui.Debug("deleted from trash", "repo", e.Name(), "trashed", e.TrashedAt.Format("2006-01-02"))
+	}
+	if len(expired) > 0 {
+		ui.Info("emptied expired trash", "count", len(expired), "older_than", fmt.Sprintf("%dd", days))
+	}
+	if err != nil {
+		ui.Warn("failed to empty expired trash", "path", journal.TrashPath(), "error", err)
+	}
+}
+
+// listSourceRepos lists the repos of an all or regex source, incrementally
+// when opts.Incremental is set, adding the listing to listings
+func listSourceRepos(ctx context.Context, source *config.Source, opts SyncOptions, last *syncState, listings map[string]listing) ([]string, error) {
+	if !opts.Incremental {
+		return ListRepos(ctx, source)
+	}
+
+	var lastSource *sourceState
+	if last != nil {
+		lastSource = last.Sources[source.Name]
+	}
+	fullListEvery := opts.FullListEvery
+	if fullListEvery <= 0 {
+		fullListEvery = DefaultFullListEvery
+	}
+
+	l, err := listReposIncremental(ctx, source, lastSource, fullListEvery)
+	if err != nil {
+		return nil, err
+	}
+	listings[source.Name] = l
+	return l.repos, nil
+}
+
 // fetchReposFromAPI fetches repository list from the Git provider API
 func fetchReposFromAPI(source *config.Source) ([]string, error) {
+	return ListRepos(context.Background(), source)
+}
+
+// ListRepos lists the repos of the source's user or org through the
+// provider API, before any regex filter
+func ListRepos(ctx context.Context, source *config.Source) ([]string, error) {
 	connType := source.GetConnectorType()
-	token := connector.GetToken(connType)
+	token := source.GetToken()
 
-	if token == "" {
+	// Plugins handle their own authentication, a token is optional
+	if token == "" && connType != connector.ConnectorPlugin {
 		envVar := connector.GetEnvVarName(connType)
 		return nil, fmt.Errorf("no token found - set %s or run 'ag connect'", envVar)
 	}
 
-	host := source.GetHost()
+	conn, err := source.NewConnector(token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create connector: %w", err)
+	}
+
+	if source.AllOrgs() {
+		return listOrgRepos(ctx, source, conn)
+	}
+
 	userOrOrg := source.GetUserOrOrg()
 
 	if userOrOrg == "" {
-		return nil, fmt.Errorf("source must include user/org (e.g., github.com/username)")
+		// Some servers can list everything the token can read instead
+		lister, ok := conn.(connector.AllReposLister)
+		if !ok {
+			return nil, fmt.Errorf("source must include user/org (e.g., github.com/username)")
+		}
+		repos, err := lister.ListAllRepos(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list repos: %w", err)
+		}
+		return repos, nil
 	}
 
-	conn, err := connector.New(connType, host, token)
+	var repos []string
+	if source.Project != "" {
+		lister, ok := conn.(connector.ProjectLister)
+		if !ok {
+			return nil, fmt.Errorf("%s sources don't support project", conn.Name())
+		}
+		repos, err = lister.ListProjectRepos(ctx, userOrOrg, source.Project)
+	} else {
+		repos, err = conn.ListRepos(ctx, userOrOrg)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to create connector: %w", err)
+		return nil, fmt.Errorf("failed to list repos: %w", err)
 	}
 
-	ctx := context.Background()
-	repos, err := conn.ListRepos(ctx, userOrOrg)
+	return repos, nil
+}
+
+// listOrgRepos lists the repos of every org the token belongs to. Orgs that
+// can't be listed, e.g. because they enforce SSO the token isn't
+// authorized for, are skipped with a warning.
+func listOrgRepos(ctx context.Context, source *config.Source, conn connector.Connector) ([]string, error) {
+	lister, ok := conn.(connector.OrgLister)
+	if !ok {
+		return nil, fmt.Errorf("%s sources can't list orgs, name one instead of *", conn.Name())
+	}
+	orgs, err := lister.ListOrgs(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list repos: %w", err)
+		return nil, fmt.Errorf("failed to list orgs: %w", err)
 	}
+	ui.Debug("listing repos of every org", "source", source.Name, "orgs", len(orgs))
 
+	var repos []string
+	var listed int
+	var lastErr error
+	for _, org := range orgs {
+		orgRepos, err := conn.ListRepos(ctx, org)
+		if err != nil {
+			ui.Warn("skipping org", "source", source.Name, "org", org, "error", err)
+			lastErr = err
+			continue
+		}
+		repos = append(repos, orgRepos...)
+		listed++
+	}
+	if listed == 0 && lastErr != nil {
+		return nil, fmt.Errorf("failed to list repos: %w", lastErr)
+	}
 	return repos, nil
 }
 
@@ -162,7 +564,9 @@ func filterReposByRegex(repos []string, pattern string) ([]string, error) {
 	return filtered, nil
 }
 
-func syncSource(source *config.Source, cfg *config.Config, opts SyncOptions) (*SyncResult, error) {
+// syncSource computes the status of a single source, handles orphaned repos,
+// and returns the clone jobs for repos that are missing locally.
+func syncSource(source *config.Source, cfg *config.Config, opts SyncOptions) (*SyncResult, []cloneJob, error) {
 	result := &SyncResult{}
 
 	// Check if local_path exists, prompt to create if not
@@ -173,92 +577,39 @@ func syncSource(source *config.Source, cfg *config.Config, opts SyncOptions) (*S
 			if !opts.Force {
 				create, promptErr := ui.ConfirmCreateDir(source.LocalPath)
 				if promptErr != nil {
-					return nil, fmt.Errorf("failed to get user input: %w", promptErr)
+					return nil, nil, fmt.Errorf("failed to get user input: %w", promptErr)
 				}
 				if !create {
 					ui.Info("skipping source", "source", source.Name, "reason", "directory not created")
-					return result, nil
+					return result, nil, nil
 				}
 			}
 			if err := os.MkdirAll(source.LocalPath, 0755); err != nil {
-				return nil, fmt.Errorf("failed to create directory: %w", err)
+				return nil, nil, fmt.Errorf("failed to create directory: %w", err)
 			}
 			ui.Info("created directory", "path", source.LocalPath)
 		}
 	}
 
-	// Get configured repos — only repos without custom LocalPath participate in orphan detection
-	configuredRepos := make(map[string]bool)
-	for _, repo := range source.Repos {
-		if !repo.HasCustomLocalPath() {
-			repoName := repoNameFromFullName(repo.Name)
-			configuredRepos[repoName] = true
-		}
-	}
-
-	// Scan local directory
-	localRepos, err := scanLocalRepos(source.LocalPath)
-	if err != nil && !os.IsNotExist(err) {
-		return nil, fmt.Errorf("failed to scan local repos: %w", err)
+	scanStart := time.Now()
+	statuses, err := buildRepoStatuses(source)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	// Build status list
-	var statuses []RepoStatus
-
-	// Add configured repos
-	for _, repo := range source.Repos {
-		repoName := repoNameFromFullName(repo.Name)
-		resolvedPath := repo.ResolvedLocalPath(source.LocalPath)
-
-		var exists bool
-		if repo.HasCustomLocalPath() {
-			exists = git.IsGitRepo(resolvedPath)
+	statuses, renamed := detectRenames(source, statuses, opts)
+	if renamed && opts.ConfigPath != "" {
+		if err := SaveConfig(cfg, opts.ConfigPath); err != nil {
+			ui.Error("failed to save config", "error", err)
 		} else {
-			exists = localRepos[repoName]
-		}
-
-		// If path exists as a non-empty non-git directory (or as a file), warn and skip.
-		// Empty directories are fine — git clone handles them.
-		if !exists {
-			if info, statErr := os.Stat(resolvedPath); statErr == nil {
-				if !info.IsDir() {
-					ui.Warn("path already exists as a file, skipping", "repo", repo.Name, "path", resolvedPath)
-					exists = true
-				} else if entries, readErr := os.ReadDir(resolvedPath); readErr == nil && len(entries) > 0 {
-					ui.Warn("path already exists but is not a git repo, skipping", "repo", repo.Name, "path", resolvedPath)
-					exists = true
-				}
-			}
-		}
-
-		status := ui.StatusAdded
-		if exists {
-			status = ui.StatusUnchanged
+			ui.Info("config saved", "path", opts.ConfigPath)
 		}
-
-		statuses = append(statuses, RepoStatus{
-			Name:        repoName,
-			FullName:    repo.Name,
-			LocalPath:   resolvedPath,
-			Status:      status,
-			InConfig:    true,
-			ExistsLocal: exists,
-		})
 	}
 
-	// Add orphaned repos (in local but not in config)
-	for repoName := range localRepos {
-		if !configuredRepos[repoName] {
-			localPath := filepath.Join(source.LocalPath, repoName)
-			statuses = append(statuses, RepoStatus{
-				Name:        repoName,
-				LocalPath:   localPath,
-				Status:      ui.StatusRemoved,
-				InConfig:    false,
-				ExistsLocal: true,
-			})
-		}
-	}
+	checkRemotes(source, statuses, opts)
+	checkUpstreams(source, statuses, opts)
+	checkGitConfig(source, statuses, opts)
+	result.Timings.phase("scan "+source.Name, scanStart)
 
 	// Check if there are any changes
 	hasNew := false
@@ -274,7 +625,7 @@ func syncSource(source *config.Source, cfg *config.Config, opts SyncOptions) (*S
 
 	if !hasNew && !hasOrphaned {
 		ui.Info("source is up to date", "source", source.Name)
-		return result, nil
+		return result, nil, nil
 	}
 
 	// Print diff
@@ -285,18 +636,26 @@ func syncSource(source *config.Source, cfg *config.Config, opts SyncOptions) (*S
 	ui.PrintDiff(source.Name, entries)
 
 	// Handle orphaned repos
-	if hasOrphaned {
+	if hasOrphaned && opts.CloneOnly {
+		ui.Info("leaving orphaned repos in place", "source", source.Name, "reason", "--clone-only")
+	} else if hasOrphaned {
+		pruneStart := time.Now()
 		if opts.DryRun {
 			// In dry-run mode, just report what would happen based on flags
 			orphaned := getOrphanedRepos(statuses)
 			if opts.Prune {
 				for _, repo := range orphaned {
-					ui.Info("would prune", "repo", repo.Name)
+					d := describePrune(repo)
+					ui.Info("would prune", "repo", repo.Name, "size", ui.FormatBytes(d.Size), "last_commit", d.LastCommitText(), "dirty", d.Dirty)
 				}
 			} else if opts.Add {
 				for _, repo := range orphaned {
-					fullName := guessFullName(source.Source, repo.Name)
-					ui.Info("would add to config", "repo", fullName)
+					a := planAdoption(cfg, source, repo)
+					if a.path != "" {
+						ui.Info("would move", "repo", a.entry.Name, "from", repo.LocalPath, "to", a.path)
+					}
+					ui.Info("would add to config", "repo", a.entry.Name, "source", a.target.Name)
+					result.wouldAdd = append(result.wouldAdd, a)
 				}
 			}
 		} else {
@@ -305,26 +664,65 @@ func syncSource(source *config.Source, cfg *config.Config, opts SyncOptions) (*S
 				action = "prune"
 			} else if opts.Add {
 				action = "add"
+			} else if opts.NonInteractive {
+				ui.Info("leaving orphaned repos in place", "source", source.Name)
+				action = "skip"
 			} else {
 				// Interactive mode
 				var err error
 				action, err = ui.ConfirmAction()
 				if err != nil {
-					return nil, fmt.Errorf("failed to get user input: %w", err)
+					return nil, nil, fmt.Errorf("failed to get user input: %w", err)
+				}
+			}
+
+			// Let the user deselect individual repos before anything happens.
+			// Prompts show what each prune would delete.
+			var orphaned []RepoStatus
+			var details map[string]ui.PruneDetails
+			if action == "prune" {
+				orphaned = getOrphanedRepos(statuses)
+				if !opts.Force {
+					details = describePrunes(orphaned)
+				}
+			}
+			if !opts.Force && !opts.NonInteractive && ui.IsTTY() {
+				// Prunes are confirmed one by one below in interactive prune mode
+				var selectPrune []RepoStatus
+				if !opts.InteractivePrune {
+					selectPrune = orphaned
+				}
+				pending := statuses
+				if opts.PruneOnly {
+					pending = nil // nothing is cloned
+				}
+				var err error
+				statuses, selectPrune, err = selectPending(pending, selectPrune, details)
+				if err != nil {
+					return nil, nil, fmt.Errorf("failed to get user input: %w", err)
+				}
+				if !opts.InteractivePrune {
+					orphaned = selectPrune
 				}
 			}
 
 			switch action {
 			case "prune":
-				orphaned := getOrphanedRepos(statuses)
+				if len(orphaned) == 0 {
+					break
+				}
+				if opts.InteractivePrune {
+					result.Pruned += pruneInteractive(source, orphaned, details)
+					break
+				}
 				if !opts.Force {
-					names := make([]string, len(orphaned))
+					repos := make([]ui.PruneDetails, len(orphaned))
 					for i, r := range orphaned {
-						names[i] = r.Name
+						repos[i] = details[r.LocalPath]
 					}
-					confirm, err := ui.ConfirmPrune(names)
+					confirm, err := ui.ConfirmPrune(repos)
 					if err != nil {
-						return nil, fmt.Errorf("failed to get confirmation: %w", err)
+						return nil, nil, fmt.Errorf("failed to get confirmation: %w", err)
 					}
 					if !confirm {
 						ui.Info("prune cancelled")
@@ -333,26 +731,46 @@ func syncSource(source *config.Source, cfg *config.Config, opts SyncOptions) (*S
 				}
 
 				for _, repo := range orphaned {
-					ui.Info("removing", "repo", repo.Name)
-					if err := os.RemoveAll(repo.LocalPath); err != nil {
-						ui.Error("failed to remove repo", "repo", repo.Name, "error", err)
-						continue
+					if pruneRepo(source, repo) {
+						result.Pruned++
 					}
-					result.Pruned++
 				}
-
 			case "add":
-				orphaned := getOrphanedRepos(statuses)
-				for _, repo := range orphaned {
-					fullName := guessFullName(source.Source, repo.Name)
-					source.Repos = append(source.Repos, config.RepoEntry{Name: fullName})
+				// Orphans go to the source for their origin's host and owner
+				var adoptions []adoption
+				for _, repo := range getOrphanedRepos(statuses) {
+					adoptions = append(adoptions, planAdoption(cfg, source, repo))
+				}
+				var created []*config.Source
+				if !opts.Force && !opts.NonInteractive && ui.CanPrompt() {
+					var err error
+					created, err = offerNewSources(cfg, source, adoptions)
+					if err != nil {
+						return nil, nil, fmt.Errorf("failed to get user input: %w", err)
+					}
+				}
+				for _, a := range adoptions {
+					if err := moveAdopted(a); err != nil {
+						ui.Error("failed to add repo", "repo", a.entry.Name, "error", err)
+						continue
+					}
+					a.target.Repos = append(a.target.Repos, a.entry)
 					result.Added++
-					ui.Info("added to config", "repo", fullName)
+					ui.Info("added to config", "repo", a.entry.Name, "source", a.target.Name)
+					emit(RepoAdded{Source: a.target.Name, Repo: a.entry.Name})
+				}
+				// Appended last: growing cfg.Sources may move it, leaving
+				// source and the other pointers into it stale
+				for _, newSource := range created {
+					if len(newSource.Repos) > 0 {
+						cfg.Sources = append(cfg.Sources, *newSource)
+						ui.Info("created source", "source", newSource.Name, "path", newSource.LocalPath)
+					}
 				}
 
 				// Save updated config
 				if opts.ConfigPath != "" {
-					if err := cfg.Save(opts.ConfigPath); err != nil {
+					if err := SaveConfig(cfg, opts.ConfigPath); err != nil {
 						ui.Error("failed to save config", "error", err)
 					} else {
 						ui.Info("config saved", "path", opts.ConfigPath)
@@ -360,155 +778,598 @@ func syncSource(source *config.Source, cfg *config.Config, opts SyncOptions) (*S
 				}
 			}
 		}
+		result.Timings.phase("prune "+source.Name, pruneStart)
 	}
 
-	// Clone new repos in parallel
-	var toClone []RepoStatus
-	for _, status := range statuses {
-		if status.Status == ui.StatusAdded {
-			toClone = append(toClone, status)
+	// Without orphans there was no action prompt, so offer the selection here
+	if (!hasOrphaned || opts.CloneOnly) && !opts.PruneOnly && !opts.DryRun && !opts.Force && !opts.NonInteractive && ui.IsTTY() {
+		var err error
+		statuses, _, err = selectPending(statuses, nil, nil)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get user input: %w", err)
 		}
 	}
 
-	if len(toClone) > 0 {
+	// Queue new repos for cloning
+	if hasNew && opts.PruneOnly {
+		ui.Info("not cloning new repos", "source", source.Name, "reason", "--prune-only")
+		return result, nil, nil
+	}
+	var jobs []cloneJob
+	var sizes *sizeChecker
+	if hasNew {
+		sizes = newSizeChecker(source, opts.IncludeLarge)
+	}
+	for _, status := range statuses {
+		if status.Status != ui.StatusAdded {
+			continue
+		}
+		if sizes.tooLarge(status.FullName) {
+			result.Skipped++
+			continue
+		}
 		if opts.DryRun {
-			for _, repo := range toClone {
-				ui.Info("would clone", "repo", repo.FullName, "path", repo.LocalPath)
-			}
-		} else {
-			cloned := cloneReposParallel(toClone, source, opts.Jobs)
-			result.Cloned = cloned
+			ui.Info("would clone", "repo", status.FullName, "path", status.LocalPath)
+			continue
 		}
+		jobs = append(jobs, cloneJob{status: status, source: source})
 	}
 
-	return result, nil
+	return result, jobs, nil
 }
 
-func cloneReposParallel(repos []RepoStatus, source *config.Source, numWorkers int) int {
-	if numWorkers <= 0 {
-		numWorkers = 4
+// printConfigDiff prints the changes a sync with Add would write to the
+// config, for a dry run
+func printConfigDiff(cfg *config.Config, configPath string, wouldAdd map[*config.Source][]string) {
+	before, err := cfg.Render()
+	if err != nil {
+		ui.Warn("failed to render config", "error", err)
+		return
 	}
 
-	// Don't use more workers than repos
-	if numWorkers > len(repos) {
-		numWorkers = len(repos)
+	after := cfg.Clone()
+	for i := range cfg.Sources {
+		for _, fullName := range wouldAdd[&cfg.Sources[i]] {
+			after.Sources[i].Repos = append(after.Sources[i].Repos, config.RepoEntry{Name: fullName})
+		}
+	}
+	afterData, err := after.Render()
+	if err != nil {
+		ui.Warn("failed to render config", "error", err)
+		return
 	}
 
-	jobs := make(chan cloneJob, len(repos))
-	results := make(chan cloneResult, len(repos))
-
-	// Start progress spinner
-	progress := ui.NewProgress(len(repos), "Cloning repos")
+	if configPath == "" {
+		configPath = "config"
+	}
+	ui.PrintConfigDiff(configPath, before, afterData)
+}
 
-	// Start workers
-	var wg gosync.WaitGroup
-	for i := 0; i < numWorkers; i++ {
-		wg.Add(1)
-		go cloneWorker(jobs, results, &wg)
+// describePrune reads what pruning a repo would delete: its size on disk,
+// last commit, and uncommitted changes
+func describePrune(repo RepoStatus) ui.PruneDetails {
+	details := ui.PruneDetails{
+		Name: repo.Name,
+		Path: repo.LocalPath,
+		Size: dirSize(repo.LocalPath),
 	}
+	if t, err := git.LastCommitTime(repo.LocalPath); err == nil {
+		details.LastCommit = t
+	}
+	if dirty, err := git.IsDirty(repo.LocalPath); err == nil {
+		details.Dirty = dirty
+	}
+	return details
+}
 
-	// Send jobs
+// describePrunes describes each repo with describePrune, by local path
+func describePrunes(repos []RepoStatus) map[string]ui.PruneDetails {
+	details := make(map[string]ui.PruneDetails, len(repos))
 	for _, repo := range repos {
-		jobs <- cloneJob{status: repo, source: source}
+		details[repo.LocalPath] = describePrune(repo)
 	}
-	close(jobs)
+	return details
+}
 
-	// Wait for workers to finish, then close results
-	go func() {
-		wg.Wait()
-		close(results)
-	}()
+// pruneInteractive asks about each orphaned repo separately and deletes the
+// confirmed ones. Returns the number of repos removed.
+func pruneInteractive(source *config.Source, orphaned []RepoStatus, details map[string]ui.PruneDetails) int {
+	pruned := 0
+	for _, repo := range orphaned {
+		d, ok := details[repo.LocalPath]
+		if !ok {
+			d = describePrune(repo)
+		}
 
-	// Collect results
-	cloned := 0
-	var errors []cloneResult
-	for res := range results {
-		progress.Increment()
-		if res.success {
-			cloned++
-		} else {
-			errors = append(errors, res)
+		confirm, err := ui.ConfirmPruneRepo(d)
+		if err != nil {
+			ui.Error("failed to get confirmation", "error", err)
+			return pruned
+		}
+		if !confirm {
+			ui.Info("keeping", "repo", repo.Name)
+			continue
+		}
+		if pruneRepo(source, repo) {
+			pruned++
 		}
 	}
+	return pruned
+}
 
-	// Stop spinner before printing results
-	progress.Finish()
-
-	// Print results
-	for _, res := range errors {
-		ui.Error("failed to clone", "repo", res.name, "error", res.err)
-	}
-	if cloned > 0 {
-		ui.Info("cloned repos", "count", cloned)
+// pruneRepo moves an orphaned repo to the trash and reports whether it did
+func pruneRepo(source *config.Source, repo RepoStatus) bool {
+	ui.Info("removing", "repo", repo.Name)
+	if err := journal.Prune(source.Name, repo.Name, repo.LocalPath); err != nil {
+		ui.Error("failed to remove repo", "repo", repo.Name, "error", err)
+		return false
 	}
-
-	return cloned
+	emit(RepoPruned{Source: source.Name, Repo: repo.Name, Path: repo.LocalPath})
+	return true
 }
 
-func cloneWorker(jobs <-chan cloneJob, results chan<- cloneResult, wg *gosync.WaitGroup) {
-	defer wg.Done()
-	for job := range jobs {
-		err := git.Clone(git.CloneOptions{
-			URL:        job.source.GetRepoURL(job.status.FullName),
-			Path:       job.status.LocalPath,
-			Branch:     job.source.GetBranch(),
-			PrivateKey: job.source.GetPrivateKey(),
-			Submodules: job.source.SSHOptions.Submodules,
-		})
-		results <- cloneResult{
-			name:    job.status.FullName,
-			success: err == nil,
-			err:     err,
+// selectPending shows every repo about to be cloned or pruned, all checked,
+// and returns the statuses without deselected clones and the prunes still
+// selected. Prunes are labeled with their details when there are any.
+func selectPending(statuses []RepoStatus, prune []RepoStatus, details map[string]ui.PruneDetails) ([]RepoStatus, []RepoStatus, error) {
+	var actions []ui.PendingAction
+	for _, s := range statuses {
+		if s.Status == ui.StatusAdded {
+			actions = append(actions, ui.PendingAction{Label: "clone " + s.FullName, Key: "clone:" + s.LocalPath})
 		}
 	}
-}
-
-func scanLocalRepos(path string) (map[string]bool, error) {
-	repos := make(map[string]bool)
+	for _, s := range prune {
+		label := "prune " + s.Name
+		if d, ok := details[s.LocalPath]; ok {
+			label += " (" + d.Summary() + ")"
+		}
+		actions = append(actions, ui.PendingAction{Label: label, Key: "prune:" + s.LocalPath})
+	}
+	if len(actions) == 0 {
+		return statuses, prune, nil
+	}
 
-	entries, err := os.ReadDir(path)
+	selected, err := ui.SelectActions(actions)
 	if err != nil {
-		return repos, err
+		return nil, nil, err
 	}
 
-	for _, entry := range entries {
-		if !entry.IsDir() {
+	// Deselected clones are dropped from this run entirely
+	var keptStatuses []RepoStatus
+	for _, s := range statuses {
+		if s.Status == ui.StatusAdded && !selected["clone:"+s.LocalPath] {
+			ui.Info("skipping clone", "repo", s.FullName)
 			continue
 		}
+		keptStatuses = append(keptStatuses, s)
+	}
 
-		// Skip hidden directories
-		if strings.HasPrefix(entry.Name(), ".") {
-			continue
+	var keptPrune []RepoStatus
+	for _, s := range prune {
+		if selected["prune:"+s.LocalPath] {
+			keptPrune = append(keptPrune, s)
 		}
+	}
+
+	return keptStatuses, keptPrune, nil
+}
 
-		fullPath := filepath.Join(path, entry.Name())
-		if git.IsGitRepo(fullPath) {
-			repos[entry.Name()] = true
+// dropConflictingJobs keeps only the first clone job for each local path
+func dropConflictingJobs(jobs []cloneJob) ([]cloneJob, []cloneJob) {
+	claimed := make(map[string]cloneJob)
+	var kept, dropped []cloneJob
+	for _, job := range jobs {
+		path := filepath.Clean(job.status.LocalPath)
+		if first, ok := claimed[path]; ok {
+			ui.Warn("skipping repo, path is already used by another repo",
+				"repo", job.status.FullName, "source", job.source.Name,
+				"conflicts_with", first.status.FullName, "path", path)
+			dropped = append(dropped, job)
+			continue
 		}
+		claimed[path] = job
+		kept = append(kept, job)
 	}
+	return kept, dropped
+}
 
-	return repos, nil
+// cloneReposParallel clones repos from all sources using a single worker pool,
+// highest priority first, reporting each clone as soon as it's done.
+// Returns the successful clones, the failures, and the results of any pull
+// jobs among repos.
+func cloneReposParallel(ctx context.Context, repos []cloneJob, numWorkers int, stagger time.Duration) ([]cloneResult, []cloneResult, []pullResult) {
+	if numWorkers <= 0 {
+		numWorkers = 4
+	}
+
+	// Don't use more workers than repos
+	if numWorkers > len(repos) {
+		numWorkers = len(repos)
+	}
+
+	jobs := make(chan cloneJob, len(repos))
+	results := make(chan cloneResult, len(repos))
+
+	sortByPriority(repos)
+
+	message := "Cloning repos"
+	pulls := slices.IndexFunc(repos, func(job cloneJob) bool { return job.pull != nil })
+	clones := slices.IndexFunc(repos, func(job cloneJob) bool { return job.pull == nil })
+	switch {
+	case pulls >= 0 && clones >= 0:
+		message = "Cloning and pulling repos"
+	case pulls >= 0:
+		message = "Pulling repos"
+	}
+	ui.Group(message)
+	defer ui.EndGroup()
+
+	// Show what each worker is cloning
+	panel := ui.NewWorkerPanel(len(repos), numWorkers, message)
+
+	// Start workers
+	var wg gosync.WaitGroup
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go cloneWorker(ctx, i, jobs, results, &wg, panel)
+	}
+
+	// Send jobs
+	go staggerJobs(repos, jobs, stagger)
+
+	// Wait for workers to finish, then close results
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// Collect results, reporting successes right away so high priority
+	// repos don't wait for the rest of the queue
+	var cloned, errors []cloneResult
+	var pulled []pullResult
+	for res := range results {
+		if res.pull != nil {
+			panel.Increment(res.pull.job.name, res.err, res.duration)
+			if res.success {
+				emitPulled(*res.pull)
+			}
+			pulled = append(pulled, *res.pull)
+			continue
+		}
+		panel.Increment(res.job.status.FullName, res.err, res.duration)
+		if res.success {
+			cloned = append(cloned, res)
+			metrics.ClonesTotal.Inc(res.job.source.Name, "success")
+			journal.RecordClone(res.job.source.Name, res.job.status.FullName, res.job.status.LocalPath, res.job.source.GetRepoURL(res.job.status.FullName))
+			emit(RepoCloned{
+				Source:   res.job.source.Name,
+				Repo:     res.job.status.FullName,
+				Path:     res.job.status.LocalPath,
+				Duration: res.duration,
+			})
+		} else {
+			errors = append(errors, res)
+			metrics.ClonesTotal.Inc(res.job.source.Name, "failure")
+		}
+	}
+
+	// Remove the panel before printing results
+	panel.Finish()
+
+	if len(cloned) > 0 {
+		ui.Info("cloned repos", "count", len(cloned))
+	}
+
+	return cloned, errors, pulled
+}
+
+func cloneWorker(ctx context.Context, id int, jobs <-chan cloneJob, results chan<- cloneResult, wg *gosync.WaitGroup, panel *ui.WorkerPanel) {
+	defer wg.Done()
+	for job := range jobs {
+		if job.pull != nil {
+			var res pullResult
+			if err := ctx.Err(); err != nil {
+				res = pullResult{job: *job.pull, err: err}
+			} else {
+				panel.Start(id, job.pull.name)
+				res = pullRepo(*job.pull)
+				panel.Idle(id)
+			}
+			results <- cloneResult{job: job, success: res.success, err: res.err, duration: res.duration, pull: &res}
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			results <- cloneResult{job: job, err: err}
+			continue
+		}
+		start := time.Now()
+		panel.Start(id, job.status.FullName)
+		err := cloneRepo(job.source, job.status.FullName, job.status.LocalPath, job.source.GetBranch(), func(phase string, percent int) {
+			panel.Update(id, phase, percent)
+		})
+		if err == nil {
+			configureUpstream(job.source, job.status.FullName, job.status.LocalPath)
+		}
+		if err == nil && job.source.Metadata {
+			writeCloneMeta(job.source, job.status.FullName, job.status.LocalPath)
+		}
+		if err == nil && job.source.IncludeWikis {
+			if err := cloneWiki(job.source, job.source.GetRepoURL(job.status.FullName), job.status.LocalPath); err != nil {
+				ui.Warn("failed to clone wiki", "repo", job.status.FullName, "error", err)
+			}
+		}
+		panel.Idle(id)
+		results <- cloneResult{
+			job:      job,
+			success:  err == nil,
+			err:      err,
+			duration: time.Since(start),
+		}
+	}
+}
+
+// cloneRepo clones a repo of the source to path with the source's key,
+// credential helper, and submodule settings, then applies its git_config.
+// progress, if set, gets git's progress reports.
+func cloneRepo(source *config.Source, fullName, path, branch string, progress func(phase string, percent int)) error {
+	url := source.GetRepoURL(fullName)
+	err := git.Clone(git.CloneOptions{
+		URL:              url,
+		Path:             path,
+		Branch:           branch,
+		PrivateKey:       source.PrivateKeyFor(path),
+		CredentialHelper: source.CredHelper,
+		Submodules:       source.SSHOptions.Submodules,
+		Tags:             source.GetTags(),
+		KnownHostsFile:   knownHostsFor(source, url),
+		ShallowSince:     source.ShallowSince,
+		SingleBranch:     source.SingleBranch,
+		Sparse:           source.SubpathFor(path),
+		Progress:         progress,
+	})
+	if err != nil {
+		return err
+	}
+	if source.TrackAll {
+		if _, _, err := git.TrackAllBranches(path); err != nil {
+			return fmt.Errorf("cloned, but %w", err)
+		}
+	}
+	return applyGitConfig(source, path)
+}
+
+// buildRepoStatuses compares a source's configured repos with the clones in
+// its local_path. Clones are matched to config entries by directory name and,
+// failing that, by their origin URL, so a clone in a renamed directory still
+// counts as present instead of showing up as both missing and orphaned.
+func buildRepoStatuses(source *config.Source) ([]RepoStatus, error) {
+	localRepos, err := scanLocalRepos(source)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to scan local repos: %w", err)
+	}
+
+	// Index clones by origin so entries can find clones under other names
+	remotes := make(map[string]string)
+	byRemote := make(map[string]string)
+	for dir := range localRepos {
+		remote, err := git.GetRemoteURL(filepath.Join(source.LocalPath, dir))
+		if err != nil {
+			continue
+		}
+		remotes[dir] = remote
+		if key := remoteKey(remote); key != "" {
+			byRemote[key] = dir
+		}
+	}
+
+	// Directories claimed by a config entry; the rest are orphans.
+	// Name matches are claimed first so they can't be taken by a URL match.
+	claimed := make(map[string]bool)
+	for _, repo := range source.Repos {
+		if !repo.HasCustomLocalPath() && localRepos[source.RepoDir(repo.Name)] {
+			claimed[source.RepoDir(repo.Name)] = true
+		}
+		// Wikis belong to their repo's entry
+		if wiki := source.RepoDir(repo.Name) + wikiSuffix; source.IncludeWikis && localRepos[wiki] {
+			claimed[wiki] = true
+		}
+	}
+
+	var statuses []RepoStatus
+
+	// Add configured repos
+	for _, repo := range source.Repos {
+		repoName := repoNameFromFullName(repo.Name)
+		resolvedPath := source.RepoPath(repo)
+
+		var exists bool
+		if repo.HasCustomLocalPath() {
+			exists = git.IsGitRepo(resolvedPath)
+		} else {
+			// Owners with slashes (e.g. subgroups) nest deeper than the scan reaches
+			exists = localRepos[source.RepoDir(repo.Name)] || git.IsGitRepo(resolvedPath)
+			if !exists {
+				if dir, ok := byRemote[remoteKey(source.GetRepoURL(repo.Name))]; ok && !claimed[dir] {
+					ui.Debug("matched repo by origin URL", "repo", repo.Name, "dir", dir)
+					claimed[dir] = true
+					resolvedPath = filepath.Join(source.LocalPath, dir)
+					exists = true
+				}
+			}
+		}
+
+		// If path exists as a non-empty non-git directory (or as a file), warn and skip.
+		// Empty directories are fine — git clone handles them.
+		if !exists {
+			if info, statErr := os.Stat(resolvedPath); statErr == nil {
+				if !info.IsDir() {
+					ui.Warn("path already exists as a file, skipping", "repo", repo.Name, "path", resolvedPath)
+					exists = true
+				} else if entries, readErr := os.ReadDir(resolvedPath); readErr == nil && len(entries) > 0 {
+					ui.Warn("path already exists but is not a git repo, skipping", "repo", repo.Name, "path", resolvedPath)
+					exists = true
+				}
+			}
+		}
+
+		status := ui.StatusAdded
+		if exists {
+			status = ui.StatusUnchanged
+		}
+
+		statuses = append(statuses, RepoStatus{
+			Name:        repoName,
+			FullName:    repo.Name,
+			LocalPath:   resolvedPath,
+			Status:      status,
+			InConfig:    true,
+			ExistsLocal: exists,
+		})
+	}
+
+	// Add orphaned repos (in local but not in config)
+	for repoName := range localRepos {
+		if claimed[repoName] {
+			continue
+		}
+		statuses = append(statuses, RepoStatus{
+			Name:        repoName,
+			FullName:    fullNameFromURL(remotes[repoName]),
+			LocalPath:   filepath.Join(source.LocalPath, repoName),
+			Status:      ui.StatusRemoved,
+			InConfig:    false,
+			ExistsLocal: true,
+		})
+	}
+
+	return statuses, nil
+}
+
+// scanLocalRepos finds git repos up to the source's scan depth below its
+// local_path and returns their paths relative to it, e.g. "repo" or
+// "owner/repo". Ignored directories are skipped, and the scan does not descend
+// into repos, so submodules and nested checkouts are not reported.
+func scanLocalRepos(source *config.Source) (map[string]bool, error) {
+	repos := make(map[string]bool)
+	err := scanDir(source.LocalPath, "", source.GetScanDepth(), loadIgnore(source), repos)
+	return repos, err
+}
+
+func scanDir(root, rel string, depth int, ignore *ignoreList, repos map[string]bool) error {
+	entries, err := os.ReadDir(filepath.Join(root, rel))
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		// Skip hidden directories
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		name := path.Join(rel, entry.Name())
+		if ignore.Match(name) {
+			ui.Debug("ignoring directory", "path", name)
+			continue
+		}
+
+		if git.IsGitRepo(filepath.Join(root, name)) {
+			repos[filepath.FromSlash(name)] = true
+			continue
+		}
+		if depth > 1 {
+			_ = scanDir(root, name, depth-1, ignore, repos)
+		}
+	}
+
+	return nil
+}
+
+// localRepo is a git repo on disk that belongs to a source
+type localRepo struct {
+	name     string
+	fullName string // "user/repo" from config, or derived from the directory for scanned repos
+	path     string
+}
+
+// listLocalRepos returns all repos of a source that exist on disk: repos found
+// in the source's local_path plus configured repos with a custom local_path.
+func listLocalRepos(source *config.Source) []localRepo {
+	var repos []localRepo
+
+	// Scan local directory for repos in source.LocalPath
+	if _, err := os.Stat(source.LocalPath); !os.IsNotExist(err) {
+		localRepos, err := scanLocalRepos(source)
+		if err != nil {
+			ui.Warn("failed to scan local repos", "source", source.Name, "error", err)
+		} else {
+			for repoName := range localRepos {
+				// Nested layouts end in <owner>/<repo>
+				fullName := filepath.ToSlash(repoName)
+				if parts := strings.Split(fullName, "/"); len(parts) > 2 {
+					fullName = strings.Join(parts[len(parts)-2:], "/")
+				}
+				repos = append(repos, localRepo{
+					name:     repoName,
+					fullName: fullName,
+					path:     filepath.Join(source.LocalPath, repoName),
+				})
+			}
+			ui.Debug("found local repos", "source", source.Name, "count", len(localRepos))
+		}
+	} else {
+		ui.Warn("source directory does not exist", "source", source.Name, "path", source.LocalPath)
+	}
+
+	// Add repos with custom local_path that exist locally
+	for _, repo := range source.Repos {
+		if repo.HasCustomLocalPath() {
+			resolvedPath := source.RepoPath(repo)
+			if git.IsGitRepo(resolvedPath) {
+				repos = append(repos, localRepo{
+					name:     repoNameFromFullName(repo.Name),
+					fullName: repo.Name,
+					path:     resolvedPath,
+				})
+			}
+		}
+	}
+
+	return repos
 }
 
 func repoNameFromFullName(fullName string) string {
-	parts := strings.Split(fullName, "/")
-	if len(parts) > 0 {
-		return parts[len(parts)-1]
+	name := strings.TrimSuffix(fullName, ".git")
+	if idx := strings.LastIndexAny(name, "/:"); idx != -1 {
+		return name[idx+1:]
 	}
-	return fullName
+	return name
 }
 
 func guessFullName(source, repoName string) string {
 	// Try to extract the default user/org from the source
 	// e.g., github.com/arch-err -> arch-err/repoName
 	parts := strings.Split(source, "/")
-	if len(parts) >= 2 {
+	if len(parts) >= 2 && parts[len(parts)-1] != "*" {
 		user := parts[len(parts)-1]
 		return user + "/" + repoName
 	}
 	return repoName
 }
 
+// orphanFullName returns the config entry name for an orphaned repo, taken
+// from its origin URL when it has one
+func orphanFullName(source *config.Source, repo RepoStatus) string {
+	if repo.FullName != "" {
+		return repo.FullName
+	}
+	return guessFullName(source.Source, repo.Name)
+}
+
 func getOrphanedRepos(statuses []RepoStatus) []RepoStatus {
 	var orphaned []RepoStatus
 	for _, s := range statuses {
@@ -521,13 +1382,16 @@ func getOrphanedRepos(statuses []RepoStatus) []RepoStatus {
 
 // ComputeSourceStatus computes the status of repos for a single source
 // without performing any actions. Returns the list of repo statuses.
-func ComputeSourceStatus(source *config.Source) ([]RepoStatus, error) {
+func ComputeSourceStatus(source *config.Source, opts StatusOptions) ([]RepoStatus, error) {
 	// Load credentials from credentials.env if it exists
 	credPath := connector.DefaultCredentialsPath()
 	if err := connector.LoadCredentialsEnv(credPath); err != nil {
 		ui.Debug("failed to load credentials file", "error", err)
 	}
 
+	// Upstream repo list, kept for the remote comparison
+	var remote []string
+
 	// Handle strategy-specific logic
 	switch source.Strategy {
 	case config.StrategyManual:
@@ -538,6 +1402,7 @@ func ComputeSourceStatus(source *config.Source) ([]RepoStatus, error) {
 			return nil, fmt.Errorf("failed to fetch repos: %w", err)
 		}
 		source.Repos = config.RepoEntriesFromNames(repos)
+		remote = repos
 	case config.StrategyRegex:
 		repos, err := fetchReposFromAPI(source)
 		if err != nil {
@@ -548,92 +1413,81 @@ func ComputeSourceStatus(source *config.Source) ([]RepoStatus, error) {
 			return nil, fmt.Errorf("invalid regex pattern: %w", err)
 		}
 		source.Repos = config.RepoEntriesFromNames(filtered)
+		remote = repos
+	case config.StrategyStatic:
+		repos, err := config.ReadStaticList(source.StaticStrategy.List)
+		if err != nil {
+			return nil, err
+		}
+		source.Repos = config.RepoEntriesFromNames(repos)
 	case config.StrategyFile:
 		return nil, fmt.Errorf("file strategy not yet supported")
 	default:
 		return nil, fmt.Errorf("unknown strategy: %s", source.Strategy)
 	}
 
-	// Build configured repos map — only repos without custom LocalPath participate in orphan detection
-	configuredRepos := make(map[string]bool)
-	for _, repo := range source.Repos {
-		if !repo.HasCustomLocalPath() {
-			repoName := repoNameFromFullName(repo.Name)
-			configuredRepos[repoName] = true
-		}
+	statuses, err := buildRepoStatuses(source)
+	if err != nil {
+		return nil, err
 	}
 
-	// Scan local directory
-	localRepos, err := scanLocalRepos(source.LocalPath)
-	if err != nil && !os.IsNotExist(err) {
-		return nil, fmt.Errorf("failed to scan local repos: %w", err)
+	if opts.Deep {
+		inspectRepos(source, statuses)
 	}
 
-	// Build status list
-	var statuses []RepoStatus
+	if opts.Remote {
+		statuses = compareUpstream(source, statuses, remote)
+	}
 
-	// Add configured repos
-	for _, repo := range source.Repos {
-		repoName := repoNameFromFullName(repo.Name)
-		resolvedPath := repo.ResolvedLocalPath(source.LocalPath)
+	return statuses, nil
+}
 
-		var exists bool
-		if repo.HasCustomLocalPath() {
-			exists = git.IsGitRepo(resolvedPath)
-		} else {
-			exists = localRepos[repoName]
+// inspectRepos fills in dirty/ahead/behind/remote state for repos that exist locally
+func inspectRepos(source *config.Source, statuses []RepoStatus) {
+	for i := range statuses {
+		s := &statuses[i]
+		if !s.ExistsLocal || !git.IsGitRepo(s.LocalPath) {
+			continue
 		}
 
-		// If path exists as a non-empty non-git directory (or as a file), warn and skip.
-		// Empty directories are fine — git clone handles them.
-		if !exists {
-			if info, statErr := os.Stat(resolvedPath); statErr == nil {
-				if !info.IsDir() {
-					ui.Warn("path already exists as a file, skipping", "repo", repo.Name, "path", resolvedPath)
-					exists = true
-				} else if entries, readErr := os.ReadDir(resolvedPath); readErr == nil && len(entries) > 0 {
-					ui.Warn("path already exists but is not a git repo, skipping", "repo", repo.Name, "path", resolvedPath)
-					exists = true
-				}
-			}
-		}
+		_, _, s.RemoteMismatch = checkRemote(source, *s)
 
-		status := ui.StatusAdded
-		if exists {
-			status = ui.StatusUnchanged
+		dirty, err := git.IsDirty(s.LocalPath)
+		if err != nil {
+			ui.Debug("failed to check working tree", "repo", s.Name, "error", err)
 		}
+		s.Dirty = dirty
 
-		statuses = append(statuses, RepoStatus{
-			Name:        repoName,
-			FullName:    repo.Name,
-			LocalPath:   resolvedPath,
-			Status:      status,
-			InConfig:    true,
-			ExistsLocal: exists,
-		})
-	}
-
-	// Add orphaned repos (in local but not in config)
-	for repoName := range localRepos {
-		if !configuredRepos[repoName] {
-			localPath := filepath.Join(source.LocalPath, repoName)
-			statuses = append(statuses, RepoStatus{
-				Name:        repoName,
-				LocalPath:   localPath,
-				Status:      ui.StatusRemoved,
-				InConfig:    false,
-				ExistsLocal: true,
-			})
+		ahead, behind, err := git.AheadBehind(s.LocalPath)
+		if err != nil {
+			// No upstream configured (e.g. detached HEAD) - not an error worth surfacing
+			ui.Debug("failed to compare with upstream", "repo", s.Name, "error", err)
+			continue
 		}
+		s.Ahead = ahead
+		s.Behind = behind
 	}
-
-	return statuses, nil
 }
 
 // PullOptions contains options for the pull command
 type PullOptions struct {
 	Force bool
 	Jobs  int
+	// NonInteractive never prompts; repos whose default branch changed
+	// upstream are reported instead of switched
+	NonInteractive bool
+	// IncludeUpstream also fetches the "upstream" remote of forks
+	IncludeUpstream bool
+	// PruneRefs drops remote-tracking branches and tags deleted on the
+	// remote, for every source rather than just those with prune_refs
+	PruneRefs bool
+	// RetryFailed only pulls the repos that failed in the last pull
+	RetryFailed bool
+	// Repair replaces clones whose pull failed on a corrupted repository
+	// with fresh clones without asking
+	Repair bool
+	// ConfigPath identifies the config failed pulls are recorded for
+	ConfigPath string
 }
 
 // PullResult contains the results of a pull operation
@@ -641,24 +1495,42 @@ type PullResult struct {
 	Updated int
 	Failed  int
 	Skipped int
+	// Digests lists the new commits of each repo the pull changed, by name
+	Digests []ui.PullDigest
+	// Failures lists the repos that failed to pull
+	Failures []SyncFailure
+	// Sources summarizes each source, in the order they were pulled
+	Sources  []SourceSummary
+	Duration time.Duration
 }
 
 type pullJob struct {
 	path       string
 	name       string
+	fullName   string
 	privateKey string
+	knownHosts string // pinned known_hosts for origin, "" to use the user's
 	submodules bool
+	mirrorURL  string
+	upstream   bool
+	pruneRefs  bool
+	source     *config.Source
 }
 
 type pullResult struct {
-	name    string
-	success bool
-	err     error
+	job      pullJob
+	success  bool
+	err      error
+	digest   *ui.PullDigest // set when the pull brought in new commits
+	duration time.Duration
 }
 
-// RunPull pulls all repos for all configured sources
-func RunPull(cfg *config.Config, opts PullOptions) (*PullResult, error) {
+// RunPull pulls all repos for all configured sources. Canceling ctx stops
+// starting pulls; pulls already running finish.
+func RunPull(ctx context.Context, cfg *config.Config, opts PullOptions) (*PullResult, error) {
 	result := &PullResult{}
+	start := time.Now()
+	defer func() { result.Duration = time.Since(start) }()
 
 	// Load credentials from credentials.env if it exists
 	credPath := connector.DefaultCredentialsPath()
@@ -666,45 +1538,31 @@ func RunPull(cfg *config.Config, opts PullOptions) (*PullResult, error) {
 		ui.Debug("failed to load credentials file", "error", err)
 	}
 
+	history := startHistory("pull", opts.ConfigPath)
+	defer func() { history.finishPull(result) }()
+
 	var allJobs []pullJob
 
 	for i := range cfg.Sources {
 		source := &cfg.Sources[i]
 
-		// Scan local directory for repos in source.LocalPath
-		if _, err := os.Stat(source.LocalPath); !os.IsNotExist(err) {
-			localRepos, err := scanLocalRepos(source.LocalPath)
-			if err != nil {
-				ui.Warn("failed to scan local repos", "source", source.Name, "error", err)
-			} else {
-				for repoName := range localRepos {
-					repoPath := filepath.Join(source.LocalPath, repoName)
-					allJobs = append(allJobs, pullJob{
-						path:       repoPath,
-						name:       repoName,
-						privateKey: source.GetPrivateKey(),
-						submodules: source.SSHOptions.Submodules,
-					})
-				}
-				ui.Info("found repos to pull", "source", source.Name, "count", len(localRepos))
-			}
-		} else {
-			ui.Warn("source directory does not exist", "source", source.Name, "path", source.LocalPath)
-		}
-
-		// Add pull jobs for repos with custom local_path that exist locally
-		for _, repo := range source.Repos {
-			if repo.HasCustomLocalPath() {
-				resolvedPath := repo.ResolvedLocalPath(source.LocalPath)
-				if git.IsGitRepo(resolvedPath) {
-					allJobs = append(allJobs, pullJob{
-						path:       resolvedPath,
-						name:       repoNameFromFullName(repo.Name),
-						privateKey: source.GetPrivateKey(),
-						submodules: source.SSHOptions.Submodules,
-					})
-				}
-			}
+		jobs := pullJobsFor(source, opts)
+		if len(jobs) > 0 {
+			ui.Info("found repos to pull", "source", source.Name, "count", len(jobs))
+		}
+		allJobs = append(allJobs, jobs...)
+	}
+
+	if opts.RetryFailed {
+		allJobs = onlyFailedPulls(opts.ConfigPath, allJobs)
+	}
+
+	if cfg.Pull.OnlyDefaultBranch {
+		var skipped []pullJob
+		allJobs, skipped = skipOffDefaultBranch(allJobs)
+		for _, job := range skipped {
+			result.Skipped++
+			summaryFor(&result.Sources, job.source.Name).Skipped++
 		}
 	}
 
@@ -713,15 +1571,171 @@ func RunPull(cfg *config.Config, opts PullOptions) (*PullResult, error) {
 		return result, nil
 	}
 
+	allJobs, hostKeyFailures := verifyPullHostKeys(allJobs)
+
 	// Pull repos in parallel
-	updated, failed := pullReposParallel(allJobs, opts.Jobs)
-	result.Updated = updated
-	result.Failed = failed
+	pulled, failures := pullReposParallel(ctx, allJobs, limitBandwidth(cfg, opts.Jobs, len(allJobs)), cfg.GetJobStagger())
+	pulled, remaining := settlePulls(pulled, append(hostKeyFailures, failures...), opts)
+
+	for _, res := range remaining {
+		result.Failures = append(result.Failures, SyncFailure{Source: res.job.source.Name, Repo: res.job.name, Path: res.job.path, Err: res.err})
+	}
+
+	result.Updated = len(pulled)
+	result.Failed = len(remaining)
+	result.Digests = pullDigests(pulled)
+	result.tallyPulls(pulled)
+	result.tallyPulls(remaining)
+
+	recordFailedPulls(opts.ConfigPath, remaining)
 
 	return result, nil
 }
 
-func pullReposParallel(jobs []pullJob, numWorkers int) (int, int) {
+// pullJobsFor returns a pull job for each clone in the source's local_path
+func pullJobsFor(source *config.Source, opts PullOptions) []pullJob {
+	var jobs []pullJob
+	for _, repo := range listLocalRepos(source) {
+		jobs = append(jobs, pullJob{
+			path:       repo.path,
+			name:       repo.name,
+			fullName:   repo.fullName,
+			privateKey: source.PrivateKeyFor(repo.path),
+			knownHosts: remoteKnownHosts(source, repo.path, "origin"),
+			submodules: source.SSHOptions.Submodules,
+			mirrorURL:  source.GetMirrorURL(repo.fullName),
+			upstream:   opts.IncludeUpstream && git.HasRemote(repo.path, "upstream"),
+			pruneRefs:  opts.PruneRefs || source.PruneRefs,
+			source:     source,
+		})
+	}
+	return jobs
+}
+
+// settlePulls retries the failed pulls that a default branch change or a
+// corrupted clone explains, and emits an event for each retried and each
+// remaining failed pull; the worker pool already reported the others.
+// Returns the successful pulls and the remaining failures.
+func settlePulls(pulled, failures []pullResult, opts PullOptions) ([]pullResult, []pullResult) {
+	// A pull fails when the tracked branch was removed upstream, which is
+	// what happens when the default branch is renamed (e.g. master -> main).
+	// Failures git already explained, like a rejected key, aren't that.
+	var remaining []pullResult
+	for _, res := range failures {
+		if errs.Kind(res.err) == "" && retryAfterBranchChange(res.job, opts) {
+			res.success, res.err = true, nil
+			pulled = append(pulled, res)
+			emitPulled(res)
+			continue
+		}
+		if errs.Kind(res.err) == errs.KindCorrupt && repairCorrupt(res.job, opts) {
+			res.success, res.err = true, nil
+			pulled = append(pulled, res)
+			emitPulled(res)
+			continue
+		}
+		remaining = append(remaining, res)
+	}
+
+	for _, res := range remaining {
+		emit(RepoPullFailed{Source: res.job.source.Name, Repo: res.job.name, Path: res.job.path, Err: res.err})
+	}
+	if len(pulled) > 0 {
+		ui.Info("pulled repos", "count", len(pulled))
+	}
+	return pulled, remaining
+}
+
+// emitPulled reports a successful pull
+func emitPulled(res pullResult) {
+	commits := 0
+	if res.digest != nil {
+		commits = len(res.digest.Commits)
+	}
+	emit(RepoPulled{Source: res.job.source.Name, Repo: res.job.name, Path: res.job.path, Commits: commits, Duration: res.duration})
+}
+
+// pullDigest summarizes the commits between the HEAD before a pull and the
+// current one. Returns nil when HEAD didn't move.
+func pullDigest(job pullJob, before string) *ui.PullDigest {
+	after, err := git.HeadCommit(job.path)
+	if err != nil || after == before {
+		return nil
+	}
+	commits, err := git.CommitsBetween(job.path, before, after)
+	if err != nil {
+		ui.Debug("failed to list pulled commits", "repo", job.name, "error", err)
+		return nil
+	}
+	if len(commits) == 0 {
+		return nil
+	}
+
+	digest := &ui.PullDigest{Name: job.name}
+	seen := make(map[string]bool)
+	for _, commit := range commits {
+		digest.Commits = append(digest.Commits, commit.SHA+" "+commit.Subject)
+		if !seen[commit.Author] {
+			seen[commit.Author] = true
+			digest.Authors = append(digest.Authors, commit.Author)
+		}
+	}
+	return digest
+}
+
+// retryAfterBranchChange checks whether a failed pull was caused by an
+// upstream default branch change and, if the user agrees, switches the
+// clone to the new branch and pulls again. Returns whether the retry succeeded.
+func retryAfterBranchChange(job pullJob, opts PullOptions) bool {
+	repo := localRepo{name: job.name, path: job.path}
+	resolver, _ := newSourceConnector(job.source).(connector.BranchResolver)
+
+	change, err := detectBranchChange(job.source, resolver, repo)
+	if err != nil || change == nil {
+		return false
+	}
+
+	if opts.NonInteractive {
+		ui.Warn("default branch changed upstream (run ag fix-branches)", "repo", job.name, "from", change.from, "to", change.to)
+		return false
+	}
+
+	switched, err := applyBranchChange(job.source, *change, opts.Force, false)
+	if err != nil {
+		ui.Error("failed to switch branch", "repo", job.name, "error", err)
+		return false
+	}
+	if !switched {
+		return false
+	}
+
+	err = git.Pull(git.PullOptions{
+		Path:             job.path,
+		PrivateKey:       job.privateKey,
+		CredentialHelper: job.source.CredHelper,
+		Submodules:       job.submodules,
+		PruneRefs:        job.pruneRefs,
+		Tags:             job.source.GetTags(),
+		KnownHostsFile:   job.knownHosts,
+	})
+	if err != nil {
+		return false
+	}
+	if job.mirrorURL != "" {
+		err = git.PushMirror(git.PushMirrorOptions{
+			Path:           job.path,
+			URL:            job.mirrorURL,
+			PrivateKey:     job.source.MirrorOptions.PrivateKey,
+			KnownHostsFile: knownHostsFor(job.source, job.mirrorURL),
+		})
+	}
+	return err == nil
+}
+
+// pullReposParallel pulls all jobs, highest priority first, reporting each
+// successful pull as soon as it's done. Returns the successful pulls and the
+// failures.
+func pullReposParallel(ctx context.Context, jobs []pullJob, numWorkers int, stagger time.Duration) ([]pullResult, []pullResult) {
 	if numWorkers <= 0 {
 		numWorkers = 4
 	}
@@ -731,9 +1745,14 @@ func pullReposParallel(jobs []pullJob, numWorkers int) (int, int) {
 		numWorkers = len(jobs)
 	}
 
+	sortByPriority(jobs)
+
 	jobsChan := make(chan pullJob, len(jobs))
 	results := make(chan pullResult, len(jobs))
 
+	ui.Group("Pulling repos")
+	defer ui.EndGroup()
+
 	// Start progress spinner
 	progress := ui.NewProgress(len(jobs), "Pulling repos")
 
@@ -741,14 +1760,11 @@ func pullReposParallel(jobs []pullJob, numWorkers int) (int, int) {
 	var wg gosync.WaitGroup
 	for i := 0; i < numWorkers; i++ {
 		wg.Add(1)
-		go pullWorker(jobsChan, results, &wg)
+		go pullWorker(ctx, jobsChan, results, &wg)
 	}
 
 	// Send jobs
-	for _, job := range jobs {
-		jobsChan <- job
-	}
-	close(jobsChan)
+	go staggerJobs(jobs, jobsChan, stagger)
 
 	// Wait for workers to finish, then close results
 	go func() {
@@ -757,45 +1773,99 @@ func pullReposParallel(jobs []pullJob, numWorkers int) (int, int) {
 	}()
 
 	// Collect results
-	updated := 0
-	failed := 0
-	var errors []pullResult
+	var pulled, failures []pullResult
 	for res := range results {
-		progress.Increment()
+		progress.Increment(res.job.name, res.err, res.duration)
 		if res.success {
-			updated++
+			pulled = append(pulled, res)
+			emitPulled(res)
 		} else {
-			failed++
-			errors = append(errors, res)
+			failures = append(failures, res)
 		}
 	}
 
-	// Stop spinner before printing results
 	progress.Finish()
 
-	// Print errors
-	for _, res := range errors {
-		ui.Error("failed to pull", "repo", res.name, "error", res.err)
-	}
-	if updated > 0 {
-		ui.Info("pulled repos", "count", updated)
-	}
+	return pulled, failures
+}
 
-	return updated, failed
+// pullDigests returns the digests of the pulls that brought in new commits,
+// sorted by repo name
+func pullDigests(pulled []pullResult) []ui.PullDigest {
+	var digests []ui.PullDigest
+	for _, res := range pulled {
+		if res.digest != nil {
+			digests = append(digests, *res.digest)
+		}
+	}
+	sort.Slice(digests, func(i, j int) bool { return digests[i].Name < digests[j].Name })
+	return digests
 }
 
-func pullWorker(jobs <-chan pullJob, results chan<- pullResult, wg *gosync.WaitGroup) {
+func pullWorker(ctx context.Context, jobs <-chan pullJob, results chan<- pullResult, wg *gosync.WaitGroup) {
 	defer wg.Done()
 	for job := range jobs {
-		err := git.Pull(git.PullOptions{
-			Path:       job.path,
-			PrivateKey: job.privateKey,
-			Submodules: job.submodules,
-		})
-		results <- pullResult{
-			name:    job.name,
-			success: err == nil,
-			err:     err,
+		if err := ctx.Err(); err != nil {
+			results <- pullResult{job: job, err: err}
+			continue
 		}
+		results <- pullRepo(job)
+	}
+}
+
+// pullRepo pulls a clone, then fetches its upstream remote and pushes to
+// its mirror as configured
+func pullRepo(job pullJob) pullResult {
+	start := time.Now()
+	before, _ := git.HeadCommit(job.path)
+	err := git.Pull(git.PullOptions{
+		Path:             job.path,
+		PrivateKey:       job.privateKey,
+		CredentialHelper: job.source.CredHelper,
+		Submodules:       job.submodules,
+		PruneRefs:        job.pruneRefs,
+		Tags:             job.source.GetTags(),
+		KnownHostsFile:   job.knownHosts,
+	})
+	metrics.PullDuration.ObserveDuration(start)
+	if err == nil {
+		metrics.PullsTotal.Inc("success")
+	} else {
+		metrics.PullsTotal.Inc("failure")
+	}
+	if err == nil && job.source.TrackAll {
+		_, _, err = git.TrackAllBranches(job.path)
+	}
+	if err == nil && job.upstream {
+		err = git.Fetch(git.FetchOptions{
+			Path:             job.path,
+			Remote:           "upstream",
+			PrivateKey:       job.privateKey,
+			CredentialHelper: job.source.CredHelper,
+			KnownHostsFile:   remoteKnownHosts(job.source, job.path, "upstream"),
+		})
+	}
+	// Replicate to the secondary remote only after a successful pull
+	if err == nil && job.mirrorURL != "" {
+		err = git.PushMirror(git.PushMirrorOptions{
+			Path:           job.path,
+			URL:            job.mirrorURL,
+			PrivateKey:     job.source.MirrorOptions.PrivateKey,
+			KnownHostsFile: knownHostsFor(job.source, job.mirrorURL),
+		})
+	}
+	if err == nil {
+		cloneMissingWiki(job)
+	}
+	var digest *ui.PullDigest
+	if err == nil && before != "" {
+		digest = pullDigest(job, before)
+	}
+	return pullResult{
+		job:      job,
+		success:  err == nil,
+		err:      err,
+		digest:   digest,
+		duration: time.Since(start),
 	}
 }
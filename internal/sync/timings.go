@@ -0,0 +1,45 @@
+package sync
+
+import (
+	"sort"
+	"time"
+)
+
+// Timings breaks a sync down into the phases it spent its time in, for
+// tuning jobs and clone depth
+type Timings struct {
+	Phases []PhaseTiming // in the order they ran
+	Clones []CloneTiming // slowest first
+}
+
+// PhaseTiming is how long one phase of a sync took, e.g. listing a source
+type PhaseTiming struct {
+	Name     string
+	Duration time.Duration
+}
+
+// CloneTiming is how long one repo took to clone
+type CloneTiming struct {
+	Repo     string
+	Source   string
+	Duration time.Duration
+}
+
+// phase records a phase that started at start and just ended
+func (t *Timings) phase(name string, start time.Time) {
+	t.Phases = append(t.Phases, PhaseTiming{Name: name, Duration: time.Since(start)})
+}
+
+// addClones records the time each clone took, keeping them slowest first
+func (t *Timings) addClones(results []cloneResult) {
+	for _, res := range results {
+		t.Clones = append(t.Clones, CloneTiming{
+			Repo:     res.job.status.FullName,
+			Source:   res.job.source.Name,
+			Duration: res.duration,
+		})
+	}
+	sort.SliceStable(t.Clones, func(i, j int) bool {
+		return t.Clones[i].Duration > t.Clones[j].Duration
+	})
+}
@@ -0,0 +1,82 @@
+package sync
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/arch-err/autogitter/internal/config"
+	"github.com/arch-err/autogitter/internal/ui"
+)
+
+// compareUpstream checks statuses against the repos that currently exist on the
+// provider. Configured and orphaned repos that no longer exist are flagged
+// GoneUpstream, and for manual sources, upstream repos missing from the config
+// are appended with StatusUpstream.
+//
+// remote is the repo list already fetched for all/regex sources; when nil,
+// the provider is queried here.
+func compareUpstream(source *config.Source, statuses []RepoStatus, remote []string) []RepoStatus {
+	if source.Strategy == config.StrategyStatic {
+		return statuses
+	}
+
+	if remote == nil {
+		var err error
+		remote, err = fetchReposFromAPI(source)
+		if err != nil {
+			ui.Warn("skipping remote comparison", "source", source.Name, "error", err)
+			return statuses
+		}
+	}
+
+	exists := make(map[string]bool, len(remote))
+	for _, name := range remote {
+		exists[strings.ToLower(name)] = true
+	}
+
+	// The listing only covers the source's own user/org, so repos of other
+	// owners are looked up one by one
+	resolver := newRepoResolver(source)
+	gone := func(fullName string) bool {
+		if exists[strings.ToLower(fullName)] {
+			return false
+		}
+		if resolver != nil {
+			if _, err := resolver.ResolveRepo(context.Background(), fullName); err == nil {
+				return false
+			}
+		}
+		return true
+	}
+
+	known := make(map[string]bool)
+	for i := range statuses {
+		s := &statuses[i]
+		if s.FullName == "" || config.IsCloneURL(s.FullName) {
+			continue
+		}
+		known[strings.ToLower(s.FullName)] = true
+		s.GoneUpstream = gone(s.FullName)
+	}
+
+	// all/regex sources already list every upstream repo the strategy picks up
+	if source.Strategy != config.StrategyManual {
+		return statuses
+	}
+
+	var upstream []RepoStatus
+	for _, name := range remote {
+		if known[strings.ToLower(name)] {
+			continue
+		}
+		upstream = append(upstream, RepoStatus{
+			Name:     repoNameFromFullName(name),
+			FullName: name,
+			Status:   ui.StatusUpstream,
+		})
+	}
+	sort.Slice(upstream, func(a, b int) bool { return upstream[a].Name < upstream[b].Name })
+
+	return append(statuses, upstream...)
+}
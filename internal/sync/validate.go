@@ -0,0 +1,119 @@
+package sync
+
+import (
+	"context"
+	"strings"
+
+	"github.com/arch-err/autogitter/internal/config"
+	"github.com/arch-err/autogitter/internal/connector"
+	"github.com/arch-err/autogitter/internal/errs"
+	"github.com/arch-err/autogitter/internal/ui"
+)
+
+// RemoteProblem is a configured repo that doesn't match the provider
+type RemoteProblem struct {
+	Source  string
+	Repo    string
+	Problem string
+}
+
+// ValidateRemote checks each repo of the manual sources against the
+// provider API, reporting repos that were renamed and repos that don't exist
+// or that the token can't see, with the closest match as a likely typo.
+// Sources without a token or a provider that can look up repos are skipped.
+func ValidateRemote(cfg *config.Config) []RemoteProblem {
+	credPath := connector.DefaultCredentialsPath()
+	if err := connector.LoadCredentialsEnv(credPath); err != nil {
+		ui.Debug("failed to load credentials file", "error", err)
+	}
+
+	var problems []RemoteProblem
+	for i := range cfg.Sources {
+		source := &cfg.Sources[i]
+		if source.Strategy != config.StrategyManual {
+			continue
+		}
+
+		resolver := newRepoResolver(source)
+		if resolver == nil {
+			ui.Warn("can't check repos without a token and provider API", "source", source.Name)
+			continue
+		}
+
+		// Listed once, on the first missing repo, to suggest the intended name
+		var listed []string
+		listOnce := func() []string {
+			if listed == nil {
+				listed, _ = fetchReposFromAPI(source)
+				if listed == nil {
+					listed = []string{}
+				}
+			}
+			return listed
+		}
+
+		checked := 0
+		for _, repo := range source.Repos {
+			if config.IsCloneURL(repo.Name) {
+				continue
+			}
+			checked++
+
+			problem := RemoteProblem{Source: source.Name, Repo: repo.Name}
+			resolved, err := resolver.ResolveRepo(context.Background(), repo.Name)
+			switch {
+			case errs.Kind(err) == errs.KindNotFound:
+				problem.Problem = "not found, or the token can't see it"
+				if match := closestName(repo.Name, listOnce()); match != "" {
+					problem.Problem += "; did you mean " + match + "?"
+				}
+			case errs.Kind(err) == errs.KindAuth:
+				problem.Problem = "token rejected by the provider"
+			case err != nil:
+				problem.Problem = "lookup failed: " + err.Error()
+			case !strings.EqualFold(resolved, repo.Name):
+				problem.Problem = "renamed to " + resolved
+			default:
+				continue
+			}
+			problems = append(problems, problem)
+		}
+		ui.Debug("checked repos against provider", "source", source.Name, "count", checked)
+	}
+
+	return problems
+}
+
+// closestName returns the candidate within a small edit distance of name,
+// or "" when none is close enough to be a likely typo
+func closestName(name string, candidates []string) string {
+	name = strings.ToLower(name)
+	best, bestDist := "", max(2, len(name)/5)+1
+	for _, candidate := range candidates {
+		if d := editDistance(name, strings.ToLower(candidate)); d < bestDist {
+			best, bestDist = candidate, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
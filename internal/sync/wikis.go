@@ -0,0 +1,68 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/arch-err/autogitter/internal/config"
+	"github.com/arch-err/autogitter/internal/git"
+	"github.com/arch-err/autogitter/internal/journal"
+	"github.com/arch-err/autogitter/internal/ui"
+)
+
+// wikiSuffix ends the directory a repo's wiki is cloned into, next to the
+// repo's own clone
+const wikiSuffix = ".wiki"
+
+// wikiURL returns the URL of a repo's wiki, which GitHub and Gitea serve as
+// a separate repo named <repo>.wiki
+func wikiURL(repoURL string) string {
+	return strings.TrimSuffix(repoURL, ".git") + wikiSuffix + ".git"
+}
+
+// cloneWiki clones the wiki of the repo at repoURL next to its clone at
+// path. Repos without a wiki, whose wiki repo doesn't exist until its first
+// page is written, are skipped quietly.
+func cloneWiki(source *config.Source, repoURL, path string) error {
+	url := wikiURL(repoURL)
+	privateKey := source.PrivateKeyFor(path)
+	exists, err := git.RemoteExists(url, privateKey, knownHostsFor(source, url), source.CredHelper)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		ui.Debug("repo has no wiki", "path", path)
+		return nil
+	}
+
+	err = git.Clone(git.CloneOptions{
+		URL:              url,
+		Path:             path + wikiSuffix,
+		PrivateKey:       privateKey,
+		CredentialHelper: source.CredHelper,
+		KnownHostsFile:   knownHostsFor(source, url),
+	})
+	if err == nil {
+		journal.RecordClone(source.Name, filepath.Base(path)+wikiSuffix, path+wikiSuffix, url)
+	}
+	return err
+}
+
+// cloneMissingWiki clones the wiki of a pulled repo when it isn't cloned
+// yet, e.g. because it was created after the repo was cloned
+func cloneMissingWiki(job pullJob) {
+	if !job.source.IncludeWikis || strings.HasSuffix(job.path, wikiSuffix) {
+		return
+	}
+	if _, err := os.Stat(job.path + wikiSuffix); err == nil {
+		return
+	}
+	origin, err := git.GetRemoteURL(job.path)
+	if err != nil {
+		return
+	}
+	if err := cloneWiki(job.source, origin, job.path); err != nil {
+		ui.Warn("failed to clone wiki", "repo", job.name, "error", err)
+	}
+}
@@ -0,0 +1,45 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Attention is a repo a pull left with conflicts to resolve by hand
+type Attention struct {
+	Name    string
+	Path    string
+	Files   []string // conflicting files, relative to the repo
+	Stashed bool     // the local changes are still in the stash
+}
+
+// PrintAttention lists the repos that need resolving by hand, each with its
+// path, conflicting files, and whether its local changes are in the stash
+func PrintAttention(items []Attention) {
+	if len(items) == 0 {
+		return
+	}
+
+	fmt.Println(HeaderStyle.Render("Needs attention"))
+
+	var names []string
+	for _, a := range items {
+		names = append(names, a.Name)
+	}
+	column := nameColumn(names, 2, 30)
+	width := TermWidth()
+
+	for _, a := range items {
+		fmt.Printf("  %s  %s\n", padRight(Truncate(a.Name, column), column), UnchangedStyle.Render(a.Path))
+		if len(a.Files) > 0 {
+			line := Truncate("    conflicts in "+strings.Join(a.Files, ", "), width)
+			fmt.Println(RemovedStyle.Render(line))
+		} else {
+			fmt.Println(RemovedStyle.Render("    merge conflict"))
+		}
+		if a.Stashed {
+			fmt.Println(UnchangedStyle.Render("    local changes are still in the stash, drop it once resolved"))
+		}
+	}
+	fmt.Println()
+}
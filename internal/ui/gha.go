@@ -0,0 +1,83 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Output modes for --output
+const (
+	OutputText = "text"
+	OutputGHA  = "gha" // GitHub Actions workflow commands
+)
+
+var (
+	outputMode = OutputText
+	groupOpen  bool
+)
+
+// SetOutput selects how errors, warnings, and sections are printed
+func SetOutput(mode string) error {
+	switch mode {
+	case OutputText, OutputGHA:
+		outputMode = mode
+		return nil
+	}
+	return fmt.Errorf("invalid output %q (must be text or gha)", mode)
+}
+
+// Group starts a collapsible section in GitHub Actions logs, closing the
+// previous one, as Actions doesn't nest them. Does nothing in text mode.
+func Group(title string) {
+	if outputMode != OutputGHA || reporter != nil {
+		return
+	}
+	EndGroup()
+	fmt.Fprintf(os.Stdout, "::group::%s\n", escapeData(title))
+	groupOpen = true
+}
+
+// EndGroup ends the section started by Group, if any
+func EndGroup() {
+	if !groupOpen {
+		return
+	}
+	fmt.Fprintln(os.Stdout, "::endgroup::")
+	groupOpen = false
+}
+
+// annotate prints an error or warning as a GitHub Actions annotation, titled
+// with the repo or source it is about
+func annotate(level, msg string, args []any) {
+	var title string
+	var fields []string
+	for i := 0; i+1 < len(args); i += 2 {
+		key, value := fmt.Sprint(args[i]), fmt.Sprint(args[i+1])
+		if title == "" && (key == "repo" || key == "source") {
+			title = value
+		}
+		fields = append(fields, key+"="+value)
+	}
+
+	text := msg
+	if len(fields) > 0 {
+		text += ": " + strings.Join(fields, " ")
+	}
+	props := ""
+	if title != "" {
+		props = " title=" + escapeProperty(title)
+	}
+	fmt.Fprintf(os.Stdout, "::%s%s::%s\n", level, props, escapeData(text))
+}
+
+// escapeData escapes the message of a workflow command, keeping multi-line
+// git errors in one annotation
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeProperty escapes a property value of a workflow command
+func escapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
@@ -0,0 +1,64 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// HistoryRun is a recorded sync or pull
+type HistoryRun struct {
+	Time     time.Time
+	Command  string
+	Duration time.Duration
+	Counts   []string // e.g. "3 cloned", only those above zero
+	Repos    []HistoryRepo
+}
+
+// HistoryRepo is something a run did to one repo
+type HistoryRepo struct {
+	Action string // e.g. "cloned", "pruned", "clone_failed"
+	Name   string
+	Detail string // the error of a failure, or the path
+	Failed bool
+}
+
+// PrintHistory prints runs with their counts and what they did to each repo
+func PrintHistory(runs []HistoryRun) {
+	if len(runs) == 0 {
+		fmt.Println(UnchangedStyle.Render("no runs recorded"))
+		fmt.Println()
+		return
+	}
+
+	var names []string
+	for _, run := range runs {
+		for _, repo := range run.Repos {
+			names = append(names, repo.Name)
+		}
+	}
+	width := nameColumn(names, 18, 30)
+
+	for _, run := range runs {
+		fmt.Printf("%s  %s  %s\n", run.Time.Local().Format("2006-01-02 15:04:05"),
+			SourceStyle.Render(run.Command), UnchangedStyle.Render(FormatDuration(run.Duration)))
+		counts := "nothing changed"
+		if len(run.Counts) > 0 {
+			counts = strings.Join(run.Counts, ", ")
+		}
+		fmt.Println(UnchangedStyle.Render("  " + counts))
+
+		for _, repo := range run.Repos {
+			style := AddedStyle
+			switch {
+			case repo.Failed, repo.Action == "pruned":
+				style = RemovedStyle
+			case repo.Action == "added":
+				style = UpstreamStyle
+			}
+			fmt.Printf("  %s  %s  %s\n", style.Render(padRight(repo.Action, 13)),
+				padRight(Truncate(repo.Name, width), width), UnchangedStyle.Render(repo.Detail))
+		}
+		fmt.Println()
+	}
+}
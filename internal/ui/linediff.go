@@ -0,0 +1,180 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// diffContext is how many unchanged lines are shown around each change
+const diffContext = 3
+
+// maxDiffCells bounds the table used to line up the changed middle of two
+// texts; beyond it, the whole middle is shown as removed and re-added
+const maxDiffCells = 4_000_000
+
+// lineOp is one line of a line diff: ' ' kept, '-' removed, or '+' added
+type lineOp struct {
+	kind byte
+	text string
+}
+
+// diffLines returns the edits turning a into b, as a longest common
+// subsequence of their lines
+func diffLines(a, b []string) []lineOp {
+	// Configs change in a few places, so trim the common ends first
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []lineOp
+	for _, line := range a[:prefix] {
+		ops = append(ops, lineOp{' ', line})
+	}
+
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if len(midA)*len(midB) > maxDiffCells {
+		for _, line := range midA {
+			ops = append(ops, lineOp{'-', line})
+		}
+		for _, line := range midB {
+			ops = append(ops, lineOp{'+', line})
+		}
+	} else {
+		// lcs[i][j] is the length of the common subsequence of midA[i:] and midB[j:]
+		lcs := make([][]int, len(midA)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(midB)+1)
+		}
+		for i := len(midA) - 1; i >= 0; i-- {
+			for j := len(midB) - 1; j >= 0; j-- {
+				if midA[i] == midB[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+
+		i, j := 0, 0
+		for i < len(midA) || j < len(midB) {
+			switch {
+			case i < len(midA) && j < len(midB) && midA[i] == midB[j]:
+				ops = append(ops, lineOp{' ', midA[i]})
+				i++
+				j++
+			case j < len(midB) && (i == len(midA) || lcs[i][j+1] >= lcs[i+1][j]):
+				ops = append(ops, lineOp{'+', midB[j]})
+				j++
+			default:
+				ops = append(ops, lineOp{'-', midA[i]})
+				i++
+			}
+		}
+	}
+
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, lineOp{' ', line})
+	}
+	return ops
+}
+
+// PrintConfigDiff prints the changes between two versions of a YAML config
+// as a unified diff. Each hunk header names the source it's in, like git
+// names the function around a change.
+func PrintConfigDiff(path string, before, after []byte) {
+	diffHeaderStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#00BFFF"))
+	hunkStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF79C6"))
+
+	a := strings.Split(strings.TrimSuffix(string(before), "\n"), "\n")
+	b := strings.Split(strings.TrimSuffix(string(after), "\n"), "\n")
+	ops := diffLines(a, b)
+
+	fmt.Println(diffHeaderStyle.Render("--- " + path))
+	fmt.Println(diffHeaderStyle.Render("+++ " + path))
+
+	for start := 0; start < len(ops); {
+		// Find the next change and extend the hunk over changes that are
+		// close enough for their context to touch
+		first := start
+		for first < len(ops) && ops[first].kind == ' ' {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+		last := first
+		for k := first; k < len(ops) && k <= last+2*diffContext; k++ {
+			if ops[k].kind != ' ' {
+				last = k
+			}
+		}
+		from := max(first-diffContext, start)
+		to := min(last+diffContext+1, len(ops))
+
+		// Line numbers of the hunk in each version, counted from 1, and
+		// how many old lines come before its first change
+		oldLine, newLine, beforeChange := 1, 1, 0
+		for k, op := range ops[:first] {
+			if op.kind != '+' {
+				beforeChange++
+				if k < from {
+					oldLine++
+				}
+			}
+			if op.kind != '-' && k < from {
+				newLine++
+			}
+		}
+		oldCount, newCount := 0, 0
+		for _, op := range ops[from:to] {
+			if op.kind != '+' {
+				oldCount++
+			}
+			if op.kind != '-' {
+				newCount++
+			}
+		}
+
+		header := fmt.Sprintf("@@ -%d,%d +%d,%d @@", oldLine, oldCount, newLine, newCount)
+		if name := sourceAbove(a, beforeChange); name != "" {
+			header += " " + name
+		}
+		fmt.Println(hunkStyle.Render(header))
+
+		for _, op := range ops[from:to] {
+			line := string(op.kind) + op.text
+			switch op.kind {
+			case '+':
+				fmt.Println(AddedStyle.Render(line))
+			case '-':
+				fmt.Println(RemovedStyle.Render(line))
+			default:
+				fmt.Println(UnchangedStyle.Render(line))
+			}
+		}
+		start = to
+	}
+	fmt.Println()
+}
+
+// sourceAbove returns the "name:" line of the source that the first n lines
+// of a config end in, or "" when they end before the first source
+func sourceAbove(lines []string, n int) string {
+	for i := min(n, len(lines)) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		if name, ok := strings.CutPrefix(line, "- name:"); ok {
+			return "name:" + name
+		}
+		if line == "sources:" {
+			return ""
+		}
+	}
+	return ""
+}
@@ -0,0 +1,106 @@
+package ui
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"golang.org/x/term"
+)
+
+// defaultPager is used when $PAGER isn't set
+const defaultPager = "less -R"
+
+// paging is set while a Pager holds stdout, with the terminal's width, so
+// output keeps being styled and fitted as if written to the terminal
+var paging struct {
+	active bool
+	width  int
+}
+
+// Pager collects output written to stdout and shows it through $PAGER
+// when it's taller than the terminal, like git does
+type Pager struct {
+	stdout *os.File
+	w      *os.File
+	height int
+	buf    bytes.Buffer
+	done   chan struct{}
+}
+
+// StartPager starts collecting stdout. Returns nil, which Stop accepts, when
+// paging is disabled or stdout isn't a terminal.
+func StartPager(enabled bool) *Pager {
+	if !enabled || !IsTTY() {
+		return nil
+	}
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		return nil
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil
+	}
+
+	// Keep colors once stdout is no longer the terminal
+	lipgloss.SetColorProfile(lipgloss.ColorProfile())
+
+	p := &Pager{stdout: os.Stdout, w: w, height: height, done: make(chan struct{})}
+	go func() {
+		io.Copy(&p.buf, r)
+		r.Close()
+		close(p.done)
+	}()
+
+	paging.active, paging.width = true, width
+	os.Stdout = w
+	return p
+}
+
+// Stop restores stdout and shows the collected output, through the pager
+// if it doesn't fit on the screen
+func (p *Pager) Stop() {
+	if p == nil {
+		return
+	}
+	p.w.Close()
+	<-p.done
+	os.Stdout = p.stdout
+	paging.active = false
+
+	if bytes.Count(p.buf.Bytes(), []byte("\n")) < p.height || !runPager(p.buf.Bytes()) {
+		os.Stdout.Write(p.buf.Bytes())
+	}
+}
+
+// runPager shows output through $PAGER. Returns false if there is no pager
+// to run, so the caller prints the output itself.
+func runPager(output []byte) bool {
+	pager, ok := os.LookupEnv("PAGER")
+	if !ok {
+		pager = defaultPager
+	}
+	args := strings.Fields(pager)
+	if len(args) == 0 || args[0] == "cat" {
+		return false
+	}
+	if _, err := exec.LookPath(args[0]); err != nil {
+		return false
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(output)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return false
+	}
+	if err := cmd.Wait(); err != nil {
+		Debug("pager exited with an error", "pager", pager, "error", err)
+	}
+	return true
+}
@@ -0,0 +1,170 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WorkerPanel shows what each worker of a pool is doing, in a region pinned
+// below the output that updates in place. Finished items scroll above it.
+// Without a terminal it prints a count per finished item, like Progress.
+type WorkerPanel struct {
+	message   string
+	total     int
+	completed int
+	workers   []workerState
+	finished  []string // lines to print above the panel on the next redraw
+	drawn     int      // lines the panel took up when last drawn
+	isTTY     bool
+	mu        sync.Mutex
+	done      chan struct{}
+	stopped   chan struct{}
+}
+
+// workerState is the item a worker is on and how far along it is
+type workerState struct {
+	name    string
+	phase   string
+	percent int
+	started time.Time
+}
+
+// NewWorkerPanel starts a panel for total items handled by the given
+// number of workers
+func NewWorkerPanel(total, workers int, message string) *WorkerPanel {
+	p := &WorkerPanel{
+		message: message,
+		total:   total,
+		workers: make([]workerState, workers),
+		isTTY:   stdoutIsTerminal(),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	if p.isTTY {
+		go p.animate()
+	} else {
+		close(p.stopped)
+		plainf("%s (0/%d)", message, total)
+	}
+	return p
+}
+
+// Start shows that a worker took on an item. Without a terminal it prints
+// a timestamped line, so items that never finish can be found in logs.
+func (p *WorkerPanel) Start(worker int, name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.workers[worker] = workerState{name: name, phase: "starting", started: time.Now()}
+	if !p.isTTY {
+		plainf("%s started", name)
+	}
+}
+
+// Update sets the phase of a worker's item and its percentage
+func (p *WorkerPanel) Update(worker int, phase string, percent int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.workers[worker].phase = phase
+	p.workers[worker].percent = percent
+}
+
+// Idle shows that a worker is done with its item
+func (p *WorkerPanel) Idle(worker int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.workers[worker] = workerState{}
+}
+
+// Increment counts one more finished item, which is listed above the panel
+// with ✓ or ✗ and how long it took, or without a terminal in a timestamped
+// line with its outcome
+func (p *WorkerPanel) Increment(name string, err error, took time.Duration) {
+	p.mu.Lock()
+	p.completed++
+	completed := p.completed
+	if p.isTTY {
+		line := AddedStyle.Render("✓ " + name)
+		if err != nil {
+			line = RemovedStyle.Render("✗ " + name)
+		}
+		p.finished = append(p.finished, line+" "+UnchangedStyle.Render(FormatDuration(took)))
+	}
+	p.mu.Unlock()
+
+	if !p.isTTY {
+		plainf("%s %s (%d/%d)", name, outcome(err, took), completed, p.total)
+	}
+}
+
+// Finish prints the remaining finished items and removes the panel
+func (p *WorkerPanel) Finish() {
+	if p.isTTY {
+		close(p.done)
+	}
+	<-p.stopped
+}
+
+func (p *WorkerPanel) animate() {
+	defer close(p.stopped)
+
+	frame := 0
+	ticker := time.NewTicker(80 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			p.mu.Lock()
+			p.redraw("", false)
+			p.mu.Unlock()
+			return
+		case <-ticker.C:
+			p.mu.Lock()
+			p.redraw(spinnerFrames[frame%len(spinnerFrames)], true)
+			p.mu.Unlock()
+			frame++
+		}
+	}
+}
+
+// redraw erases the panel, prints the items finished since the last redraw,
+// and draws the panel again unless it's going away. Called with mu held.
+func (p *WorkerPanel) redraw(spinner string, panel bool) {
+	var b strings.Builder
+	for range p.drawn {
+		b.WriteString("\033[1A\033[2K")
+	}
+	b.WriteString("\r")
+	for _, line := range p.finished {
+		b.WriteString(line + "\n")
+	}
+	p.finished = p.finished[:0]
+	p.drawn = 0
+
+	if panel {
+		// Lines must not wrap, or erasing them misses the wrapped part
+		width := TermWidth() - 1
+		lines := []string{fmt.Sprintf("%s %s (%d/%d)", spinner, p.message, p.completed, p.total)}
+		for i, w := range p.workers {
+			if w.name == "" {
+				lines = append(lines, UnchangedStyle.Render(Truncate(fmt.Sprintf("  [%d] idle", i+1), width)))
+				continue
+			}
+			state := w.phase
+			if w.percent > 0 {
+				state = fmt.Sprintf("%s %d%%", w.phase, w.percent)
+			}
+			elapsed := int(time.Since(w.started).Seconds())
+			lines = append(lines, Truncate(fmt.Sprintf("  [%d] %s  %s  %ds", i+1, w.name, state, elapsed), width))
+		}
+		for _, line := range lines {
+			b.WriteString(line + "\n")
+		}
+		p.drawn = len(lines)
+	}
+
+	fmt.Print(b.String())
+}
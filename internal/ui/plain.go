@@ -0,0 +1,27 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// plainf prints a progress line for logs, without a terminal to draw on,
+// prefixed with an RFC3339 timestamp. Stdout isn't buffered, so each line
+// reaches CI logs and journald as soon as it happens.
+func plainf(format string, args ...any) {
+	if reporter != nil {
+		return
+	}
+	fmt.Fprintf(os.Stdout, "%s %s\n", time.Now().UTC().Format(time.RFC3339), fmt.Sprintf(format, args...))
+}
+
+// outcome describes how an item ended, with the first line of its error
+func outcome(err error, took time.Duration) string {
+	if err == nil {
+		return "done in " + FormatDuration(took)
+	}
+	msg, _, _ := strings.Cut(err.Error(), "\n")
+	return fmt.Sprintf("failed after %s: %s", FormatDuration(took), msg)
+}
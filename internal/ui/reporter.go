@@ -0,0 +1,31 @@
+package ui
+
+import (
+	"os"
+
+	"golang.org/x/term"
+)
+
+// Reporter receives log messages in place of the terminal, for programs
+// embedding autogitter. keyvals alternate keys and values, like the logger's.
+type Reporter interface {
+	Debug(msg string, keyvals ...any)
+	Info(msg string, keyvals ...any)
+	Warn(msg string, keyvals ...any)
+	Error(msg string, keyvals ...any)
+}
+
+// reporter, when set, gets every log message, and nothing is drawn on the
+// terminal: no progress, panels, or diffs
+var reporter Reporter
+
+// SetReporter sends log messages to r and stops all terminal output, or
+// restores both when r is nil
+func SetReporter(r Reporter) {
+	reporter = r
+}
+
+// stdoutIsTerminal reports whether progress can be drawn in place on stdout
+func stdoutIsTerminal() bool {
+	return reporter == nil && term.IsTerminal(int(os.Stdout.Fd()))
+}
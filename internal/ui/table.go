@@ -0,0 +1,101 @@
+package ui
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+)
+
+// SummaryTable is a summary with a row of counts per source and a totals row
+type SummaryTable struct {
+	Columns []string // names of the count columns, e.g. "Cloned"
+	Rows    []SummaryRow
+	Total   time.Duration // wall time of the whole run, shown in the totals row
+}
+
+// SummaryRow is one source's counts, in the order of the table's columns,
+// and the time spent on it
+type SummaryRow struct {
+	Name     string
+	Counts   []int
+	Duration time.Duration
+}
+
+// RenderSummaryTable renders the table with its totals row. Zero counts are
+// dimmed so the numbers that matter stand out.
+func RenderSummaryTable(t SummaryTable) string {
+	totals := make([]int, len(t.Columns))
+	var names []string
+	for _, row := range t.Rows {
+		names = append(names, row.Name)
+		for i, n := range row.Counts {
+			totals[i] += n
+		}
+	}
+	nameWidth := nameColumn(append(names, "Total"), 4, 9*(len(t.Columns)+1))
+
+	cells := func(name string, counts []int, d time.Duration) []string {
+		line := []string{Truncate(name, nameWidth)}
+		for _, n := range counts {
+			line = append(line, strconv.Itoa(n))
+		}
+		return append(line, FormatDuration(d))
+	}
+
+	tbl := table.New().
+		Border(lipgloss.RoundedBorder()).
+		BorderStyle(UnchangedStyle).
+		Headers(append(append([]string{"Source"}, t.Columns...), "Time")...)
+	for _, row := range t.Rows {
+		tbl.Row(cells(row.Name, row.Counts, row.Duration)...)
+	}
+	tbl.Row(cells("Total", totals, t.Total)...)
+
+	cell := lipgloss.NewStyle().Padding(0, 1)
+	last := len(t.Rows)
+	tbl.StyleFunc(func(row, col int) lipgloss.Style {
+		style := cell
+		if col > 0 {
+			style = style.Align(lipgloss.Right)
+		}
+		switch {
+		case row == table.HeaderRow:
+			return style.Inherit(HeaderStyle)
+		case row == last:
+			style = style.Bold(true)
+		}
+		if col > 0 && col <= len(t.Columns) && countAt(t, totals, row, col-1) == 0 {
+			return style.Inherit(UnchangedStyle)
+		}
+		return style
+	})
+
+	return tbl.Render()
+}
+
+// countAt returns the count in a data row, the last being the totals
+func countAt(t SummaryTable, totals []int, row, col int) int {
+	if row == len(t.Rows) {
+		return totals[col]
+	}
+	if col < len(t.Rows[row].Counts) {
+		return t.Rows[row].Counts[col]
+	}
+	return 0
+}
+
+// FormatDuration renders a duration rounded for a summary, e.g. "1.2s" or "3m05s"
+func FormatDuration(d time.Duration) string {
+	switch {
+	case d < time.Second:
+		return fmt.Sprintf("%dms", d.Milliseconds())
+	case d < time.Minute:
+		return fmt.Sprintf("%.1fs", d.Seconds())
+	default:
+		d = d.Round(time.Second)
+		return fmt.Sprintf("%dm%02ds", int(d.Minutes()), int(d.Seconds())%60)
+	}
+}
@@ -0,0 +1,44 @@
+package ui
+
+import (
+	"fmt"
+	"time"
+)
+
+// Timing is a phase of a run, or a repo, and how long it took
+type Timing struct {
+	Name     string
+	Duration time.Duration
+}
+
+// PrintTimings prints how long each phase took, with its share of the
+// total, followed by the slowest clones
+func PrintTimings(phases []Timing, total time.Duration, slowest []Timing) {
+	fmt.Println(HeaderStyle.Render("Timings"))
+	printTimingList(phases, total)
+
+	if len(slowest) > 0 {
+		fmt.Println()
+		fmt.Println(HeaderStyle.Render("Slowest clones"))
+		printTimingList(slowest, 0)
+	}
+	fmt.Println()
+}
+
+// printTimingList prints one aligned line per timing, with its percentage
+// of total when total is set
+func printTimingList(timings []Timing, total time.Duration) {
+	var names []string
+	for _, t := range timings {
+		names = append(names, t.Name)
+	}
+	width := nameColumn(names, 2, 16)
+
+	for _, t := range timings {
+		line := fmt.Sprintf("  %s  %7s", padRight(Truncate(t.Name, width), width), FormatDuration(t.Duration))
+		if total > 0 {
+			line += UnchangedStyle.Render(fmt.Sprintf("  %3.0f%%", 100*t.Duration.Seconds()/total.Seconds()))
+		}
+		fmt.Println(line)
+	}
+}
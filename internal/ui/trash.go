@@ -0,0 +1,41 @@
+package ui
+
+import (
+	"fmt"
+	"time"
+)
+
+// TrashEntry is a clone in the trash
+type TrashEntry struct {
+	Name      string
+	Path      string // where the clone was, empty when unknown
+	TrashedAt time.Time
+}
+
+// PrintTrash prints the clones in the trash with when they were moved there
+// and where they came from
+func PrintTrash(entries []TrashEntry) {
+	if len(entries) == 0 {
+		fmt.Println(UnchangedStyle.Render("trash is empty"))
+		fmt.Println()
+		return
+	}
+
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name)
+	}
+	width := nameColumn(names, 2, 40)
+
+	for _, entry := range entries {
+		days := int(time.Since(entry.TrashedAt).Hours() / 24)
+		path := entry.Path
+		if path == "" {
+			path = "unknown path"
+		}
+		fmt.Printf("  %s  %s  %s\n", padRight(Truncate(entry.Name, width), width),
+			RemovedStyle.Render(fmt.Sprintf("%3dd ago (%s)", days, entry.TrashedAt.Local().Format("2006-01-02"))),
+			UnchangedStyle.Render(path))
+	}
+	fmt.Println()
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/base64"
 	"fmt"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
@@ -16,18 +17,21 @@ import (
 
 var (
 	// Styles for diff display
-	AddedStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("#04B575"))
-	RemovedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF5555"))
+	AddedStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("#04B575"))
+	RemovedStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF5555"))
 	UnchangedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#ABABAB"))
-	HeaderStyle  = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#7D56F4"))
-	SourceStyle  = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#04B575"))
+	UpstreamStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("#00BFFF"))
+	HeaderStyle    = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#7D56F4"))
+	SourceStyle    = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#04B575"))
 )
 
 var Logger *log.Logger
 
 func init() {
+	// Logs without a terminal, e.g. in CI or journald, get timestamps
 	Logger = log.NewWithOptions(os.Stderr, log.Options{
-		ReportTimestamp: false,
+		ReportTimestamp: !term.IsTerminal(int(os.Stderr.Fd())),
+		TimeFormat:      time.RFC3339,
 	})
 }
 
@@ -40,8 +44,38 @@ func SetDebug(enabled bool) {
 }
 
 type DiffEntry struct {
-	Name   string
-	Status DiffStatus
+	Name           string
+	Status         DiffStatus
+	Dirty          bool
+	Ahead          int
+	Behind         int
+	RemoteMismatch bool
+	GoneUpstream   bool
+}
+
+// Annotation returns a short marker for local state, e.g. "[dirty ↑2 ↓1]".
+// Returns an empty string when the repo is clean and in sync with its upstream.
+func (e DiffEntry) Annotation() string {
+	var parts []string
+	if e.Dirty {
+		parts = append(parts, "dirty")
+	}
+	if e.Ahead > 0 {
+		parts = append(parts, fmt.Sprintf("↑%d", e.Ahead))
+	}
+	if e.Behind > 0 {
+		parts = append(parts, fmt.Sprintf("↓%d", e.Behind))
+	}
+	if e.RemoteMismatch {
+		parts = append(parts, "origin≠config")
+	}
+	if e.GoneUpstream {
+		parts = append(parts, "gone upstream")
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "[" + strings.Join(parts, " ") + "]"
 }
 
 type DiffStatus int
@@ -50,13 +84,18 @@ const (
 	StatusAdded DiffStatus = iota
 	StatusRemoved
 	StatusUnchanged
+	StatusUpstream // exists on the provider but not in config or locally
 )
 
 func PrintDiff(sourceName string, entries []DiffEntry) {
+	if reporter != nil {
+		return
+	}
 	fmt.Println()
 	fmt.Println(SourceStyle.Render(fmt.Sprintf("  %s", sourceName)))
 	fmt.Println()
 
+	width := TermWidth()
 	for _, entry := range entries {
 		var prefix string
 		var style lipgloss.Style
@@ -71,9 +110,12 @@ func PrintDiff(sourceName string, entries []DiffEntry) {
 		case StatusUnchanged:
 			prefix = "    "
 			style = UnchangedStyle
+		case StatusUpstream:
+			prefix = "  > "
+			style = UpstreamStyle
 		}
 
-		fmt.Println(style.Render(prefix + entry.Name))
+		fmt.Println(style.Render(Truncate(prefix+entry.Name, width)))
 	}
 	fmt.Println()
 }
@@ -90,44 +132,314 @@ func PrintUnifiedDiff(diffs []SourceDiff) {
 	diffHeaderStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#00BFFF"))
 	// Hunk header style (purple/magenta like @@ lines)
 	hunkStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF79C6"))
+	// Local state annotation style (yellow, stands out from unchanged gray)
+	annotationStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#F1FA8C"))
 
 	fmt.Println(diffHeaderStyle.Render("--- local"))
 	fmt.Println(diffHeaderStyle.Render("+++ config"))
 
+	width := TermWidth()
 	for _, diff := range diffs {
 		fmt.Println(hunkStyle.Render(fmt.Sprintf("@@ %s @@", diff.Name)))
 
+		// Annotations line up in a column after the longest name
+		var names []string
+		annotationWidth := 0
+		for _, entry := range diff.Entries {
+			names = append(names, entry.Name)
+			annotationWidth = max(annotationWidth, lipgloss.Width(entry.Annotation()))
+		}
+		reserve := 0
+		if annotationWidth > 0 {
+			reserve = annotationWidth + 1
+		}
+		column := nameColumn(names, 2, reserve)
+
 		for _, entry := range diff.Entries {
 			var line string
 			var style lipgloss.Style
 
 			switch entry.Status {
 			case StatusAdded:
-				line = "+ " + entry.Name
+				line = "+ "
 				style = AddedStyle
 			case StatusRemoved:
-				line = "- " + entry.Name
+				line = "- "
 				style = RemovedStyle
 			case StatusUnchanged:
-				line = "  " + entry.Name
+				line = "  "
 				style = UnchangedStyle
+			case StatusUpstream:
+				line = "> "
+				style = UpstreamStyle
 			}
+			name := Truncate(entry.Name, column)
 
-			fmt.Println(style.Render(line))
+			if annotation := entry.Annotation(); annotation != "" {
+				fmt.Println(style.Render(line+padRight(name, column)) + " " + annotationStyle.Render(Truncate(annotation, width-column-3)))
+				continue
+			}
+			fmt.Println(style.Render(line + name))
 		}
 		fmt.Println()
 	}
 }
 
-func ConfirmPrune(repos []string) (bool, error) {
+// StaleEntry is a repo shown in the staleness report
+type StaleEntry struct {
+	Name         string
+	LastActivity time.Time
+}
+
+// PrintStale prints the stale repos of one source with their age
+func PrintStale(sourceName string, entries []StaleEntry) {
+	fmt.Println(SourceStyle.Render(sourceName))
+
+	if len(entries) == 0 {
+		fmt.Println(UnchangedStyle.Render("  no stale repos"))
+		fmt.Println()
+		return
+	}
+
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name)
+	}
+	width := nameColumn(names, 2, 22)
+
+	for _, entry := range entries {
+		days := int(time.Since(entry.LastActivity).Hours() / 24)
+		fmt.Printf("  %s  %s\n", padRight(Truncate(entry.Name, width), width),
+			RemovedStyle.Render(fmt.Sprintf("%dd ago (%s)", days, entry.LastActivity.Format("2006-01-02"))))
+	}
+	fmt.Println()
+}
+
+// PullDigest summarizes the commits a pull brought into one repo
+type PullDigest struct {
+	Name    string
+	Authors []string // distinct, in order of their newest commit
+	Commits []string // "<sha> <subject>", newest first
+}
+
+// PrintPullDigest prints the new commits of each updated repo, with the
+// commit subjects when showLog is set
+func PrintPullDigest(digests []PullDigest, showLog bool) {
+	if len(digests) == 0 {
+		return
+	}
+
+	fmt.Println()
+	fmt.Println(HeaderStyle.Render("Updates"))
+
+	var names []string
+	for _, d := range digests {
+		names = append(names, d.Name)
+	}
+	width := nameColumn(names, 2, 30)
+
+	for _, d := range digests {
+		noun := "commits"
+		if len(d.Commits) == 1 {
+			noun = "commit"
+		}
+		fmt.Printf("  %s  %s %s\n", padRight(Truncate(d.Name, width), width),
+			AddedStyle.Render(fmt.Sprintf("%d new %s", len(d.Commits), noun)),
+			UnchangedStyle.Render("by "+strings.Join(d.Authors, ", ")))
+		if showLog {
+			for _, commit := range d.Commits {
+				fmt.Println(UnchangedStyle.Render("    " + commit))
+			}
+		}
+	}
+	fmt.Println()
+}
+
+// PrintNews prints the repos created and removed upstream for one source
+// since its last sync
+func PrintNews(sourceName string, since time.Time, created, removed []string) {
+	fmt.Println(SourceStyle.Render(sourceName) + UnchangedStyle.Render(" since "+since.Local().Format("2006-01-02 15:04")))
+
+	if len(created) == 0 && len(removed) == 0 {
+		fmt.Println(UnchangedStyle.Render("  nothing new"))
+		fmt.Println()
+		return
+	}
+
+	for _, name := range created {
+		fmt.Println(AddedStyle.Render("  + " + name))
+	}
+	for _, name := range removed {
+		fmt.Println(RemovedStyle.Render("  - " + name))
+	}
+	fmt.Println()
+}
+
+// PendingAction is one entry in the SelectActions list
+type PendingAction struct {
+	Label string
+	Key   string
+}
+
+// SelectActions lets the user deselect individual pending actions. All actions
+// start selected; the keys of the ones left selected are returned.
+func SelectActions(actions []PendingAction) (map[string]bool, error) {
+	options := make([]huh.Option[string], len(actions))
+	for i, action := range actions {
+		options[i] = huh.NewOption(action.Label, action.Key).Selected(true)
+	}
+
+	var keys []string
+	err := huh.NewMultiSelect[string]().
+		Title("Pending actions").
+		Description("Deselect anything you want to skip (space to toggle, enter to confirm)").
+		Options(options...).
+		Value(&keys).
+		Run()
+	if err != nil {
+		return nil, err
+	}
+
+	selected := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		selected[key] = true
+	}
+	return selected, nil
+}
+
+// SelectRepos shows a searchable multi-select of repo names, none selected,
+// and returns the ones the user picked
+func SelectRepos(sourceName string, repos []string) ([]string, error) {
+	options := huh.NewOptions(repos...)
+
+	var picked []string
+	err := huh.NewMultiSelect[string]().
+		Title(fmt.Sprintf("Add repos to %s", sourceName)).
+		Description("Type / to search, space to toggle, enter to confirm").
+		Options(options...).
+		Filterable(true).
+		Height(min(len(repos)+2, 20)).
+		Value(&picked).
+		Run()
+
+	return picked, err
+}
+
+// RepoChoice is one entry in the PickRepo list
+type RepoChoice struct {
+	Label string
+	Path  string
+}
+
+// PickRepo lets the user pick one of several matching repos and returns its
+// path. The picker is drawn on stderr so stdout stays clean for command
+// substitution, e.g. cd "$(ag find foo)".
+func PickRepo(choices []RepoChoice) (string, error) {
+	options := make([]huh.Option[string], len(choices))
+	for i, choice := range choices {
+		options[i] = huh.NewOption(choice.Label, choice.Path)
+	}
+
+	var path string
+	err := huh.NewForm(huh.NewGroup(
+		huh.NewSelect[string]().
+			Title("Multiple repos match").
+			Options(options...).
+			Height(min(len(choices)+2, 15)).
+			Value(&path),
+	)).WithOutput(os.Stderr).Run()
+
+	return path, err
+}
+
+// ConfirmPrune asks whether to delete the orphaned repos, listing the size,
+// last commit, and uncommitted changes of each
+func ConfirmPrune(repos []PruneDetails) (bool, error) {
 	if len(repos) == 0 {
 		return false, nil
 	}
 
+	var names []string
+	var total int64
+	dirty := 0
+	for _, d := range repos {
+		names = append(names, d.Name)
+		total += d.Size
+		if d.Dirty {
+			dirty++
+		}
+	}
+	width := nameColumn(names, 0, 52)
+
+	var desc strings.Builder
+	fmt.Fprintf(&desc, "%d repo(s) not in config will be moved to the trash (%s)", len(repos), FormatBytes(total))
+	if dirty > 0 {
+		fmt.Fprintf(&desc, ", %d with uncommitted changes", dirty)
+	}
+	desc.WriteString("\n")
+	for _, d := range repos {
+		line := fmt.Sprintf("\n%s  %9s  %s", padRight(Truncate(d.Name, width), width), FormatBytes(d.Size), d.LastCommitText())
+		if d.Dirty {
+			line += "  " + RemovedStyle.Render("uncommitted changes")
+		}
+		desc.WriteString(line)
+	}
+
 	var confirm bool
 	err := huh.NewConfirm().
 		Title("Delete these repos from disk?").
-		Description(fmt.Sprintf("%d repo(s) not in config will be permanently deleted", len(repos))).
+		Description(desc.String()).
+		Affirmative("Yes, delete").
+		Negative("No, keep").
+		Value(&confirm).
+		Run()
+
+	return confirm, err
+}
+
+// PruneDetails describes a repo about to be pruned
+type PruneDetails struct {
+	Name       string
+	Path       string
+	LastCommit time.Time
+	Dirty      bool
+	Size       int64
+}
+
+// LastCommitText returns the date of the last commit and how long ago it
+// was, or "unknown"
+func (d PruneDetails) LastCommitText() string {
+	if d.LastCommit.IsZero() {
+		return "unknown"
+	}
+	days := int(time.Since(d.LastCommit).Hours() / 24)
+	return fmt.Sprintf("%s (%dd ago)", d.LastCommit.Format("2006-01-02"), days)
+}
+
+// Summary returns the details on one line, e.g.
+// "12.3 MB, last commit 2026-01-02 (40d ago), uncommitted changes"
+func (d PruneDetails) Summary() string {
+	s := FormatBytes(d.Size) + ", last commit " + d.LastCommitText()
+	if d.Dirty {
+		s += ", uncommitted changes"
+	}
+	return s
+}
+
+// ConfirmPruneRepo asks whether to delete a single repo, showing its last
+// commit, uncommitted changes, and size on disk
+func ConfirmPruneRepo(d PruneDetails) (bool, error) {
+	lastCommit := d.LastCommitText()
+	state := "clean"
+	if d.Dirty {
+		state = "uncommitted changes"
+	}
+
+	var confirm bool
+	err := huh.NewConfirm().
+		Title(fmt.Sprintf("Delete %s?", d.Name)).
+		Description(fmt.Sprintf("%s\nlast commit: %s\nworking tree: %s\nsize: %s",
+			d.Path, lastCommit, state, FormatBytes(d.Size))).
 		Affirmative("Yes, delete").
 		Negative("No, keep").
 		Value(&confirm).
@@ -169,6 +481,85 @@ func ConfirmSync(toClone int, toRemove int) (bool, error) {
 	return confirm, err
 }
 
+// ConfirmUndo asks whether to undo an operation, listing what undoing it does
+func ConfirmUndo(operation string, steps []string) (bool, error) {
+	var confirm bool
+	err := huh.NewConfirm().
+		Title(fmt.Sprintf("Undo %s?", operation)).
+		Description(strings.Join(steps, "\n")).
+		Affirmative("Yes, undo").
+		Negative("No").
+		Value(&confirm).
+		Run()
+
+	return confirm, err
+}
+
+func ConfirmRepair(repo, path string) (bool, error) {
+	var confirm bool
+	err := huh.NewConfirm().
+		Title(fmt.Sprintf("%s is corrupted", repo)).
+		Description(fmt.Sprintf("Move %s to the trash and clone it again?\nUncommitted files are copied to the rescue directory first.", path)).
+		Affirmative("Yes, re-clone").
+		Negative("No, skip").
+		Value(&confirm).
+		Run()
+
+	return confirm, err
+}
+
+func ConfirmNewSource(repo, source, path string) (bool, error) {
+	var confirm bool
+	err := huh.NewConfirm().
+		Title(fmt.Sprintf("%s is from %s, which no source covers", repo, source)).
+		Description(fmt.Sprintf("Create a manual source for it in %s and move the clone there?\nOtherwise it's added under its full clone URL.", path)).
+		Affirmative("Yes, create").
+		Negative("No, keep it here").
+		Value(&confirm).
+		Run()
+
+	return confirm, err
+}
+
+func ConfirmRename(oldName, newName string) (bool, error) {
+	var confirm bool
+	err := huh.NewConfirm().
+		Title(fmt.Sprintf("%s was renamed upstream to %s", oldName, newName)).
+		Description("Rename the local clone and update the config?").
+		Affirmative("Yes, rename").
+		Negative("No, skip").
+		Value(&confirm).
+		Run()
+
+	return confirm, err
+}
+
+func ConfirmSwitchBranch(repo, from, to string) (bool, error) {
+	var confirm bool
+	err := huh.NewConfirm().
+		Title(fmt.Sprintf("%s: default branch changed from %s to %s", repo, from, to)).
+		Description(fmt.Sprintf("Switch the local checkout to %s and track origin/%s?", to, to)).
+		Affirmative("Yes, switch").
+		Negative("No, skip").
+		Value(&confirm).
+		Run()
+
+	return confirm, err
+}
+
+func ConfirmReloadConfig(path string) (bool, error) {
+	var confirm bool
+	err := huh.NewConfirm().
+		Title(fmt.Sprintf("Config changed on disk: %s", path)).
+		Description("Reload it and apply this run's repo changes on top?").
+		Affirmative("Yes, reload").
+		Negative("No, don't save").
+		Value(&confirm).
+		Run()
+
+	return confirm, err
+}
+
 func ConfirmCreateDir(path string) (bool, error) {
 	var confirm bool
 	err := huh.NewConfirm().
@@ -182,34 +573,88 @@ func ConfirmCreateDir(path string) (bool, error) {
 	return confirm, err
 }
 
-func PrintSummary(cloned, pruned, skipped int) {
+// SummaryFailure is a failed source or repo listed in the summary
+type SummaryFailure struct {
+	Name  string
+	Error string
+}
+
+// PrintSummary prints the summary table followed by the failures, if any
+func PrintSummary(t SummaryTable, failures []SummaryFailure) {
 	fmt.Println()
 	fmt.Println(HeaderStyle.Render("Summary"))
-	if cloned > 0 {
-		fmt.Println(AddedStyle.Render(fmt.Sprintf("  Cloned: %d", cloned)))
+	fmt.Println(RenderSummaryTable(t))
+	if len(failures) > 0 {
+		fmt.Println(RemovedStyle.Render(fmt.Sprintf("  Failed: %d", len(failures))))
+
+		// Errors line up after the longest name, and are cut at the terminal's edge
+		var names []string
+		for _, f := range failures {
+			names = append(names, f.Name+":")
+		}
+		width := TermWidth()
+		column := nameColumn(names, 4, width/3)
+		for _, f := range failures {
+			name := padRight(Truncate(f.Name+":", column), column)
+			line := Truncate(fmt.Sprintf("    %s %s", name, f.Error), width)
+			fmt.Println(RemovedStyle.Render(line))
+		}
 	}
-	if pruned > 0 {
-		fmt.Println(RemovedStyle.Render(fmt.Sprintf("  Pruned: %d", pruned)))
+	fmt.Println()
+}
+
+// FormatBytes renders a byte count with a binary unit, e.g. "12.3 MiB"
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
 	}
-	if skipped > 0 {
-		fmt.Println(UnchangedStyle.Render(fmt.Sprintf("  Skipped: %d", skipped)))
+	value := float64(n)
+	exp := 0
+	for value >= unit*unit {
+		value /= unit
+		exp++
 	}
-	fmt.Println()
+	return fmt.Sprintf("%.1f %ciB", value/unit, "KMGTPE"[exp])
 }
 
 func Info(msg string, args ...interface{}) {
+	if reporter != nil {
+		reporter.Info(msg, args...)
+		return
+	}
 	Logger.Info(msg, args...)
 }
 
 func Warn(msg string, args ...interface{}) {
+	if reporter != nil {
+		reporter.Warn(msg, args...)
+		return
+	}
+	if outputMode == OutputGHA {
+		annotate("warning", msg, args)
+		return
+	}
 	Logger.Warn(msg, args...)
 }
 
 func Error(msg string, args ...interface{}) {
+	if reporter != nil {
+		reporter.Error(msg, args...)
+		return
+	}
+	if outputMode == OutputGHA {
+		annotate("error", msg, args)
+		return
+	}
 	Logger.Error(msg, args...)
 }
 
 func Debug(msg string, args ...interface{}) {
+	if reporter != nil {
+		reporter.Debug(msg, args...)
+		return
+	}
 	Logger.Debug(msg, args...)
 }
 
@@ -231,13 +676,13 @@ func NewProgress(total int, message string) *Progress {
 		total:   total,
 		message: message,
 		done:    make(chan struct{}),
-		isTTY:   term.IsTerminal(int(os.Stdout.Fd())),
+		isTTY:   stdoutIsTerminal(),
 	}
 
 	if p.isTTY {
 		go p.animate()
 	} else {
-		fmt.Printf("%s (0/%d)\n", message, total)
+		plainf("%s (0/%d)", message, total)
 	}
 
 	return p
@@ -264,8 +709,9 @@ func (p *Progress) animate() {
 	}
 }
 
-// Increment marks one more item as completed
-func (p *Progress) Increment() {
+// Increment marks one more item as completed. Without a terminal it prints
+// a timestamped line with the item's name and outcome.
+func (p *Progress) Increment(name string, err error, took time.Duration) {
 	p.mu.Lock()
 	p.completed++
 	completed := p.completed
@@ -273,7 +719,7 @@ func (p *Progress) Increment() {
 	p.mu.Unlock()
 
 	if !p.isTTY {
-		fmt.Printf("%s (%d/%d)\n", p.message, completed, total)
+		plainf("%s %s (%d/%d)", name, outcome(err, took), completed, total)
 	}
 }
 
@@ -288,12 +734,26 @@ func (p *Progress) Finish() {
 
 // IsTTY returns whether we're running in an interactive terminal
 func IsTTY() bool {
-	return term.IsTerminal(int(os.Stdout.Fd()))
+	if paging.active {
+		return true
+	}
+	return stdoutIsTerminal()
+}
+
+// CanPrompt reports whether prompts can be shown on stderr, even when stdout
+// is redirected
+func CanPrompt() bool {
+	return term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stderr.Fd()))
 }
 
 // CopyToClipboard copies text to the system clipboard.
 // It tries the clipboard package first, then falls back to OSC 52 escape sequences.
+// Nothing is copied when stdout is not a terminal. Returns whether the text was copied.
 func CopyToClipboard(text string) bool {
+	if !IsTTY() {
+		return false
+	}
+
 	// Try clipboard package first
 	if err := clipboard.Init(); err == nil {
 		clipboard.Write(clipboard.FmtText, []byte(text))
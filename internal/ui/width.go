@@ -0,0 +1,70 @@
+package ui
+
+import (
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"golang.org/x/term"
+)
+
+// minColumn is the narrowest a name column gets on a small terminal
+const minColumn = 12
+
+// TermWidth returns the width of the terminal on stdout, or 0 when stdout
+// isn't a terminal and lines shouldn't be cut
+func TermWidth() int {
+	if paging.active {
+		return paging.width
+	}
+	if !IsTTY() {
+		return 0
+	}
+	width, _, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		return 0
+	}
+	return width
+}
+
+// Truncate shortens s to at most width cells, ending it with an ellipsis.
+// A width of 0 or less leaves s alone.
+func Truncate(s string, width int) string {
+	if width <= 0 || lipgloss.Width(s) <= width {
+		return s
+	}
+
+	var b strings.Builder
+	used := 0
+	for _, r := range s {
+		w := lipgloss.Width(string(r))
+		if used+w > width-1 {
+			break
+		}
+		b.WriteRune(r)
+		used += w
+	}
+	return b.String() + "…"
+}
+
+// padRight pads s with spaces to width cells
+func padRight(s string, width int) string {
+	if pad := width - lipgloss.Width(s); pad > 0 {
+		return s + strings.Repeat(" ", pad)
+	}
+	return s
+}
+
+// nameColumn returns the width of a column that fits the longest name, cut
+// down on a narrow terminal to leave indent cells before it and reserve
+// cells after it. Names wider than the column are cut with Truncate.
+func nameColumn(names []string, indent, reserve int) int {
+	width := 0
+	for _, name := range names {
+		width = max(width, lipgloss.Width(name))
+	}
+	if tw := TermWidth(); tw > 0 {
+		width = min(width, max(tw-indent-reserve, minColumn))
+	}
+	return width
+}
@@ -0,0 +1,245 @@
+// Package autogitter embeds autogitter's sync engine in other Go programs.
+//
+// Load a config, create an Engine, and run syncs and pulls with a context:
+//
+//	cfg, err := autogitter.LoadConfig("/etc/mirror/config.yaml")
+//	if err != nil {
+//		return err
+//	}
+//	engine := autogitter.New(cfg, autogitter.Options{
+//		ConfigPath: "/etc/mirror/config.yaml",
+//		Reporter:   myLogger,
+//	})
+//	result, err := engine.Sync(ctx, autogitter.SyncOptions{Prune: true})
+//
+// The engine never prompts and draws nothing on the terminal. Log messages
+// go to the Reporter, and what happened is returned in the result.
+package autogitter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/arch-err/autogitter/internal/config"
+	"github.com/arch-err/autogitter/internal/connector"
+	"github.com/arch-err/autogitter/internal/errs"
+	"github.com/arch-err/autogitter/internal/sync"
+	"github.com/arch-err/autogitter/internal/ui"
+)
+
+type (
+	// Config is a loaded and validated config
+	Config = config.Config
+	// Source is one source of a config, e.g. a GitHub org
+	Source = config.Source
+	// RepoEntry is a repo listed in a source
+	RepoEntry = config.RepoEntry
+
+	// SyncResult counts what a sync did, in total and per source
+	SyncResult = sync.SyncResult
+	// PullResult counts what a pull did, with the new commits of each repo
+	PullResult = sync.PullResult
+	// Failure is a source or repo that failed in a sync or pull
+	Failure = sync.SyncFailure
+	// SourceSummary is what a sync or pull did with one source
+	SourceSummary = sync.SourceSummary
+
+	// Reporter receives the engine's log messages
+	Reporter = ui.Reporter
+)
+
+// Events emitted while a sync or pull runs. Subscribe receives them.
+type (
+	Event           = sync.Event
+	RepoCloned      = sync.RepoCloned
+	RepoCloneFailed = sync.RepoCloneFailed
+	RepoPruned      = sync.RepoPruned
+	RepoAdded       = sync.RepoAdded
+	RepoPulled      = sync.RepoPulled
+	RepoPullFailed  = sync.RepoPullFailed
+	SourceSynced    = sync.SourceSynced
+	SourceFailed    = sync.SourceFailed
+)
+
+// Subscribe calls fn with every event of every engine from now on, until the
+// returned function is called. Like the reporter, subscriptions are
+// process-wide.
+func Subscribe(fn func(Event)) (unsubscribe func()) {
+	return sync.Subscribe(fn)
+}
+
+// Errors that failures wrap, for errors.As. ErrorKind names them.
+type (
+	AuthError          = errs.AuthError
+	HostKeyError       = errs.HostKeyError
+	NotFoundError      = errs.NotFoundError
+	NetworkError       = errs.NetworkError
+	DirtyWorktreeError = errs.DirtyWorktreeError
+	RateLimitError     = errs.RateLimitError
+	CorruptRepoError   = errs.CorruptRepoError
+	ConflictError      = errs.ConflictError
+)
+
+// ErrorKind classifies err as "auth", "host_key", "not_found", "network",
+// "dirty", "rate_limit", "corrupt", or "conflict", or "" when it's none of
+// them
+func ErrorKind(err error) string {
+	return errs.Kind(err)
+}
+
+// DefaultConfigPath returns where the ag command looks for its config
+func DefaultConfigPath() string {
+	return config.DefaultConfigPath()
+}
+
+// LoadConfig loads config files and merges them in order, on top of the
+// system config, like repeated --config flags. Paths may be URLs.
+func LoadConfig(paths ...string) (*Config, error) {
+	if len(paths) == 0 {
+		paths = []string{config.DefaultConfigPath()}
+	}
+	return config.LoadFiles(paths)
+}
+
+// Options configure an Engine
+type Options struct {
+	// ConfigPath is the config file that repos added by a sync are saved to,
+	// and that failures are recorded for. Empty leaves the config unchanged.
+	ConfigPath string
+	// Jobs is the number of parallel clone and pull workers (default 4)
+	Jobs int
+	// Reporter receives log messages. Nil discards them.
+	Reporter Reporter
+}
+
+// Engine syncs and pulls the repos of a config
+type Engine struct {
+	cfg  *Config
+	opts Options
+}
+
+// New returns an engine for cfg. The reporter is process-wide: log messages
+// of every engine go to the one given to the last New.
+func New(cfg *Config, opts Options) *Engine {
+	reporter := opts.Reporter
+	if reporter == nil {
+		reporter = discard{}
+	}
+	ui.SetReporter(reporter)
+	return &Engine{cfg: cfg, opts: opts}
+}
+
+// Config returns the engine's config, including repos added by syncs
+func (e *Engine) Config() *Config {
+	return e.cfg
+}
+
+// SyncOptions configure a sync. Orphaned clones, ones no longer in the
+// config, are left alone unless Prune or Add is set.
+type SyncOptions struct {
+	// Prune moves orphaned clones to the trash, where ag undo can restore
+	// them from
+	Prune bool
+	// Add adds orphaned clones to the config
+	Add bool
+	// DryRun reports what would happen without changing anything
+	DryRun bool
+	// FixRemotes points origin at the configured URL when they differ
+	FixRemotes bool
+	// FixConfig sets git_config values on existing clones where they differ
+	FixConfig bool
+	// IncludeLarge clones repos even when they exceed max_repo_size
+	IncludeLarge bool
+	// CheckRenames asks the provider whether any clone was renamed upstream
+	CheckRenames bool
+	// RetryFailed only re-attempts the clones that failed in the last sync
+	RetryFailed bool
+	// PruneOnly prunes orphaned clones, as Prune, and clones nothing
+	PruneOnly bool
+	// Pull also pulls the existing clones, in the same worker pool as the
+	// new ones
+	Pull bool
+	// Incremental reuses the last sync's listing of all and regex sources
+	// while the provider's activity feed shows no new or renamed repos.
+	// Needs the engine's ConfigPath, which the listing is saved for.
+	Incremental bool
+	// FullListEvery lists sources in full once the last full listing is
+	// older, with Incremental (default 24h)
+	FullListEvery time.Duration
+}
+
+// Sync clones missing repos of every source and handles orphaned ones.
+// Canceling ctx stops listing sources and starting clones. Failed sources
+// and repos are in the result's Failures rather than the error, which is
+// only set when the sync couldn't run.
+func (e *Engine) Sync(ctx context.Context, opts SyncOptions) (*SyncResult, error) {
+	return sync.Run(ctx, e.cfg, sync.SyncOptions{
+		Prune:          opts.Prune,
+		Add:            opts.Add,
+		Force:          true,
+		NonInteractive: true,
+		ConfigPath:     e.opts.ConfigPath,
+		Jobs:           e.opts.Jobs,
+		DryRun:         opts.DryRun,
+		FixRemotes:     opts.FixRemotes,
+		FixConfig:      opts.FixConfig,
+		IncludeLarge:   opts.IncludeLarge,
+		CheckRenames:   opts.CheckRenames,
+		RetryFailed:    opts.RetryFailed,
+		PruneOnly:      opts.PruneOnly,
+		Pull:           opts.Pull,
+		Incremental:    opts.Incremental,
+		FullListEvery:  opts.FullListEvery,
+	})
+}
+
+// PullOptions configure a pull
+type PullOptions struct {
+	// IncludeUpstream also fetches the "upstream" remote of forks
+	IncludeUpstream bool
+	// PruneRefs drops remote-tracking branches and tags deleted on the remote
+	PruneRefs bool
+	// RetryFailed only pulls the repos that failed in the last pull
+	RetryFailed bool
+	// Repair moves clones whose pull failed on a corrupted repository to
+	// the trash and clones them again, keeping their uncommitted files in
+	// the rescue directory
+	Repair bool
+}
+
+// Pull pulls every cloned repo of every source. Canceling ctx stops
+// starting pulls. Failed repos are in the result's Failures.
+func (e *Engine) Pull(ctx context.Context, opts PullOptions) (*PullResult, error) {
+	return sync.RunPull(ctx, e.cfg, sync.PullOptions{
+		Force:           true,
+		NonInteractive:  true,
+		Jobs:            e.opts.Jobs,
+		IncludeUpstream: opts.IncludeUpstream,
+		PruneRefs:       opts.PruneRefs,
+		RetryFailed:     opts.RetryFailed,
+		Repair:          opts.Repair,
+		ConfigPath:      e.opts.ConfigPath,
+	})
+}
+
+// ListRepos lists the repos of the named source's user or org through the
+// provider API, as "owner/repo"
+func (e *Engine) ListRepos(ctx context.Context, sourceName string) ([]string, error) {
+	source := e.cfg.FindSource(sourceName)
+	if source == nil {
+		return nil, fmt.Errorf("no source named %q", sourceName)
+	}
+	if err := connector.LoadCredentialsEnv(connector.DefaultCredentialsPath()); err != nil {
+		ui.Debug("failed to load credentials file", "error", err)
+	}
+	return sync.ListRepos(ctx, source)
+}
+
+// discard is the reporter used when none is given
+type discard struct{}
+
+func (discard) Debug(string, ...any) {}
+func (discard) Info(string, ...any)  {}
+func (discard) Warn(string, ...any)  {}
+func (discard) Error(string, ...any) {}